@@ -0,0 +1,101 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// enterpriseHelmValues are the Helm values that enable features
+// that are only available in Consul Enterprise.
+var enterpriseHelmValues = []string{
+	"global.enableConsulNamespaces",
+	"snapshotAgent.enabled",
+}
+
+// CaseTraits are the properties of a test case that the -run-secure-only,
+// -run-insecure-only and -run-enterprise-only flags select cases by,
+// so that CI jobs can split the matrices of table-driven tests
+// between them without matching case names with -run.
+type CaseTraits struct {
+	// Secure is true if the case's Consul install enables TLS or ACLs.
+	Secure bool
+	// Enterprise is true if the case uses Consul Enterprise features.
+	Enterprise bool
+}
+
+// CaseTraitsFromHelmValues returns the traits of a case
+// that installs Consul with helmValues.
+func CaseTraitsFromHelmValues(helmValues map[string]string) CaseTraits {
+	traits := CaseTraits{
+		Secure: helmValues["global.tls.enabled"] == "true" || helmValues["global.acls.manageSystemACLs"] == "true",
+	}
+	for _, key := range enterpriseHelmValues {
+		if helmValues[key] == "true" {
+			traits.Enterprise = true
+		}
+	}
+	return traits
+}
+
+// SkipFilteredCase skips t if the case filter flags don't select a case
+// with traits. consul.NewHelmCluster calls it with the traits of its
+// Helm values, so tests only need to call it themselves to skip a case
+// before they create other resources.
+func (t *TestConfig) SkipFilteredCase(tt *testing.T, traits CaseTraits) {
+	tt.Helper()
+
+	if reason := t.caseFilterReason(traits); reason != "" {
+		tt.Skipf("skipping this case because %s", reason)
+	}
+}
+
+// SkipNonSmokeCase skips t if -smoke-only or -short is set and t isn't one
+// of the smoke cases of the suite. The suite calls it for every test context
+// that a test requests, so tests don't need to call it themselves.
+func (t *TestConfig) SkipNonSmokeCase(tt *testing.T) {
+	tt.Helper()
+
+	if t.SmokeOnly && !isSmokeCase(t.SmokeCases, tt.Name()) {
+		tt.Skip("skipping this case because -smoke-only is set and it isn't a smoke case of the suite")
+	}
+}
+
+// isSmokeCase returns true if the test named name is one of smokeCases
+// or its parent test, which needs to run for the smoke case to run,
+// or a subtest of one of them.
+func isSmokeCase(smokeCases []string, name string) bool {
+	for _, smokeCase := range smokeCases {
+		if IsSmokeCaseRun(smokeCase, name) || strings.HasPrefix(subtestName(smokeCase), name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSmokeCaseRun returns true if the test named name
+// is the smoke case smokeCase or one of its subtests,
+// i.e. if running the test runs the smoke case.
+func IsSmokeCaseRun(smokeCase, name string) bool {
+	smokeCase = subtestName(smokeCase)
+	return name == smokeCase || strings.HasPrefix(name, smokeCase+"/")
+}
+
+// subtestName returns name the way the testing package
+// names subtests, with spaces replaced by underscores.
+func subtestName(name string) string {
+	return strings.ReplaceAll(name, " ", "_")
+}
+
+// caseFilterReason returns why the case filter flags don't select
+// a case with traits, or "" if they select it.
+func (t *TestConfig) caseFilterReason(traits CaseTraits) string {
+	switch {
+	case t.RunSecureOnly && !traits.Secure:
+		return "-run-secure-only is set and it doesn't enable TLS or ACLs"
+	case t.RunInsecureOnly && traits.Secure:
+		return "-run-insecure-only is set and it enables TLS or ACLs"
+	case t.RunEnterpriseOnly && !traits.Enterprise:
+		return "-run-enterprise-only is set and it doesn't use Consul Enterprise features"
+	}
+	return ""
+}
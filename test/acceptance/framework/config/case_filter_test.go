@@ -0,0 +1,95 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaseTraitsFromHelmValues(t *testing.T) {
+	cases := map[string]struct {
+		helmValues map[string]string
+		exp        CaseTraits
+	}{
+		"defaults": {
+			helmValues: nil,
+			exp:        CaseTraits{},
+		},
+		"tls": {
+			helmValues: map[string]string{"global.tls.enabled": "true"},
+			exp:        CaseTraits{Secure: true},
+		},
+		"acls": {
+			helmValues: map[string]string{"global.tls.enabled": "false", "global.acls.manageSystemACLs": "true"},
+			exp:        CaseTraits{Secure: true},
+		},
+		"consul namespaces": {
+			helmValues: map[string]string{"global.enableConsulNamespaces": "true"},
+			exp:        CaseTraits{Enterprise: true},
+		},
+		"secure snapshot agent": {
+			helmValues: map[string]string{"global.tls.enabled": "true", "snapshotAgent.enabled": "true"},
+			exp:        CaseTraits{Secure: true, Enterprise: true},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.exp, CaseTraitsFromHelmValues(c.helmValues))
+		})
+	}
+}
+
+func TestTestConfig_caseFilterReason(t *testing.T) {
+	insecure := CaseTraits{}
+	secure := CaseTraits{Secure: true}
+	enterprise := CaseTraits{Enterprise: true}
+
+	cfg := &TestConfig{}
+	require.Empty(t, cfg.caseFilterReason(insecure))
+	require.Empty(t, cfg.caseFilterReason(secure))
+
+	cfg = &TestConfig{RunSecureOnly: true}
+	require.NotEmpty(t, cfg.caseFilterReason(insecure))
+	require.Empty(t, cfg.caseFilterReason(secure))
+
+	cfg = &TestConfig{RunInsecureOnly: true}
+	require.Empty(t, cfg.caseFilterReason(insecure))
+	require.NotEmpty(t, cfg.caseFilterReason(secure))
+
+	cfg = &TestConfig{RunEnterpriseOnly: true, RunSecureOnly: true}
+	require.NotEmpty(t, cfg.caseFilterReason(enterprise))
+	require.NotEmpty(t, cfg.caseFilterReason(secure))
+	require.Empty(t, cfg.caseFilterReason(CaseTraits{Secure: true, Enterprise: true}))
+}
+
+func TestIsSmokeCase(t *testing.T) {
+	smokeCases := []string{"TestConnectInject/secure: false; auto-encrypt: false", "TestMeshGatewayDefault"}
+
+	cases := map[string]bool{
+		"TestConnectInject": true,
+		"TestConnectInject/secure:_false;_auto-encrypt:_false":      true,
+		"TestConnectInject/secure:_false;_auto-encrypt:_false/step": true,
+		"TestConnectInject/secure:_true;_auto-encrypt:_false":       false,
+		"TestConnectInject/secure:_false":                           false,
+		"TestConnectInjectNamespaces":                               false,
+		"TestMeshGatewayDefault":                                    true,
+		"TestMeshGatewayDefault/case":                               true,
+		"TestMeshGatewaySecure":                                     false,
+	}
+	for name, exp := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, exp, isSmokeCase(smokeCases, name))
+		})
+	}
+
+	require.False(t, isSmokeCase(nil, "TestConnectInject"))
+}
+
+func TestIsSmokeCaseRun(t *testing.T) {
+	smokeCase := "TestConnectInject/secure: false; auto-encrypt: false"
+	require.True(t, IsSmokeCaseRun(smokeCase, "TestConnectInject/secure:_false;_auto-encrypt:_false"))
+	require.True(t, IsSmokeCaseRun(smokeCase, "TestConnectInject/secure:_false;_auto-encrypt:_false/step"))
+	// The parent test runs for the smoke case to run, but running it doesn't run the smoke case.
+	require.False(t, IsSmokeCaseRun(smokeCase, "TestConnectInject"))
+	require.False(t, IsSmokeCaseRun(smokeCase, "TestConnectInject/secure:_true;_auto-encrypt:_false"))
+}
@@ -6,7 +6,10 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/resources"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
 	"gopkg.in/yaml.v2"
 )
 
@@ -14,11 +17,33 @@ import (
 // Note: this will need to be changed if this file is moved.
 const HelmChartPath = "../../../.."
 
+// DefaultClusterDomain is the DNS domain of Kubernetes clusters
+// unless they're configured with a different one.
+const DefaultClusterDomain = "cluster.local"
+
+// LinuxNodeSelector is the node selector that schedules pods on Linux nodes.
+const LinuxNodeSelector = "kubernetes.io/os: linux"
+
+// linuxOnlyComponents are the prefixes of the Helm values
+// of the chart's components that support a nodeSelector.
+var linuxOnlyComponents = []string{
+	"server",
+	"client",
+	"syncCatalog",
+	"connectInject",
+	"controller",
+	"meshGateway",
+	"ingressGateways.defaults",
+	"terminatingGateways.defaults",
+}
+
 // TestConfig holds configuration for the test suite
 type TestConfig struct {
 	Kubeconfig    string
 	KubeContext   string
 	KubeNamespace string
+	KubeVersion   string
+	ClusterDomain string
 
 	EnableMultiCluster     bool
 	SecondaryKubeconfig    string
@@ -28,17 +53,67 @@ type TestConfig struct {
 	EnableEnterprise            bool
 	EnterpriseLicenseSecretName string
 	EnterpriseLicenseSecretKey  string
+	EnterpriseLicense           string
+	ConsulNamespacePrefix       string
 
 	EnableOpenshift bool
 
+	EnableWindows bool
+
+	EnablePerf      bool
+	PerfResources   int
+	PerfSyncTimeout time.Duration
+
+	SoakDuration     time.Duration
+	SoakMaxErrorRate float64
+
 	ConsulImage    string
 	ConsulK8SImage string
 
+	TestImageRegistry string
+
 	NoCleanupOnFailure bool
+	NoCleanup          bool
 	DebugDirectory     string
+	JUnitOutDirectory  string
+
+	CollectEnvoyAccessLogs bool
+
+	MetricsStatsdAddr     string
+	MetricsPushgatewayURL string
+
+	TestRunID            string
+	CleanupOrphans       bool
+	CleanupOrphansMinAge time.Duration
 
 	UseKind bool
 
+	Provider string
+
+	HelmInstallTimeout time.Duration
+	HelmWait           bool
+	HelmAtomic         bool
+	HelmExtraArgs      []string
+
+	ReuseClusters bool
+
+	RunSecureOnly     bool
+	RunInsecureOnly   bool
+	RunEnterpriseOnly bool
+
+	// SmokeOnly is true if only the smoke cases of the suite should be run.
+	SmokeOnly bool
+	// SmokeCases are the names of the tests and subtests that the suite
+	// registered with suite.SmokeCases, as returned by (*testing.T).Name().
+	SmokeCases []string
+
+	HelmValuesLogFilter []string
+
+	Timeouts timeouts.Timeouts
+
+	ResourceBudgets        resources.Budgets
+	ResourceSampleInterval time.Duration
+
 	helmChartPath string
 }
 
@@ -66,12 +141,37 @@ func (t *TestConfig) HelmValuesFromConfig() (map[string]string, error) {
 		setIfNotEmpty(helmValues, "global.openshift.enabled", "true")
 	}
 
+	// Consul doesn't run on Windows, so in clusters with Windows
+	// node pools all components need to be scheduled on Linux nodes.
+	if t.EnableWindows {
+		for _, component := range linuxOnlyComponents {
+			setIfNotEmpty(helmValues, component+".nodeSelector", LinuxNodeSelector)
+		}
+	}
+
 	setIfNotEmpty(helmValues, "global.image", t.ConsulImage)
 	setIfNotEmpty(helmValues, "global.imageK8S", t.ConsulK8SImage)
 
 	return helmValues, nil
 }
 
+// ConsulNamespace returns name prefixed
+// with ConsulNamespacePrefix so that suites sharing Consul servers
+// don't use the same namespaces.
+func (t *TestConfig) ConsulNamespace(name string) string {
+	return t.ConsulNamespacePrefix + name
+}
+
+// ServiceFQDN returns the fully qualified DNS name of the Kubernetes
+// service with the given name in namespace, using the cluster domain.
+func (t *TestConfig) ServiceFQDN(name, namespace string) string {
+	clusterDomain := t.ClusterDomain
+	if clusterDomain == "" {
+		clusterDomain = DefaultClusterDomain
+	}
+	return fmt.Sprintf("%s.%s.svc.%s", name, namespace, clusterDomain)
+}
+
 // entImage parses out consul version from Chart.yaml
 // and sets global.image to the consul enterprise image with that version.
 func (t *TestConfig) entImage() (string, error) {
@@ -80,6 +80,22 @@ func TestConfig_HelmValuesFromConfig(t *testing.T) {
 				"global.openshift.enabled": "true",
 			},
 		},
+		{
+			"schedules all components on Linux nodes when EnableWindows is set",
+			TestConfig{
+				EnableWindows: true,
+			},
+			map[string]string{
+				"server.nodeSelector":                       "kubernetes.io/os: linux",
+				"client.nodeSelector":                       "kubernetes.io/os: linux",
+				"syncCatalog.nodeSelector":                  "kubernetes.io/os: linux",
+				"connectInject.nodeSelector":                "kubernetes.io/os: linux",
+				"controller.nodeSelector":                   "kubernetes.io/os: linux",
+				"meshGateway.nodeSelector":                  "kubernetes.io/os: linux",
+				"ingressGateways.defaults.nodeSelector":     "kubernetes.io/os: linux",
+				"terminatingGateways.defaults.nodeSelector": "kubernetes.io/os: linux",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -140,3 +156,13 @@ appVersion: %s
 		})
 	}
 }
+
+func TestConfig_ServiceFQDN(t *testing.T) {
+	require.Equal(t, "kubernetes.default.svc.cluster.local", (&TestConfig{}).ServiceFQDN("kubernetes", "default"))
+	require.Equal(t, "consul-dns.ns1.svc.my-cluster.example", (&TestConfig{ClusterDomain: "my-cluster.example"}).ServiceFQDN("consul-dns", "ns1"))
+}
+
+func TestConfig_ConsulNamespace(t *testing.T) {
+	require.Equal(t, "from-k8s", (&TestConfig{}).ConsulNamespace("from-k8s"))
+	require.Equal(t, "suite-a-from-k8s", (&TestConfig{ConsulNamespacePrefix: "suite-a-"}).ConsulNamespace("from-k8s"))
+}
@@ -0,0 +1,163 @@
+package consul
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// bootstrapTokenSecretKey is the key of the bootstrap token
+// in the secret created by createBootstrapTokenSecret.
+const bootstrapTokenSecretKey = "token"
+
+// GlobalManagementPolicyID is the ID of the built-in global-management policy.
+const GlobalManagementPolicyID = "00000000-0000-0000-0000-000000000001"
+
+// GenerateACLToken returns a new random ACL token secret ID.
+// Consul requires secret IDs to be UUIDs, so it's formatted like one.
+func GenerateACLToken(t *testing.T) string {
+	t.Helper()
+
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// CreateToken creates an ACL policy with the provided rules and an ACL token
+// linked to that policy, and returns the token's secret ID.
+// The client must have permissions to manage ACLs, e.g. by using the bootstrap token.
+// Both the token and the policy are deleted when the test finishes,
+// unless noCleanup is set or noCleanupOnFailure is set and the test failed.
+func CreateToken(t *testing.T, client *api.Client, noCleanupOnFailure, noCleanup bool, policyRules string) string {
+	t.Helper()
+
+	return CreateNamespaceToken(t, client, noCleanupOnFailure, noCleanup, "", policyRules)
+}
+
+// CreateNamespaceToken is the same as CreateToken but it creates
+// the policy and the token in the provided Consul namespace.
+// Namespaces are a Consul Enterprise feature, and namespace must already exist.
+// If namespace is empty, the policy and the token are created in the default namespace.
+func CreateNamespaceToken(t *testing.T, client *api.Client, noCleanupOnFailure, noCleanup bool, namespace, policyRules string) string {
+	t.Helper()
+
+	writeOpts := &api.WriteOptions{Namespace: namespace}
+
+	policyName := helpers.RandomName()
+	logger.Logf(t, "creating ACL policy %q", policyName)
+	policy, _, err := client.ACL().PolicyCreate(&api.ACLPolicy{
+		Name:        policyName,
+		Description: "Policy created by acceptance tests",
+		Rules:       policyRules,
+		Namespace:   namespace,
+	}, writeOpts)
+	require.NoError(t, err)
+
+	token, _, err := client.ACL().TokenCreate(&api.ACLToken{
+		Description: "Token created by acceptance tests",
+		Policies:    []*api.ACLTokenPolicyLink{{ID: policy.ID}},
+		Namespace:   namespace,
+	}, writeOpts)
+	require.NoError(t, err)
+
+	helpers.Cleanup(t, noCleanupOnFailure, noCleanup, func() {
+		// Ignore errors here because the Consul cluster
+		// may have already been destroyed.
+		client.ACL().TokenDelete(token.AccessorID, writeOpts)
+		client.ACL().PolicyDelete(policy.ID, writeOpts)
+	})
+
+	return token.SecretID
+}
+
+// ComponentACLToken returns the ACL token that server-acl-init stored in the
+// <release>-consul-<component>-acl-token secret, e.g. for "controller" or
+// "connect-inject", waiting for the secret to be created.
+func (h *HelmCluster) ComponentACLToken(t *testing.T, component string) string {
+	t.Helper()
+
+	secretName := fmt.Sprintf("%s-%s-acl-token", h.fullName(), component)
+	secret := k8s.WaitForSecret(t, h.helmOptions.KubectlOptions, secretName, "token")
+	return string(secret.Data["token"])
+}
+
+// TokenPolicies returns the policies linked to token. The client needs
+// to be able to read ACL policies, e.g. by using the bootstrap token.
+// Policies are read in the Consul namespace of the token.
+func TokenPolicies(t *testing.T, client *api.Client, token string) []*api.ACLPolicy {
+	t.Helper()
+
+	self, _, err := client.ACL().TokenReadSelf(&api.QueryOptions{Token: token})
+	require.NoError(t, err)
+
+	var policies []*api.ACLPolicy
+	for _, link := range self.Policies {
+		policy, _, err := client.ACL().PolicyRead(link.ID, &api.QueryOptions{Namespace: self.Namespace})
+		require.NoError(t, err)
+		require.NotNil(t, policy, "policy %s of token %s not found", link.Name, self.AccessorID)
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+// RequireNotGlobalManagement fails the test if token is linked to the global-management
+// policy, so that tests can check that the tokens of components are least privilege.
+func RequireNotGlobalManagement(t *testing.T, client *api.Client, token string) {
+	t.Helper()
+
+	policies := TokenPolicies(t, client, token)
+	require.NotEmpty(t, policies, "token has no policies")
+	for _, policy := range policies {
+		require.NotEqual(t, GlobalManagementPolicyID, policy.ID, "token is linked to the global-management policy")
+	}
+}
+
+// RequirePermissionDenied fails the test unless err is
+// the error Consul returns when an ACL token isn't allowed
+// to perform an operation.
+func RequirePermissionDenied(t *testing.T, err error, msgAndArgs ...interface{}) {
+	t.Helper()
+
+	require.Error(t, err, msgAndArgs...)
+	require.True(t, isPermissionDenied(err), "expected a permission denied error, got: %s", err)
+}
+
+func isPermissionDenied(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Permission denied")
+}
+
+// bootstrapTokenSecretName returns the name of the secret with the bootstrap token
+// provided with WithBootstrapToken. It contains the release name so that Destroy deletes it.
+func bootstrapTokenSecretName(releaseName string) string {
+	return fmt.Sprintf("%s-consul-custom-bootstrap-token", releaseName)
+}
+
+// createBootstrapTokenSecret creates the Kubernetes secret with the bootstrap
+// token if the cluster was created with WithBootstrapToken. It needs to exist
+// before the Helm install because server-acl-init reads the token from it.
+func (h *HelmCluster) createBootstrapTokenSecret(t *testing.T) {
+	t.Helper()
+
+	if h.bootstrapToken == "" {
+		return
+	}
+
+	name := bootstrapTokenSecretName(h.releaseName)
+	logger.Logf(t, "creating bootstrap token secret %s", name)
+	_, err := h.kubernetesClient.CoreV1().Secrets(h.helmOptions.KubectlOptions.Namespace).Create(helpers.TestContext(t), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: testlabels.ForTest(t)},
+		StringData: map[string]string{bootstrapTokenSecretKey: h.bootstrapToken},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+}
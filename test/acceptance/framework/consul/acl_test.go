@@ -0,0 +1,117 @@
+package consul
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateACLToken(t *testing.T) {
+	token := GenerateACLToken(t)
+
+	require.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`), token)
+	require.NotEqual(t, token, GenerateACLToken(t))
+}
+
+func TestCreateNamespaceToken(t *testing.T) {
+	cases := map[string]struct {
+		noCleanup   bool
+		expRequests []string
+	}{
+		"cleanup": {
+			expRequests: []string{
+				"PUT /v1/acl/policy?ns=ns1",
+				"PUT /v1/acl/token?ns=ns1",
+				"DELETE /v1/acl/token/token-accessor?ns=ns1",
+				"DELETE /v1/acl/policy/policy-id?ns=ns1",
+			},
+		},
+		"no cleanup": {
+			noCleanup: true,
+			expRequests: []string{
+				"PUT /v1/acl/policy?ns=ns1",
+				"PUT /v1/acl/token?ns=ns1",
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			var requests []string
+			var policy api.ACLPolicy
+			var token api.ACLToken
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				requests = append(requests, r.Method+" "+r.URL.RequestURI())
+				switch {
+				case r.Method == http.MethodPut && r.URL.Path == "/v1/acl/policy":
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&policy))
+					policy.ID = "policy-id"
+					require.NoError(t, json.NewEncoder(w).Encode(policy))
+				case r.Method == http.MethodPut && r.URL.Path == "/v1/acl/token":
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&token))
+					token.AccessorID = "token-accessor"
+					token.SecretID = "token-secret"
+					require.NoError(t, json.NewEncoder(w).Encode(token))
+				case r.Method == http.MethodDelete:
+					w.Write([]byte("true"))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(server.Close)
+			client, err := api.NewClient(&api.Config{Address: server.URL})
+			require.NoError(t, err)
+
+			// Run in a subtest so that its cleanup runs before the requests are checked.
+			t.Run("create", func(t *testing.T) {
+				secretID := CreateNamespaceToken(t, client, false, c.noCleanup, "ns1", `service "foo" { policy = "write" }`)
+				require.Equal(t, "token-secret", secretID)
+			})
+
+			require.Equal(t, `service "foo" { policy = "write" }`, policy.Rules)
+			require.Equal(t, "ns1", policy.Namespace)
+			require.Equal(t, []*api.ACLTokenPolicyLink{{ID: "policy-id"}}, token.Policies)
+			require.Equal(t, "ns1", token.Namespace)
+			require.Equal(t, c.expRequests, requests)
+		})
+	}
+}
+
+func TestIsPermissionDenied(t *testing.T) {
+	require.True(t, isPermissionDenied(errors.New(`Unexpected response code: 403 (Permission denied)`)))
+	require.False(t, isPermissionDenied(errors.New(`Unexpected response code: 500 (rpc error)`)))
+	require.False(t, isPermissionDenied(nil))
+}
+
+func TestNewHelmCluster_BootstrapToken(t *testing.T) {
+	bootstrapTokenValues := []string{
+		"global.acls.bootstrapToken.secretName",
+		"global.acls.bootstrapToken.secretKey",
+	}
+
+	t.Run("bootstrap token values are not set without WithBootstrapToken", func(t *testing.T) {
+		cluster := NewHelmCluster(t, nil, &ctx{}, &config.TestConfig{}, "test").(*HelmCluster)
+		for _, key := range bootstrapTokenValues {
+			require.NotContains(t, cluster.helmOptions.SetValues, key)
+		}
+		require.Empty(t, cluster.bootstrapToken)
+	})
+
+	t.Run("bootstrap token values point at the bootstrap token secret", func(t *testing.T) {
+		cluster := NewHelmCluster(t, nil, &ctx{}, &config.TestConfig{}, "test", WithBootstrapToken("token")).(*HelmCluster)
+		require.Equal(t, "test-consul-custom-bootstrap-token", cluster.helmOptions.SetValues["global.acls.bootstrapToken.secretName"])
+		require.Equal(t, "token", cluster.helmOptions.SetValues["global.acls.bootstrapToken.secretKey"])
+		require.Equal(t, "token", cluster.bootstrapToken)
+	})
+}
@@ -0,0 +1,38 @@
+package consul
+
+import (
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AgentClient returns a client for the HTTP API of the Consul server or
+// client agent in pod, e.g. one of consulCluster.ServerPods(t), rather than
+// of the servers behind the server service like SetupConsulClient.
+// It uses the HTTP port, so the release must not enable TLS.
+func AgentClient(t *testing.T, ctx environment.TestContext, pod corev1.Pod) *api.Client {
+	t.Helper()
+
+	options := *ctx.KubectlOptions(t)
+	options.Namespace = pod.Namespace
+	endpoint := k8s.PortForward(t, &options, terratestk8s.ResourceTypePod, pod.Name, 8500)
+	client, err := api.NewClient(&api.Config{Address: endpoint})
+	require.NoError(t, err)
+	return client
+}
+
+// AgentSelf returns the response of /v1/agent/self of the Consul agent
+// in pod, which includes its configuration in "DebugConfig".
+// Like AgentClient, it requires a release that doesn't enable TLS.
+func AgentSelf(t *testing.T, ctx environment.TestContext, pod corev1.Pod) map[string]map[string]interface{} {
+	t.Helper()
+
+	self, err := AgentClient(t, ctx, pod).Agent().Self()
+	require.NoError(t, err)
+	return self
+}
@@ -0,0 +1,102 @@
+package consul
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	terratestLogger "github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// serverContainer is the name of the Consul container in server pods.
+const serverContainer = "consul"
+
+// ExecCLI runs the consul CLI with args in the server pod and returns its output,
+// e.g. for `consul members -wan` or `consul operator raft list-peers`, which are
+// useful when an operation isn't exposed by the Go API or when the behavior of
+// the CLI itself is under test. The chart already points the CLI at the HTTPS
+// port and the CA of the server if TLS is enabled. If ACLs are enabled, the
+// CLI uses the bootstrap token, or the replication token in secondary datacenters.
+func ExecCLI(t *testing.T, ctx environment.TestContext, pod corev1.Pod, args ...string) string {
+	t.Helper()
+
+	output, err := ExecCLIE(t, ctx, pod, args...)
+	require.NoError(t, err)
+	return output
+}
+
+// ExecCLIE is like ExecCLI but returns an error if the command fails,
+// together with its output, e.g. to check how the CLI fails.
+func ExecCLIE(t *testing.T, ctx environment.TestContext, pod corev1.Pod, args ...string) (string, error) {
+	t.Helper()
+
+	fullName, err := serverFullName(pod)
+	require.NoError(t, err)
+	token := serverCLIToken(t, ctx.KubernetesClient(t), pod.Namespace, fullName)
+
+	options := *ctx.KubectlOptions(t)
+	options.Namespace = pod.Namespace
+	cliCommand := append([]string{"consul"}, args...)
+	command := cliCommand
+	if token != "" {
+		command = append([]string{"env", "CONSUL_HTTP_TOKEN=" + token}, cliCommand...)
+	}
+
+	logger.Logf(t, "running %s in pod %s", strings.Join(cliCommand, " "), pod.Name)
+	// The command includes the token, so kubectl's logger is discarded
+	// and the token is redacted from the error.
+	kubectlArgs := append([]string{"exec", pod.Name, "-c", serverContainer, "--"}, command...)
+	output, err := k8s.RunKubectlAndGetOutputWithLoggerE(t, &options, terratestLogger.Discard, kubectlArgs...)
+	if err != nil && token != "" {
+		err = fmt.Errorf("%s", strings.ReplaceAll(err.Error(), token, redactedValue))
+	}
+	return output, err
+}
+
+// serverCLIToken returns the token that ExecCLI uses in the servers
+// of the release with the given full name, or an empty string if
+// the chart didn't create one because ACLs aren't enabled.
+// The bootstrap token comes first because primary datacenters that
+// create a federation secret have both tokens, and the replication
+// token can't run every command.
+func serverCLIToken(t *testing.T, client kubernetes.Interface, namespace, fullName string) string {
+	t.Helper()
+
+	secrets := client.CoreV1().Secrets(namespace)
+	for _, tokenSecret := range []struct {
+		name string
+		key  string
+	}{
+		{fullName + "-bootstrap-acl-token", "token"},
+		{fullName + "-federation", "replicationToken"},
+	} {
+		secret, err := secrets.Get(helpers.TestContext(t), tokenSecret.name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		require.NoError(t, err)
+		if token := string(secret.Data[tokenSecret.key]); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// serverFullName returns the full name of the release, e.g. <release>-consul,
+// from the name of one of its server pods, <full name>-server-<ordinal>.
+func serverFullName(pod corev1.Pod) (string, error) {
+	i := strings.LastIndex(pod.Name, "-server-")
+	if i <= 0 {
+		return "", fmt.Errorf("pod %s is not a Consul server pod", pod.Name)
+	}
+	return pod.Name[:i], nil
+}
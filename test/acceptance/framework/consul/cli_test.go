@@ -0,0 +1,87 @@
+package consul
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestServerFullName(t *testing.T) {
+	cases := map[string]struct {
+		podName string
+		exp     string
+		expErr  string
+	}{
+		"default name": {
+			podName: "test-abcde-consul-server-0",
+			exp:     "test-abcde-consul",
+		},
+		"name containing -server-": {
+			podName: "my-server-release-consul-server-2",
+			exp:     "my-server-release-consul",
+		},
+		"not a server pod": {
+			podName: "test-abcde-consul-connect-injector-webhook-deployment-5d7f8",
+			expErr:  "pod test-abcde-consul-connect-injector-webhook-deployment-5d7f8 is not a Consul server pod",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fullName, err := serverFullName(corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: c.podName}})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, fullName)
+		})
+	}
+}
+
+func TestServerCLIToken(t *testing.T) {
+	secret := func(name, key, token string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string][]byte{key: []byte(token)},
+		}
+	}
+	bootstrap := secret("test-consul-bootstrap-acl-token", "token", "bootstrap")
+	federation := secret("test-consul-federation", "replicationToken", "replication")
+
+	cases := map[string]struct {
+		secrets []*corev1.Secret
+		exp     string
+	}{
+		"ACLs disabled": {
+			exp: "",
+		},
+		"primary datacenter": {
+			secrets: []*corev1.Secret{bootstrap},
+			exp:     "bootstrap",
+		},
+		"secondary datacenter": {
+			secrets: []*corev1.Secret{federation},
+			exp:     "replication",
+		},
+		"primary datacenter with a federation secret": {
+			secrets: []*corev1.Secret{federation, bootstrap},
+			exp:     "bootstrap",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			for _, s := range c.secrets {
+				_, err := client.CoreV1().Secrets(s.Namespace).Create(context.Background(), s, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+			require.Equal(t, c.exp, serverCLIToken(t, client, "default", "test-consul"))
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package consul
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The functions in this file find the pods and services of the release's
+// components so that tests don't need to build label selectors and resource
+// names themselves when they check logs, restarts or exec into pods.
+
+// ComponentSelector returns the label selector of the pods of component,
+// e.g. helpers.ComponentServer, in the release.
+func (h *HelmCluster) ComponentSelector(component string) string {
+	return fmt.Sprintf("release=%s,component=%s", h.releaseName, component)
+}
+
+// ComponentPods returns the pods of component, e.g. helpers.ComponentServer.
+func (h *HelmCluster) ComponentPods(t *testing.T, component string) []corev1.Pod {
+	t.Helper()
+
+	pods, err := h.kubernetesClient.CoreV1().Pods(h.helmOptions.KubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: h.ComponentSelector(component)})
+	require.NoError(t, err)
+	return pods.Items
+}
+
+// ServerPods returns the Consul server pods.
+func (h *HelmCluster) ServerPods(t *testing.T) []corev1.Pod {
+	t.Helper()
+
+	return h.ComponentPods(t, helpers.ComponentServer)
+}
+
+// ClientPods returns the Consul client pods.
+func (h *HelmCluster) ClientPods(t *testing.T) []corev1.Pod {
+	t.Helper()
+
+	return h.ComponentPods(t, helpers.ComponentClient)
+}
+
+// ControllerPod returns the controller pod. It fails the test
+// unless there is exactly one, so tests that run more than one
+// controller replica should use ComponentPods instead.
+func (h *HelmCluster) ControllerPod(t *testing.T) corev1.Pod {
+	t.Helper()
+
+	return h.singlePod(t, helpers.ComponentController)
+}
+
+// InjectorPod returns the connect injector pod. Like ControllerPod,
+// it fails the test unless there is exactly one.
+func (h *HelmCluster) InjectorPod(t *testing.T) corev1.Pod {
+	t.Helper()
+
+	return h.singlePod(t, helpers.ComponentConnectInjector)
+}
+
+// ServerService returns the headless service of the Consul servers.
+func (h *HelmCluster) ServerService(t *testing.T) *corev1.Service {
+	t.Helper()
+
+	return h.service(t, h.fullName()+"-server")
+}
+
+// InjectorService returns the service of the connect injector webhook.
+func (h *HelmCluster) InjectorService(t *testing.T) *corev1.Service {
+	t.Helper()
+
+	return h.service(t, h.fullName()+"-connect-injector-svc")
+}
+
+// IngressGatewayService returns the service of the ingress gateway
+// with the given name, as set in ingressGateways.gateways[].name.
+func (h *HelmCluster) IngressGatewayService(t *testing.T, name string) *corev1.Service {
+	t.Helper()
+
+	return h.service(t, h.fullName()+"-"+name)
+}
+
+func (h *HelmCluster) singlePod(t *testing.T, component string) corev1.Pod {
+	t.Helper()
+
+	pods := h.ComponentPods(t, component)
+	require.Len(t, pods, 1, "expected exactly one %s pod, found: %v", component, k8s.PodNames(pods))
+	return pods[0]
+}
+
+func (h *HelmCluster) service(t *testing.T, name string) *corev1.Service {
+	t.Helper()
+
+	service, err := h.kubernetesClient.CoreV1().Services(h.helmOptions.KubectlOptions.Namespace).Get(helpers.TestContext(t), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	return service
+}
+
+// fullName returns the prefix of the names of the release's resources,
+// like the chart's consul.fullname template.
+func (h *HelmCluster) fullName() string {
+	name := h.helmOptions.SetValues["fullnameOverride"]
+	if name == "" {
+		name = h.helmOptions.SetValues["global.name"]
+	}
+	if name == "" {
+		name = h.releaseName + "-consul"
+	}
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimSuffix(name, "-")
+}
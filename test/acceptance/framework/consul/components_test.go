@@ -0,0 +1,65 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHelmCluster_Components(t *testing.T) {
+	pod := func(name, release, component string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"release": release, "component": component},
+		}}
+	}
+	cluster := NewHelmCluster(t, nil, &ctx{}, &config.TestConfig{}, "test").(*HelmCluster)
+	cluster.kubernetesClient = fake.NewSimpleClientset(
+		pod("test-consul-server-0", "test", helpers.ComponentServer),
+		pod("test-consul-server-1", "test", helpers.ComponentServer),
+		pod("other-consul-server-0", "other", helpers.ComponentServer),
+		pod("test-consul-controller-abc", "test", helpers.ComponentController),
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "test-consul-connect-injector-svc"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "test-consul-ingress-gateway"}},
+	)
+
+	var names []string
+	for _, pod := range cluster.ServerPods(t) {
+		names = append(names, pod.Name)
+	}
+	require.ElementsMatch(t, []string{"test-consul-server-0", "test-consul-server-1"}, names)
+	require.Empty(t, cluster.ClientPods(t))
+	require.Equal(t, "test-consul-controller-abc", cluster.ControllerPod(t).Name)
+	require.Equal(t, "test-consul-connect-injector-svc", cluster.InjectorService(t).Name)
+	require.Equal(t, "test-consul-ingress-gateway", cluster.IngressGatewayService(t, "ingress-gateway").Name)
+}
+
+func TestHelmCluster_FullName(t *testing.T) {
+	cases := map[string]struct {
+		helmValues map[string]string
+		exp        string
+	}{
+		"release name": {
+			exp: "test-consul",
+		},
+		"global.name": {
+			helmValues: map[string]string{"global.name": "consul"},
+			exp:        "consul",
+		},
+		"fullnameOverride takes precedence over global.name": {
+			helmValues: map[string]string{"global.name": "consul", "fullnameOverride": "override-"},
+			exp:        "override",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			cluster := NewHelmCluster(t, c.helmValues, &ctx{}, &config.TestConfig{}, "test").(*HelmCluster)
+			require.Equal(t, c.exp, cluster.fullName())
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+// CreateConfigEntry writes the config entry to Consul directly through the API,
+// for example, to pre-seed an entry that a custom resource will later conflict with.
+// The config entry is deleted when the test finishes if it still exists,
+// unless noCleanup is set or noCleanupOnFailure is set and the test failed.
+func CreateConfigEntry(t *testing.T, client *api.Client, noCleanupOnFailure, noCleanup bool, entry api.ConfigEntry, opts *api.WriteOptions) {
+	t.Helper()
+
+	logger.Logf(t, "creating %s config entry %q", entry.GetKind(), entry.GetName())
+	written, _, err := client.ConfigEntries().Set(entry, opts)
+	require.NoError(t, err)
+	require.True(t, written, "config entry %s/%s was not written", entry.GetKind(), entry.GetName())
+
+	helpers.Cleanup(t, noCleanupOnFailure, noCleanup, func() {
+		// Ignore errors here because the test may have already deleted
+		// the config entry or the Consul cluster may have been destroyed.
+		client.ConfigEntries().Delete(entry.GetKind(), entry.GetName(), opts)
+	})
+}
+
+// DeleteConfigEntry deletes the config entry with the given kind and name from Consul.
+func DeleteConfigEntry(t *testing.T, client *api.Client, kind, name string, opts *api.WriteOptions) {
+	t.Helper()
+
+	logger.Logf(t, "deleting %s config entry %q", kind, name)
+	_, err := client.ConfigEntries().Delete(kind, name, opts)
+	require.NoError(t, err)
+}
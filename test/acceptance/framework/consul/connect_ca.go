@@ -0,0 +1,55 @@
+package consul
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// RotateCA rotates the root certificate of the Connect CA to the certificate
+// and private key of ca using the Connect CA configuration API, and returns
+// the ID of the new active root once the servers have switched to it.
+// The old root is kept as a trusted root and cross-signs the new one,
+// so existing leaf certificates stay valid while they're replaced.
+// It only supports the built-in Consul CA provider.
+func RotateCA(t *testing.T, client *api.Client, ca *CA) string {
+	t.Helper()
+
+	roots, _, err := client.Connect().CARoots(nil)
+	require.NoError(t, err)
+	oldRootID := roots.ActiveRootID
+
+	config, _, err := client.Connect().CAGetConfig(nil)
+	require.NoError(t, err)
+	require.Equal(t, "consul", config.Provider, "only the consul CA provider can be rotated to a custom root")
+
+	// Copy the provider config so that other settings, like the leaf cert TTL, are kept.
+	providerConfig := make(map[string]interface{}, len(config.Config)+2)
+	for k, v := range config.Config {
+		providerConfig[k] = v
+	}
+	providerConfig["PrivateKey"] = string(ca.KeyPEM)
+	providerConfig["RootCert"] = string(ca.CertPEM)
+
+	logger.Logf(t, "rotating Connect CA root %s", oldRootID)
+	_, err = client.Connect().CASetConfig(&api.CAConfig{
+		Provider: config.Provider,
+		Config:   providerConfig,
+	}, nil)
+	require.NoError(t, err)
+
+	var newRootID string
+	helpers.RetryEventually(t, 1*time.Minute, func(r *retry.R) {
+		roots, _, err := client.Connect().CARoots(nil)
+		require.NoError(r, err)
+		require.NotEqual(r, oldRootID, roots.ActiveRootID, "active Connect CA root has not changed")
+		newRootID = roots.ActiveRootID
+	})
+	logger.Logf(t, "Connect CA root rotated to %s", newRootID)
+	return newRootID
+}
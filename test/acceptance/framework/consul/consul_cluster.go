@@ -1,26 +1,40 @@
 package consul
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/gruntwork-io/terratest/modules/helm"
 	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
 	terratestLogger "github.com/gruntwork-io/terratest/modules/logger"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helm"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/metrics"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/portforward"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	"helm.sh/helm/v3/pkg/postrender"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -31,20 +45,245 @@ type Cluster interface {
 	// Upgrade runs helm upgrade. It will merge the helm values from the
 	// initial install with helmValues. Any keys that were previously set
 	// will be overridden by the helmValues keys.
+	// It always upgrades to the Helm chart in this repository,
+	// even if the cluster was installed using a different chart.
 	Upgrade(t *testing.T, helmValues map[string]string)
 	SetupConsulClient(t *testing.T, secure bool) *api.Client
+	// SetupConsulClientInNamespace is like SetupConsulClient, but the client
+	// makes requests in the provided Consul namespace by default
+	// so that they don't need to set it in their query or write options.
+	SetupConsulClientInNamespace(t *testing.T, secure bool, namespace string) *api.Client
+	// ServerPods returns the Consul server pods.
+	ServerPods(t *testing.T) []corev1.Pod
+	// ClientPods returns the Consul client pods.
+	ClientPods(t *testing.T) []corev1.Pod
+	// ComponentSelector returns the label selector of the pods of
+	// a component of the release, e.g. for kubectl wait.
+	ComponentSelector(component string) string
+	// ComponentPods returns the pods of a component of the release,
+	// e.g. helpers.ComponentController.
+	ComponentPods(t *testing.T, component string) []corev1.Pod
+	// ControllerPod returns the controller pod and fails
+	// the test unless there is exactly one.
+	ControllerPod(t *testing.T) corev1.Pod
+	// InjectorPod returns the connect injector pod and fails
+	// the test unless there is exactly one.
+	InjectorPod(t *testing.T) corev1.Pod
+	// ServerService returns the headless service of the Consul servers.
+	ServerService(t *testing.T) *corev1.Service
+	// InjectorService returns the service of the connect injector webhook.
+	InjectorService(t *testing.T) *corev1.Service
+	// IngressGatewayService returns the service of the ingress gateway
+	// with the given name, as set in ingressGateways.gateways[].name.
+	IngressGatewayService(t *testing.T, name string) *corev1.Service
+	// ComponentACLToken returns the ACL token that server-acl-init
+	// created for a component of the release, e.g. "controller".
+	ComponentACLToken(t *testing.T, component string) string
 }
 
 // HelmCluster implements Cluster and uses Helm
 // to create, destroy, and upgrade consul
 type HelmCluster struct {
-	ctx                environment.TestContext
-	helmOptions        *helm.Options
-	releaseName        string
-	kubernetesClient   kubernetes.Interface
-	noCleanupOnFailure bool
-	debugDirectory     string
-	logger             terratestLogger.TestLogger
+	ctx                 environment.TestContext
+	helmOptions         *helm.Options
+	chart               string
+	releaseName         string
+	enterpriseLicense   string
+	customCA            *CA
+	bootstrapToken      string
+	labels              map[string]string
+	kubernetesClient    kubernetes.Interface
+	noCleanupOnFailure  bool
+	noCleanup           bool
+	debugDirectory      string
+	helmValuesLogFilter []string
+	preInstallHooks     []InstallHook
+	postInstallHooks    []InstallHook
+	cleanupScope        *testing.T
+}
+
+// defaultInstallTimeout is the Helm install timeout used
+// when it is not set in the test config or via WithInstallTimeout.
+const defaultInstallTimeout = 15 * time.Minute
+
+// HelmClusterOption configures optional settings of a HelmCluster.
+type HelmClusterOption func(*helmClusterOptions)
+
+type helmClusterOptions struct {
+	valuesFiles    []string
+	values         map[string]interface{}
+	setFiles       map[string]string
+	fileValues     map[string]string
+	installTimeout time.Duration
+	atomic         bool
+	chart          string
+	chartVersion   string
+	skipCRDInstall bool
+	customCA       *CA
+	bootstrapToken string
+	federation     *corev1.Secret
+	preInstall     []InstallHook
+	postInstall    []InstallHook
+	cleanupScope   *testing.T
+}
+
+// InstallHook is a step that runs before or after a HelmCluster is installed,
+// such as creating resources the release depends on or seeding Consul with
+// config entries. It can register cleanup with helpers.Cleanup.
+type InstallHook func(t *testing.T, cluster *HelmCluster)
+
+// SkipCRDInstall doesn't install the custom resource definitions
+// that are part of the chart when the controller is enabled.
+// CRDs are cluster-scoped and can only be owned by a single Helm release,
+// so when more than one release with the controller enabled is installed
+// into the same Kubernetes cluster, all but the first must skip them.
+// It's also useful for testing CRD upgrades with CRDs applied separately
+// using k8s.ApplyCRDs. Because the chart's CRDs are templates rather than
+// files in a crds directory, Helm's --skip-crds flag doesn't apply to them,
+// so they are removed from the rendered manifests instead.
+func SkipCRDInstall() HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		o.skipCRDInstall = true
+	}
+}
+
+// WithCustomCA configures TLS to use the provided CA instead of
+// the CA that the Helm chart generates. The CA certificate and key
+// are stored in Kubernetes secrets that are created before the install
+// and the global.tls.caCert and global.tls.caKey values point at them.
+// TLS still needs to be enabled with the global.tls.enabled value.
+func WithCustomCA(ca *CA) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		o.customCA = ca
+	}
+}
+
+// WithBootstrapToken configures ACLs to use the provided bootstrap token
+// instead of bootstrapping ACLs during the install. The token is stored in a
+// Kubernetes secret that is created before the install and the
+// global.acls.bootstrapToken values point at it, so server-acl-init uses it
+// to create the tokens of the other components. The servers must already accept
+// the token, for example, as their master token set with server.extraConfig,
+// and ACLs still need to be enabled with the global.acls.manageSystemACLs value.
+// SetupConsulClient uses the token because the chart doesn't create
+// its own bootstrap token secret.
+func WithBootstrapToken(token string) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		o.bootstrapToken = token
+	}
+}
+
+// WithFederationSecret configures a secondary datacenter with the federation
+// secret exported from the primary datacenter with ExportFederationSecret:
+// it sets the values for the CA, the server config with the primary's
+// gateways and, if the secret has them, the ACL replication token and
+// the gossip encryption key. The secret must be imported into the secondary
+// cluster with ImportFederationSecret before Create. Values from the
+// helmValues map, e.g. global.federation.enabled, take precedence.
+func WithFederationSecret(secret *corev1.Secret) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		o.federation = secret
+	}
+}
+
+// WithChart installs the provided chart, such as a chart from a Helm repository,
+// instead of the Helm chart in this repository. If version is not empty,
+// that version of the chart will be installed.
+// This is useful for testing upgrades from previous releases of the chart.
+func WithChart(chart, version string) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		o.chart = chart
+		o.chartVersion = version
+	}
+}
+
+// WithInstallTimeout sets the time to wait for the Helm install to complete,
+// overriding the timeout from the test config.
+func WithInstallTimeout(timeout time.Duration) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		o.installTimeout = timeout
+	}
+}
+
+// WithAtomicInstall makes the Helm install roll back if it fails
+// or doesn't complete within the install timeout.
+func WithAtomicInstall() HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		o.atomic = true
+	}
+}
+
+// WithValuesFiles passes the provided values YAML files to helm with the -f flag.
+// Values set with the helmValues map take precedence over values from these files.
+func WithValuesFiles(files ...string) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		o.valuesFiles = append(o.valuesFiles, files...)
+	}
+}
+
+// WithValues passes structured Helm values to the install. This is useful for complex
+// nested values, like server.extraConfig or gateway definitions, that are hard to express
+// as --set strings. Values from multiple calls are merged, with later calls taking precedence.
+// These values take precedence over values files but not over the helmValues map.
+func WithValues(values map[string]interface{}) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		if o.values == nil {
+			o.values = map[string]interface{}{}
+		}
+		mergeValues(o.values, values)
+	}
+}
+
+// WithSetFile sets the Helm value key to the contents of the file at path,
+// like helm's --set-file flag. This is useful for multi-line values,
+// like CA certificates or ACL policies, that would need escaping in --set strings.
+// These values take precedence over the helmValues map.
+func WithSetFile(key, path string) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		if o.setFiles == nil {
+			o.setFiles = map[string]string{}
+		}
+		o.setFiles[key] = path
+	}
+}
+
+// WithFileValue is like WithSetFile but takes the contents of the value rather
+// than a path to a file. The contents are written to a temporary file
+// that is removed when the test finishes.
+func WithFileValue(key, contents string) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		if o.fileValues == nil {
+			o.fileValues = map[string]string{}
+		}
+		o.fileValues[key] = contents
+	}
+}
+
+// WithPreInstall runs hook before the Helm install, in the order hooks are provided.
+// Pre-install hooks run before the cluster's own cleanup is registered,
+// so any cleanup they register runs after the release is deleted.
+func WithPreInstall(hook InstallHook) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		o.preInstall = append(o.preInstall, hook)
+	}
+}
+
+// WithPostInstall runs hook after the Helm install, once the release's pods
+// and webhooks are ready, in the order hooks are provided. Any cleanup
+// post-install hooks register runs before the release is deleted.
+func WithPostInstall(hook InstallHook) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		o.postInstall = append(o.postInstall, hook)
+	}
+}
+
+// WithCleanupScope destroys the release when owner finishes instead of when
+// the test that calls Create finishes, so that the release can be shared by
+// later subtests of owner. See suite.TestSuite.
+func WithCleanupScope(owner *testing.T) HelmClusterOption {
+	return func(o *helmClusterOptions) {
+		o.cleanupScope = owner
+	}
 }
 
 func NewHelmCluster(
@@ -52,7 +291,19 @@ func NewHelmCluster(
 	helmValues map[string]string,
 	ctx environment.TestContext,
 	cfg *config.TestConfig,
-	releaseName string) Cluster {
+	releaseName string,
+	options ...HelmClusterOption) Cluster {
+
+	cfg.SkipFilteredCase(t, config.CaseTraitsFromHelmValues(helmValues))
+
+	clusterOpts := &helmClusterOptions{
+		installTimeout: cfg.HelmInstallTimeout,
+		atomic:         cfg.HelmAtomic,
+		chart:          config.HelmChartPath,
+	}
+	for _, opt := range options {
+		opt(clusterOpts)
+	}
 
 	// Deploy with the following defaults unless helmValues overwrites it.
 	values := map[string]string{
@@ -66,49 +317,235 @@ func NewHelmCluster(
 
 	// Merge all helm values
 	mergeMaps(values, valuesFromConfig)
+	var enterpriseLicense string
+	if cfg.EnableEnterprise && cfg.EnterpriseLicense != "" {
+		enterpriseLicense = cfg.EnterpriseLicense
+		values["server.enterpriseLicense.secretName"] = enterpriseLicenseSecretName(releaseName)
+		values["server.enterpriseLicense.secretKey"] = enterpriseLicenseSecretKey
+	}
+	if clusterOpts.customCA != nil {
+		values["global.tls.caCert.secretName"] = caCertSecretName(releaseName)
+		values["global.tls.caCert.secretKey"] = caCertSecretKey
+		values["global.tls.caKey.secretName"] = caKeySecretName(releaseName)
+		values["global.tls.caKey.secretKey"] = caKeySecretKey
+	}
+	if clusterOpts.bootstrapToken != "" {
+		values["global.acls.bootstrapToken.secretName"] = bootstrapTokenSecretName(releaseName)
+		values["global.acls.bootstrapToken.secretKey"] = bootstrapTokenSecretKey
+	}
+	if clusterOpts.federation != nil {
+		mergeMaps(values, federationHelmValues(clusterOpts.federation))
+	}
 	mergeMaps(values, helmValues)
 
-	logger := terratestLogger.New(logger.TestLogger{})
-
-	// Wait up to 15 min for K8s resources to be in a ready state. Increasing
+	// Wait up to 15 min for K8s resources to be in a ready state by default. Increasing
 	// this from the default of 5 min could help with flakiness in environments
 	// like AKS where volumes take a long time to mount.
-	extraArgs := map[string][]string{
-		"install": {"--timeout", "15m"},
+	installTimeout := clusterOpts.installTimeout
+	if installTimeout == 0 {
+		installTimeout = defaultInstallTimeout
+	}
+	var postRenderers []postrender.PostRenderer
+	if clusterOpts.skipCRDInstall {
+		postRenderers = append(postRenderers, skipCRDsPostRenderer{})
+	}
+	// The labels are only computed once so that upgrades keep the name of the
+	// test that installed the release. Upgrade refreshes their timestamp.
+	labels := testlabels.ForTest(t)
+	if labels != nil {
+		postRenderers = append(postRenderers, labelsPostRenderer{labels: labels})
+	}
+
+	// Structured values are written to a values file so that helm
+	// applies them after any user-provided values files.
+	valuesFiles := clusterOpts.valuesFiles
+	if len(clusterOpts.values) > 0 {
+		valuesFiles = append(valuesFiles, writeValuesFile(t, clusterOpts.values))
+	}
+	setFiles := map[string]string{}
+	for k, path := range clusterOpts.setFiles {
+		setFiles[k] = path
+	}
+	for k, contents := range clusterOpts.fileValues {
+		setFiles[k] = writeTempFile(t, "value-*", []byte(contents))
 	}
 
 	opts := &helm.Options{
 		SetValues:      values,
+		ValuesFiles:    valuesFiles,
+		SetFileValues:  setFiles,
+		Version:        clusterOpts.chartVersion,
 		KubectlOptions: ctx.KubectlOptions(t),
-		Logger:         logger,
-		ExtraArgs:      extraArgs,
+		Timeout:        installTimeout,
+		Wait:           cfg.HelmWait,
+		Atomic:         clusterOpts.atomic,
+		PostRenderer:   chainPostRenderers(postRenderers),
+		ImageRegistry:  cfg.TestImageRegistry,
+		ExtraArgs:      cfg.HelmExtraArgs,
 	}
 	return &HelmCluster{
-		ctx:                ctx,
-		helmOptions:        opts,
-		releaseName:        releaseName,
-		kubernetesClient:   ctx.KubernetesClient(t),
-		noCleanupOnFailure: cfg.NoCleanupOnFailure,
-		debugDirectory:     cfg.DebugDirectory,
-		logger:             logger,
+		ctx:                 ctx,
+		helmOptions:         opts,
+		chart:               clusterOpts.chart,
+		releaseName:         releaseName,
+		enterpriseLicense:   enterpriseLicense,
+		customCA:            clusterOpts.customCA,
+		bootstrapToken:      clusterOpts.bootstrapToken,
+		labels:              labels,
+		kubernetesClient:    ctx.KubernetesClient(t),
+		noCleanupOnFailure:  cfg.NoCleanupOnFailure,
+		noCleanup:           cfg.NoCleanup,
+		debugDirectory:      cfg.DebugDirectory,
+		helmValuesLogFilter: cfg.HelmValuesLogFilter,
+		preInstallHooks:     clusterOpts.preInstall,
+		postInstallHooks:    clusterOpts.postInstall,
+		cleanupScope:        clusterOpts.cleanupScope,
+	}
+}
+
+// NewHelmClusterInNamespace is like NewHelmCluster but installs the release into
+// the Kubernetes namespace with the given name rather than the namespace of ctx,
+// so that tests that run in parallel don't share a namespace. It creates the
+// namespace if it doesn't exist yet and deletes it after the release is destroyed.
+func NewHelmClusterInNamespace(
+	t *testing.T,
+	helmValues map[string]string,
+	ctx environment.TestContext,
+	cfg *config.TestConfig,
+	releaseName string,
+	namespace string,
+	options ...HelmClusterOption) Cluster {
+
+	t.Helper()
+
+	// The namespace is deleted with the same scope as the release
+	// so that it outlives the release when WithCleanupScope is used.
+	clusterOpts := &helmClusterOptions{}
+	for _, opt := range options {
+		opt(clusterOpts)
 	}
+	cleanupT := t
+	if clusterOpts.cleanupScope != nil {
+		cleanupT = clusterOpts.cleanupScope
+	}
+
+	nsCtx := &namespacedContext{TestContext: ctx, namespace: namespace}
+	cluster := NewHelmCluster(t, helmValues, nsCtx, cfg, releaseName, options...)
+	createReleaseNamespace(t, cleanupT, ctx.KubernetesClient(t), namespace, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+	return cluster
+}
+
+// namespacedContext is a test context whose kubectl options
+// are scoped to a namespace other than that of the context.
+type namespacedContext struct {
+	environment.TestContext
+	namespace string
+}
+
+func (n *namespacedContext) KubectlOptions(t *testing.T) *terratestk8s.KubectlOptions {
+	options := n.TestContext.KubectlOptions(t)
+	return &terratestk8s.KubectlOptions{
+		ContextName: options.ContextName,
+		ConfigPath:  options.ConfigPath,
+		Env:         options.Env,
+		Namespace:   n.namespace,
+	}
+}
+
+// createReleaseNamespace creates the namespace that a release is installed into
+// and deletes it when cleanupT finishes. Namespaces that already exist are
+// left in place because they may hold resources the test didn't create.
+func createReleaseNamespace(t, cleanupT *testing.T, client kubernetes.Interface, namespace string, noCleanupOnFailure, noCleanup bool) {
+	t.Helper()
+
+	logger.Logf(t, "creating namespace %q", namespace)
+	_, err := client.CoreV1().Namespaces().Create(helpers.TestContext(t), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: testlabels.ForTest(t)},
+	}, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		logger.Logf(t, "namespace %q already exists and won't be deleted", namespace)
+		return
+	}
+	require.NoError(t, err)
+
+	helpers.Cleanup(cleanupT, noCleanupOnFailure, noCleanup, func() {
+		logger.Logf(cleanupT, "deleting namespace %q", namespace)
+		err := client.CoreV1().Namespaces().Delete(helpers.TestContext(cleanupT), namespace, metav1.DeleteOptions{})
+		if !errors.IsNotFound(err) {
+			require.NoError(cleanupT, err)
+		}
+	})
 }
 
 func (h *HelmCluster) Create(t *testing.T) {
 	t.Helper()
 
+	// Fail if there are any existing installations of the Helm chart.
+	h.checkForPriorInstallations(t)
+
+	// Run the pre-install hooks before registering the cleanup below
+	// so that their cleanup runs after the release is deleted.
+	h.runHooks(t, "pre-install", h.preInstallHooks)
+
 	// Make sure we delete the cluster if we receive an interrupt signal and
 	// register cleanup so that we delete the cluster when test finishes.
-	helpers.Cleanup(t, h.noCleanupOnFailure, func() {
-		h.Destroy(t)
+	cleanupT := t
+	if h.cleanupScope != nil {
+		cleanupT = h.cleanupScope
+	}
+	helpers.Cleanup(cleanupT, h.noCleanupOnFailure, h.noCleanup, func() {
+		h.Destroy(cleanupT)
 	})
 
-	// Fail if there are any existing installations of the Helm chart.
-	h.checkForPriorInstallations(t)
+	h.createEnterpriseLicenseSecret(t)
+	h.createCASecrets(t)
+	h.createBootstrapTokenSecret(t)
+
+	h.logHelmValues(t, "install")
+
+	activeReleases.Store(h.releaseName, true)
+	// Collect debugging information if either the install fails or the pods
+	// don't become ready. This runs even if the test fails with FailNow.
+	defer func() {
+		if t.Failed() {
+			h.logInstallFailure(t)
+		}
+	}()
+
+	recordInstall := metrics.Time(t, metrics.PhaseInstall)
+	err := helm.InstallE(t, h.helmOptions, h.chart, h.releaseName)
+	require.NoError(t, err, "see the test log for events and status of pods and persistent volume claims of release %s", h.releaseName)
+	h.labelReleaseSecrets(t)
 
-	helm.Install(t, h.helmOptions, config.HelmChartPath, h.releaseName)
+	helpers.WaitForAllPodsRunning(t, h.kubernetesClient, h.helmOptions.KubectlOptions.Namespace, h.releaseName)
+	recordInstall()
+
+	recordWebhookReady := metrics.Time(t, metrics.PhaseWebhookReady)
+	h.waitForWebhooks(t)
+	recordWebhookReady()
+
+	h.runHooks(t, "post-install", h.postInstallHooks)
+}
+
+// runHooks runs the install hooks in order.
+func (h *HelmCluster) runHooks(t *testing.T, stage string, hooks []InstallHook) {
+	t.Helper()
+
+	for i, hook := range hooks {
+		logger.Logf(t, "running %s hook %d of %d for release %s", stage, i+1, len(hooks), h.releaseName)
+		hook(t, h)
+	}
+}
 
-	helpers.WaitForAllPodsToBeReady(t, h.kubernetesClient, h.helmOptions.KubectlOptions.Namespace, fmt.Sprintf("release=%s", h.releaseName))
+// ReleaseName returns the name of the Helm release.
+func (h *HelmCluster) ReleaseName() string {
+	return h.releaseName
+}
+
+// KubectlOptions returns the options of the Kubernetes
+// context and namespace the release is installed into.
+func (h *HelmCluster) KubectlOptions() *terratestk8s.KubectlOptions {
+	return h.helmOptions.KubectlOptions
 }
 
 func (h *HelmCluster) Destroy(t *testing.T) {
@@ -118,17 +555,18 @@ func (h *HelmCluster) Destroy(t *testing.T) {
 
 	// Ignore the error returned by the helm delete here so that we can
 	// always idempotently clean up resources in the cluster.
-	helm.DeleteE(t, h.helmOptions, h.releaseName, false)
+	helm.DeleteE(t, h.helmOptions, h.releaseName)
+	activeReleases.Delete(h.releaseName)
 
 	// Delete PVCs.
-	h.kubernetesClient.CoreV1().PersistentVolumeClaims(h.helmOptions.KubectlOptions.Namespace).DeleteCollection(context.Background(), metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
+	h.kubernetesClient.CoreV1().PersistentVolumeClaims(h.helmOptions.KubectlOptions.Namespace).DeleteCollection(helpers.TestContext(t), metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
 
 	// Delete any serviceaccounts that have h.releaseName in their name.
-	sas, err := h.kubernetesClient.CoreV1().ServiceAccounts(h.helmOptions.KubectlOptions.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
+	sas, err := h.kubernetesClient.CoreV1().ServiceAccounts(h.helmOptions.KubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
 	require.NoError(t, err)
 	for _, sa := range sas.Items {
 		if strings.Contains(sa.Name, h.releaseName) {
-			err := h.kubernetesClient.CoreV1().ServiceAccounts(h.helmOptions.KubectlOptions.Namespace).Delete(context.Background(), sa.Name, metav1.DeleteOptions{})
+			err := h.kubernetesClient.CoreV1().ServiceAccounts(h.helmOptions.KubectlOptions.Namespace).Delete(helpers.TestContext(t), sa.Name, metav1.DeleteOptions{})
 			if !errors.IsNotFound(err) {
 				require.NoError(t, err)
 			}
@@ -136,11 +574,11 @@ func (h *HelmCluster) Destroy(t *testing.T) {
 	}
 
 	// Delete any roles that have h.releaseName in their name.
-	roles, err := h.kubernetesClient.RbacV1().Roles(h.helmOptions.KubectlOptions.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
+	roles, err := h.kubernetesClient.RbacV1().Roles(h.helmOptions.KubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
 	require.NoError(t, err)
 	for _, role := range roles.Items {
 		if strings.Contains(role.Name, h.releaseName) {
-			err := h.kubernetesClient.RbacV1().Roles(h.helmOptions.KubectlOptions.Namespace).Delete(context.Background(), role.Name, metav1.DeleteOptions{})
+			err := h.kubernetesClient.RbacV1().Roles(h.helmOptions.KubectlOptions.Namespace).Delete(helpers.TestContext(t), role.Name, metav1.DeleteOptions{})
 			if !errors.IsNotFound(err) {
 				require.NoError(t, err)
 			}
@@ -148,11 +586,11 @@ func (h *HelmCluster) Destroy(t *testing.T) {
 	}
 
 	// Delete any rolebindings that have h.releaseName in their name.
-	roleBindings, err := h.kubernetesClient.RbacV1().RoleBindings(h.helmOptions.KubectlOptions.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
+	roleBindings, err := h.kubernetesClient.RbacV1().RoleBindings(h.helmOptions.KubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
 	require.NoError(t, err)
 	for _, roleBinding := range roleBindings.Items {
 		if strings.Contains(roleBinding.Name, h.releaseName) {
-			err := h.kubernetesClient.RbacV1().RoleBindings(h.helmOptions.KubectlOptions.Namespace).Delete(context.Background(), roleBinding.Name, metav1.DeleteOptions{})
+			err := h.kubernetesClient.RbacV1().RoleBindings(h.helmOptions.KubectlOptions.Namespace).Delete(helpers.TestContext(t), roleBinding.Name, metav1.DeleteOptions{})
 			if !errors.IsNotFound(err) {
 				require.NoError(t, err)
 			}
@@ -160,11 +598,11 @@ func (h *HelmCluster) Destroy(t *testing.T) {
 	}
 
 	// Delete any secrets that have h.releaseName in their name.
-	secrets, err := h.kubernetesClient.CoreV1().Secrets(h.helmOptions.KubectlOptions.Namespace).List(context.Background(), metav1.ListOptions{})
+	secrets, err := h.kubernetesClient.CoreV1().Secrets(h.helmOptions.KubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{})
 	require.NoError(t, err)
 	for _, secret := range secrets.Items {
 		if strings.Contains(secret.Name, h.releaseName) {
-			err := h.kubernetesClient.CoreV1().Secrets(h.helmOptions.KubectlOptions.Namespace).Delete(context.Background(), secret.Name, metav1.DeleteOptions{})
+			err := h.kubernetesClient.CoreV1().Secrets(h.helmOptions.KubectlOptions.Namespace).Delete(helpers.TestContext(t), secret.Name, metav1.DeleteOptions{})
 			if !errors.IsNotFound(err) {
 				require.NoError(t, err)
 			}
@@ -172,11 +610,62 @@ func (h *HelmCluster) Destroy(t *testing.T) {
 	}
 
 	// Delete any jobs that have h.releaseName in their name.
-	jobs, err := h.kubernetesClient.BatchV1().Jobs(h.helmOptions.KubectlOptions.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
+	jobs, err := h.kubernetesClient.BatchV1().Jobs(h.helmOptions.KubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
 	require.NoError(t, err)
 	for _, job := range jobs.Items {
 		if strings.Contains(job.Name, h.releaseName) {
-			err := h.kubernetesClient.BatchV1().Jobs(h.helmOptions.KubectlOptions.Namespace).Delete(context.Background(), job.Name, metav1.DeleteOptions{})
+			err := h.kubernetesClient.BatchV1().Jobs(h.helmOptions.KubectlOptions.Namespace).Delete(helpers.TestContext(t), job.Name, metav1.DeleteOptions{})
+			if !errors.IsNotFound(err) {
+				require.NoError(t, err)
+			}
+		}
+	}
+
+	h.deleteClusterScopedResources(t)
+}
+
+// deleteClusterScopedResources deletes the cluster roles, cluster role bindings
+// and webhook configurations of the release that are left behind if Helm's
+// delete fails or the resources were created by the release's jobs rather than
+// by Helm. Unlike namespaced resources, they aren't deleted with the namespace
+// and would make later installs with the same release name fail.
+func (h *HelmCluster) deleteClusterScopedResources(t *testing.T) {
+	t.Helper()
+
+	rbac := h.kubernetesClient.RbacV1()
+	admission := h.kubernetesClient.AdmissionregistrationV1()
+	h.deleteReleaseResources(t, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return rbac.ClusterRoles().List(ctx, opts)
+	}, rbac.ClusterRoles().Delete)
+	h.deleteReleaseResources(t, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return rbac.ClusterRoleBindings().List(ctx, opts)
+	}, rbac.ClusterRoleBindings().Delete)
+	h.deleteReleaseResources(t, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return admission.MutatingWebhookConfigurations().List(ctx, opts)
+	}, admission.MutatingWebhookConfigurations().Delete)
+	h.deleteReleaseResources(t, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return admission.ValidatingWebhookConfigurations().List(ctx, opts)
+	}, admission.ValidatingWebhookConfigurations().Delete)
+}
+
+// deleteReleaseResources deletes the resources returned by list
+// that are labeled with the release and have h.releaseName in their name.
+func (h *HelmCluster) deleteReleaseResources(
+	t *testing.T,
+	list func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error),
+	deleteFunc func(ctx context.Context, name string, opts metav1.DeleteOptions) error) {
+
+	t.Helper()
+
+	resources, err := list(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
+	require.NoError(t, err)
+	items, err := apimeta.ExtractList(resources)
+	require.NoError(t, err)
+	for _, item := range items {
+		object, err := apimeta.Accessor(item)
+		require.NoError(t, err)
+		if strings.Contains(object.GetName(), h.releaseName) {
+			err := deleteFunc(helpers.TestContext(t), object.GetName(), metav1.DeleteOptions{})
 			if !errors.IsNotFound(err) {
 				require.NoError(t, err)
 			}
@@ -188,16 +677,40 @@ func (h *HelmCluster) Upgrade(t *testing.T, helmValues map[string]string) {
 	t.Helper()
 
 	mergeMaps(h.helmOptions.SetValues, helmValues)
+
+	// The version only applies to the chart used for the initial install.
+	h.helmOptions.Version = ""
+	// The post-renderer shares the labels, so this also updates
+	// the labels of the chart's resources.
+	testlabels.RefreshTimestamp(h.labels)
+	h.logHelmValues(t, "upgrade")
 	helm.Upgrade(t, h.helmOptions, config.HelmChartPath, h.releaseName)
-	helpers.WaitForAllPodsToBeReady(t, h.kubernetesClient, h.helmOptions.KubectlOptions.Namespace, fmt.Sprintf("release=%s", h.releaseName))
+	h.labelReleaseSecrets(t)
+	helpers.WaitForAllPodsRunning(t, h.kubernetesClient, h.helmOptions.KubectlOptions.Namespace, h.releaseName)
+	h.waitForWebhooks(t)
 }
 
 func (h *HelmCluster) SetupConsulClient(t *testing.T, secure bool) *api.Client {
 	t.Helper()
 
+	return h.setupConsulClient(t, secure, "")
+}
+
+func (h *HelmCluster) SetupConsulClientInNamespace(t *testing.T, secure bool, namespace string) *api.Client {
+	t.Helper()
+
+	return h.setupConsulClient(t, secure, namespace)
+}
+
+// setupConsulClient port-forwards to a Consul server and returns a client
+// for it. If consulNamespace is not empty, the client makes requests in that
+// Consul namespace unless the request's options set a different namespace.
+func (h *HelmCluster) setupConsulClient(t *testing.T, secure bool, consulNamespace string) *api.Client {
+	t.Helper()
+
 	namespace := h.helmOptions.KubectlOptions.Namespace
 	config := api.DefaultConfig()
-	localPort := terratestk8s.GetAvailablePort(t)
+	config.Namespace = consulNamespace
 	remotePort := 8500 // use non-secure by default
 
 	if secure {
@@ -208,15 +721,18 @@ func (h *HelmCluster) SetupConsulClient(t *testing.T, secure bool) *api.Client {
 		config.TLSConfig.InsecureSkipVerify = true
 		config.Scheme = "https"
 
-		// Get the ACL token. First, attempt to read it from the bootstrap token (this will be true in primary Consul servers).
+		// Get the ACL token. If the cluster was created with WithBootstrapToken,
+		// the chart doesn't create a bootstrap token secret, so use the provided token.
+		// Otherwise, attempt to read it from the bootstrap token (this will be true in primary Consul servers).
 		// If the bootstrap token doesn't exist, it means we are running against a secondary cluster
 		// and will try to read the replication token from the federation secret.
 		// In secondary servers, we don't create a bootstrap token since ACLs are only bootstrapped in the primary.
 		// Instead, we provide a replication token that serves the role of the bootstrap token.
-		aclSecret, err := h.kubernetesClient.CoreV1().Secrets(namespace).Get(context.Background(), h.releaseName+"-consul-bootstrap-acl-token", metav1.GetOptions{})
-		if err != nil && errors.IsNotFound(err) {
+		if h.bootstrapToken != "" {
+			config.Token = h.bootstrapToken
+		} else if aclSecret, err := h.kubernetesClient.CoreV1().Secrets(namespace).Get(helpers.TestContext(t), h.releaseName+"-consul-bootstrap-acl-token", metav1.GetOptions{}); err != nil && errors.IsNotFound(err) {
 			federationSecret := fmt.Sprintf("%s-consul-federation", h.releaseName)
-			aclSecret, err = h.kubernetesClient.CoreV1().Secrets(namespace).Get(context.Background(), federationSecret, metav1.GetOptions{})
+			aclSecret, err = h.kubernetesClient.CoreV1().Secrets(namespace).Get(helpers.TestContext(t), federationSecret, metav1.GetOptions{})
 			require.NoError(t, err)
 			config.Token = string(aclSecret.Data["replicationToken"])
 		} else if err == nil {
@@ -226,58 +742,185 @@ func (h *HelmCluster) SetupConsulClient(t *testing.T, secure bool) *api.Client {
 		}
 	}
 
-	tunnel := terratestk8s.NewTunnelWithLogger(
-		h.helmOptions.KubectlOptions,
-		terratestk8s.ResourceTypePod,
-		fmt.Sprintf("%s-consul-server-0", h.releaseName),
-		localPort,
-		remotePort,
-		h.logger)
+	// The tunnel reconnects to another server pod if the pod it's
+	// forwarding to is restarted, and requests made while no server is
+	// ready wait for one, so the client keeps working in tests that
+	// restart or scale the servers without being created again.
+	tunnel := portforward.NewTunnel(t, h.helmOptions.KubectlOptions,
+		fmt.Sprintf("release=%s,component=server", h.releaseName), remotePort)
 
-	// Retry creating the port forward since it can fail occasionally.
-	retry.RunWith(&retry.Counter{Wait: 1 * time.Second, Count: 3}, t, func(r *retry.R) {
-		// NOTE: It's okay to pass in `t` to ForwardPortE despite being in a retry
-		// because we're using ForwardPortE (not ForwardPort) so the `t` won't
-		// get used to fail the test, just for logging.
-		require.NoError(r, tunnel.ForwardPortE(t))
-	})
+	config.Address = tunnel.Endpoint()
+	consulClient, err := api.NewClient(config)
+	require.NoError(t, err)
 
-	t.Cleanup(func() {
-		tunnel.Close()
+	return consulClient
+}
+
+// waitForWebhooks waits for the webhooks of the release, such as the connect
+// injector and the controller webhooks, to be ready to serve requests
+// so that tests can create resources right after installing or upgrading.
+func (h *HelmCluster) waitForWebhooks(t *testing.T) {
+	t.Helper()
+
+	listOptions := metav1.ListOptions{LabelSelector: fmt.Sprintf("release=%s", h.releaseName)}
+	mutating, err := h.kubernetesClient.AdmissionregistrationV1().MutatingWebhookConfigurations().List(helpers.TestContext(t), listOptions)
+	require.NoError(t, err)
+	for _, config := range mutating.Items {
+		k8s.WaitForWebhook(t, h.helmOptions.KubectlOptions, config.Name)
+	}
+
+	validating, err := h.kubernetesClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(helpers.TestContext(t), listOptions)
+	require.NoError(t, err)
+	for _, config := range validating.Items {
+		k8s.WaitForWebhook(t, h.helmOptions.KubectlOptions, config.Name)
+	}
+}
+
+// enterpriseLicenseSecretKey is the key of the enterprise license
+// in the secrets created by createEnterpriseLicenseSecret.
+const enterpriseLicenseSecretKey = "license"
+
+// enterpriseLicenseSecretName returns the name of the enterprise license secret
+// for the release. It contains the release name so that Destroy deletes it.
+func enterpriseLicenseSecretName(releaseName string) string {
+	return fmt.Sprintf("%s-consul-enterprise-license", releaseName)
+}
+
+// createEnterpriseLicenseSecret creates the Kubernetes secret with the
+// enterprise license from the test config, unless the test has
+// overridden the enterprise license Helm values to use a different secret.
+func (h *HelmCluster) createEnterpriseLicenseSecret(t *testing.T) {
+	t.Helper()
+
+	secretName := enterpriseLicenseSecretName(h.releaseName)
+	if h.enterpriseLicense == "" || h.helmOptions.SetValues["server.enterpriseLicense.secretName"] != secretName {
+		return
+	}
+
+	logger.Logf(t, "creating enterprise license secret %s", secretName)
+	_, err := h.kubernetesClient.CoreV1().Secrets(h.helmOptions.KubectlOptions.Namespace).Create(helpers.TestContext(t), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName, Labels: testlabels.ForTest(t)},
+		StringData: map[string]string{enterpriseLicenseSecretKey: h.enterpriseLicense},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+}
+
+// labelReleaseSecrets adds the test's labels to the secrets in which Helm
+// stores the release so that they're deleted with the rest of the release's
+// resources if the release is orphaned. The post-renderer only labels the
+// resources in the chart.
+func (h *HelmCluster) labelReleaseSecrets(t *testing.T) {
+	t.Helper()
+
+	if h.labels == nil {
+		return
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": h.labels},
 	})
+	require.NoError(t, err)
 
-	config.Address = fmt.Sprintf("127.0.0.1:%d", localPort)
-	consulClient, err := api.NewClient(config)
+	secrets := h.kubernetesClient.CoreV1().Secrets(h.helmOptions.KubectlOptions.Namespace)
+	list, err := secrets.List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: fmt.Sprintf("owner=helm,name=%s", h.releaseName)})
 	require.NoError(t, err)
+	for _, secret := range list.Items {
+		_, err := secrets.Patch(helpers.TestContext(t), secret.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		require.NoError(t, err)
+	}
+}
 
-	return consulClient
+// logInstallFailure collects information to help debug failed installs:
+// the status of the Helm release, recent events in the namespace,
+// the status of the release's persistent volume claims, and descriptions
+// of pods in the release that are not ready. It logs this information
+// and writes it to the debug directory.
+func (h *HelmCluster) logInstallFailure(t *testing.T) {
+	t.Helper()
+
+	var report strings.Builder
+	section := func(title, content string) {
+		fmt.Fprintf(&report, "==> %s\n%s\n\n", title, strings.TrimSpace(content))
+	}
+
+	status, err := helm.StatusE(t, h.helmOptions, h.releaseName)
+	if err != nil {
+		status = fmt.Sprintf("failed to get status: %s", err)
+	}
+	section(fmt.Sprintf("status of release %s", h.releaseName), status)
+
+	events, err := k8s.RunKubectlAndGetOutputWithLoggerE(t, h.helmOptions.KubectlOptions, terratestLogger.Discard, "get", "events", "--sort-by=.lastTimestamp")
+	if err != nil {
+		events = fmt.Sprintf("failed to get events: %s", err)
+	}
+	section("events", events)
+
+	pvcs, err := k8s.RunKubectlAndGetOutputWithLoggerE(t, h.helmOptions.KubectlOptions, terratestLogger.Discard, "get", "pvc", "-l", "release="+h.releaseName, "-o", "wide")
+	if err != nil {
+		pvcs = fmt.Sprintf("failed to get persistent volume claims: %s", err)
+	}
+	section(fmt.Sprintf("persistent volume claims of release %s", h.releaseName), pvcs)
+
+	pods, err := h.kubernetesClient.CoreV1().Pods(h.helmOptions.KubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "release=" + h.releaseName})
+	if err != nil {
+		section(fmt.Sprintf("pods of release %s", h.releaseName), fmt.Sprintf("failed to list pods: %s", err))
+	} else {
+		for _, pod := range pods.Items {
+			if helpers.IsReady(pod) {
+				continue
+			}
+			desc, err := k8s.RunKubectlAndGetOutputWithLoggerE(t, h.helmOptions.KubectlOptions, terratestLogger.Discard, "describe", "pod", pod.Name)
+			if err != nil {
+				desc = fmt.Sprintf("failed to describe pod: %s", err)
+			}
+			section(fmt.Sprintf("pod %s is not ready (phase: %s)", pod.Name, pod.Status.Phase), desc)
+		}
+	}
+
+	logger.Logf(t, "install of release %s failed:\n%s", h.releaseName, report.String())
+
+	if h.debugDirectory == "" {
+		return
+	}
+	testDebugDirectory := filepath.Join(h.debugDirectory, t.Name())
+	if err := os.MkdirAll(testDebugDirectory, 0755); err != nil {
+		logger.Logf(t, "failed to create debug directory %s: %s", testDebugDirectory, err)
+		return
+	}
+	filename := filepath.Join(testDebugDirectory, fmt.Sprintf("%s-install-failure.txt", h.releaseName))
+	if err := ioutil.WriteFile(filename, []byte(report.String()), 0600); err != nil {
+		logger.Logf(t, "failed to write install failure report to %s: %s", filename, err)
+	}
 }
 
+// activeReleases holds the names of the Helm releases that have been created
+// by HelmClusters in this test run and haven't been destroyed yet.
+var activeReleases sync.Map
+
 // checkForPriorInstallations checks if there is an existing Helm release
-// for this Helm chart already installed. If there is, it fails the tests.
+// for this Helm chart already installed, other than the releases created
+// by this test run. If there is, it fails the tests.
 func (h *HelmCluster) checkForPriorInstallations(t *testing.T) {
 	t.Helper()
 
-	var helmListOutput string
+	var installedReleases []helm.Release
 	// Check if there's an existing cluster and fail if there is one.
 	// We may need to retry since this is the first command run once the Kube
 	// cluster is created and sometimes the API server returns errors.
 	retry.RunWith(&retry.Counter{Wait: 1 * time.Second, Count: 3}, t, func(r *retry.R) {
 		var err error
-		// NOTE: It's okay to pass in `t` to RunHelmCommandAndGetOutputE despite being in a retry
-		// because we're using RunHelmCommandAndGetOutputE (not RunHelmCommandAndGetOutput) so the `t` won't
-		// get used to fail the test, just for logging.
-		helmListOutput, err = helm.RunHelmCommandAndGetOutputE(t, h.helmOptions, "list", "--output", "json")
+		// NOTE: It's okay to pass in `t` to ListReleasesE despite being in a retry
+		// because it's only used for logging, not to fail the test.
+		installedReleases, err = helm.ListReleasesE(t, h.helmOptions)
 		require.NoError(r, err)
 	})
 
-	var installedReleases []map[string]string
-
-	err := json.Unmarshal([]byte(helmListOutput), &installedReleases)
-	require.NoError(t, err, "unmarshalling %q", helmListOutput)
-
 	for _, r := range installedReleases {
-		require.NotContains(t, r["chart"], "consul", fmt.Sprintf("detected an existing installation of Consul %s, release name: %s", r["chart"], r["name"]))
+		// Releases created by other HelmClusters in this test run are expected,
+		// for example, when a test installs more than one Consul datacenter.
+		if _, ok := activeReleases.Load(r.Name); ok {
+			continue
+		}
+		require.NotContains(t, r.Chart, "consul", fmt.Sprintf("detected an existing installation of Consul %s, release name: %s", r.Chart, r.Name))
 	}
 }
 
@@ -288,3 +931,153 @@ func mergeMaps(a, b map[string]string) {
 		a[k] = v
 	}
 }
+
+// mergeValues deep merges the structured Helm values in b into a.
+// Nested maps are merged recursively; any other values in b overwrite the values in a.
+// Maps and lists are copied from b so that a doesn't share them with the caller,
+// who may reuse b for other clusters.
+func mergeValues(a, b map[string]interface{}) {
+	for k, v := range b {
+		bMap, bIsMap := v.(map[string]interface{})
+		aMap, aIsMap := a[k].(map[string]interface{})
+		if bIsMap && aIsMap {
+			mergeValues(aMap, bMap)
+			continue
+		}
+		a[k] = copyValue(v)
+	}
+}
+
+// copyValue returns a deep copy of a structured Helm value.
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			c[k] = copyValue(value)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, value := range v {
+			c[i] = copyValue(value)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// writeValuesFile writes structured Helm values to a temporary YAML file
+// that is removed when the test finishes, and returns the path to it.
+func writeValuesFile(t *testing.T, values map[string]interface{}) string {
+	t.Helper()
+
+	valuesYAML, err := yaml.Marshal(values)
+	require.NoError(t, err)
+
+	return writeTempFile(t, "values-*.yaml", valuesYAML)
+}
+
+// writeTempFile writes contents to a temporary file named after pattern,
+// as in ioutil.TempFile, that is removed when the test finishes,
+// and returns the path to it.
+func writeTempFile(t *testing.T, pattern string, contents []byte) string {
+	t.Helper()
+
+	file, err := ioutil.TempFile("", pattern)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.Remove(file.Name())
+	})
+
+	_, err = file.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	return file.Name()
+}
+
+// skipCRDsPostRenderer is a Helm post-renderer that removes all
+// CustomResourceDefinition documents from the rendered manifests.
+type skipCRDsPostRenderer struct{}
+
+func (skipCRDsPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	var modified bytes.Buffer
+	for _, doc := range splitManifests(renderedManifests.String()) {
+		if isCRD(doc) {
+			continue
+		}
+		modified.WriteString(doc)
+	}
+	return &modified, nil
+}
+
+// labelsPostRenderer is a Helm post-renderer that adds labels
+// to all resources in the rendered manifests.
+type labelsPostRenderer struct {
+	labels map[string]string
+}
+
+func (l labelsPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	modified, err := testlabels.AddToManifest(renderedManifests.Bytes(), l.labels)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(modified), nil
+}
+
+// postRendererChain is a Helm post-renderer that runs post-renderers in order.
+type postRendererChain []postrender.PostRenderer
+
+func (c postRendererChain) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	var err error
+	for _, postRenderer := range c {
+		renderedManifests, err = postRenderer.Run(renderedManifests)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return renderedManifests, nil
+}
+
+// chainPostRenderers returns a post-renderer that runs postRenderers in order,
+// or nil if there are none.
+func chainPostRenderers(postRenderers []postrender.PostRenderer) postrender.PostRenderer {
+	switch len(postRenderers) {
+	case 0:
+		return nil
+	case 1:
+		return postRenderers[0]
+	default:
+		return postRendererChain(postRenderers)
+	}
+}
+
+// splitManifests splits a YAML stream into its documents,
+// keeping the --- separator at the start of each document.
+func splitManifests(manifests string) []string {
+	var docs []string
+	var doc strings.Builder
+	for _, line := range strings.SplitAfter(manifests, "\n") {
+		if strings.HasPrefix(line, "---") && doc.Len() > 0 {
+			docs = append(docs, doc.String())
+			doc.Reset()
+		}
+		doc.WriteString(line)
+	}
+	if doc.Len() > 0 {
+		docs = append(docs, doc.String())
+	}
+	return docs
+}
+
+// isCRD returns true if the YAML document is a CustomResourceDefinition.
+func isCRD(doc string) bool {
+	for _, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(line, "kind: CustomResourceDefinition") {
+			return true
+		}
+	}
+	return false
+}
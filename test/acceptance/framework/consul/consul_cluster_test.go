@@ -1,11 +1,23 @@
 package consul
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/gruntwork-io/terratest/modules/k8s"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
 	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 )
@@ -58,6 +70,314 @@ func TestNewHelmCluster(t *testing.T) {
 	}
 }
 
+func TestNewHelmCluster_EnterpriseLicense(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         *config.TestConfig
+		helmValues  map[string]string
+		wantValues  map[string]string
+		wantLicense string
+	}{
+		{
+			name:       "license is not set when enterprise is disabled",
+			cfg:        &config.TestConfig{EnterpriseLicense: "license"},
+			wantValues: map[string]string{},
+		},
+		{
+			name: "license secret is set when enterprise is enabled",
+			cfg:  &config.TestConfig{EnableEnterprise: true, EnterpriseLicense: "license"},
+			wantValues: map[string]string{
+				"server.enterpriseLicense.secretName": "test-consul-enterprise-license",
+				"server.enterpriseLicense.secretKey":  "license",
+			},
+			wantLicense: "license",
+		},
+		{
+			name: "helmValues override the license secret",
+			cfg:  &config.TestConfig{EnableEnterprise: true, EnterpriseLicense: "license"},
+			helmValues: map[string]string{
+				"server.enterpriseLicense.secretName": "",
+				"server.enterpriseLicense.secretKey":  "",
+			},
+			wantValues: map[string]string{
+				"server.enterpriseLicense.secretName": "",
+				"server.enterpriseLicense.secretKey":  "",
+			},
+			wantLicense: "license",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := NewHelmCluster(t, tt.helmValues, &ctx{}, tt.cfg, "test").(*HelmCluster)
+			for _, key := range []string{"server.enterpriseLicense.secretName", "server.enterpriseLicense.secretKey"} {
+				want, ok := tt.wantValues[key]
+				got, gotOK := cluster.helmOptions.SetValues[key]
+				require.Equal(t, ok, gotOK, key)
+				require.Equal(t, want, got, key)
+			}
+			require.Equal(t, tt.wantLicense, cluster.enterpriseLicense)
+		})
+	}
+}
+
+func TestNewHelmCluster_InstallOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         *config.TestConfig
+		options     []HelmClusterOption
+		wantTimeout time.Duration
+		wantWait    bool
+		wantAtomic  bool
+	}{
+		{
+			name:        "uses the default timeout when it's not set",
+			cfg:         &config.TestConfig{},
+			wantTimeout: 15 * time.Minute,
+		},
+		{
+			name: "uses the timeout, wait and atomic from the config",
+			cfg: &config.TestConfig{
+				HelmInstallTimeout: 10 * time.Minute,
+				HelmWait:           true,
+				HelmAtomic:         true,
+			},
+			wantTimeout: 10 * time.Minute,
+			wantWait:    true,
+			wantAtomic:  true,
+		},
+		{
+			name:        "options override the config",
+			cfg:         &config.TestConfig{HelmInstallTimeout: 10 * time.Minute},
+			options:     []HelmClusterOption{WithInstallTimeout(5 * time.Minute), WithAtomicInstall()},
+			wantTimeout: 5 * time.Minute,
+			wantAtomic:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := NewHelmCluster(t, nil, &ctx{}, tt.cfg, "test", tt.options...).(*HelmCluster).helmOptions
+			require.Equal(t, tt.wantTimeout, opts.Timeout)
+			require.Equal(t, tt.wantWait, opts.Wait)
+			require.Equal(t, tt.wantAtomic, opts.Atomic)
+			require.Nil(t, opts.PostRenderer)
+		})
+	}
+}
+
+func TestNewHelmCluster_SkipCRDInstall(t *testing.T) {
+	cluster := NewHelmCluster(t, nil, &ctx{}, &config.TestConfig{}, "test", SkipCRDInstall())
+	postRenderer := cluster.(*HelmCluster).helmOptions.PostRenderer
+	require.NotNil(t, postRenderer)
+
+	manifests := `---
+# Source: consul/templates/crd-servicedefaults.yaml
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: servicedefaults.consul.hashicorp.com
+---
+# Source: consul/templates/server-service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: test-consul-server
+`
+	out, err := postRenderer.Run(bytes.NewBufferString(manifests))
+	require.NoError(t, err)
+	require.Equal(t, `---
+# Source: consul/templates/server-service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: test-consul-server
+`, out.String())
+}
+
+func TestNewHelmCluster_TestLabels(t *testing.T) {
+	testlabels.SetRunID("abc123")
+	t.Cleanup(func() { testlabels.SetRunID("") })
+
+	cluster := NewHelmCluster(t, nil, &ctx{}, &config.TestConfig{}, "test", SkipCRDInstall()).(*HelmCluster)
+	postRenderer := cluster.helmOptions.PostRenderer
+	require.NotNil(t, postRenderer)
+
+	manifests := `---
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: servicedefaults.consul.hashicorp.com
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: test-consul-server
+`
+	out, err := postRenderer.Run(bytes.NewBufferString(manifests))
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf(`apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    test-name: TestNewHelmCluster_TestLabels
+    test-run-id: abc123
+    test-timestamp: "%s"
+  name: test-consul-server
+`, cluster.labels[testlabels.TimestampLabel]), out.String())
+
+	client := fake.NewSimpleClientset(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:   "sh.helm.release.v1.test.v1",
+		Labels: map[string]string{"owner": "helm", "name": "test"},
+	}})
+	cluster.kubernetesClient = client
+	cluster.labelReleaseSecrets(t)
+	secret, err := client.CoreV1().Secrets("").Get(context.Background(), "sh.helm.release.v1.test.v1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "abc123", secret.Labels[testlabels.TestRunIDLabel])
+	require.Equal(t, "helm", secret.Labels["owner"])
+}
+
+func TestNewHelmCluster_SetFiles(t *testing.T) {
+	caCert := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	cluster := NewHelmCluster(t, nil, &ctx{}, &config.TestConfig{}, "test",
+		WithSetFile("server.extraConfig", "/tmp/extra-config.json"),
+		WithFileValue("global.tls.caCert", caCert))
+	setFiles := cluster.(*HelmCluster).helmOptions.SetFileValues
+
+	require.Len(t, setFiles, 2)
+	require.Equal(t, "/tmp/extra-config.json", setFiles["server.extraConfig"])
+	contents, err := ioutil.ReadFile(setFiles["global.tls.caCert"])
+	require.NoError(t, err)
+	require.Equal(t, caCert, string(contents))
+}
+
+// Test that later values take precedence when merging structured
+// Helm values and that the merged values don't share maps or lists
+// with the values passed in.
+func TestMergeValues(t *testing.T) {
+	first := map[string]interface{}{
+		"server": map[string]interface{}{
+			"replicas":    1,
+			"extraConfig": "{}",
+		},
+		"ingressGateways": map[string]interface{}{
+			"gateways": []interface{}{map[string]interface{}{"name": "gateway"}},
+		},
+	}
+	second := map[string]interface{}{
+		"server": map[string]interface{}{"replicas": 3},
+		"client": map[string]interface{}{"enabled": false},
+	}
+
+	merged := map[string]interface{}{}
+	mergeValues(merged, first)
+	mergeValues(merged, second)
+	require.Equal(t, map[string]interface{}{
+		"server": map[string]interface{}{
+			"replicas":    3,
+			"extraConfig": "{}",
+		},
+		"client": map[string]interface{}{"enabled": false},
+		"ingressGateways": map[string]interface{}{
+			"gateways": []interface{}{map[string]interface{}{"name": "gateway"}},
+		},
+	}, merged)
+
+	// Merging into the merged values must not change the values passed in.
+	mergeValues(merged, map[string]interface{}{
+		"server": map[string]interface{}{"extraConfig": `{"log_level": "DEBUG"}`},
+	})
+	merged["ingressGateways"].(map[string]interface{})["gateways"].([]interface{})[0].(map[string]interface{})["name"] = "other"
+	require.Equal(t, "{}", first["server"].(map[string]interface{})["extraConfig"])
+	require.Equal(t, 3, second["server"].(map[string]interface{})["replicas"])
+	require.Equal(t, "gateway", first["ingressGateways"].(map[string]interface{})["gateways"].([]interface{})[0].(map[string]interface{})["name"])
+}
+
+// Test that structured Helm values are written as YAML
+// to a file that is removed when the test finishes.
+func TestWriteValuesFile(t *testing.T) {
+	var path string
+	t.Run("write", func(t *testing.T) {
+		path = writeValuesFile(t, map[string]interface{}{
+			"server": map[string]interface{}{"extraConfig": `{"log_level": "DEBUG"}`},
+		})
+		contents, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "server:\n  extraConfig: '{\"log_level\": \"DEBUG\"}'\n", string(contents))
+	})
+	require.NoFileExists(t, path)
+}
+
+func TestHelmCluster_InstallHooks(t *testing.T) {
+	var ran []string
+	hook := func(name string) InstallHook {
+		return func(t *testing.T, cluster *HelmCluster) {
+			require.Equal(t, "test", cluster.ReleaseName())
+			ran = append(ran, name)
+		}
+	}
+	cluster := NewHelmCluster(t, nil, &ctx{}, &config.TestConfig{}, "test",
+		WithPreInstall(hook("pre-1")),
+		WithPostInstall(hook("post-1")),
+		WithPreInstall(hook("pre-2")),
+	).(*HelmCluster)
+
+	cluster.runHooks(t, "pre-install", cluster.preInstallHooks)
+	require.Equal(t, []string{"pre-1", "pre-2"}, ran)
+	cluster.runHooks(t, "post-install", cluster.postInstallHooks)
+	require.Equal(t, []string{"pre-1", "pre-2", "post-1"}, ran)
+}
+
+func TestNewHelmClusterInNamespace(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "existing"}})
+	testCtx := &clientCtx{client: client}
+
+	t.Run("creates and deletes the namespace", func(t *testing.T) {
+		cluster := NewHelmClusterInNamespace(t, nil, testCtx, &config.TestConfig{}, "test", "release-ns").(*HelmCluster)
+		require.Equal(t, "release-ns", cluster.helmOptions.KubectlOptions.Namespace)
+		require.Equal(t, "release-ns", cluster.ctx.KubectlOptions(t).Namespace)
+		require.Equal(t, "default", testCtx.KubectlOptions(t).Namespace, "the options of the context should be unchanged")
+
+		_, err := client.CoreV1().Namespaces().Get(context.Background(), "release-ns", metav1.GetOptions{})
+		require.NoError(t, err)
+	})
+	_, err := client.CoreV1().Namespaces().Get(context.Background(), "release-ns", metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err), "expected the namespace to be deleted, got %v", err)
+
+	t.Run("keeps an existing namespace", func(t *testing.T) {
+		NewHelmClusterInNamespace(t, nil, testCtx, &config.TestConfig{}, "test", "existing")
+	})
+	_, err = client.CoreV1().Namespaces().Get(context.Background(), "existing", metav1.GetOptions{})
+	require.NoError(t, err)
+}
+
+func TestHelmCluster_DeleteClusterScopedResources(t *testing.T) {
+	releaseLabels := map[string]string{"release": "test"}
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "test-consul-client", Labels: releaseLabels}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "other-consul-client", Labels: map[string]string{"release": "other"}}},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "test-consul-client", Labels: releaseLabels}},
+		&admissionregistrationv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "test-consul-connect-injector-cfg", Labels: releaseLabels}},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "test-consul-controller-webhook", Labels: releaseLabels}},
+	)
+	cluster := &HelmCluster{releaseName: "test", kubernetesClient: client}
+
+	cluster.deleteClusterScopedResources(t)
+
+	clusterRoles, err := client.RbacV1().ClusterRoles().List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, clusterRoles.Items, 1)
+	require.Equal(t, "other-consul-client", clusterRoles.Items[0].Name)
+	clusterRoleBindings, err := client.RbacV1().ClusterRoleBindings().List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, clusterRoleBindings.Items)
+	mutating, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, mutating.Items)
+	validating, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, validating.Items)
+}
+
 type ctx struct{}
 
 func (c *ctx) Name() string {
@@ -70,3 +390,23 @@ func (c *ctx) KubectlOptions(_ *testing.T) *k8s.KubectlOptions {
 func (c *ctx) KubernetesClient(_ *testing.T) kubernetes.Interface {
 	return fake.NewSimpleClientset()
 }
+func (c *ctx) KubernetesVersion(_ *testing.T) environment.KubernetesVersion {
+	return environment.KubernetesVersion{Major: 1, Minor: 19}
+}
+func (c *ctx) HasAPIResource(_ *testing.T, _, _ string) bool {
+	return true
+}
+
+// clientCtx is a test context whose Kubernetes client
+// keeps its state between calls to KubernetesClient.
+type clientCtx struct {
+	ctx
+	client kubernetes.Interface
+}
+
+func (c *clientCtx) KubectlOptions(_ *testing.T) *k8s.KubectlOptions {
+	return &k8s.KubectlOptions{Namespace: "default"}
+}
+func (c *clientCtx) KubernetesClient(_ *testing.T) kubernetes.Interface {
+	return c.client
+}
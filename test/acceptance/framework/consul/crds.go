@@ -0,0 +1,42 @@
+package consul
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helm"
+	"github.com/stretchr/testify/require"
+)
+
+// WriteChartCRDs renders the custom resource definitions from the Helm chart
+// in this repository and writes them to dir, one file per CRD named after
+// the chart template, e.g. crd-servicedefaults.yaml. The CRDs can then be
+// modified by the test and applied with k8s.ApplyCRDs.
+// It returns the paths of the files it wrote.
+func WriteChartCRDs(t *testing.T, dir string) []string {
+	t.Helper()
+
+	templates, err := filepath.Glob(filepath.Join(config.HelmChartPath, "templates", "crd-*.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, templates, "no CRD templates found in the Helm chart")
+
+	require.NoError(t, os.MkdirAll(dir, 0755))
+
+	options := &helm.Options{
+		SetValues: map[string]string{"controller.enabled": "true"},
+	}
+	var files []string
+	for _, template := range templates {
+		name := filepath.Base(template)
+		crd, err := helm.RenderTemplateE(t, options, config.HelmChartPath, "crds", []string{filepath.Join("templates", name)})
+		require.NoError(t, err)
+
+		file := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(file, []byte(crd), 0644))
+		files = append(files, file)
+	}
+	return files
+}
@@ -0,0 +1,93 @@
+package consul
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Keys of the federation secret that the primary datacenter creates
+// when global.federation.createFederationSecret is true.
+const (
+	federationCACertKey           = "caCert"
+	federationCAKeyKey            = "caKey"
+	federationServerConfigKey     = "serverConfigJSON"
+	federationReplicationTokenKey = "replicationToken"
+	federationGossipKeyKey        = "gossipEncryptionKey"
+)
+
+// FederationSecretName returns the name of the federation secret
+// of the release in the primary datacenter.
+func FederationSecretName(releaseName string) string {
+	return fmt.Sprintf("%s-consul-federation", releaseName)
+}
+
+// ExportFederationSecret returns the federation secret of the release
+// installed in primaryCtx with global.federation.createFederationSecret,
+// without its server-set metadata so that it can be imported into
+// another Kubernetes cluster with ImportFederationSecret.
+func ExportFederationSecret(t *testing.T, primaryCtx environment.TestContext, releaseName string) *corev1.Secret {
+	t.Helper()
+
+	name := FederationSecretName(releaseName)
+	logger.Logf(t, "retrieving federation secret %s from the primary cluster", name)
+	secret, err := primaryCtx.KubernetesClient(t).CoreV1().Secrets(primaryCtx.KubectlOptions(t).Namespace).Get(helpers.TestContext(t), name, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secret.Name},
+		Type:       secret.Type,
+		Data:       secret.Data,
+	}
+}
+
+// ImportFederationSecret creates secret, exported with ExportFederationSecret,
+// in the namespace of secondaryCtx. Its name contains the release name, so
+// Destroy of the secondary datacenter's HelmCluster deletes it.
+func ImportFederationSecret(t *testing.T, secondaryCtx environment.TestContext, secret *corev1.Secret) {
+	t.Helper()
+
+	logger.Logf(t, "creating federation secret %s in the secondary cluster", secret.Name)
+	imported := secret.DeepCopy()
+	imported.Labels = testlabels.Merge(imported.Labels, testlabels.ForTest(t))
+	_, err := secondaryCtx.KubernetesClient(t).CoreV1().Secrets(secondaryCtx.KubectlOptions(t).Namespace).Create(helpers.TestContext(t), imported, metav1.CreateOptions{})
+	require.NoError(t, err)
+}
+
+// federationHelmValues returns the Helm values that configure a secondary
+// datacenter with the contents of the federation secret: the CA, the server
+// config with the primary's gateways, and, if the secret has them,
+// the ACL replication token and the gossip encryption key.
+func federationHelmValues(secret *corev1.Secret) map[string]string {
+	values := map[string]string{
+		"global.federation.enabled": "true",
+
+		"global.tls.enabled":           "true",
+		"global.tls.caCert.secretName": secret.Name,
+		"global.tls.caCert.secretKey":  federationCACertKey,
+		"global.tls.caKey.secretName":  secret.Name,
+		"global.tls.caKey.secretKey":   federationCAKeyKey,
+
+		"server.extraVolumes[0].type":          "secret",
+		"server.extraVolumes[0].name":          secret.Name,
+		"server.extraVolumes[0].load":          "true",
+		"server.extraVolumes[0].items[0].key":  federationServerConfigKey,
+		"server.extraVolumes[0].items[0].path": "config.json",
+	}
+	if _, ok := secret.Data[federationReplicationTokenKey]; ok {
+		values["global.acls.replicationToken.secretName"] = secret.Name
+		values["global.acls.replicationToken.secretKey"] = federationReplicationTokenKey
+	}
+	if _, ok := secret.Data[federationGossipKeyKey]; ok {
+		values["global.gossipEncryption.secretName"] = secret.Name
+		values["global.gossipEncryption.secretKey"] = federationGossipKeyKey
+	}
+	return values
+}
@@ -0,0 +1,40 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewHelmCluster_FederationSecret(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: FederationSecretName("test")},
+		Data: map[string][]byte{
+			federationCACertKey:       []byte("cert"),
+			federationCAKeyKey:        []byte("key"),
+			federationServerConfigKey: []byte("{}"),
+		},
+	}
+
+	helmValues := map[string]string{"global.tls.enabled": "false"}
+	cluster := NewHelmCluster(t, helmValues, &ctx{}, &config.TestConfig{}, "test", WithFederationSecret(secret)).(*HelmCluster)
+	values := cluster.helmOptions.SetValues
+	require.Equal(t, "test-consul-federation", values["global.tls.caCert.secretName"])
+	require.Equal(t, "caCert", values["global.tls.caCert.secretKey"])
+	require.Equal(t, "caKey", values["global.tls.caKey.secretKey"])
+	require.Equal(t, "serverConfigJSON", values["server.extraVolumes[0].items[0].key"])
+	require.Equal(t, "false", values["global.tls.enabled"], "helmValues should take precedence")
+	require.NotContains(t, values, "global.acls.replicationToken.secretName")
+	require.NotContains(t, values, "global.gossipEncryption.secretName")
+
+	secret.Data[federationReplicationTokenKey] = []byte("token")
+	secret.Data[federationGossipKeyKey] = []byte("gossip")
+	values = federationHelmValues(secret)
+	require.Equal(t, "test-consul-federation", values["global.acls.replicationToken.secretName"])
+	require.Equal(t, "replicationToken", values["global.acls.replicationToken.secretKey"])
+	require.Equal(t, "test-consul-federation", values["global.gossipEncryption.secretName"])
+	require.Equal(t, "gossipEncryptionKey", values["global.gossipEncryption.secretKey"])
+}
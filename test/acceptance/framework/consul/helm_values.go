@@ -0,0 +1,103 @@
+package consul
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/stretchr/testify/require"
+)
+
+const redactedValue = "<redacted>"
+
+// sensitiveValueKey matches the last part of Helm value keys
+// whose values may contain secrets.
+var sensitiveValueKey = regexp.MustCompile(`(?i)(token|license|password|privatekey|cakey|encryptionkey)$`)
+
+// secretReferenceKey matches the last part of Helm value keys that reference
+// a Kubernetes secret rather than contain the secret itself.
+var secretReferenceKey = regexp.MustCompile(`^secret(Name|Key)$`)
+
+// logHelmValues logs the Helm values used for an install or upgrade of the release,
+// filtered by the Helm values log filter and with secrets redacted, and writes them
+// to the debug directory so that the test can be reproduced outside of the test code.
+func (h *HelmCluster) logHelmValues(t *testing.T, action string) {
+	t.Helper()
+
+	values := formatHelmValues(filterHelmValues(h.helmOptions.SetValues, h.helmValuesLogFilter))
+	if len(h.helmOptions.ValuesFiles) > 0 {
+		values += fmt.Sprintf("\nvalues files: %s", strings.Join(h.helmOptions.ValuesFiles, ", "))
+	}
+	if len(h.helmOptions.SetFileValues) > 0 {
+		values += fmt.Sprintf("\nset files:\n%s", formatHelmValues(h.helmOptions.SetFileValues))
+	}
+	logger.Logf(t, "helm %s of release %s with values:\n%s", action, h.releaseName, values)
+
+	if h.debugDirectory == "" {
+		return
+	}
+	testDebugDirectory := filepath.Join(h.debugDirectory, t.Name())
+	require.NoError(t, os.MkdirAll(testDebugDirectory, 0755))
+	valuesFilename := filepath.Join(testDebugDirectory, fmt.Sprintf("%s-%s-values.txt", h.releaseName, action))
+	require.NoError(t, ioutil.WriteFile(valuesFilename, []byte(values+"\n"), 0600))
+}
+
+// filterHelmValues returns the values whose keys match one of the prefixes
+// or all values if there are no prefixes. Values that may contain secrets are redacted.
+func filterHelmValues(values map[string]string, prefixes []string) map[string]string {
+	filtered := map[string]string{}
+	for k, v := range values {
+		if !hasAnyPrefix(k, prefixes) {
+			continue
+		}
+		if isSensitiveHelmValue(k, v) {
+			v = redactedValue
+		}
+		filtered[k] = v
+	}
+	return filtered
+}
+
+// isSensitiveHelmValue returns true if the value for key may contain a secret.
+// Boolean values, such as feature toggles, are never secret.
+func isSensitiveHelmValue(key, value string) bool {
+	if _, err := strconv.ParseBool(value); err == nil {
+		return false
+	}
+	parts := strings.Split(key, ".")
+	last := parts[len(parts)-1]
+	return !secretReferenceKey.MatchString(last) && sensitiveValueKey.MatchString(last)
+}
+
+// hasAnyPrefix returns true if there are no prefixes or key matches
+// one of the prefixes. A prefix matches whole parts of the key,
+// e.g. "global.tls" matches "global.tls.enabled" but not "global.tlsFoo".
+func hasAnyPrefix(key string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if key == prefix || strings.HasPrefix(key, prefix+".") || strings.HasPrefix(key, prefix+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// formatHelmValues formats values as sorted key=value lines,
+// the same way they are passed to helm with --set.
+func formatHelmValues(values map[string]string) string {
+	var lines []string
+	for k, v := range values {
+		lines = append(lines, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,79 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterHelmValues(t *testing.T) {
+	values := map[string]string{
+		"global.tls.enabled":                          "true",
+		"global.tlsFoo":                               "bar",
+		"global.acls.createReplicationToken":          "true",
+		"global.acls.bootstrapToken.secretName":       "bootstrap-token",
+		"global.acls.replicationToken":                "secret-token",
+		"server.enterpriseLicense":                    "secret-license",
+		"server.enterpriseLicense.secretKey":          "key",
+		"server.extraVolumes[0].items[0].key":         "serverConfigJSON",
+		"connectInject.enabled":                       "true",
+		"connectInject.consulNamespaces.mirroringK8S": "false",
+	}
+
+	cases := map[string]struct {
+		prefixes []string
+		expected map[string]string
+	}{
+		"no filter": {
+			prefixes: nil,
+			expected: map[string]string{
+				"global.tls.enabled":                          "true",
+				"global.tlsFoo":                               "bar",
+				"global.acls.createReplicationToken":          "true",
+				"global.acls.bootstrapToken.secretName":       "bootstrap-token",
+				"global.acls.replicationToken":                redactedValue,
+				"server.enterpriseLicense":                    redactedValue,
+				"server.enterpriseLicense.secretKey":          "key",
+				"server.extraVolumes[0].items[0].key":         "serverConfigJSON",
+				"connectInject.enabled":                       "true",
+				"connectInject.consulNamespaces.mirroringK8S": "false",
+			},
+		},
+		"prefixes match whole parts of keys": {
+			prefixes: []string{"global.tls", "connectInject"},
+			expected: map[string]string{
+				"global.tls.enabled":                          "true",
+				"connectInject.enabled":                       "true",
+				"connectInject.consulNamespaces.mirroringK8S": "false",
+			},
+		},
+		"prefixes match lists": {
+			prefixes: []string{"server.extraVolumes"},
+			expected: map[string]string{
+				"server.extraVolumes[0].items[0].key": "serverConfigJSON",
+			},
+		},
+		"secrets are redacted when filtered": {
+			prefixes: []string{"global.acls"},
+			expected: map[string]string{
+				"global.acls.createReplicationToken":    "true",
+				"global.acls.bootstrapToken.secretName": "bootstrap-token",
+				"global.acls.replicationToken":          redactedValue,
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expected, filterHelmValues(values, c.prefixes))
+		})
+	}
+}
+
+func TestFormatHelmValues(t *testing.T) {
+	formatted := formatHelmValues(map[string]string{
+		"server.replicas":    "1",
+		"global.tls.enabled": "true",
+	})
+	require.Equal(t, "global.tls.enabled=true\nserver.replicas=1", formatted)
+}
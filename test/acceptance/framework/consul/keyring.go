@@ -0,0 +1,86 @@
+package consul
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+// gossipKeyLength is the length in bytes of gossip encryption keys
+// generated by `consul keygen`.
+const gossipKeyLength = 32
+
+// GenerateGossipKey returns a new random base64-encoded gossip encryption key,
+// equivalent to the output of `consul keygen`.
+func GenerateGossipKey(t *testing.T) string {
+	t.Helper()
+
+	key := make([]byte, gossipKeyLength)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// GossipEncryptionEnabled returns true if the agent that client
+// is connected to has encryption enabled on the LAN gossip pool.
+func GossipEncryptionEnabled(t *testing.T, client *api.Client) bool {
+	t.Helper()
+
+	self, err := client.Agent().Self()
+	require.NoError(t, err)
+	serfLAN, ok := self["Stats"]["serf_lan"].(map[string]interface{})
+	require.True(t, ok, "serf_lan stats not found in agent self response")
+	return serfLAN["encrypted"] == "true"
+}
+
+// ListGossipKeys returns the gossip encryption keys installed
+// on the LAN gossip pool of the datacenter client is connected to.
+func ListGossipKeys(t *testing.T, client *api.Client) *api.KeyringResponse {
+	t.Helper()
+
+	responses, err := client.Operator().KeyringList(nil)
+	require.NoError(t, err)
+	for _, resp := range responses {
+		if !resp.WAN {
+			return resp
+		}
+	}
+	require.FailNow(t, "keyring response for the LAN gossip pool not found")
+	return nil
+}
+
+// RotateGossipKey rotates the gossip encryption key of the cluster to newKey
+// using the keyring API. It installs the new key on all agents, makes it the
+// primary key, and then removes all other keys.
+func RotateGossipKey(t *testing.T, client *api.Client, newKey string) {
+	t.Helper()
+
+	oldKeys := ListGossipKeys(t, client).Keys
+
+	logger.Log(t, "installing new gossip encryption key")
+	require.NoError(t, client.Operator().KeyringInstall(newKey, nil))
+
+	logger.Log(t, "using new gossip encryption key as the primary key")
+	require.NoError(t, client.Operator().KeyringUse(newKey, nil))
+
+	for key := range oldKeys {
+		if key == newKey {
+			continue
+		}
+		logger.Log(t, "removing old gossip encryption key")
+		require.NoError(t, client.Operator().KeyringRemove(key, nil))
+	}
+}
+
+// RequireOnlyGossipKey fails the test unless key is the only gossip encryption key
+// and the primary key on all agents in the LAN gossip pool.
+func RequireOnlyGossipKey(t require.TestingT, keyring *api.KeyringResponse, key string) {
+	require.Len(t, keyring.Keys, 1, fmt.Sprintf("expected one gossip key but got %d", len(keyring.Keys)))
+	require.Equal(t, keyring.NumNodes, keyring.Keys[key], "gossip key is not installed on all nodes")
+	require.Equal(t, keyring.NumNodes, keyring.PrimaryKeys[key], "gossip key is not the primary key on all nodes")
+}
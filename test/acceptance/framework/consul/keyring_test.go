@@ -0,0 +1,17 @@
+package consul
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateGossipKey(t *testing.T) {
+	key := GenerateGossipKey(t)
+
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	require.NoError(t, err)
+	require.Len(t, decoded, gossipKeyLength)
+	require.NotEqual(t, key, GenerateGossipKey(t))
+}
@@ -0,0 +1,58 @@
+package consul
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+// defaultNamespace is the name of the Consul namespace
+// that always exists and can't be deleted.
+const defaultNamespace = "default"
+
+// CleanupNamespaces deletes the Consul namespaces whose names start with prefix.
+// Enterprise tests register it with helpers.Cleanup so that namespaces created
+// by the tests or by consul-k8s, e.g. the destination and mirrored namespaces,
+// don't leak into later test cases. Suites that share Consul servers should
+// use different prefixes (see -consul-namespace-prefix) so that they don't
+// delete each other's namespaces. If prefix is empty, it doesn't delete
+// anything because the namespaces of the suite can't be told apart from others.
+func CleanupNamespaces(t *testing.T, client *api.Client, prefix string) {
+	t.Helper()
+
+	if prefix == "" {
+		logger.Log(t, "skipping the cleanup of Consul namespaces because -consul-namespace-prefix is not set")
+		return
+	}
+
+	namespaces, _, err := client.Namespaces().List(nil)
+	require.NoError(t, err)
+	for _, name := range namespacesToDelete(namespaces, prefix) {
+		logger.Logf(t, "deleting Consul namespace %q", name)
+		_, err := client.Namespaces().Delete(name, nil)
+		require.NoError(t, err)
+	}
+}
+
+// namespacesToDelete returns the names of the namespaces that start with prefix,
+// skipping the default namespace and namespaces already marked for deletion.
+// It returns no namespaces if prefix is empty.
+func namespacesToDelete(namespaces []*api.Namespace, prefix string) []string {
+	if prefix == "" {
+		return nil
+	}
+
+	var names []string
+	for _, namespace := range namespaces {
+		if namespace.Name == defaultNamespace || namespace.DeletedAt != nil {
+			continue
+		}
+		if strings.HasPrefix(namespace.Name, prefix) {
+			names = append(names, namespace.Name)
+		}
+	}
+	return names
+}
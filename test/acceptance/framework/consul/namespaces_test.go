@@ -0,0 +1,49 @@
+package consul
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespacesToDelete(t *testing.T) {
+	deletedAt := time.Now()
+	namespaces := []*api.Namespace{
+		{Name: "default"},
+		{Name: "from-k8s"},
+		{Name: "suite-a-from-k8s"},
+		{Name: "suite-a-ns1"},
+		{Name: "suite-a-deleted", DeletedAt: &deletedAt},
+		{Name: "suite-b-ns1"},
+	}
+
+	cases := map[string]struct {
+		prefix string
+		exp    []string
+	}{
+		"no prefix deletes nothing": {
+			prefix: "",
+			exp:    nil,
+		},
+		"prefix": {
+			prefix: "suite-a-",
+			exp:    []string{"suite-a-from-k8s", "suite-a-ns1"},
+		},
+		"no matches": {
+			prefix: "suite-c-",
+			exp:    nil,
+		},
+		"default namespace is never deleted": {
+			prefix: "default",
+			exp:    nil,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.exp, namespacesToDelete(namespaces, c.prefix))
+		})
+	}
+}
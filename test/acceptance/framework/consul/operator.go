@@ -0,0 +1,48 @@
+package consul
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+// RequireRaftPeers fails unless the Raft configuration of the datacenter
+// client is connected to has exactly expectedPeers servers, all of which
+// are voters, and one of them is the leader. It takes a require.TestingT
+// so that it can be used with retry.R to wait for servers to join.
+func RequireRaftPeers(r require.TestingT, client *api.Client, expectedPeers int) {
+	raftConfig, err := client.Operator().RaftGetConfiguration(nil)
+	require.NoError(r, err)
+	require.NoError(r, checkRaftPeers(raftConfig, expectedPeers))
+}
+
+// checkRaftPeers returns an error if raftConfig doesn't have exactly
+// expectedPeers voters and a single leader.
+func checkRaftPeers(raftConfig *api.RaftConfiguration, expectedPeers int) error {
+	if len(raftConfig.Servers) != expectedPeers {
+		return fmt.Errorf("expected %d raft peers but got %d: %s", expectedPeers, len(raftConfig.Servers), raftPeerNames(raftConfig))
+	}
+	leaders := 0
+	for _, server := range raftConfig.Servers {
+		if !server.Voter {
+			return fmt.Errorf("raft peer %s is not a voter", server.Node)
+		}
+		if server.Leader {
+			leaders++
+		}
+	}
+	if leaders != 1 {
+		return fmt.Errorf("expected 1 raft leader but got %d: %s", leaders, raftPeerNames(raftConfig))
+	}
+	return nil
+}
+
+// raftPeerNames returns the node names of the raft peers for error messages.
+func raftPeerNames(raftConfig *api.RaftConfiguration) []string {
+	var names []string
+	for _, server := range raftConfig.Servers {
+		names = append(names, server.Node)
+	}
+	return names
+}
@@ -0,0 +1,63 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRaftPeers(t *testing.T) {
+	cases := map[string]struct {
+		servers       []*api.RaftServer
+		expectedPeers int
+		expErr        string
+	}{
+		"single server": {
+			servers:       []*api.RaftServer{{Node: "server-0", Voter: true, Leader: true}},
+			expectedPeers: 1,
+		},
+		"three servers": {
+			servers: []*api.RaftServer{
+				{Node: "server-0", Voter: true},
+				{Node: "server-1", Voter: true, Leader: true},
+				{Node: "server-2", Voter: true},
+			},
+			expectedPeers: 3,
+		},
+		"missing peer": {
+			servers:       []*api.RaftServer{{Node: "server-0", Voter: true, Leader: true}, {Node: "server-1", Voter: true}},
+			expectedPeers: 3,
+			expErr:        "expected 3 raft peers but got 2: [server-0 server-1]",
+		},
+		"non-voter": {
+			servers: []*api.RaftServer{
+				{Node: "server-0", Voter: true, Leader: true},
+				{Node: "server-1", Voter: true},
+				{Node: "server-2"},
+			},
+			expectedPeers: 3,
+			expErr:        "raft peer server-2 is not a voter",
+		},
+		"no leader": {
+			servers: []*api.RaftServer{
+				{Node: "server-0", Voter: true},
+				{Node: "server-1", Voter: true},
+				{Node: "server-2", Voter: true},
+			},
+			expectedPeers: 3,
+			expErr:        "expected 1 raft leader but got 0: [server-0 server-1 server-2]",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := checkRaftPeers(&api.RaftConfiguration{Servers: c.servers}, c.expectedPeers)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package consul
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+// configEntryKinds are the kinds of config entries that State tracks,
+// in the order they need to be deleted in. Consul rejects deleting entries
+// that others depend on, e.g. the service-defaults that set the protocol
+// of a service with a service-router, so dependents come first.
+var configEntryKinds = []string{
+	api.ServiceIntentions,
+	api.IngressGateway,
+	api.TerminatingGateway,
+	api.ServiceRouter,
+	api.ServiceSplitter,
+	api.ServiceResolver,
+	api.ServiceDefaults,
+	api.ProxyDefaults,
+}
+
+// State is the state of a Consul cluster, saved with SaveState,
+// that tests sharing the cluster reset it to with Reset
+// so that they don't affect each other.
+type State struct {
+	consulNamespaces bool
+	configEntries    map[string]api.ConfigEntry
+}
+
+// SaveState saves the config entries in Consul, including intentions,
+// such as the global proxy-defaults that the connect injector writes on startup.
+// If consulNamespaces is true, it saves the entries in all Consul namespaces.
+func SaveState(t *testing.T, client *api.Client, consulNamespaces bool) *State {
+	t.Helper()
+
+	state := &State{consulNamespaces: consulNamespaces, configEntries: map[string]api.ConfigEntry{}}
+	for _, entry := range state.listConfigEntries(t, client) {
+		state.configEntries[configEntryKey(entry)] = entry
+	}
+	return state
+}
+
+// Reset deletes the config entries that have been written since the state
+// was saved and restores the saved entries that have been changed or deleted.
+// If the state includes Consul namespaces, it also deletes the Consul namespaces
+// that start with namespacePrefix along with everything registered in them,
+// unless namespacePrefix is empty (see CleanupNamespaces).
+// Services of Kubernetes pods are deregistered when the pods are deleted,
+// so they aren't deleted here.
+func (s *State) Reset(t *testing.T, client *api.Client, namespacePrefix string) {
+	t.Helper()
+
+	current := map[string]api.ConfigEntry{}
+	for _, entry := range s.listConfigEntries(t, client) {
+		key := configEntryKey(entry)
+		current[key] = entry
+		if _, ok := s.configEntries[key]; ok {
+			continue
+		}
+		logger.Logf(t, "deleting %s config entry %q", entry.GetKind(), entry.GetName())
+		_, err := client.ConfigEntries().Delete(entry.GetKind(), entry.GetName(), &api.WriteOptions{Namespace: entry.GetNamespace()})
+		require.NoError(t, err)
+	}
+
+	for key, saved := range s.configEntries {
+		if entry, ok := current[key]; ok && entry.GetModifyIndex() == saved.GetModifyIndex() {
+			continue
+		}
+		logger.Logf(t, "restoring %s config entry %q", saved.GetKind(), saved.GetName())
+		_, _, err := client.ConfigEntries().Set(saved, &api.WriteOptions{Namespace: saved.GetNamespace()})
+		require.NoError(t, err)
+	}
+
+	if s.consulNamespaces {
+		CleanupNamespaces(t, client, namespacePrefix)
+	}
+}
+
+// listConfigEntries returns the config entries of all configEntryKinds,
+// sorted in the order they can be deleted in.
+func (s *State) listConfigEntries(t *testing.T, client *api.Client) []api.ConfigEntry {
+	t.Helper()
+
+	var queryOpts *api.QueryOptions
+	if s.consulNamespaces {
+		queryOpts = &api.QueryOptions{Namespace: "*"}
+	}
+
+	var entries []api.ConfigEntry
+	for _, kind := range configEntryKinds {
+		kindEntries, _, err := client.ConfigEntries().List(kind, queryOpts)
+		require.NoError(t, err)
+		entries = append(entries, kindEntries...)
+	}
+	sortForDeletion(entries)
+	return entries
+}
+
+// sortForDeletion sorts entries in the order of configEntryKinds,
+// keeping the order of entries of the same kind.
+func sortForDeletion(entries []api.ConfigEntry) {
+	order := map[string]int{}
+	for i, kind := range configEntryKinds {
+		order[kind] = i
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return order[entries[i].GetKind()] < order[entries[j].GetKind()]
+	})
+}
+
+// configEntryKey returns a key that identifies entry within a Consul cluster.
+func configEntryKey(entry api.ConfigEntry) string {
+	return entry.GetKind() + "/" + entry.GetNamespace() + "/" + entry.GetName()
+}
@@ -0,0 +1,31 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortForDeletion(t *testing.T) {
+	entries := []api.ConfigEntry{
+		&api.ProxyConfigEntry{Kind: api.ProxyDefaults, Name: api.ProxyConfigGlobal},
+		&api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "foo"},
+		&api.ServiceRouterConfigEntry{Kind: api.ServiceRouter, Name: "foo"},
+		&api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "bar"},
+		&api.ServiceIntentionsConfigEntry{Kind: api.ServiceIntentions, Name: "foo"},
+	}
+	sortForDeletion(entries)
+
+	var keys []string
+	for _, entry := range entries {
+		keys = append(keys, configEntryKey(entry))
+	}
+	require.Equal(t, []string{
+		"service-intentions//foo",
+		"service-router//foo",
+		"service-defaults//foo",
+		"service-defaults//bar",
+		"proxy-defaults//global",
+	}, keys)
+}
@@ -0,0 +1,119 @@
+package consul
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// caCertSecretKey is the key of the CA certificate
+	// in the secret created by createCASecrets.
+	caCertSecretKey = "tls.crt"
+	// caKeySecretKey is the key of the CA private key
+	// in the secret created by createCASecrets.
+	caKeySecretKey = "tls.key"
+)
+
+// CA is a certificate authority to use for TLS instead of the CA
+// that the Helm chart generates. See WithCustomCA.
+type CA struct {
+	// CertPEM is the PEM-encoded CA certificate.
+	CertPEM []byte
+	// KeyPEM is the PEM-encoded CA private key.
+	KeyPEM []byte
+}
+
+// GenerateCA generates a self-signed CA with an ECDSA key, similar to
+// the one generated by 'consul tls ca create', with the provided common name.
+func GenerateCA(t *testing.T, commonName string) *CA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-1 * time.Minute),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &CA{
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// CertPool returns a certificate pool containing only the CA certificate
+// so that it can be used to verify certificates signed by the CA.
+func (c *CA) CertPool(t *testing.T) *x509.CertPool {
+	t.Helper()
+
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(c.CertPEM), "failed to parse CA certificate")
+	return pool
+}
+
+// caCertSecretName returns the name of the secret with the custom CA certificate
+// for the release. It contains the release name so that Destroy deletes it.
+func caCertSecretName(releaseName string) string {
+	return fmt.Sprintf("%s-consul-custom-ca-cert", releaseName)
+}
+
+// caKeySecretName returns the name of the secret with the custom CA private key
+// for the release. It contains the release name so that Destroy deletes it.
+func caKeySecretName(releaseName string) string {
+	return fmt.Sprintf("%s-consul-custom-ca-key", releaseName)
+}
+
+// createCASecrets creates the Kubernetes secrets with the custom CA certificate
+// and private key if the cluster was created with WithCustomCA.
+// They need to exist before the Helm install because the chart's
+// TLS init job, which runs as a pre-install hook, uses them to sign
+// the server certificates.
+func (h *HelmCluster) createCASecrets(t *testing.T) {
+	t.Helper()
+
+	if h.customCA == nil {
+		return
+	}
+
+	secrets := map[string]map[string][]byte{
+		caCertSecretName(h.releaseName): {caCertSecretKey: h.customCA.CertPEM},
+		caKeySecretName(h.releaseName):  {caKeySecretKey: h.customCA.KeyPEM},
+	}
+	for name, data := range secrets {
+		logger.Logf(t, "creating CA secret %s", name)
+		_, err := h.kubernetesClient.CoreV1().Secrets(h.helmOptions.KubectlOptions.Namespace).Create(helpers.TestContext(t), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: testlabels.ForTest(t)},
+			Data:       data,
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,64 @@
+package consul
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCA(t *testing.T) {
+	ca := GenerateCA(t, "Test CA")
+
+	certBlock, _ := pem.Decode(ca.CertPEM)
+	require.NotNil(t, certBlock)
+	require.Equal(t, "CERTIFICATE", certBlock.Type)
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	require.NoError(t, err)
+	require.True(t, cert.IsCA)
+	require.Equal(t, "Test CA", cert.Subject.CommonName)
+	require.NotZero(t, cert.KeyUsage&x509.KeyUsageCertSign)
+
+	keyBlock, _ := pem.Decode(ca.KeyPEM)
+	require.NotNil(t, keyBlock)
+	require.Equal(t, "EC PRIVATE KEY", keyBlock.Type)
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	require.NoError(t, err)
+	require.Equal(t, cert.PublicKey, key.Public())
+
+	// The certificate should be verifiable with the CA's own cert pool.
+	_, err = cert.Verify(x509.VerifyOptions{Roots: ca.CertPool(t)})
+	require.NoError(t, err)
+
+	// Each CA should be unique.
+	require.NotEqual(t, ca.CertPEM, GenerateCA(t, "Test CA").CertPEM)
+}
+
+func TestNewHelmCluster_CustomCA(t *testing.T) {
+	caValues := []string{
+		"global.tls.caCert.secretName",
+		"global.tls.caCert.secretKey",
+		"global.tls.caKey.secretName",
+		"global.tls.caKey.secretKey",
+	}
+
+	t.Run("CA values are not set without WithCustomCA", func(t *testing.T) {
+		cluster := NewHelmCluster(t, nil, &ctx{}, &config.TestConfig{}, "test").(*HelmCluster)
+		for _, key := range caValues {
+			require.NotContains(t, cluster.helmOptions.SetValues, key)
+		}
+		require.Nil(t, cluster.customCA)
+	})
+
+	t.Run("CA values point at the custom CA secrets", func(t *testing.T) {
+		ca := &CA{CertPEM: []byte("cert"), KeyPEM: []byte("key")}
+		cluster := NewHelmCluster(t, nil, &ctx{}, &config.TestConfig{}, "test", WithCustomCA(ca)).(*HelmCluster)
+		require.Equal(t, "test-consul-custom-ca-cert", cluster.helmOptions.SetValues["global.tls.caCert.secretName"])
+		require.Equal(t, "tls.crt", cluster.helmOptions.SetValues["global.tls.caCert.secretKey"])
+		require.Equal(t, "test-consul-custom-ca-key", cluster.helmOptions.SetValues["global.tls.caKey.secretName"])
+		require.Equal(t, "tls.key", cluster.helmOptions.SetValues["global.tls.caKey.secretKey"])
+		require.Equal(t, ca, cluster.customCA)
+	})
+}
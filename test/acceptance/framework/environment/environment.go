@@ -29,6 +29,11 @@ type TestEnvironment interface {
 type TestContext interface {
 	KubectlOptions(t *testing.T) *k8s.KubectlOptions
 	KubernetesClient(t *testing.T) kubernetes.Interface
+	// KubernetesVersion returns the version of the Kubernetes cluster.
+	KubernetesVersion(t *testing.T) KubernetesVersion
+	// HasAPIResource returns true if the Kubernetes cluster serves
+	// resources of kind in groupVersion, e.g. "policy/v1beta1".
+	HasAPIResource(t *testing.T, groupVersion, kind string) bool
 }
 
 type KubernetesEnvironment struct {
@@ -37,6 +42,7 @@ type KubernetesEnvironment struct {
 
 func NewKubernetesEnvironmentFromConfig(config *config.TestConfig) *KubernetesEnvironment {
 	defaultContext := NewContext(config.KubeNamespace, config.Kubeconfig, config.KubeContext)
+	defaultContext.expectedVersion = config.KubeVersion
 
 	// Create a kubernetes environment with default context.
 	kenv := &KubernetesEnvironment{
@@ -47,7 +53,9 @@ func NewKubernetesEnvironmentFromConfig(config *config.TestConfig) *KubernetesEn
 
 	// Add secondary context if multi cluster tests are enabled.
 	if config.EnableMultiCluster {
-		kenv.contexts[SecondaryContextName] = NewContext(config.SecondaryKubeNamespace, config.SecondaryKubeconfig, config.SecondaryKubeContext)
+		secondaryContext := NewContext(config.SecondaryKubeNamespace, config.SecondaryKubeconfig, config.SecondaryKubeContext)
+		secondaryContext.expectedVersion = config.KubeVersion
+		kenv.contexts[SecondaryContextName] = secondaryContext
 	}
 
 	return kenv
@@ -72,13 +80,18 @@ type kubernetesContext struct {
 	kubeContextName  string
 	namespace        string
 
+	// expectedVersion is the version of Kubernetes from the -kube-version flag.
+	// If it's set, the version of the cluster must match it.
+	expectedVersion string
+
 	client  kubernetes.Interface
 	options *k8s.KubectlOptions
+	version *KubernetesVersion
 
 	logDirectory string
 }
 
-func (k kubernetesContext) KubectlOptions(t *testing.T) *k8s.KubectlOptions {
+func (k *kubernetesContext) KubectlOptions(t *testing.T) *k8s.KubectlOptions {
 	if k.options != nil {
 		return k.options
 	}
@@ -108,7 +121,7 @@ func (k kubernetesContext) KubectlOptions(t *testing.T) *k8s.KubectlOptions {
 	return k.options
 }
 
-func (k kubernetesContext) KubernetesClient(t *testing.T) kubernetes.Interface {
+func (k *kubernetesContext) KubernetesClient(t *testing.T) kubernetes.Interface {
 	if k.client != nil {
 		return k.client
 	}
@@ -118,6 +131,24 @@ func (k kubernetesContext) KubernetesClient(t *testing.T) kubernetes.Interface {
 	return k.client
 }
 
+func (k *kubernetesContext) KubernetesVersion(t *testing.T) KubernetesVersion {
+	if k.version != nil {
+		return *k.version
+	}
+
+	version := requireKubernetesVersion(t, k.KubernetesClient(t).Discovery(), k.expectedVersion)
+	k.version = &version
+
+	return version
+}
+
+func (k *kubernetesContext) HasAPIResource(t *testing.T, groupVersion, kind string) bool {
+	ok, err := hasAPIResource(k.KubernetesClient(t).Discovery(), groupVersion, kind)
+	require.NoError(t, err)
+
+	return ok
+}
+
 func NewContext(namespace, pathToKubeConfig, kubeContextName string) *kubernetesContext {
 	return &kubernetesContext{
 		namespace:        namespace,
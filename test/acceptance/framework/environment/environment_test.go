@@ -0,0 +1,87 @@
+package environment
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/stretchr/testify/require"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: current
+clusters:
+- name: cluster
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: user
+  user:
+    token: token
+contexts:
+- name: current
+  context:
+    cluster: cluster
+    user: user
+    namespace: context-ns
+- name: other
+  context:
+    cluster: cluster
+    user: user
+`
+
+// Test that the kubeconfig, context and namespace from the test config
+// are used for the KubectlOptions of the default and secondary contexts.
+func TestKubernetesEnvironment_KubectlOptions(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, ioutil.WriteFile(kubeconfig, []byte(testKubeconfig), 0600))
+
+	cases := map[string]struct {
+		kubeContext  string
+		namespace    string
+		expNamespace string
+	}{
+		"namespace is set from the flag": {
+			namespace:    "flag-ns",
+			expNamespace: "flag-ns",
+		},
+		"namespace is set from the current context": {
+			expNamespace: "context-ns",
+		},
+		"namespace is set from the context from the flag": {
+			kubeContext:  "other",
+			expNamespace: "default",
+		},
+		"namespace from the flag overrides the context": {
+			kubeContext:  "current",
+			namespace:    "flag-ns",
+			expNamespace: "flag-ns",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			env := NewKubernetesEnvironmentFromConfig(&config.TestConfig{
+				Kubeconfig:             kubeconfig,
+				KubeContext:            c.kubeContext,
+				KubeNamespace:          c.namespace,
+				EnableMultiCluster:     true,
+				SecondaryKubeconfig:    kubeconfig,
+				SecondaryKubeContext:   c.kubeContext,
+				SecondaryKubeNamespace: c.namespace,
+			})
+
+			for _, ctx := range []TestContext{env.DefaultContext(t), env.Context(t, SecondaryContextName)} {
+				options := ctx.KubectlOptions(t)
+				require.Equal(t, kubeconfig, options.ConfigPath)
+				require.Equal(t, c.kubeContext, options.ContextName)
+				require.Equal(t, c.expNamespace, options.Namespace)
+
+				// The options should be resolved once and reused.
+				require.Same(t, options, ctx.KubectlOptions(t))
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package environment
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const (
+	ProviderKind = "kind"
+	ProviderGKE  = "gke"
+	ProviderEKS  = "eks"
+	ProviderAKS  = "aks"
+)
+
+// Providers is the list of supported values for the -provider flag.
+var Providers = []string{ProviderKind, ProviderGKE, ProviderEKS, ProviderAKS}
+
+// Provisioner makes Kubernetes clusters available to the test suite
+// and tears them down once the suite finishes.
+type Provisioner interface {
+	// Provision makes the Kubernetes cluster with the given name available
+	// and returns the path to its kubeconfig file and the name of its context.
+	Provision(name string) (kubeconfig, kubeContext string, err error)
+	// Deprovision tears down the Kubernetes cluster with the given name.
+	Deprovision(name string) error
+}
+
+// NewProvisioner returns a Provisioner for the given provider.
+// The kind provider creates ephemeral clusters using the kind CLI.
+// Cloud providers expect their clusters to be created ahead of time
+// (for example, with the Terraform configurations in test/terraform)
+// and use the provided kubeconfig and kubeContext to talk to them.
+func NewProvisioner(provider, kubeconfig, kubeContext string) (Provisioner, error) {
+	switch provider {
+	case ProviderKind:
+		return &kindProvisioner{}, nil
+	case ProviderGKE, ProviderEKS, ProviderAKS:
+		return &kubeconfigProvisioner{kubeconfig: kubeconfig, kubeContext: kubeContext}, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider %q", provider)
+	}
+}
+
+// kindProvisioner creates and deletes local kind clusters.
+type kindProvisioner struct {
+	kubeconfigDir string
+}
+
+func (k *kindProvisioner) Provision(name string) (string, string, error) {
+	if k.kubeconfigDir == "" {
+		dir, err := ioutil.TempDir("", "consul-test-kind")
+		if err != nil {
+			return "", "", err
+		}
+		k.kubeconfigDir = dir
+	}
+
+	kubeconfig := filepath.Join(k.kubeconfigDir, name)
+	if err := runCommand("kind", "create", "cluster", "--name", name, "--kubeconfig", kubeconfig, "--wait", "5m"); err != nil {
+		return "", "", err
+	}
+
+	// kind names the context of a cluster "kind-<cluster name>".
+	return kubeconfig, fmt.Sprintf("kind-%s", name), nil
+}
+
+func (k *kindProvisioner) Deprovision(name string) error {
+	if err := runCommand("kind", "delete", "cluster", "--name", name); err != nil {
+		return err
+	}
+	return k.removeKubeconfig(name)
+}
+
+// removeKubeconfig removes the kubeconfig file of the cluster with the given name
+// and the temporary directory of the kubeconfig files once it is empty.
+func (k *kindProvisioner) removeKubeconfig(name string) error {
+	if k.kubeconfigDir == "" {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(k.kubeconfigDir, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	files, err := ioutil.ReadDir(k.kubeconfigDir)
+	if err != nil {
+		return err
+	}
+	// Keep the directory for the kubeconfig files of other clusters.
+	if len(files) > 0 {
+		return nil
+	}
+	if err := os.Remove(k.kubeconfigDir); err != nil {
+		return err
+	}
+	k.kubeconfigDir = ""
+	return nil
+}
+
+// kubeconfigProvisioner uses a cluster that already exists
+// and was provided via a kubeconfig file and context.
+// It never creates or deletes any clusters.
+type kubeconfigProvisioner struct {
+	kubeconfig  string
+	kubeContext string
+}
+
+func (k *kubeconfigProvisioner) Provision(_ string) (string, string, error) {
+	return k.kubeconfig, k.kubeContext, nil
+}
+
+func (k *kubeconfigProvisioner) Deprovision(_ string) error {
+	return nil
+}
+
+// runCommand runs the command with the provided args,
+// streaming its output to the output of the test binary.
+func runCommand(command string, args ...string) error {
+	cmd := exec.Command(command, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s %v: %s", command, args, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package environment
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProvisioner(t *testing.T) {
+	tests := []struct {
+		provider string
+		want     Provisioner
+		expErr   string
+	}{
+		{
+			provider: ProviderKind,
+			want:     &kindProvisioner{},
+		},
+		{
+			provider: ProviderGKE,
+			want:     &kubeconfigProvisioner{kubeconfig: "kubeconfig", kubeContext: "context"},
+		},
+		{
+			provider: ProviderEKS,
+			want:     &kubeconfigProvisioner{kubeconfig: "kubeconfig", kubeContext: "context"},
+		},
+		{
+			provider: ProviderAKS,
+			want:     &kubeconfigProvisioner{kubeconfig: "kubeconfig", kubeContext: "context"},
+		},
+		{
+			provider: "foo",
+			expErr:   `unsupported provider "foo"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			provisioner, err := NewProvisioner(tt.provider, "kubeconfig", "context")
+			if tt.expErr != "" {
+				require.EqualError(t, err, tt.expErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, provisioner)
+			}
+		})
+	}
+}
+
+// Test that the kind provisioner removes the kubeconfig file of each
+// deprovisioned cluster and the temporary directory after the last one.
+func TestKindProvisioner_RemoveKubeconfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"primary", "secondary"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("kubeconfig"), 0600))
+	}
+	provisioner := &kindProvisioner{kubeconfigDir: dir}
+
+	require.NoError(t, provisioner.removeKubeconfig("primary"))
+	require.NoFileExists(t, filepath.Join(dir, "primary"))
+	require.FileExists(t, filepath.Join(dir, "secondary"))
+
+	require.NoError(t, provisioner.removeKubeconfig("secondary"))
+	require.NoDirExists(t, dir)
+	require.Empty(t, provisioner.kubeconfigDir)
+
+	// Deprovisioning a cluster that wasn't provisioned does nothing.
+	require.NoError(t, provisioner.removeKubeconfig("primary"))
+}
+
+// Test that the kubeconfig provisioner returns the provided
+// kubeconfig and context and doesn't error on deprovision.
+func TestKubeconfigProvisioner(t *testing.T) {
+	provisioner := &kubeconfigProvisioner{kubeconfig: "kubeconfig", kubeContext: "context"}
+
+	kubeconfig, kubeContext, err := provisioner.Provision("test")
+	require.NoError(t, err)
+	require.Equal(t, "kubeconfig", kubeconfig)
+	require.Equal(t, "context", kubeContext)
+
+	require.NoError(t, provisioner.Deprovision("test"))
+}
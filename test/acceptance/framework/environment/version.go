@@ -0,0 +1,116 @@
+package environment
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+)
+
+// versionRegexp matches the major and minor version at the start of
+// a Kubernetes version, e.g. "1.19" in "v1.19.4-gke.1700" or "1.19+".
+var versionRegexp = regexp.MustCompile(`^v?(\d+)\.(\d+)`)
+
+// KubernetesVersion is the major and minor version of a Kubernetes cluster.
+// Patch versions are ignored because APIs and features only change between minor versions.
+type KubernetesVersion struct {
+	Major int
+	Minor int
+}
+
+// ParseKubernetesVersion parses the major and minor version from version,
+// which can be a version like "1.19" or a git version like "v1.19.4-gke.1700".
+func ParseKubernetesVersion(version string) (KubernetesVersion, error) {
+	matches := versionRegexp.FindStringSubmatch(version)
+	if matches == nil {
+		return KubernetesVersion{}, fmt.Errorf("invalid Kubernetes version %q", version)
+	}
+	// The regexp only matches digits, so these can't fail.
+	major, _ := strconv.Atoi(matches[1])
+	minor, _ := strconv.Atoi(matches[2])
+	return KubernetesVersion{Major: major, Minor: minor}, nil
+}
+
+// AtLeast returns true if v is the same as or newer than major.minor.
+func (v KubernetesVersion) AtLeast(major, minor int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	return v.Minor >= minor
+}
+
+func (v KubernetesVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// serverVersion returns the version of the Kubernetes API server.
+// Some providers report minor versions like "19+" so the git version,
+// which is always a semantic version, is parsed instead.
+func serverVersion(client discovery.DiscoveryInterface) (KubernetesVersion, error) {
+	info, err := client.ServerVersion()
+	if err != nil {
+		return KubernetesVersion{}, err
+	}
+	return ParseKubernetesVersion(info.GitVersion)
+}
+
+// hasAPIResource returns true if the API server serves resources
+// of the given kind in the given group version, e.g. "policy/v1beta1".
+func hasAPIResource(client discovery.DiscoveryInterface, groupVersion, kind string) (bool, error) {
+	resources, err := client.ServerResourcesForGroupVersion(groupVersion)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Kind == kind {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SkipUnlessKubernetesVersion skips the test if the Kubernetes cluster
+// of ctx is older than major.minor, for example, because a feature
+// the test relies on isn't available in older versions.
+func SkipUnlessKubernetesVersion(t *testing.T, ctx TestContext, major, minor int) {
+	t.Helper()
+
+	version := ctx.KubernetesVersion(t)
+	if !version.AtLeast(major, minor) {
+		t.Skipf("skipping this test because it requires Kubernetes %d.%d or newer, but the cluster runs Kubernetes %s", major, minor, version)
+	}
+}
+
+// SkipUnlessAPIResource skips the test if the Kubernetes cluster of ctx
+// doesn't serve resources of kind in groupVersion, for example,
+// because the API was removed in newer versions of Kubernetes.
+func SkipUnlessAPIResource(t *testing.T, ctx TestContext, groupVersion, kind string) {
+	t.Helper()
+
+	if !ctx.HasAPIResource(t, groupVersion, kind) {
+		t.Skipf("skipping this test because the cluster runs Kubernetes %s, which doesn't serve %s %s", ctx.KubernetesVersion(t), groupVersion, kind)
+	}
+}
+
+// requireKubernetesVersion returns the version of the Kubernetes cluster
+// of client, failing the test if it doesn't match expectedVersion.
+// expectedVersion is ignored if it's empty.
+func requireKubernetesVersion(t *testing.T, client discovery.DiscoveryInterface, expectedVersion string) KubernetesVersion {
+	t.Helper()
+
+	version, err := serverVersion(client)
+	require.NoError(t, err)
+	if expectedVersion != "" {
+		expected, err := ParseKubernetesVersion(expectedVersion)
+		require.NoError(t, err)
+		require.Equal(t, expected, version, "the cluster runs Kubernetes %s but -kube-version is %s", version, expectedVersion)
+	}
+	return version
+}
@@ -0,0 +1,100 @@
+package environment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseKubernetesVersion(t *testing.T) {
+	cases := map[string]struct {
+		version    string
+		expVersion KubernetesVersion
+		expErr     string
+	}{
+		"major and minor version": {
+			version:    "1.19",
+			expVersion: KubernetesVersion{Major: 1, Minor: 19},
+		},
+		"git version": {
+			version:    "v1.19.4",
+			expVersion: KubernetesVersion{Major: 1, Minor: 19},
+		},
+		"provider git version": {
+			version:    "v1.18.12-gke.1210",
+			expVersion: KubernetesVersion{Major: 1, Minor: 18},
+		},
+		"minor version with suffix": {
+			version:    "1.20+",
+			expVersion: KubernetesVersion{Major: 1, Minor: 20},
+		},
+		"major version only": {
+			version: "1",
+			expErr:  `invalid Kubernetes version "1"`,
+		},
+		"not a version": {
+			version: "latest",
+			expErr:  `invalid Kubernetes version "latest"`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			version, err := ParseKubernetesVersion(c.version)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expVersion, version)
+		})
+	}
+}
+
+func TestKubernetesVersion_AtLeast(t *testing.T) {
+	version := KubernetesVersion{Major: 1, Minor: 19}
+
+	require.True(t, version.AtLeast(1, 18))
+	require.True(t, version.AtLeast(1, 19))
+	require.False(t, version.AtLeast(1, 20))
+	require.True(t, version.AtLeast(0, 30))
+	require.False(t, version.AtLeast(2, 0))
+	require.Equal(t, "1.19", version.String())
+}
+
+func TestRequireKubernetesVersion(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	// Some providers set the minor version to e.g. "19+", so the git version is used.
+	client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{
+		Major:      "1",
+		Minor:      "19+",
+		GitVersion: "v1.19.4-eks-49a6c0",
+	}
+
+	require.Equal(t, KubernetesVersion{Major: 1, Minor: 19}, requireKubernetesVersion(t, client.Discovery(), ""))
+	require.Equal(t, KubernetesVersion{Major: 1, Minor: 19}, requireKubernetesVersion(t, client.Discovery(), "1.19"))
+}
+
+func TestHasAPIResource(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "policy/v1beta1",
+			APIResources: []metav1.APIResource{
+				{Name: "poddisruptionbudgets", Kind: "PodDisruptionBudget"},
+			},
+		},
+	}
+
+	ok, err := hasAPIResource(client.Discovery(), "policy/v1beta1", "PodDisruptionBudget")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = hasAPIResource(client.Discovery(), "policy/v1beta1", "PodSecurityPolicy")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,280 @@
+package envoy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/stretchr/testify/require"
+)
+
+// AdminPort is the port of the Envoy admin API in connect-injected sidecars.
+const AdminPort = 19000
+
+// Admin is a client for the admin API of an Envoy sidecar.
+type Admin struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewAdmin port-forwards to the admin API of the Envoy sidecar running in the pod
+// with the given name and returns a client for it. The port-forward
+// is closed when the test finishes.
+func NewAdmin(t *testing.T, options *terratestk8s.KubectlOptions, podName string) *Admin {
+	t.Helper()
+
+	endpoint := k8s.PortForward(t, options, terratestk8s.ResourceTypePod, podName, AdminPort)
+
+	return &Admin{
+		baseURL:    "http://" + endpoint,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ConfigDump fetches and parses the /config_dump endpoint.
+func (a *Admin) ConfigDump(t require.TestingT) *ConfigDump {
+	var dump ConfigDump
+	a.get(t, "/config_dump", &dump)
+	return &dump
+}
+
+// Clusters fetches and parses the /clusters endpoint,
+// which reports the upstream hosts of each cluster and their health.
+func (a *Admin) Clusters(t require.TestingT) []ClusterStatus {
+	var clusters struct {
+		ClusterStatuses []ClusterStatus `json:"cluster_statuses"`
+	}
+	a.get(t, "/clusters?format=json", &clusters)
+	return clusters.ClusterStatuses
+}
+
+// Certs fetches and parses the /certs endpoint, which reports the
+// CA certificates and certificate chains that the sidecar uses for mTLS.
+func (a *Admin) Certs(t require.TestingT) []Certificates {
+	var certs struct {
+		Certificates []Certificates `json:"certificates"`
+	}
+	a.get(t, "/certs", &certs)
+	return certs.Certificates
+}
+
+// Stats fetches the counters and gauges from the /stats endpoint whose names
+// match the regular expression filter, e.g. `^cluster\.dc2\..*\.upstream_cx_total$`,
+// and returns their values by name.
+func (a *Admin) Stats(t require.TestingT, filter string) map[string]int64 {
+	var stats struct {
+		Stats []struct {
+			Name  string `json:"name"`
+			Value *int64 `json:"value"`
+		} `json:"stats"`
+	}
+	a.get(t, "/stats?format=json&filter="+url.QueryEscape(filter), &stats)
+
+	values := map[string]int64{}
+	for _, stat := range stats.Stats {
+		// Histograms are listed without a name or a value.
+		if stat.Name != "" && stat.Value != nil {
+			values[stat.Name] = *stat.Value
+		}
+	}
+	return values
+}
+
+// LogLevels fetches and parses the /logging endpoint,
+// which reports the log level of each of Envoy's loggers, e.g. "upstream": "debug".
+func (a *Admin) LogLevels(t require.TestingT) map[string]string {
+	// Envoy only allows POST requests to /logging because it can also change
+	// the log levels. Without query parameters it only lists them.
+	resp, err := a.httpClient.Post(a.baseURL+"/logging", "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response from /logging: %s", body)
+	return parseLogLevels(string(body))
+}
+
+// parseLogLevels parses the response of the /logging endpoint, which lists
+// the loggers after an "active loggers:" line as indented "name: level" lines.
+func parseLogLevels(body string) map[string]string {
+	levels := map[string]string{}
+	for _, line := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(line, " ") {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		levels[parts[0]] = strings.TrimSpace(parts[1])
+	}
+	return levels
+}
+
+// get makes a GET request to the admin API and decodes the JSON response into out.
+func (a *Admin) get(t require.TestingT, path string, out interface{}) {
+	resp, err := a.httpClient.Get(a.baseURL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response from %s: %s", path, body)
+	require.NoError(t, json.Unmarshal(body, out))
+}
+
+// ConfigDump is the response of the /config_dump endpoint.
+// It only decodes the parts of the config that tests commonly assert on.
+// The full config of each cluster and listener is available via their Raw fields.
+type ConfigDump struct {
+	Configs []configDumpSection `json:"configs"`
+}
+
+type configDumpSection struct {
+	Type string `json:"@type"`
+
+	StaticClusters        []clusterWrapper `json:"static_clusters"`
+	DynamicActiveClusters []clusterWrapper `json:"dynamic_active_clusters"`
+
+	StaticListeners  []listenerWrapper `json:"static_listeners"`
+	DynamicListeners []struct {
+		ActiveState *listenerWrapper `json:"active_state"`
+	} `json:"dynamic_listeners"`
+}
+
+type clusterWrapper struct {
+	Cluster json.RawMessage `json:"cluster"`
+}
+
+type listenerWrapper struct {
+	Listener json.RawMessage `json:"listener"`
+}
+
+// Cluster is an Envoy cluster from the config dump.
+type Cluster struct {
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	ConnectTimeout string `json:"connect_timeout"`
+
+	// Raw is the full JSON config of the cluster.
+	Raw json.RawMessage `json:"-"`
+}
+
+// Listener is an Envoy listener from the config dump.
+type Listener struct {
+	Name    string `json:"name"`
+	Address struct {
+		SocketAddress SocketAddress `json:"socket_address"`
+	} `json:"address"`
+
+	// Raw is the full JSON config of the listener.
+	Raw json.RawMessage `json:"-"`
+}
+
+// SocketAddress is an address of a listener or an upstream host.
+type SocketAddress struct {
+	Address   string `json:"address"`
+	PortValue int    `json:"port_value"`
+}
+
+// Clusters returns all static and active dynamic clusters keyed by name.
+func (d *ConfigDump) Clusters(t require.TestingT) map[string]Cluster {
+	clusters := map[string]Cluster{}
+	for _, section := range d.Configs {
+		if !strings.HasSuffix(section.Type, "ClustersConfigDump") {
+			continue
+		}
+		for _, c := range append(section.StaticClusters, section.DynamicActiveClusters...) {
+			cluster := Cluster{Raw: c.Cluster}
+			require.NoError(t, json.Unmarshal(c.Cluster, &cluster))
+			clusters[cluster.Name] = cluster
+		}
+	}
+	return clusters
+}
+
+// Listeners returns all static and active dynamic listeners keyed by name.
+func (d *ConfigDump) Listeners(t require.TestingT) map[string]Listener {
+	var raw []json.RawMessage
+	for _, section := range d.Configs {
+		if !strings.HasSuffix(section.Type, "ListenersConfigDump") {
+			continue
+		}
+		for _, l := range section.StaticListeners {
+			raw = append(raw, l.Listener)
+		}
+		for _, l := range section.DynamicListeners {
+			if l.ActiveState != nil {
+				raw = append(raw, l.ActiveState.Listener)
+			}
+		}
+	}
+
+	listeners := map[string]Listener{}
+	for _, r := range raw {
+		listener := Listener{Raw: r}
+		require.NoError(t, json.Unmarshal(r, &listener))
+		listeners[listener.Name] = listener
+	}
+	return listeners
+}
+
+// ClusterStatus is the status of a cluster from the /clusters endpoint.
+type ClusterStatus struct {
+	Name         string       `json:"name"`
+	HostStatuses []HostStatus `json:"host_statuses"`
+}
+
+// HostStatus is the status of an upstream host of a cluster.
+type HostStatus struct {
+	Address struct {
+		SocketAddress SocketAddress `json:"socket_address"`
+	} `json:"address"`
+	HealthStatus struct {
+		EDSHealthStatus string `json:"eds_health_status"`
+	} `json:"health_status"`
+}
+
+// Healthy returns true if EDS reports the host as healthy.
+func (h HostStatus) Healthy() bool {
+	return h.HealthStatus.EDSHealthStatus == "HEALTHY"
+}
+
+// HealthyHosts returns the addresses of the healthy hosts of the cluster.
+func (c ClusterStatus) HealthyHosts() []SocketAddress {
+	var hosts []SocketAddress
+	for _, h := range c.HostStatuses {
+		if h.Healthy() {
+			hosts = append(hosts, h.Address.SocketAddress)
+		}
+	}
+	return hosts
+}
+
+// Certificates are the certificates of a TLS context from the /certs endpoint.
+type Certificates struct {
+	CACert    []CertificateDetails `json:"ca_cert"`
+	CertChain []CertificateDetails `json:"cert_chain"`
+}
+
+// CertificateDetails describes a certificate from the /certs endpoint.
+type CertificateDetails struct {
+	// SerialNumber is the hex-encoded serial number of the certificate.
+	SerialNumber string `json:"serial_number"`
+}
+
+// HasSerialNumber returns true if the certificate has the given serial number.
+// Envoy hex-encodes serial numbers, so they're compared as numbers
+// to ignore differences in case and leading zeros.
+func (c CertificateDetails) HasSerialNumber(serialNumber *big.Int) bool {
+	serial, ok := new(big.Int).SetString(c.SerialNumber, 16)
+	return ok && serial.Cmp(serialNumber) == 0
+}
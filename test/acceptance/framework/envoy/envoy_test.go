@@ -0,0 +1,197 @@
+package envoy
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const configDump = `{
+  "configs": [
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.BootstrapConfigDump",
+      "bootstrap": {}
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.ClustersConfigDump",
+      "static_clusters": [
+        {
+          "cluster": {
+            "name": "local_agent",
+            "type": "STATIC",
+            "connect_timeout": "1s"
+          }
+        }
+      ],
+      "dynamic_active_clusters": [
+        {
+          "version_info": "1",
+          "cluster": {
+            "name": "static-server.default.dc1.internal.11111111-2222-3333-4444-555555555555.consul",
+            "type": "EDS",
+            "connect_timeout": "5s"
+          }
+        }
+      ]
+    },
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.ListenersConfigDump",
+      "dynamic_listeners": [
+        {
+          "name": "public_listener:10.0.0.1:20000",
+          "active_state": {
+            "listener": {
+              "name": "public_listener:10.0.0.1:20000",
+              "address": {
+                "socket_address": {
+                  "address": "10.0.0.1",
+                  "port_value": 20000
+                }
+              }
+            }
+          }
+        },
+        {
+          "name": "warming_listener",
+          "warming_state": {}
+        }
+      ]
+    }
+  ]
+}`
+
+const clusters = `{
+  "cluster_statuses": [
+    {
+      "name": "static-server",
+      "host_statuses": [
+        {
+          "address": {"socket_address": {"address": "10.0.0.2", "port_value": 20000}},
+          "health_status": {"eds_health_status": "HEALTHY"}
+        },
+        {
+          "address": {"socket_address": {"address": "10.0.0.3", "port_value": 20000}},
+          "health_status": {"eds_health_status": "UNHEALTHY"}
+        }
+      ]
+    }
+  ]
+}`
+
+const certs = `{
+  "certificates": [
+    {
+      "ca_cert": [
+        {"path": "<inline>", "serial_number": "0a1b", "days_until_expiration": "3649"},
+        {"path": "<inline>", "serial_number": "FF", "days_until_expiration": "3649"}
+      ],
+      "cert_chain": [
+        {"path": "<inline>", "serial_number": "1234", "days_until_expiration": "2"}
+      ]
+    }
+  ]
+}`
+
+const stats = `{
+  "stats": [
+    {"name": "cluster.dc2.internal.11111111-2222-3333-4444-555555555555.consul.upstream_cx_total", "value": 7},
+    {"name": "cluster.dc2.internal.11111111-2222-3333-4444-555555555555.consul.upstream_cx_active", "value": 0},
+    {"histograms": {"supported_quantiles": [0, 50, 100], "computed_quantiles": []}}
+  ]
+}`
+
+const logging = `active loggers:
+  admin: critical
+  main: critical
+  upstream: debug
+`
+
+func TestAdmin_ConfigDump(t *testing.T) {
+	admin := testAdmin(t)
+
+	dump := admin.ConfigDump(t)
+
+	clusters := dump.Clusters(t)
+	require.Len(t, clusters, 2)
+	require.Equal(t, "STATIC", clusters["local_agent"].Type)
+	require.Equal(t, "1s", clusters["local_agent"].ConnectTimeout)
+	serverCluster := clusters["static-server.default.dc1.internal.11111111-2222-3333-4444-555555555555.consul"]
+	require.Equal(t, "EDS", serverCluster.Type)
+	require.Contains(t, string(serverCluster.Raw), `"connect_timeout": "5s"`)
+
+	listeners := dump.Listeners(t)
+	require.Len(t, listeners, 1)
+	listener := listeners["public_listener:10.0.0.1:20000"]
+	require.Equal(t, SocketAddress{Address: "10.0.0.1", PortValue: 20000}, listener.Address.SocketAddress)
+}
+
+func TestAdmin_Clusters(t *testing.T) {
+	admin := testAdmin(t)
+
+	statuses := admin.Clusters(t)
+	require.Len(t, statuses, 1)
+	require.Equal(t, "static-server", statuses[0].Name)
+	require.Len(t, statuses[0].HostStatuses, 2)
+	require.Equal(t, []SocketAddress{{Address: "10.0.0.2", PortValue: 20000}}, statuses[0].HealthyHosts())
+}
+
+func TestAdmin_Certs(t *testing.T) {
+	admin := testAdmin(t)
+
+	certs := admin.Certs(t)
+	require.Len(t, certs, 1)
+	require.Len(t, certs[0].CACert, 2)
+	require.True(t, certs[0].CACert[0].HasSerialNumber(big.NewInt(0xa1b)))
+	require.True(t, certs[0].CACert[1].HasSerialNumber(big.NewInt(0xff)))
+	require.False(t, certs[0].CACert[1].HasSerialNumber(big.NewInt(0xa1b)))
+	require.Len(t, certs[0].CertChain, 1)
+	require.Equal(t, "1234", certs[0].CertChain[0].SerialNumber)
+}
+
+func TestAdmin_Stats(t *testing.T) {
+	admin := testAdmin(t)
+
+	values := admin.Stats(t, `^cluster\.dc2\.`)
+	require.Equal(t, map[string]int64{
+		"cluster.dc2.internal.11111111-2222-3333-4444-555555555555.consul.upstream_cx_total":  7,
+		"cluster.dc2.internal.11111111-2222-3333-4444-555555555555.consul.upstream_cx_active": 0,
+	}, values)
+}
+
+func TestAdmin_LogLevels(t *testing.T) {
+	admin := testAdmin(t)
+
+	levels := admin.LogLevels(t)
+	require.Equal(t, map[string]string{"admin": "critical", "upstream": "debug", "main": "critical"}, levels)
+}
+
+// testAdmin returns an Admin that talks to a fake Envoy admin API.
+func testAdmin(t *testing.T) *Admin {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/config_dump":
+			fmt.Fprint(w, configDump)
+		case "/logging":
+			require.Equal(t, http.MethodPost, r.Method)
+			fmt.Fprint(w, logging)
+		case "/certs":
+			fmt.Fprint(w, certs)
+		case "/stats":
+			require.Equal(t, "json", r.URL.Query().Get("format"))
+			require.Equal(t, `^cluster\.dc2\.`, r.URL.Query().Get("filter"))
+			fmt.Fprint(w, stats)
+		case "/clusters":
+			require.Equal(t, "json", r.URL.Query().Get("format"))
+			fmt.Fprint(w, clusters)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return &Admin{baseURL: server.URL, httpClient: server.Client()}
+}
@@ -0,0 +1,298 @@
+package fixtures
+
+import (
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+)
+
+// apiVersion is the API version of the controller's custom resources.
+const apiVersion = "consul.hashicorp.com/v1alpha1"
+
+// configEntry is a custom resource for a Consul config entry.
+// It's embedded in the builders for each kind.
+type configEntry struct {
+	kind string
+	name string
+	spec map[string]interface{}
+}
+
+func newConfigEntry(kind, name string) configEntry {
+	return configEntry{kind: kind, name: name, spec: map[string]interface{}{}}
+}
+
+// Name returns the name of the custom resource.
+func (c *configEntry) Name() string {
+	return c.name
+}
+
+// Object implements Fixture.
+func (c *configEntry) Object() map[string]interface{} {
+	obj := map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       c.kind,
+		"metadata":   map[string]interface{}{"name": c.name},
+	}
+	if len(c.spec) > 0 {
+		obj["spec"] = c.spec
+	}
+	return obj
+}
+
+// Apply creates or updates the custom resource. See Apply.
+func (c *configEntry) Apply(t *testing.T, options *terratestk8s.KubectlOptions) {
+	t.Helper()
+
+	Apply(t, options, c)
+}
+
+// Delete deletes the custom resource if it exists. See Delete.
+func (c *configEntry) Delete(t *testing.T, options *terratestk8s.KubectlOptions) {
+	t.Helper()
+
+	Delete(t, options, c)
+}
+
+// set sets the field of the spec at path to value,
+// creating the maps along the path if they don't exist.
+func (c *configEntry) set(value interface{}, path ...string) {
+	m := c.spec
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[key] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+// appendTo appends value to the list in the spec at key.
+func (c *configEntry) appendTo(key string, value interface{}) {
+	list, _ := c.spec[key].([]interface{})
+	c.spec[key] = append(list, value)
+}
+
+// ServiceDefaults is a ServiceDefaults custom resource.
+type ServiceDefaults struct {
+	configEntry
+}
+
+// NewServiceDefaults returns a ServiceDefaults custom resource for the service name.
+func NewServiceDefaults(name string) *ServiceDefaults {
+	return &ServiceDefaults{newConfigEntry("ServiceDefaults", name)}
+}
+
+// WithProtocol sets the protocol of the service, e.g. "http".
+func (s *ServiceDefaults) WithProtocol(protocol string) *ServiceDefaults {
+	s.set(protocol, "protocol")
+	return s
+}
+
+// ServiceResolver is a ServiceResolver custom resource.
+type ServiceResolver struct {
+	configEntry
+}
+
+// NewServiceResolver returns a ServiceResolver custom resource for the service name.
+func NewServiceResolver(name string) *ServiceResolver {
+	return &ServiceResolver{newConfigEntry("ServiceResolver", name)}
+}
+
+// WithRedirect redirects requests for the service to service.
+func (s *ServiceResolver) WithRedirect(service string) *ServiceResolver {
+	s.set(service, "redirect", "service")
+	return s
+}
+
+// WithSubset adds a subset of the service's instances named name
+// that match filter, e.g. "Service.Meta.version == v1".
+func (s *ServiceResolver) WithSubset(name, filter string) *ServiceResolver {
+	s.set(filter, "subsets", name, "filter")
+	return s
+}
+
+// WithDefaultSubset sends requests for the service that don't
+// ask for a subset to the instances in subset.
+func (s *ServiceResolver) WithDefaultSubset(subset string) *ServiceResolver {
+	s.set(subset, "defaultSubset")
+	return s
+}
+
+// ProxyDefaults is a ProxyDefaults custom resource.
+type ProxyDefaults struct {
+	configEntry
+}
+
+// NewProxyDefaults returns a ProxyDefaults custom resource.
+// Its name is always "global" because that's the only name Consul allows.
+func NewProxyDefaults() *ProxyDefaults {
+	return &ProxyDefaults{newConfigEntry("ProxyDefaults", "global")}
+}
+
+// WithMeshGatewayMode sets the mesh gateway mode, e.g. "local".
+func (p *ProxyDefaults) WithMeshGatewayMode(mode string) *ProxyDefaults {
+	p.set(mode, "meshGateway", "mode")
+	return p
+}
+
+// WithConfig sets key in the opaque proxy config to value.
+func (p *ProxyDefaults) WithConfig(key string, value interface{}) *ProxyDefaults {
+	p.set(value, "config", key)
+	return p
+}
+
+// ServiceRouter is a ServiceRouter custom resource.
+type ServiceRouter struct {
+	configEntry
+}
+
+// NewServiceRouter returns a ServiceRouter custom resource for the service name.
+func NewServiceRouter(name string) *ServiceRouter {
+	return &ServiceRouter{newConfigEntry("ServiceRouter", name)}
+}
+
+// WithPathPrefixRoute adds a route that matches HTTP requests with pathPrefix.
+func (s *ServiceRouter) WithPathPrefixRoute(pathPrefix string) *ServiceRouter {
+	s.appendTo("routes", map[string]interface{}{
+		"match": map[string]interface{}{
+			"http": map[string]interface{}{"pathPrefix": pathPrefix},
+		},
+	})
+	return s
+}
+
+// WithPathPrefixRouteToSubset adds a route that sends HTTP requests
+// with pathPrefix to the subset of the router's service.
+func (s *ServiceRouter) WithPathPrefixRouteToSubset(pathPrefix, subset string) *ServiceRouter {
+	s.appendTo("routes", map[string]interface{}{
+		"match": map[string]interface{}{
+			"http": map[string]interface{}{"pathPrefix": pathPrefix},
+		},
+		"destination": map[string]interface{}{"serviceSubset": subset},
+	})
+	return s
+}
+
+// ServiceSplitter is a ServiceSplitter custom resource.
+type ServiceSplitter struct {
+	configEntry
+}
+
+// NewServiceSplitter returns a ServiceSplitter custom resource for the service name.
+func NewServiceSplitter(name string) *ServiceSplitter {
+	return &ServiceSplitter{newConfigEntry("ServiceSplitter", name)}
+}
+
+// WithSplit adds a split that sends weight percent of the traffic to service.
+// If service is empty, the traffic is sent to the splitter's own service.
+func (s *ServiceSplitter) WithSplit(weight float64, service string) *ServiceSplitter {
+	split := map[string]interface{}{"weight": weight}
+	if service != "" {
+		split["service"] = service
+	}
+	s.appendTo("splits", split)
+	return s
+}
+
+// WithSubsetSplit adds a split that sends weight percent of the traffic
+// to the subset of the splitter's service.
+func (s *ServiceSplitter) WithSubsetSplit(weight float64, subset string) *ServiceSplitter {
+	s.appendTo("splits", map[string]interface{}{"weight": weight, "serviceSubset": subset})
+	return s
+}
+
+// ServiceIntentions is a ServiceIntentions custom resource.
+type ServiceIntentions struct {
+	configEntry
+}
+
+// NewServiceIntentions returns a ServiceIntentions custom resource named name
+// for the intentions with the destination service.
+func NewServiceIntentions(name, destination string) *ServiceIntentions {
+	s := &ServiceIntentions{newConfigEntry("ServiceIntentions", name)}
+	s.set(destination, "destination", "name")
+	return s
+}
+
+// WithSource adds an intention from the source service with action, "allow" or "deny".
+func (s *ServiceIntentions) WithSource(source, action string) *ServiceIntentions {
+	s.appendTo("sources", map[string]interface{}{"name": source, "action": action})
+	return s
+}
+
+// WithSourceInNamespace is like WithSource for a source service
+// in a different Consul namespace than the destination.
+func (s *ServiceIntentions) WithSourceInNamespace(source, namespace, action string) *ServiceIntentions {
+	s.appendTo("sources", map[string]interface{}{"name": source, "namespace": namespace, "action": action})
+	return s
+}
+
+// HTTPPermission is an L7 permission of an intention.
+// Empty fields don't restrict which requests it matches.
+type HTTPPermission struct {
+	// Action is "allow" or "deny".
+	Action     string
+	PathExact  string
+	PathPrefix string
+	Methods    []string
+}
+
+// WithSourcePermissions adds an intention from the source service
+// with HTTP permissions instead of an action.
+func (s *ServiceIntentions) WithSourcePermissions(source string, permissions ...HTTPPermission) *ServiceIntentions {
+	var perms []interface{}
+	for _, p := range permissions {
+		http := map[string]interface{}{}
+		if p.PathExact != "" {
+			http["pathExact"] = p.PathExact
+		}
+		if p.PathPrefix != "" {
+			http["pathPrefix"] = p.PathPrefix
+		}
+		if len(p.Methods) > 0 {
+			var methods []interface{}
+			for _, method := range p.Methods {
+				methods = append(methods, method)
+			}
+			http["methods"] = methods
+		}
+		perms = append(perms, map[string]interface{}{"action": p.Action, "http": http})
+	}
+	s.appendTo("sources", map[string]interface{}{"name": source, "permissions": perms})
+	return s
+}
+
+// DefaultCustomResources returns a custom resource of every kind the controller
+// supports, along with the service-defaults they need, e.g. to set the protocol
+// of services with L7 config entries:
+//
+//   - service-defaults "defaults" with protocol http
+//   - service-resolver "resolver" redirecting to bar
+//   - proxy-defaults "global" with mesh gateway mode local
+//   - service-router "router" with a route for the path prefix /foo
+//   - service-splitter "splitter" sending all traffic to itself
+//   - service-intentions "intentions" for svc1, allowing svc2 and allowing
+//     GET and PUT requests to /foo from svc3
+func DefaultCustomResources() []Fixture {
+	return []Fixture{
+		NewServiceDefaults("defaults").WithProtocol("http"),
+		NewServiceResolver("resolver").WithRedirect("bar"),
+		NewProxyDefaults().
+			WithConfig("foo", `{"http":{"name":"envoy.zipkin","config":{"collector_cluster":"zipkin","collector_endpoint":"/api/v1/spans","shared_span_context":false}}}`).
+			WithConfig("members", 3).
+			WithMeshGatewayMode("local"),
+		NewServiceDefaults("router").WithProtocol("http"),
+		NewServiceRouter("router").WithPathPrefixRoute("/foo"),
+		NewServiceDefaults("splitter").WithProtocol("http"),
+		NewServiceDefaults("other-splitter").WithProtocol("http"),
+		NewServiceSplitter("splitter").WithSplit(100, ""),
+		NewServiceDefaults("svc1").WithProtocol("http"),
+		NewServiceDefaults("svc2"),
+		NewServiceDefaults("svc3").WithProtocol("http"),
+		NewServiceIntentions("intentions", "svc1").
+			WithSource("svc2", "allow").
+			WithSourcePermissions("svc3", HTTPPermission{Action: "allow", PathExact: "/foo", Methods: []string{"GET", "PUT"}}),
+	}
+}
@@ -0,0 +1,87 @@
+// Package fixtures builds the custom resources that tests apply to
+// Kubernetes in Go so that tests can vary their names and fields per
+// test case instead of sharing static YAML files and patching them.
+package fixtures
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+// Fixture is a Kubernetes resource that tests can apply and delete.
+type Fixture interface {
+	// Object returns the resource as an object that can be marshalled to YAML.
+	Object() map[string]interface{}
+}
+
+// YAML returns fixtures as a multi-document YAML manifest.
+func YAML(fixtures ...Fixture) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, fixture := range fixtures {
+		doc, err := yaml.Marshal(fixture.Object())
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("---\n")
+		buf.Write(doc)
+	}
+	return buf.Bytes(), nil
+}
+
+// Apply creates or updates fixtures in the namespace of options with kubectl apply.
+// Applying a fixture that was changed since it was last applied updates the
+// resource to match it, so tests can update resources by applying them again.
+func Apply(t *testing.T, options *terratestk8s.KubectlOptions, fixtures ...Fixture) {
+	t.Helper()
+
+	manifest := writeManifest(t, fixtures)
+	defer os.Remove(manifest)
+	k8s.KubectlApply(t, options, manifest)
+}
+
+// ApplyExpectError is like Apply, but it expects kubectl apply to fail,
+// for example, because a validating webhook rejects the fixtures.
+// It returns the output of kubectl so that tests can assert on the error message.
+// Fixtures that are applied anyway are deleted when the test finishes.
+func ApplyExpectError(t *testing.T, options *terratestk8s.KubectlOptions, noCleanupOnFailure, noCleanup bool, fixtures ...Fixture) string {
+	t.Helper()
+
+	manifest := writeManifest(t, fixtures)
+	defer os.Remove(manifest)
+	return k8s.KubectlApplyExpectError(t, options, noCleanupOnFailure, noCleanup, manifest)
+}
+
+// Delete deletes fixtures from the namespace of options,
+// ignoring the ones that don't exist, for example,
+// because the test deleted them already.
+func Delete(t *testing.T, options *terratestk8s.KubectlOptions, fixtures ...Fixture) {
+	t.Helper()
+
+	manifest := writeManifest(t, fixtures)
+	defer os.Remove(manifest)
+	out, err := k8s.RunKubectlAndGetOutputE(t, options, "delete", "--ignore-not-found", "-f", manifest)
+	require.NoError(t, err, out)
+}
+
+// writeManifest writes fixtures to a temporary file and returns its path.
+// The caller must remove the file.
+func writeManifest(t *testing.T, fixtures []Fixture) string {
+	t.Helper()
+
+	manifest, err := YAML(fixtures...)
+	require.NoError(t, err)
+
+	file, err := ioutil.TempFile("", "fixtures-*.yaml")
+	require.NoError(t, err)
+	_, err = file.Write(manifest)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	return file.Name()
+}
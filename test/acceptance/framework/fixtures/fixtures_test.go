@@ -0,0 +1,210 @@
+package fixtures
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestYAML(t *testing.T) {
+	cases := map[string]struct {
+		fixtures []Fixture
+		expYAML  string
+	}{
+		"service-defaults": {
+			fixtures: []Fixture{NewServiceDefaults("foo").WithProtocol("http")},
+			expYAML: `---
+apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceDefaults
+metadata:
+  name: foo
+spec:
+  protocol: http
+`,
+		},
+		"service-defaults without spec": {
+			fixtures: []Fixture{NewServiceDefaults("foo")},
+			expYAML: `---
+apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceDefaults
+metadata:
+  name: foo
+`,
+		},
+		"proxy-defaults": {
+			fixtures: []Fixture{NewProxyDefaults().WithConfig("members", 3).WithConfig("foo", "bar").WithMeshGatewayMode("local")},
+			expYAML: `---
+apiVersion: consul.hashicorp.com/v1alpha1
+kind: ProxyDefaults
+metadata:
+  name: global
+spec:
+  config:
+    foo: bar
+    members: 3
+  meshGateway:
+    mode: local
+`,
+		},
+		"service-resolver and service-router": {
+			fixtures: []Fixture{
+				NewServiceResolver("foo").WithRedirect("bar"),
+				NewServiceRouter("foo").WithPathPrefixRoute("/foo").WithPathPrefixRoute("/bar"),
+			},
+			expYAML: `---
+apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceResolver
+metadata:
+  name: foo
+spec:
+  redirect:
+    service: bar
+---
+apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceRouter
+metadata:
+  name: foo
+spec:
+  routes:
+  - match:
+      http:
+        pathPrefix: /foo
+  - match:
+      http:
+        pathPrefix: /bar
+`,
+		},
+		"service-splitter": {
+			fixtures: []Fixture{NewServiceSplitter("foo").WithSplit(50, "").WithSplit(50, "bar")},
+			expYAML: `---
+apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceSplitter
+metadata:
+  name: foo
+spec:
+  splits:
+  - weight: 50
+  - service: bar
+    weight: 50
+`,
+		},
+		"subsets": {
+			fixtures: []Fixture{
+				NewServiceResolver("foo").
+					WithSubset("v1", "Service.Meta.version == v1").
+					WithSubset("v2", "Service.Meta.version == v2").
+					WithDefaultSubset("v1"),
+				NewServiceSplitter("foo").WithSubsetSplit(90, "v1").WithSubsetSplit(10, "v2"),
+				NewServiceRouter("foo").WithPathPrefixRouteToSubset("/v2", "v2"),
+			},
+			expYAML: `---
+apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceResolver
+metadata:
+  name: foo
+spec:
+  defaultSubset: v1
+  subsets:
+    v1:
+      filter: Service.Meta.version == v1
+    v2:
+      filter: Service.Meta.version == v2
+---
+apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceSplitter
+metadata:
+  name: foo
+spec:
+  splits:
+  - serviceSubset: v1
+    weight: 90
+  - serviceSubset: v2
+    weight: 10
+---
+apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceRouter
+metadata:
+  name: foo
+spec:
+  routes:
+  - destination:
+      serviceSubset: v2
+    match:
+      http:
+        pathPrefix: /v2
+`,
+		},
+		"service-intentions": {
+			fixtures: []Fixture{
+				NewServiceIntentions("foo", "bar").
+					WithSource("baz", "allow").
+					WithSourceInNamespace("qux", "ns", "deny").
+					WithSourcePermissions("quux",
+						HTTPPermission{Action: "deny", PathPrefix: "/admin"},
+						HTTPPermission{Action: "allow", PathExact: "/foo", Methods: []string{"GET", "PUT"}}),
+			},
+			expYAML: `---
+apiVersion: consul.hashicorp.com/v1alpha1
+kind: ServiceIntentions
+metadata:
+  name: foo
+spec:
+  destination:
+    name: bar
+  sources:
+  - action: allow
+    name: baz
+  - action: deny
+    name: qux
+    namespace: ns
+  - name: quux
+    permissions:
+    - action: deny
+      http:
+        pathPrefix: /admin
+    - action: allow
+      http:
+        methods:
+        - GET
+        - PUT
+        pathExact: /foo
+`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := YAML(c.fixtures...)
+			require.NoError(t, err)
+			require.Equal(t, c.expYAML, string(actual))
+		})
+	}
+}
+
+// Test that builders can be changed and applied again to update
+// a resource, and that changing one doesn't change another.
+func TestBuilders_Update(t *testing.T) {
+	defaults := NewServiceDefaults("foo").WithProtocol("http")
+	other := NewServiceDefaults("bar").WithProtocol("http")
+	defaults.WithProtocol("tcp")
+
+	require.Equal(t, "tcp", defaults.Object()["spec"].(map[string]interface{})["protocol"])
+	require.Equal(t, "http", other.Object()["spec"].(map[string]interface{})["protocol"])
+}
+
+func TestDefaultCustomResources(t *testing.T) {
+	kinds := map[string]bool{}
+	names := map[string]bool{}
+	for _, fixture := range DefaultCustomResources() {
+		obj := fixture.Object()
+		kind := obj["kind"].(string)
+		name := obj["metadata"].(map[string]interface{})["name"].(string)
+		kinds[kind] = true
+		require.False(t, names[kind+"/"+name], "duplicate %s %s", kind, name)
+		names[kind+"/"+name] = true
+	}
+
+	for _, kind := range []string{"ServiceDefaults", "ServiceResolver", "ProxyDefaults", "ServiceRouter", "ServiceSplitter", "ServiceIntentions"} {
+		require.True(t, kinds[kind], "missing %s", kind)
+	}
+}
@@ -3,15 +3,36 @@ package flags
 import (
 	"errors"
 	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helm"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/resources"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
 )
 
+// consulNamespacePrefixRegex matches valid values of -consul-namespace-prefix.
+// Prefixed names must be valid names for both Consul and Kubernetes namespaces
+// because the prefix is also used for namespaces mirrored from Kubernetes.
+var consulNamespacePrefixRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// clusterDomainRegex matches DNS names made of lowercase labels, e.g. cluster.local.
+var clusterDomainRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
 type TestFlags struct {
-	flagKubeconfig  string
-	flagKubecontext string
-	flagNamespace   string
+	flagKubeconfig    string
+	flagKubecontext   string
+	flagNamespace     string
+	flagKubeVersion   string
+	flagClusterDomain string
 
 	flagEnableMultiCluster   bool
 	flagSecondaryKubeconfig  string
@@ -21,18 +42,68 @@ type TestFlags struct {
 	flagEnableEnterprise            bool
 	flagEnterpriseLicenseSecretName string
 	flagEnterpriseLicenseSecretKey  string
+	flagEnterpriseLicense           string
+	flagConsulNamespacePrefix       string
 
 	flagEnableOpenshift bool
 
+	flagEnableWindows bool
+
+	flagEnablePerf      bool
+	flagPerfResources   int
+	flagPerfSyncTimeout time.Duration
+
+	flagSoakDuration     time.Duration
+	flagSoakMaxErrorRate float64
+
 	flagConsulImage    string
 	flagConsulK8sImage string
 
+	flagTestImageRegistry string
+
 	flagNoCleanupOnFailure bool
+	flagNoCleanup          bool
+
+	flagTestRunID            string
+	flagCleanupOrphans       bool
+	flagCleanupOrphansMinAge time.Duration
 
 	flagDebugDirectory string
 
+	flagCollectEnvoyAccessLogs bool
+
+	flagJUnitOutDirectory string
+
+	flagMetricsStatsdAddr     string
+	flagMetricsPushgatewayURL string
+
 	flagUseKind bool
 
+	flagProvider string
+
+	flagHelmInstallTimeout time.Duration
+	flagHelmWait           bool
+	flagHelmAtomic         bool
+	flagHelmExtraArgs      string
+
+	flagReuseClusters bool
+
+	flagRunSecureOnly     bool
+	flagRunInsecureOnly   bool
+	flagRunEnterpriseOnly bool
+
+	flagSmokeOnly bool
+
+	flagHelmValuesLogFilter string
+
+	flagTimeoutPodsReady      time.Duration
+	flagTimeoutWebhookReady   time.Duration
+	flagTimeoutControllerSync time.Duration
+	flagTimeoutTrafficCheck   time.Duration
+
+	flagResourceBudgets        string
+	flagResourceSampleInterval time.Duration
+
 	once sync.Once
 }
 
@@ -49,9 +120,23 @@ func (t *TestFlags) init() {
 	flag.StringVar(&t.flagKubecontext, "kubecontext", "", "The name of the Kubernetes context to use. If this is blank, "+
 		"the context set as the current context will be used by default.")
 	flag.StringVar(&t.flagNamespace, "namespace", "", "The Kubernetes namespace to use for tests.")
+	flag.StringVar(&t.flagKubeVersion, "kube-version", "", "The major and minor version of Kubernetes, e.g. 1.19, that the clusters "+
+		"are expected to run. If set, tests that check the Kubernetes version fail if a cluster runs a different version, "+
+		"so that test runs for a specific version don't silently run against another one. "+
+		"If this is blank, the version is only discovered from the clusters.")
+	flag.StringVar(&t.flagClusterDomain, "cluster-domain", config.DefaultClusterDomain,
+		"The DNS domain of the Kubernetes cluster(s), i.e. the suffix of the fully qualified names of services, "+
+			"<service>.<namespace>.svc.<cluster domain>. Set it when the clusters use a domain other than the default.")
 
 	flag.StringVar(&t.flagConsulImage, "consul-image", "", "The Consul image to use for all tests.")
 	flag.StringVar(&t.flagConsulK8sImage, "consul-k8s-image", "", "The consul-k8s image to use for all tests.")
+	flag.StringVar(&t.flagTestImageRegistry, "test-image-registry", "",
+		"The registry, optionally with a path prefix, e.g. registry.example.com/mirror, to pull all images used by the tests from "+
+			"instead of their original registries, e.g. to run the tests in networks without access to public registries. "+
+			"This includes the Consul, consul-k8s and Envoy images, whether they're set by the chart, the tests or the image flags, "+
+			"and the images of the test fixture apps. Images are expected under the same name without their original registry, "+
+			"e.g. hashicorp/consul:1.9.0 is pulled as registry.example.com/mirror/hashicorp/consul:1.9.0. "+
+			"If this is blank, images are pulled from their original registries.")
 
 	flag.BoolVar(&t.flagEnableMultiCluster, "enable-multi-cluster", false,
 		"If true, the tests that require multiple Kubernetes clusters will be run. "+
@@ -69,23 +154,164 @@ func (t *TestFlags) init() {
 		"The name of the Kubernetes secret containing the enterprise license.")
 	flag.StringVar(&t.flagEnterpriseLicenseSecretKey, "enterprise-license-secret-key", "",
 		"The key of the Kubernetes secret containing the enterprise license.")
+	flag.StringVar(&t.flagEnterpriseLicense, "enterprise-license", "",
+		"The enterprise license. If set together with -enable-enterprise, the tests will create a Kubernetes secret "+
+			"with the license for each Helm install and configure the servers to use it. "+
+			"Cannot be used together with -enterprise-license-secret-name and -enterprise-license-secret-key.")
+	flag.StringVar(&t.flagConsulNamespacePrefix, "consul-namespace-prefix", "",
+		"The prefix of the Consul namespaces that the enterprise tests create, e.g. suite-a-. "+
+			"The tests delete the Consul namespaces with this prefix when they finish, so suites that "+
+			"run in parallel against the same Consul servers should use different prefixes. "+
+			"If not set, the tests don't delete Consul namespaces. "+
+			"Must consist of lowercase alphanumeric characters and dashes and start with an alphanumeric character.")
 
 	flag.BoolVar(&t.flagEnableOpenshift, "enable-openshift", false,
 		"If true, the tests will automatically add Openshift Helm value for each Helm install.")
 
+	flag.BoolVar(&t.flagEnableWindows, "enable-windows", false,
+		"If true, the tests will schedule all Consul components on Linux nodes and run the tests "+
+			"that require Windows nodes. Use this when the Kubernetes cluster has Windows node pools.")
+
+	flag.BoolVar(&t.flagEnablePerf, "enable-perf", false,
+		"If true, the performance tests will be run. These tests create a large number of custom resources "+
+			"and report how long it takes the controller to sync them to Consul.")
+	flag.IntVar(&t.flagPerfResources, "perf-resources", 200,
+		"The number of custom resources of each kind that the performance tests create.")
+	flag.DurationVar(&t.flagPerfSyncTimeout, "perf-sync-timeout", 10*time.Minute,
+		"The time to wait for the controller to sync all custom resources created by the performance tests.")
+
+	flag.DurationVar(&t.flagSoakDuration, "soak-duration", 0,
+		"If positive, the soak tests will be run for this long. They keep a constant load of requests between "+
+			"services in the mesh while restarting the servers, clients and gateways, and report the error rate over time. "+
+			"The -timeout of go test has to be longer than this.")
+	flag.Float64Var(&t.flagSoakMaxErrorRate, "soak-max-error-rate", 0.01,
+		"The highest fraction of failed requests, between 0 and 1, over the whole run of a soak test for it to pass.")
+
 	flag.BoolVar(&t.flagNoCleanupOnFailure, "no-cleanup-on-failure", false,
 		"If true, the tests will not cleanup Kubernetes resources they create when they finish running."+
 			"Note this flag must be run with -failfast flag, otherwise subsequent tests will fail.")
 
+	flag.BoolVar(&t.flagNoCleanup, "no-cleanup", false,
+		"If true, the tests will not cleanup Kubernetes resources they create when they finish running, "+
+			"regardless of whether they passed or failed. This is useful for inspecting resources after a test run. "+
+			"Note this flag must be run with -failfast flag and a single test selected with -run, otherwise subsequent tests will fail.")
+
+	flag.StringVar(&t.flagTestRunID, "test-run-id", "",
+		"The ID of this test run, e.g. the ID of the CI job. The tests label the Kubernetes resources they create "+
+			"with this ID, the name of the test and the time they were created, so that resources left behind by "+
+			"test runs that didn't clean up can be found and deleted with -cleanup-orphans. "+
+			"If this is blank, a random ID is generated.")
+	flag.BoolVar(&t.flagCleanupOrphans, "cleanup-orphans", false,
+		"If true, before running the tests, delete the Kubernetes resources in the cluster(s) that were created "+
+			"by other test runs and that no test has created or updated for at least -cleanup-orphans-min-age. "+
+			"This is useful for long-lived clusters that are shared between CI runs.")
+	flag.DurationVar(&t.flagCleanupOrphansMinAge, "cleanup-orphans-min-age", 3*time.Hour,
+		"The minimum age of the resources that -cleanup-orphans deletes. It should be longer than a test run "+
+			"so that the resources of test runs that are running at the same time against the same cluster aren't deleted.")
+
 	flag.StringVar(&t.flagDebugDirectory, "debug-directory", "", "The directory where to write debug information about failed test runs, "+
 		"such as logs and pod definitions. If not provided, a temporary directory will be created by the tests.")
 
+	flag.BoolVar(&t.flagCollectEnvoyAccessLogs, "collect-envoy-access-logs", false,
+		"If true, the requests that the Envoy sidecars of a failed test handled are written to <pod>-envoy-access.log "+
+			"in the -debug-directory, including their headers, the cluster they were routed to and the decisions of the "+
+			"filters that enforce intentions. They're taken from the debug logs of the sidecars, which are enabled "+
+			"unless a test sets connectInject.envoyExtraArgs.")
+
+	flag.StringVar(&t.flagJUnitOutDirectory, "junit-out", "", "The directory where to write test results of each test suite "+
+		"in JUnit XML (<suite>.xml) and JSON (<suite>.json) formats, including durations of each test and subtest. "+
+		"The results are read from the test output, so tests run in verbose mode when this is set. "+
+		"If not provided, no results will be written.")
+
+	flag.StringVar(&t.flagMetricsStatsdAddr, "metrics-statsd-addr", "",
+		"The address of a statsd server, e.g. localhost:8125, to send the durations of the phases of each test to, "+
+			"such as installing Consul (install), waiting for webhooks (webhook-ready), waiting for the controller to sync "+
+			"custom resources (first-sync) and checking connections between services (traffic-check). They're sent over UDP "+
+			"as timers with DogStatsD tags for the suite, test and phase. If not provided, the durations aren't sent to statsd.")
+	flag.StringVar(&t.flagMetricsPushgatewayURL, "metrics-pushgateway-url", "",
+		"The URL of a Prometheus Pushgateway, e.g. http://localhost:9091, to push the total duration of the phases of each test "+
+			"to when each test suite finishes. See -metrics-statsd-addr for the phases. The metrics are pushed to the group of "+
+			"the -test-run-id and the suite. If not provided, the durations aren't pushed.")
+
 	flag.BoolVar(&t.flagUseKind, "use-kind", false,
 		"If true, the tests will assume they are running against a local kind cluster(s).")
+
+	flag.StringVar(&t.flagProvider, "provider", "",
+		fmt.Sprintf("The provider of the Kubernetes cluster(s) to run tests against. One of: %s. ", strings.Join(environment.Providers, ", "))+
+			"If set to kind, the tests will create ephemeral kind clusters and delete them when the tests finish. "+
+			"Other providers will use the clusters from the provided kubeconfig(s). "+
+			"If this is blank, the tests will use the clusters from the provided kubeconfig(s) without provisioning.")
+
+	flag.DurationVar(&t.flagHelmInstallTimeout, "helm-install-timeout", 15*time.Minute,
+		"The time to wait for each Helm install to complete. Increasing it could help with flakiness "+
+			"in environments like AKS where volumes take a long time to mount.")
+	flag.BoolVar(&t.flagHelmWait, "helm-wait", false,
+		"If true, Helm installs will wait until all resources are in a ready state before marking the release as successful.")
+	flag.BoolVar(&t.flagHelmAtomic, "helm-atomic", false,
+		"If true, Helm installs will be rolled back if they fail or don't complete within -helm-install-timeout. "+
+			"This implies -helm-wait.")
+	flag.StringVar(&t.flagHelmExtraArgs, "helm-extra-args", "",
+		"Space-separated helm CLI flags, e.g. \"--kube-apiserver=https://10.0.0.1:6443 --debug --timeout=20m\", "+
+			"applied to every Helm action of the tests. They take precedence over the other Helm flags, "+
+			"e.g. --timeout over -helm-install-timeout and --wait=false over -helm-wait. Supported are the helm CLI's --kube-apiserver, --kube-token, "+
+			"--kube-as-user, --kube-as-group, --kube-ca-file, --kube-insecure-skip-tls-verify, --repository-config, "+
+			"--repository-cache and --debug flags, and the --timeout, --wait, --atomic, --no-hooks, --skip-crds and "+
+			"--disable-openapi-validation flags of the actions that have them. "+
+			"The Kubernetes context and namespace are always set from the test flags.")
+
+	flag.BoolVar(&t.flagReuseClusters, "reuse-clusters", false,
+		"If true, the cases of tests that support it share a single Helm install of Consul between cases "+
+			"with the same Helm values instead of installing Consul for each case. Each case creates its resources "+
+			"in its own Kubernetes namespace, and the config entries and Consul namespaces it creates are deleted "+
+			"when it finishes. This reduces the run time of tests with many cases.")
+
+	flag.BoolVar(&t.flagRunSecureOnly, "run-secure-only", false,
+		"If true, only the test cases that install Consul with TLS or ACLs enabled will be run. "+
+			"Cannot be used together with -run-insecure-only.")
+	flag.BoolVar(&t.flagRunInsecureOnly, "run-insecure-only", false,
+		"If true, only the test cases that install Consul without TLS and ACLs will be run. "+
+			"Cannot be used together with -run-secure-only.")
+	flag.BoolVar(&t.flagRunEnterpriseOnly, "run-enterprise-only", false,
+		"If true, only the test cases that use Consul Enterprise features, such as Consul namespaces, will be run. "+
+			"Requires -enable-enterprise.")
+
+	flag.BoolVar(&t.flagSmokeOnly, "smoke-only", false,
+		"If true, only the smoke cases that each suite registers will be run, which is one representative case "+
+			"per suite that gives quick signal, e.g. for pull requests. Suites that don't register smoke cases "+
+			"are skipped. Running the tests with -short has the same effect.")
+
+	flag.StringVar(&t.flagHelmValuesLogFilter, "helm-values-log-filter", "",
+		"Comma-separated list of Helm value prefixes, e.g. global.tls,connectInject. "+
+			"Only the Helm values matching one of these prefixes will be logged and written to the debug directory "+
+			"for each Helm install and upgrade. If this is blank, all Helm values will be logged. "+
+			"Values that may contain secrets, such as ACL tokens and enterprise licenses, are always redacted.")
+
+	defaultTimeouts := timeouts.Defaults()
+	flag.DurationVar(&t.flagTimeoutPodsReady, "timeout-pods-ready", defaultTimeouts.PodsReady,
+		"The time to wait for pods and deployments to become ready.")
+	flag.DurationVar(&t.flagTimeoutWebhookReady, "timeout-webhook-ready", defaultTimeouts.WebhookReady,
+		"The time to wait for webhooks, such as the controller's validating webhook, to start serving requests.")
+	flag.DurationVar(&t.flagTimeoutControllerSync, "timeout-controller-sync", defaultTimeouts.ControllerSync,
+		"The time to wait for the controller to sync custom resources to Consul, "+
+			"including the time it takes the controller to perform leader election on startup.")
+	flag.DurationVar(&t.flagTimeoutTrafficCheck, "timeout-traffic-check", defaultTimeouts.TrafficCheck,
+		"The time to wait for a connection between services to succeed or fail as expected.")
+
+	flag.StringVar(&t.flagResourceBudgets, "resource-budgets", "",
+		"Comma-separated list of CPU and memory budgets for a single pod of a component, "+
+			"e.g. server.memory=200Mi,controller.cpu=100m,envoy-sidecar.memory=64Mi. "+
+			fmt.Sprintf("Components are: %s. ", strings.Join(resources.Components, ", "))+
+			"If set, the usage of pods of these components is sampled while each test runs, "+
+			"using metrics-server if it's installed and the kubelet summary API otherwise, "+
+			"and the test fails if the peak usage of a pod exceeds its budget. "+
+			"If this is blank, usage is not sampled.")
+	flag.DurationVar(&t.flagResourceSampleInterval, "resource-sample-interval", 10*time.Second,
+		"The interval at which resource usage is sampled if -resource-budgets is set.")
 }
 
 func (t *TestFlags) Validate() error {
-	if t.flagEnableMultiCluster {
+	// When the kind provider is used, the secondary cluster is created by the tests.
+	if t.flagEnableMultiCluster && t.flagProvider != environment.ProviderKind {
 		if t.flagSecondaryKubecontext == "" && t.flagSecondaryKubeconfig == "" {
 			return errors.New("at least one of -secondary-kubecontext or -secondary-kubeconfig flags must be provided if -enable-multi-cluster is set")
 		}
@@ -97,6 +323,92 @@ func (t *TestFlags) Validate() error {
 		return errors.New("both of -enterprise-license-secret-name and -enterprise-license-secret-name flags must be provided; not just one")
 	}
 
+	if t.flagEnterpriseLicense != "" && t.flagEnterpriseLicenseSecretName != "" {
+		return errors.New("-enterprise-license cannot be provided together with -enterprise-license-secret-name and -enterprise-license-secret-key")
+	}
+
+	if t.flagConsulNamespacePrefix != "" && !consulNamespacePrefixRegex.MatchString(t.flagConsulNamespacePrefix) {
+		return fmt.Errorf("-consul-namespace-prefix must consist of lowercase alphanumeric characters and dashes "+
+			"and start with an alphanumeric character, got %q", t.flagConsulNamespacePrefix)
+	}
+
+	if strings.Contains(t.flagTestImageRegistry, "://") {
+		return fmt.Errorf("-test-image-registry must be a registry host and an optional path without a scheme, got %q", t.flagTestImageRegistry)
+	}
+
+	if err := helm.ValidateExtraArgs(strings.Fields(t.flagHelmExtraArgs)); err != nil {
+		return fmt.Errorf("-helm-extra-args is invalid: %s", err)
+	}
+
+	if t.flagRunSecureOnly && t.flagRunInsecureOnly {
+		return errors.New("-run-secure-only and -run-insecure-only cannot be used together")
+	}
+
+	if t.flagRunEnterpriseOnly && !t.flagEnableEnterprise {
+		return errors.New("-run-enterprise-only requires -enable-enterprise")
+	}
+
+	if t.flagTestRunID != "" {
+		if err := testlabels.ValidateRunID(t.flagTestRunID); err != nil {
+			return fmt.Errorf("-test-run-id must be a valid label value: %s", err)
+		}
+	}
+
+	if t.flagCleanupOrphans && t.flagCleanupOrphansMinAge <= 0 {
+		return fmt.Errorf("-cleanup-orphans-min-age must be positive if -cleanup-orphans is set, got %s", t.flagCleanupOrphansMinAge)
+	}
+
+	if t.flagKubeVersion != "" {
+		if _, err := environment.ParseKubernetesVersion(t.flagKubeVersion); err != nil {
+			return fmt.Errorf("-kube-version must be a Kubernetes version like 1.19: %s", err)
+		}
+	}
+
+	if t.flagClusterDomain != "" && !clusterDomainRegex.MatchString(t.flagClusterDomain) {
+		return fmt.Errorf("-cluster-domain must be a DNS name without leading or trailing dots, e.g. cluster.local, got %q", t.flagClusterDomain)
+	}
+
+	if t.flagMetricsStatsdAddr != "" {
+		if _, _, err := net.SplitHostPort(t.flagMetricsStatsdAddr); err != nil {
+			return fmt.Errorf("-metrics-statsd-addr must be a host and port, e.g. localhost:8125: %s", err)
+		}
+	}
+
+	if t.flagMetricsPushgatewayURL != "" {
+		u, err := url.Parse(t.flagMetricsPushgatewayURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("-metrics-pushgateway-url must be an http or https URL, e.g. http://localhost:9091, got %q", t.flagMetricsPushgatewayURL)
+		}
+	}
+
+	if t.flagProvider != "" && !sliceContains(environment.Providers, t.flagProvider) {
+		return fmt.Errorf("-provider must be one of: %s", strings.Join(environment.Providers, ", "))
+	}
+
+	if t.flagEnablePerf && t.flagPerfResources <= 0 {
+		return fmt.Errorf("-perf-resources must be positive if -enable-perf is set, got %d", t.flagPerfResources)
+	}
+
+	if t.flagSoakDuration < 0 {
+		return fmt.Errorf("-soak-duration must not be negative, got %s", t.flagSoakDuration)
+	}
+
+	if t.flagSoakMaxErrorRate < 0 || t.flagSoakMaxErrorRate > 1 {
+		return fmt.Errorf("-soak-max-error-rate must be between 0 and 1, got %v", t.flagSoakMaxErrorRate)
+	}
+
+	if _, err := resources.ParseBudgets(t.flagResourceBudgets); err != nil {
+		return fmt.Errorf("-resource-budgets is invalid: %s", err)
+	}
+
+	if t.flagResourceBudgets != "" && t.flagResourceSampleInterval <= 0 {
+		return fmt.Errorf("-resource-sample-interval must be positive if -resource-budgets is set, got %s", t.flagResourceSampleInterval)
+	}
+
+	if err := t.timeouts().Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -107,6 +419,8 @@ func (t *TestFlags) TestConfigFromFlags() *config.TestConfig {
 		Kubeconfig:    t.flagKubeconfig,
 		KubeContext:   t.flagKubecontext,
 		KubeNamespace: t.flagNamespace,
+		KubeVersion:   t.flagKubeVersion,
+		ClusterDomain: t.flagClusterDomain,
 
 		EnableMultiCluster:     t.flagEnableMultiCluster,
 		SecondaryKubeconfig:    t.flagSecondaryKubeconfig,
@@ -116,14 +430,100 @@ func (t *TestFlags) TestConfigFromFlags() *config.TestConfig {
 		EnableEnterprise:            t.flagEnableEnterprise,
 		EnterpriseLicenseSecretName: t.flagEnterpriseLicenseSecretName,
 		EnterpriseLicenseSecretKey:  t.flagEnterpriseLicenseSecretKey,
+		EnterpriseLicense:           t.flagEnterpriseLicense,
+		ConsulNamespacePrefix:       t.flagConsulNamespacePrefix,
 
 		EnableOpenshift: t.flagEnableOpenshift,
 
+		EnableWindows: t.flagEnableWindows,
+
+		EnablePerf:      t.flagEnablePerf,
+		PerfResources:   t.flagPerfResources,
+		PerfSyncTimeout: t.flagPerfSyncTimeout,
+
+		SoakDuration:     t.flagSoakDuration,
+		SoakMaxErrorRate: t.flagSoakMaxErrorRate,
+
 		ConsulImage:    t.flagConsulImage,
 		ConsulK8SImage: t.flagConsulK8sImage,
 
+		TestImageRegistry: t.flagTestImageRegistry,
+
 		NoCleanupOnFailure: t.flagNoCleanupOnFailure,
+		NoCleanup:          t.flagNoCleanup,
 		DebugDirectory:     tempDir,
-		UseKind:            t.flagUseKind,
+		JUnitOutDirectory:  t.flagJUnitOutDirectory,
+		UseKind:            t.flagUseKind || t.flagProvider == environment.ProviderKind,
+		Provider:           t.flagProvider,
+
+		CollectEnvoyAccessLogs: t.flagCollectEnvoyAccessLogs,
+
+		MetricsStatsdAddr:     t.flagMetricsStatsdAddr,
+		MetricsPushgatewayURL: t.flagMetricsPushgatewayURL,
+
+		TestRunID:            t.flagTestRunID,
+		CleanupOrphans:       t.flagCleanupOrphans,
+		CleanupOrphansMinAge: t.flagCleanupOrphansMinAge,
+
+		HelmInstallTimeout: t.flagHelmInstallTimeout,
+		HelmWait:           t.flagHelmWait,
+		HelmAtomic:         t.flagHelmAtomic,
+		HelmExtraArgs:      strings.Fields(t.flagHelmExtraArgs),
+
+		ReuseClusters: t.flagReuseClusters,
+
+		RunSecureOnly:     t.flagRunSecureOnly,
+		RunInsecureOnly:   t.flagRunInsecureOnly,
+		RunEnterpriseOnly: t.flagRunEnterpriseOnly,
+
+		SmokeOnly: t.flagSmokeOnly,
+
+		HelmValuesLogFilter: splitCommaSeparated(t.flagHelmValuesLogFilter),
+
+		Timeouts: t.timeouts(),
+
+		ResourceBudgets:        t.resourceBudgets(),
+		ResourceSampleInterval: t.flagResourceSampleInterval,
+	}
+}
+
+func (t *TestFlags) timeouts() timeouts.Timeouts {
+	return timeouts.Timeouts{
+		PodsReady:      t.flagTimeoutPodsReady,
+		WebhookReady:   t.flagTimeoutWebhookReady,
+		ControllerSync: t.flagTimeoutControllerSync,
+		TrafficCheck:   t.flagTimeoutTrafficCheck,
+	}
+}
+
+// resourceBudgets returns the parsed budgets or nil if they're not set.
+// Invalid budgets are also nil because Validate returns an error for them.
+func (t *TestFlags) resourceBudgets() resources.Budgets {
+	budgets, err := resources.ParseBudgets(t.flagResourceBudgets)
+	if err != nil || len(budgets) == 0 {
+		return nil
+	}
+	return budgets
+}
+
+// sliceContains returns true if s contains target.
+func sliceContains(s []string, target string) bool {
+	for _, elem := range s {
+		if elem == target {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommaSeparated splits s on commas, trimming whitespace
+// and dropping empty elements.
+func splitCommaSeparated(s string) []string {
+	var elems []string
+	for _, elem := range strings.Split(s, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			elems = append(elems, elem)
+		}
 	}
+	return elems
 }
@@ -2,7 +2,9 @@ package flags
 
 import (
 	"testing"
+	"time"
 
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
 	"github.com/stretchr/testify/require"
 )
 
@@ -13,6 +15,30 @@ func TestFlags_validate(t *testing.T) {
 		flagSecondaryKubecontext string
 		flagEntLicenseSecretName string
 		flagEntLicenseSecretKey  string
+		flagEntLicense           string
+		flagConsulNSPrefix       string
+		flagTestImageRegistry    string
+		flagTestRunID            string
+		flagCleanupOrphans       bool
+		flagCleanupOrphansMinAge time.Duration
+		flagProvider             string
+		flagKubeVersion          string
+		flagClusterDomain        string
+		flagEnablePerf           bool
+		flagPerfResources        int
+		flagSoakDuration         time.Duration
+		flagSoakMaxErrorRate     float64
+		flagMetricsStatsdAddr    string
+		flagMetricsPushgateway   string
+		flagTimeoutTrafficCheck  time.Duration
+		flagResourceBudgets      string
+		flagResourceInterval     time.Duration
+		flagHelmExtraArgs        string
+		flagEnableEnterprise     bool
+		flagRunSecureOnly        bool
+		flagRunInsecureOnly      bool
+		flagRunEnterpriseOnly    bool
+		flagSmokeOnly            bool
 	}
 	tests := []struct {
 		name       string
@@ -105,15 +131,334 @@ func TestFlags_validate(t *testing.T) {
 			false,
 			"",
 		},
+		{
+			"enterprise license: no error when only -enterprise-license is provided",
+			fields{
+				flagEntLicense: "license",
+			},
+			false,
+			"",
+		},
+		{
+			"enterprise license: error when -enterprise-license and -enterprise-license-secret-name are provided",
+			fields{
+				flagEntLicenseSecretName: "secret",
+				flagEntLicenseSecretKey:  "key",
+				flagEntLicense:           "license",
+			},
+			true,
+			"-enterprise-license cannot be provided together with -enterprise-license-secret-name and -enterprise-license-secret-key",
+		},
+		{
+			"provider: no error when provider is supported",
+			fields{
+				flagProvider: "gke",
+			},
+			false,
+			"",
+		},
+		{
+			"provider: error when provider is not supported",
+			fields{
+				flagProvider: "foo",
+			},
+			true,
+			"-provider must be one of: kind, gke, eks, aks",
+		},
+		{
+			"kube version: no error when the version is valid",
+			fields{
+				flagKubeVersion: "1.19",
+			},
+			false,
+			"",
+		},
+		{
+			"consul namespace prefix: no error when the prefix is valid",
+			fields{
+				flagConsulNSPrefix: "suite-a-",
+			},
+			false,
+			"",
+		},
+		{
+			"consul namespace prefix: error when the prefix is invalid",
+			fields{
+				flagConsulNSPrefix: "Suite_A",
+			},
+			true,
+			`-consul-namespace-prefix must consist of lowercase alphanumeric characters and dashes and start with an alphanumeric character, got "Suite_A"`,
+		},
+		{
+			"test image registry: no error when the registry has a path",
+			fields{
+				flagTestImageRegistry: "registry.example.com:5000/mirror",
+			},
+			false,
+			"",
+		},
+		{
+			"test image registry: error when the registry has a scheme",
+			fields{
+				flagTestImageRegistry: "https://registry.example.com",
+			},
+			true,
+			`-test-image-registry must be a registry host and an optional path without a scheme, got "https://registry.example.com"`,
+		},
+		{
+			"test run ID: error when the ID isn't a valid label value",
+			fields{
+				flagTestRunID: "ci/42",
+			},
+			true,
+			`-test-run-id must be a valid label value: "ci/42" must be at most 63 alphanumeric characters, dashes, underscores or dots and start and end with an alphanumeric character`,
+		},
+		{
+			"cleanup orphans: no error when the minimum age is positive",
+			fields{
+				flagTestRunID:            "ci-42",
+				flagCleanupOrphans:       true,
+				flagCleanupOrphansMinAge: 3 * time.Hour,
+			},
+			false,
+			"",
+		},
+		{
+			"cleanup orphans: error when the minimum age is not positive",
+			fields{
+				flagCleanupOrphans: true,
+			},
+			true,
+			"-cleanup-orphans-min-age must be positive if -cleanup-orphans is set, got 0s",
+		},
+		{
+			"kube version: error when the version is invalid",
+			fields{
+				flagKubeVersion: "latest",
+			},
+			true,
+			`-kube-version must be a Kubernetes version like 1.19: invalid Kubernetes version "latest"`,
+		},
+		{
+			"cluster domain: no error when the domain is valid",
+			fields{
+				flagClusterDomain: "my-cluster.example",
+			},
+			false,
+			"",
+		},
+		{
+			"cluster domain: error when the domain has a trailing dot",
+			fields{
+				flagClusterDomain: "cluster.local.",
+			},
+			true,
+			`-cluster-domain must be a DNS name without leading or trailing dots, e.g. cluster.local, got "cluster.local."`,
+		},
+		{
+			"timeouts: error when a timeout is not positive",
+			fields{
+				flagTimeoutTrafficCheck: -1 * time.Second,
+			},
+			true,
+			"-timeout-traffic-check must be positive, got -1s",
+		},
+		{
+			"enable perf: errors when the number of resources is not positive",
+			fields{
+				flagEnablePerf:    true,
+				flagPerfResources: 0,
+			},
+			true,
+			"-perf-resources must be positive if -enable-perf is set, got 0",
+		},
+		{
+			"enable perf: no error when the number of resources is positive",
+			fields{
+				flagEnablePerf:    true,
+				flagPerfResources: 200,
+			},
+			false,
+			"",
+		},
+		{
+			"soak: error when the duration is negative",
+			fields{
+				flagSoakDuration: -1 * time.Minute,
+			},
+			true,
+			"-soak-duration must not be negative, got -1m0s",
+		},
+		{
+			"soak: error when the max error rate is above 1",
+			fields{
+				flagSoakDuration:     time.Hour,
+				flagSoakMaxErrorRate: 5,
+			},
+			true,
+			"-soak-max-error-rate must be between 0 and 1, got 5",
+		},
+		{
+			"soak: no error when the duration and max error rate are valid",
+			fields{
+				flagSoakDuration:     time.Hour,
+				flagSoakMaxErrorRate: 0.01,
+			},
+			false,
+			"",
+		},
+		{
+			"metrics: no error when the statsd address and pushgateway URL are valid",
+			fields{
+				flagMetricsStatsdAddr:  "localhost:8125",
+				flagMetricsPushgateway: "http://localhost:9091",
+			},
+			false,
+			"",
+		},
+		{
+			"metrics: error when the statsd address has no port",
+			fields{
+				flagMetricsStatsdAddr: "localhost",
+			},
+			true,
+			"-metrics-statsd-addr must be a host and port, e.g. localhost:8125: address localhost: missing port in address",
+		},
+		{
+			"metrics: error when the pushgateway URL has no scheme",
+			fields{
+				flagMetricsPushgateway: "localhost:9091",
+			},
+			true,
+			`-metrics-pushgateway-url must be an http or https URL, e.g. http://localhost:9091, got "localhost:9091"`,
+		},
+		{
+			"resource budgets: no error when the budgets are valid",
+			fields{
+				flagResourceBudgets:  "server.memory=200Mi,envoy-sidecar.cpu=50m",
+				flagResourceInterval: 10 * time.Second,
+			},
+			false,
+			"",
+		},
+		{
+			"resource budgets: error when a budget is invalid",
+			fields{
+				flagResourceBudgets:  "server.disk=1Gi",
+				flagResourceInterval: 10 * time.Second,
+			},
+			true,
+			`-resource-budgets is invalid: invalid resource budget "server.disk=1Gi": resource must be cpu or memory`,
+		},
+		{
+			"resource budgets: error when the sample interval is not positive",
+			fields{
+				flagResourceBudgets: "server.memory=200Mi",
+			},
+			true,
+			"-resource-sample-interval must be positive if -resource-budgets is set, got 0s",
+		},
+		{
+			"case filter: errors when secure only and insecure only are both set",
+			fields{
+				flagRunSecureOnly:   true,
+				flagRunInsecureOnly: true,
+			},
+			true,
+			"-run-secure-only and -run-insecure-only cannot be used together",
+		},
+		{
+			"case filter: errors when enterprise only is set without enterprise",
+			fields{
+				flagRunEnterpriseOnly: true,
+			},
+			true,
+			"-run-enterprise-only requires -enable-enterprise",
+		},
+		{
+			"case filter: no error when enterprise only and secure only are set with enterprise",
+			fields{
+				flagEnableEnterprise:  true,
+				flagRunEnterpriseOnly: true,
+				flagRunSecureOnly:     true,
+			},
+			false,
+			"",
+		},
+		{
+			"smoke only: no error when combined with the case filter",
+			fields{
+				flagSmokeOnly:       true,
+				flagRunInsecureOnly: true,
+			},
+			false,
+			"",
+		},
+		{
+			"helm extra args: no error when the flags are supported",
+			fields{
+				flagHelmExtraArgs: "--kube-apiserver=https://10.0.0.1:6443 --debug --timeout 20m",
+			},
+			false,
+			"",
+		},
+		{
+			"helm extra args: error when a flag is not supported",
+			fields{
+				flagHelmExtraArgs: "--kube-context=other",
+			},
+			true,
+			`-helm-extra-args is invalid: invalid extra helm args "--kube-context=other": unknown flag: --kube-context`,
+		},
+		{
+			"provider: no error when multi cluster is enabled with kind and secondary kubeconfig and kubecontext are empty",
+			fields{
+				flagEnableMultiCluster: true,
+				flagProvider:           "kind",
+			},
+			false,
+			"",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			defaultTimeouts := timeouts.Defaults()
 			tf := &TestFlags{
 				flagEnableMultiCluster:          tt.fields.flagEnableMultiCluster,
 				flagSecondaryKubeconfig:         tt.fields.flagSecondaryKubeconfig,
 				flagSecondaryKubecontext:        tt.fields.flagSecondaryKubecontext,
 				flagEnterpriseLicenseSecretName: tt.fields.flagEntLicenseSecretName,
 				flagEnterpriseLicenseSecretKey:  tt.fields.flagEntLicenseSecretKey,
+				flagEnterpriseLicense:           tt.fields.flagEntLicense,
+				flagConsulNamespacePrefix:       tt.fields.flagConsulNSPrefix,
+				flagTestImageRegistry:           tt.fields.flagTestImageRegistry,
+				flagTestRunID:                   tt.fields.flagTestRunID,
+				flagCleanupOrphans:              tt.fields.flagCleanupOrphans,
+				flagCleanupOrphansMinAge:        tt.fields.flagCleanupOrphansMinAge,
+				flagProvider:                    tt.fields.flagProvider,
+				flagKubeVersion:                 tt.fields.flagKubeVersion,
+				flagClusterDomain:               tt.fields.flagClusterDomain,
+				flagEnablePerf:                  tt.fields.flagEnablePerf,
+				flagPerfResources:               tt.fields.flagPerfResources,
+				flagSoakDuration:                tt.fields.flagSoakDuration,
+				flagSoakMaxErrorRate:            tt.fields.flagSoakMaxErrorRate,
+				flagMetricsStatsdAddr:           tt.fields.flagMetricsStatsdAddr,
+				flagMetricsPushgatewayURL:       tt.fields.flagMetricsPushgateway,
+				flagTimeoutPodsReady:            defaultTimeouts.PodsReady,
+				flagTimeoutWebhookReady:         defaultTimeouts.WebhookReady,
+				flagTimeoutControllerSync:       defaultTimeouts.ControllerSync,
+				flagTimeoutTrafficCheck:         defaultTimeouts.TrafficCheck,
+				flagResourceBudgets:             tt.fields.flagResourceBudgets,
+				flagResourceSampleInterval:      tt.fields.flagResourceInterval,
+				flagHelmExtraArgs:               tt.fields.flagHelmExtraArgs,
+				flagEnableEnterprise:            tt.fields.flagEnableEnterprise,
+				flagRunSecureOnly:               tt.fields.flagRunSecureOnly,
+				flagRunInsecureOnly:             tt.fields.flagRunInsecureOnly,
+				flagRunEnterpriseOnly:           tt.fields.flagRunEnterpriseOnly,
+				flagSmokeOnly:                   tt.fields.flagSmokeOnly,
+			}
+			if tt.fields.flagTimeoutTrafficCheck != 0 {
+				tf.flagTimeoutTrafficCheck = tt.fields.flagTimeoutTrafficCheck
 			}
 			err := tf.Validate()
 			if tt.wantErr {
@@ -124,3 +469,8 @@ func TestFlags_validate(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitCommaSeparated(t *testing.T) {
+	require.Nil(t, splitCommaSeparated(""))
+	require.Equal(t, []string{"global.tls", "connectInject"}, splitCommaSeparated("global.tls, connectInject,"))
+}
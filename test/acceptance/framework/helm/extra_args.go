@@ -0,0 +1,117 @@
+package helm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"helm.sh/helm/v3/pkg/cli"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// extraArgs are the helm CLI flags from Options.ExtraArgs
+// that the actions in this package support.
+type extraArgs struct {
+	kubeAPIServer       string
+	kubeToken           string
+	kubeAsUser          string
+	kubeAsGroups        []string
+	kubeCAFile          string
+	kubeInsecureSkipTLS bool
+	debug               bool
+	repositoryConfig    string
+	repositoryCache     string
+	timeout             time.Duration
+	// wait and atomic are nil unless the flags are set so that
+	// they only override Options.Wait and Options.Atomic if they are.
+	wait                *bool
+	atomic              *bool
+	noHooks             bool
+	skipCRDs            bool
+	disableOpenAPICheck bool
+}
+
+// ValidateExtraArgs returns an error if args contain flags that
+// Options.ExtraArgs doesn't support or values that can't be parsed.
+func ValidateExtraArgs(args []string) error {
+	_, err := parseExtraArgs(args)
+	return err
+}
+
+// parseExtraArgs parses args like the helm CLI parses its flags.
+// Only flags are supported, positional arguments are an error.
+func parseExtraArgs(args []string) (*extraArgs, error) {
+	a := &extraArgs{}
+	fs := pflag.NewFlagSet("helm", pflag.ContinueOnError)
+	fs.Usage = func() {}
+
+	// The global flags of the helm CLI, except the ones that
+	// the framework sets itself from the Kubernetes context.
+	fs.StringVar(&a.kubeAPIServer, "kube-apiserver", "", "")
+	fs.StringVar(&a.kubeToken, "kube-token", "", "")
+	fs.StringVar(&a.kubeAsUser, "kube-as-user", "", "")
+	fs.StringArrayVar(&a.kubeAsGroups, "kube-as-group", nil, "")
+	fs.StringVar(&a.kubeCAFile, "kube-ca-file", "", "")
+	fs.BoolVar(&a.kubeInsecureSkipTLS, "kube-insecure-skip-tls-verify", false, "")
+	fs.BoolVar(&a.debug, "debug", false, "")
+	fs.StringVar(&a.repositoryConfig, "repository-config", "", "")
+	fs.StringVar(&a.repositoryCache, "repository-cache", "", "")
+
+	// The flags of the install, upgrade and uninstall commands.
+	fs.DurationVar(&a.timeout, "timeout", 0, "")
+	wait := fs.Bool("wait", false, "")
+	atomic := fs.Bool("atomic", false, "")
+	fs.BoolVar(&a.noHooks, "no-hooks", false, "")
+	fs.BoolVar(&a.skipCRDs, "skip-crds", false, "")
+	fs.BoolVar(&a.disableOpenAPICheck, "disable-openapi-validation", false, "")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("invalid extra helm args %q: %s", strings.Join(args, " "), err)
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("invalid extra helm args %q: unexpected arguments %q", strings.Join(args, " "), fs.Args())
+	}
+	if fs.Changed("wait") {
+		a.wait = wait
+	}
+	if fs.Changed("atomic") {
+		a.atomic = atomic
+	}
+	return a, nil
+}
+
+// applyKubeFlags sets the Kubernetes connection flags of a on flags.
+func (a *extraArgs) applyKubeFlags(flags *genericclioptions.ConfigFlags) {
+	if a.kubeAPIServer != "" {
+		flags.APIServer = &a.kubeAPIServer
+	}
+	if a.kubeToken != "" {
+		flags.BearerToken = &a.kubeToken
+	}
+	if a.kubeAsUser != "" {
+		flags.Impersonate = &a.kubeAsUser
+	}
+	if len(a.kubeAsGroups) > 0 {
+		flags.ImpersonateGroup = &a.kubeAsGroups
+	}
+	if a.kubeCAFile != "" {
+		flags.CAFile = &a.kubeCAFile
+	}
+	if a.kubeInsecureSkipTLS {
+		flags.Insecure = &a.kubeInsecureSkipTLS
+	}
+}
+
+// settings returns the helm CLI settings with the repository flags of a.
+func (a *extraArgs) settings() *cli.EnvSettings {
+	settings := cli.New()
+	if a.repositoryConfig != "" {
+		settings.RepositoryConfig = a.repositoryConfig
+	}
+	if a.repositoryCache != "" {
+		settings.RepositoryCache = a.repositoryCache
+	}
+	settings.Debug = a.debug
+	return settings
+}
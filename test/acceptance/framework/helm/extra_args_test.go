@@ -0,0 +1,48 @@
+package helm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestParseExtraArgs(t *testing.T) {
+	args, err := parseExtraArgs([]string{"--kube-apiserver=https://10.0.0.1:6443", "--debug", "--timeout", "10m", "--kube-as-group=a", "--kube-as-group=b"})
+	require.NoError(t, err)
+	require.Equal(t, "https://10.0.0.1:6443", args.kubeAPIServer)
+	require.True(t, args.debug)
+	require.Equal(t, 10*time.Minute, timeout(&Options{Timeout: time.Minute}, args))
+
+	flags := &genericclioptions.ConfigFlags{}
+	args.applyKubeFlags(flags)
+	require.Equal(t, "https://10.0.0.1:6443", *flags.APIServer)
+	require.Equal(t, []string{"a", "b"}, *flags.ImpersonateGroup)
+	require.Nil(t, flags.BearerToken)
+
+	args, err = parseExtraArgs(nil)
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, timeout(&Options{Timeout: time.Minute}, args))
+	require.True(t, boolArg(args.wait, true))
+}
+
+func TestParseExtraArgs_BoolPrecedence(t *testing.T) {
+	args, err := parseExtraArgs([]string{"--wait=false", "--atomic"})
+	require.NoError(t, err)
+	require.False(t, boolArg(args.wait, true), "an extra arg should take precedence over the option")
+	require.True(t, boolArg(args.atomic, false), "an extra arg should take precedence over the option")
+}
+
+func TestParseExtraArgs_Errors(t *testing.T) {
+	cases := map[string][]string{
+		"unknown flag":    {"--kube-context=other"},
+		"invalid value":   {"--timeout=soon"},
+		"positional args": {"--debug", "consul"},
+	}
+	for name, args := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, ValidateExtraArgs(args))
+		})
+	}
+}
@@ -0,0 +1,580 @@
+// Package helm runs Helm actions, such as installing and upgrading releases,
+// using the Helm Go SDK rather than the helm binary. The function names and
+// options mirror terratest's helm module, which shells out to helm.
+package helm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/images"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/helmpath"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/repo"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// ErrReleaseNotFound is returned, wrapped in an *Error, by actions on a release that doesn't exist.
+var ErrReleaseNotFound = driver.ErrReleaseNotFound
+
+// Error is returned when a Helm action fails. Use errors.Is or errors.As
+// to check for the underlying error, e.g. ErrReleaseNotFound.
+type Error struct {
+	Action  string
+	Release string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("helm %s of release %s failed: %s", e.Action, e.Release, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Options configures Helm actions.
+type Options struct {
+	// KubectlOptions are the kubeconfig, context and namespace
+	// of the Kubernetes cluster to run the actions against.
+	KubectlOptions *terratestk8s.KubectlOptions
+
+	// SetValues are the values set with --set. They take precedence over ValuesFiles.
+	SetValues map[string]string
+	// SetFileValues are the values set with --set-file, mapping keys to the files
+	// that contain their values. They take precedence over SetValues.
+	SetFileValues map[string]string
+	// ValuesFiles are the values files passed with -f.
+	ValuesFiles []string
+
+	// Version is the version of the chart if it's installed from a repository.
+	Version string
+
+	// Timeout is the time to wait for hooks and, if Wait is true, for the
+	// release's resources to be ready. It defaults to 5 minutes like the helm CLI.
+	Timeout time.Duration
+	// Wait makes installs wait until the release's resources are ready.
+	Wait bool
+	// Atomic makes installs roll back if they fail. It implies Wait.
+	Atomic bool
+
+	// PostRenderer, if set, modifies the rendered manifests
+	// before they're applied by installs and upgrades.
+	PostRenderer postrender.PostRenderer
+
+	// ImageRegistry, if set, is the registry that the images in the chart's
+	// values are pulled from instead of their original registries,
+	// see the images package.
+	ImageRegistry string
+
+	// ExtraArgs are helm CLI flags, e.g. --kube-apiserver=https://10.0.0.1:6443,
+	// --debug or --timeout=10m, that are applied to every action. They take
+	// precedence over the other options. The supported flags are the helm
+	// CLI's Kubernetes connection, repository and --debug flags and the
+	// --timeout, --wait, --atomic, --no-hooks, --skip-crds and
+	// --disable-openapi-validation flags of the actions that have them.
+	ExtraArgs []string
+}
+
+// InstallE installs chart as a release named releaseName. The chart can be
+// a path to a chart directory or a chart in a repository, e.g. hashicorp/consul.
+func InstallE(t *testing.T, options *Options, chartName, releaseName string) error {
+	args, err := parseExtraArgs(options.ExtraArgs)
+	if err != nil {
+		return &Error{Action: "install", Release: releaseName, Err: err}
+	}
+	cfg, namespace, err := actionConfig(t, options, args)
+	if err != nil {
+		return &Error{Action: "install", Release: releaseName, Err: err}
+	}
+
+	install := action.NewInstall(cfg)
+	install.ReleaseName = releaseName
+	install.Namespace = namespace
+	install.Version = options.Version
+	install.Timeout = timeout(options, args)
+	install.Wait = boolArg(args.wait, options.Wait)
+	install.Atomic = boolArg(args.atomic, options.Atomic)
+	install.DisableHooks = args.noHooks
+	install.SkipCRDs = args.skipCRDs
+	install.DisableOpenAPIValidation = args.disableOpenAPICheck
+	install.PostRenderer = options.PostRenderer
+
+	if err := runWithChart(options, args, chartName, &install.ChartPathOptions, func(chrt *chart.Chart, vals map[string]interface{}) error {
+		rel, err := install.Run(chrt, vals)
+		if args.debug && rel != nil {
+			logRelease(t, rel)
+		}
+		return err
+	}); err != nil {
+		return &Error{Action: "install", Release: releaseName, Err: err}
+	}
+	return nil
+}
+
+// UpgradeE upgrades the release named releaseName to chart.
+func UpgradeE(t *testing.T, options *Options, chartName, releaseName string) error {
+	args, err := parseExtraArgs(options.ExtraArgs)
+	if err != nil {
+		return &Error{Action: "upgrade", Release: releaseName, Err: err}
+	}
+	cfg, namespace, err := actionConfig(t, options, args)
+	if err != nil {
+		return &Error{Action: "upgrade", Release: releaseName, Err: err}
+	}
+
+	upgrade := action.NewUpgrade(cfg)
+	upgrade.Namespace = namespace
+	upgrade.Version = options.Version
+	upgrade.Timeout = timeout(options, args)
+	upgrade.Wait = boolArg(args.wait, false)
+	upgrade.Atomic = boolArg(args.atomic, false)
+	upgrade.DisableHooks = args.noHooks
+	upgrade.SkipCRDs = args.skipCRDs
+	upgrade.DisableOpenAPIValidation = args.disableOpenAPICheck
+	upgrade.PostRenderer = options.PostRenderer
+
+	if err := runWithChart(options, args, chartName, &upgrade.ChartPathOptions, func(chrt *chart.Chart, vals map[string]interface{}) error {
+		rel, err := upgrade.Run(releaseName, chrt, vals)
+		if args.debug && rel != nil {
+			logRelease(t, rel)
+		}
+		return err
+	}); err != nil {
+		return &Error{Action: "upgrade", Release: releaseName, Err: err}
+	}
+	return nil
+}
+
+// Upgrade is like UpgradeE but fails the test if there's an error.
+func Upgrade(t *testing.T, options *Options, chartName, releaseName string) {
+	t.Helper()
+
+	if err := UpgradeE(t, options, chartName, releaseName); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// DeleteE uninstalls the release named releaseName.
+func DeleteE(t *testing.T, options *Options, releaseName string) error {
+	args, err := parseExtraArgs(options.ExtraArgs)
+	if err != nil {
+		return &Error{Action: "uninstall", Release: releaseName, Err: err}
+	}
+	cfg, _, err := actionConfig(t, options, args)
+	if err != nil {
+		return &Error{Action: "uninstall", Release: releaseName, Err: err}
+	}
+
+	uninstall := action.NewUninstall(cfg)
+	uninstall.Timeout = timeout(options, args)
+	uninstall.DisableHooks = args.noHooks
+	if _, err := uninstall.Run(releaseName); err != nil {
+		return &Error{Action: "uninstall", Release: releaseName, Err: err}
+	}
+	return nil
+}
+
+// StatusE returns a summary of the status of the release
+// named releaseName, similar to the output of helm status.
+func StatusE(t *testing.T, options *Options, releaseName string) (string, error) {
+	args, err := parseExtraArgs(options.ExtraArgs)
+	if err != nil {
+		return "", &Error{Action: "status", Release: releaseName, Err: err}
+	}
+	cfg, _, err := actionConfig(t, options, args)
+	if err != nil {
+		return "", &Error{Action: "status", Release: releaseName, Err: err}
+	}
+
+	rel, err := action.NewStatus(cfg).Run(releaseName)
+	if err != nil {
+		return "", &Error{Action: "status", Release: releaseName, Err: err}
+	}
+	return formatStatus(rel), nil
+}
+
+// Release is a summary of a Helm release.
+type Release struct {
+	Name string
+	// Chart is the chart name and version, e.g. consul-0.27.0.
+	Chart  string
+	Status string
+}
+
+// ListReleasesE returns the deployed and failed releases
+// in the namespace of the options, like helm list.
+func ListReleasesE(t *testing.T, options *Options) ([]Release, error) {
+	args, err := parseExtraArgs(options.ExtraArgs)
+	if err != nil {
+		return nil, &Error{Action: "list", Err: err}
+	}
+	cfg, _, err := actionConfig(t, options, args)
+	if err != nil {
+		return nil, &Error{Action: "list", Err: err}
+	}
+
+	list := action.NewList(cfg)
+	list.SetStateMask()
+	rels, err := list.Run()
+	if err != nil {
+		return nil, &Error{Action: "list", Err: err}
+	}
+
+	var releases []Release
+	for _, rel := range rels {
+		releases = append(releases, Release{
+			Name:   rel.Name,
+			Chart:  fmt.Sprintf("%s-%s", rel.Chart.Metadata.Name, rel.Chart.Metadata.Version),
+			Status: rel.Info.Status.String(),
+		})
+	}
+	return releases, nil
+}
+
+// RenderTemplateE renders the templates of chart with the values from options
+// without contacting the Kubernetes cluster, like helm template --show-only.
+// templateFiles are paths relative to the chart, e.g. templates/server-service.yaml.
+// The rendered templates are returned as a single YAML stream.
+func RenderTemplateE(t *testing.T, options *Options, chartName, releaseName string, templateFiles []string) (string, error) {
+	args, err := parseExtraArgs(options.ExtraArgs)
+	if err != nil {
+		return "", &Error{Action: "template", Release: releaseName, Err: err}
+	}
+	cfg := &action.Configuration{Log: func(string, ...interface{}) {}}
+
+	install := action.NewInstall(cfg)
+	install.ReleaseName = releaseName
+	install.Namespace = "default"
+	if options.KubectlOptions != nil && options.KubectlOptions.Namespace != "" {
+		install.Namespace = options.KubectlOptions.Namespace
+	}
+	install.Version = options.Version
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.SkipCRDs = args.skipCRDs
+
+	var rel *release.Release
+	if err := runWithChart(options, args, chartName, &install.ChartPathOptions, func(chrt *chart.Chart, vals map[string]interface{}) error {
+		var err error
+		rel, err = install.Run(chrt, vals)
+		return err
+	}); err != nil {
+		return "", &Error{Action: "template", Release: releaseName, Err: err}
+	}
+
+	rendered, err := selectTemplates(rel.Chart.Metadata.Name, rel.Manifest, templateFiles)
+	if err != nil {
+		return "", &Error{Action: "template", Release: releaseName, Err: err}
+	}
+	return rendered, nil
+}
+
+// AddRepo adds the chart repository at url as name so that charts
+// can be installed from it, e.g. name/consul, like helm repo add.
+// It fails the test if there's an error.
+func AddRepo(t *testing.T, options *Options, name, url string) {
+	t.Helper()
+
+	if err := AddRepoE(t, options, name, url); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// AddRepoE adds the chart repository at url as name, like helm repo add.
+func AddRepoE(t *testing.T, options *Options, name, url string) error {
+	args, err := parseExtraArgs(options.ExtraArgs)
+	if err != nil {
+		return &Error{Action: "repo add", Release: name, Err: err}
+	}
+	settings := args.settings()
+	entry := &repo.Entry{Name: name, URL: url}
+
+	chartRepo, err := repo.NewChartRepository(entry, getter.All(settings))
+	if err != nil {
+		return &Error{Action: "repo add", Release: name, Err: err}
+	}
+	chartRepo.CachePath = settings.RepositoryCache
+	if _, err := chartRepo.DownloadIndexFile(); err != nil {
+		return &Error{Action: "repo add", Release: name, Err: err}
+	}
+
+	repoFile, err := loadRepoFile(settings.RepositoryConfig)
+	if err != nil {
+		return &Error{Action: "repo add", Release: name, Err: err}
+	}
+	repoFile.Update(entry)
+	if err := repoFile.WriteFile(settings.RepositoryConfig, 0644); err != nil {
+		return &Error{Action: "repo add", Release: name, Err: err}
+	}
+	return nil
+}
+
+// RemoveRepoE removes the chart repository name, like helm repo remove.
+func RemoveRepoE(t *testing.T, options *Options, name string) error {
+	args, err := parseExtraArgs(options.ExtraArgs)
+	if err != nil {
+		return &Error{Action: "repo remove", Release: name, Err: err}
+	}
+	settings := args.settings()
+
+	repoFile, err := loadRepoFile(settings.RepositoryConfig)
+	if err != nil {
+		return &Error{Action: "repo remove", Release: name, Err: err}
+	}
+	if !repoFile.Remove(name) {
+		return &Error{Action: "repo remove", Release: name, Err: fmt.Errorf("no repo named %q found", name)}
+	}
+	if err := repoFile.WriteFile(settings.RepositoryConfig, 0644); err != nil {
+		return &Error{Action: "repo remove", Release: name, Err: err}
+	}
+	os.Remove(filepath.Join(settings.RepositoryCache, helmpath.CacheIndexFile(name)))
+	os.Remove(filepath.Join(settings.RepositoryCache, helmpath.CacheChartsFile(name)))
+	return nil
+}
+
+// loadRepoFile loads the repositories file at path,
+// or returns an empty one if it doesn't exist yet.
+func loadRepoFile(path string) (*repo.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return repo.NewFile(), nil
+	}
+	return repo.LoadFile(path)
+}
+
+// timeout returns the timeout of the extra args, of options
+// or the helm CLI's default timeout, in that order.
+func timeout(options *Options, args *extraArgs) time.Duration {
+	if args.timeout != 0 {
+		return args.timeout
+	}
+	if options.Timeout == 0 {
+		return defaultTimeout
+	}
+	return options.Timeout
+}
+
+// boolArg returns the value of a boolean extra arg if it's set,
+// otherwise the value of the option it overrides.
+func boolArg(arg *bool, option bool) bool {
+	if arg != nil {
+		return *arg
+	}
+	return option
+}
+
+// defaultTimeout is the default timeout of the helm CLI.
+const defaultTimeout = 5 * time.Minute
+
+// runWithChart locates and loads the chart, merges the values from options
+// and calls run with them.
+func runWithChart(options *Options, args *extraArgs, chartName string, pathOptions *action.ChartPathOptions, run func(*chart.Chart, map[string]interface{}) error) error {
+	settings := args.settings()
+	chartPath, err := pathOptions.LocateChart(chartName, settings)
+	if err != nil {
+		return err
+	}
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return err
+	}
+	vals, err := mergeValues(options, getter.All(settings))
+	if err != nil {
+		return err
+	}
+	if options.ImageRegistry != "" {
+		if err := rewriteImageValues(chrt, vals, options.ImageRegistry); err != nil {
+			return err
+		}
+	}
+	return run(chrt, vals)
+}
+
+// rewriteImageValues sets every image value of the chart, e.g. global.image or
+// global.imageEnvoy, to be pulled from registry. It uses the chart's defaults
+// for the values that vals doesn't set, so that it works for any chart version.
+func rewriteImageValues(chrt *chart.Chart, vals map[string]interface{}, registry string) error {
+	coalesced, err := chartutil.CoalesceValues(chrt, vals)
+	if err != nil {
+		return err
+	}
+	var rewrite func(values map[string]interface{}, path []string)
+	rewrite = func(values map[string]interface{}, path []string) {
+		for key, value := range values {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				rewrite(v, append(path, key))
+			case string:
+				if strings.HasPrefix(key, "image") && v != "" {
+					setValue(vals, append(path, key), images.Rewrite(registry, v))
+				}
+			}
+		}
+	}
+	rewrite(coalesced, nil)
+	return nil
+}
+
+// setValue sets the value at path in vals, creating the maps along the path.
+func setValue(vals map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := vals[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			vals[key] = next
+		}
+		vals = next
+	}
+	vals[path[len(path)-1]] = value
+}
+
+// mergeValues merges the values files, set values and set file values from options
+// in the same way as the helm CLI's -f, --set and --set-file flags.
+func mergeValues(options *Options, providers getter.Providers) (map[string]interface{}, error) {
+	valueOpts := &values.Options{
+		ValueFiles: options.ValuesFiles,
+		Values:     setFlagValues(options.SetValues),
+		FileValues: setFlagValues(options.SetFileValues),
+	}
+	return valueOpts.MergeValues(providers)
+}
+
+// setFlagValues formats values as key=value strings like the --set flags expect.
+// They're sorted so that the result doesn't depend on map order
+// if keys overlap, e.g. server and server.replicas.
+func setFlagValues(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	flagValues := make([]string, 0, len(keys))
+	for _, k := range keys {
+		flagValues = append(flagValues, fmt.Sprintf("%s=%s", k, values[k]))
+	}
+	return flagValues
+}
+
+// selectTemplates returns the documents of manifest that were rendered
+// from templateFiles, or all documents if templateFiles is empty.
+func selectTemplates(chartName, manifest string, templateFiles []string) (string, error) {
+	docs := releaseutil.SplitManifests(manifest)
+	keys := make([]string, 0, len(docs))
+	for k := range docs {
+		keys = append(keys, k)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var rendered strings.Builder
+	for _, templateFile := range templateFiles {
+		source := fmt.Sprintf("# Source: %s/%s\n", chartName, templateFile)
+		found := false
+		for _, k := range keys {
+			if strings.HasPrefix(docs[k]+"\n", source) {
+				fmt.Fprintf(&rendered, "---\n%s\n", docs[k])
+				found = true
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("could not find template %s in chart", templateFile)
+		}
+	}
+	if len(templateFiles) == 0 {
+		for _, k := range keys {
+			fmt.Fprintf(&rendered, "---\n%s\n", docs[k])
+		}
+	}
+	return rendered.String(), nil
+}
+
+// formatStatus formats the status of a release like helm status.
+func formatStatus(rel *release.Release) string {
+	var status strings.Builder
+	fmt.Fprintf(&status, "NAME: %s\n", rel.Name)
+	fmt.Fprintf(&status, "LAST DEPLOYED: %s\n", rel.Info.LastDeployed.Format(time.ANSIC))
+	fmt.Fprintf(&status, "NAMESPACE: %s\n", rel.Namespace)
+	fmt.Fprintf(&status, "STATUS: %s\n", rel.Info.Status.String())
+	fmt.Fprintf(&status, "REVISION: %d\n", rel.Version)
+	if rel.Info.Description != "" {
+		fmt.Fprintf(&status, "DESCRIPTION: %s\n", rel.Info.Description)
+	}
+	for _, hook := range rel.Hooks {
+		if hook.LastRun.Phase == release.HookPhaseUnknown {
+			continue
+		}
+		fmt.Fprintf(&status, "HOOK %s: %s\n", hook.Name, hook.LastRun.Phase)
+	}
+	return status.String()
+}
+
+// logRelease logs the computed values and the manifests of rel,
+// like the helm CLI's --debug flag.
+func logRelease(t *testing.T, rel *release.Release) {
+	computed, err := chartutil.CoalesceValues(rel.Chart, rel.Config)
+	if err != nil {
+		logger.Logf(t, "could not compute the values of release %s: %s", rel.Name, err)
+	} else if yaml, err := computed.YAML(); err == nil {
+		logger.Logf(t, "COMPUTED VALUES of release %s:\n%s", rel.Name, yaml)
+	}
+	for _, hook := range rel.Hooks {
+		logger.Logf(t, "HOOK %s of release %s:\n%s", hook.Path, rel.Name, hook.Manifest)
+	}
+	logger.Logf(t, "MANIFEST of release %s:\n%s", rel.Name, rel.Manifest)
+}
+
+// actionConfig returns the Helm action configuration for the Kubernetes
+// cluster and namespace of options, and the namespace. If the options
+// don't set a namespace, the namespace of the kubeconfig context is used.
+func actionConfig(t *testing.T, options *Options, args *extraArgs) (*action.Configuration, string, error) {
+	kubectlOptions := options.KubectlOptions
+	if kubectlOptions == nil {
+		kubectlOptions = &terratestk8s.KubectlOptions{}
+	}
+	configPath, err := kubectlOptions.GetConfigPath(t)
+	if err != nil {
+		return nil, "", err
+	}
+	contextName := kubectlOptions.ContextName
+	namespace := kubectlOptions.Namespace
+	flags := &genericclioptions.ConfigFlags{
+		KubeConfig: &configPath,
+		Context:    &contextName,
+		Namespace:  &namespace,
+	}
+	args.applyKubeFlags(flags)
+	if namespace == "" {
+		namespace, _, err = flags.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	cfg := &action.Configuration{}
+	err = cfg.Init(flags, namespace, "secret", func(format string, v ...interface{}) {
+		logger.Logf(t, format, v...)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return cfg, namespace, nil
+}
@@ -0,0 +1,95 @@
+package helm
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+func TestRenderTemplateE(t *testing.T) {
+	options := &Options{
+		SetValues: map[string]string{"server.replicas": "5"},
+	}
+	rendered, err := RenderTemplateE(t, options, config.HelmChartPath, "test", []string{"templates/server-statefulset.yaml"})
+	require.NoError(t, err)
+	require.Contains(t, rendered, "# Source: consul/templates/server-statefulset.yaml")
+	require.Contains(t, rendered, "name: test-consul-server")
+	require.Contains(t, rendered, "replicas: 5")
+	require.NotContains(t, rendered, "# Source: consul/templates/server-service.yaml")
+}
+
+func TestRenderTemplateE_MissingTemplate(t *testing.T) {
+	_, err := RenderTemplateE(t, &Options{}, config.HelmChartPath, "test", []string{"templates/does-not-exist.yaml"})
+	require.EqualError(t, err, "helm template of release test failed: could not find template templates/does-not-exist.yaml in chart")
+
+	var helmErr *Error
+	require.True(t, errors.As(err, &helmErr))
+	require.Equal(t, "template", helmErr.Action)
+}
+
+// Test that the images in the chart's default values and in the
+// values from options are rewritten to be pulled from the registry.
+func TestRenderTemplateE_ImageRegistry(t *testing.T) {
+	options := &Options{
+		SetValues: map[string]string{
+			"global.imageK8S":       "docker.io/hashicorp/consul-k8s:dev",
+			"connectInject.enabled": "true",
+		},
+		ImageRegistry: "registry.example.com/mirror",
+	}
+	rendered, err := RenderTemplateE(t, options, config.HelmChartPath, "test", []string{
+		"templates/server-statefulset.yaml",
+		"templates/connect-inject-deployment.yaml",
+	})
+	require.NoError(t, err)
+	require.Contains(t, rendered, `image: "registry.example.com/mirror/hashicorp/consul:`)
+	require.Contains(t, rendered, `image: "registry.example.com/mirror/hashicorp/consul-k8s:dev"`)
+	require.Contains(t, rendered, `-consul-image="registry.example.com/mirror/hashicorp/consul:`)
+	require.Contains(t, rendered, `-envoy-image="registry.example.com/mirror/envoyproxy/envoy-alpine:`)
+	require.NotContains(t, rendered, `image: "hashicorp/`)
+}
+
+func TestMergeValues(t *testing.T) {
+	valuesFile := filepath.Join(t.TempDir(), "values.yaml")
+	require.NoError(t, ioutil.WriteFile(valuesFile, []byte(`
+global:
+  name: from-file
+server:
+  replicas: 3
+`), 0600))
+
+	// Multi-line values with commas and quotes can't be passed with --set.
+	extraConfig := "{\n  \"log_level\": \"DEBUG\",\n  \"ui\": true\n}\n"
+	extraConfigFile := filepath.Join(t.TempDir(), "extra-config.json")
+	require.NoError(t, ioutil.WriteFile(extraConfigFile, []byte(extraConfig), 0600))
+
+	vals, err := mergeValues(&Options{
+		ValuesFiles: []string{valuesFile},
+		SetValues: map[string]string{
+			"server.replicas":    "1",
+			"client.enabled":     "false",
+			"server.extraArgs":   "--log-level debug",
+			"server.extraConfig": "{}",
+		},
+		SetFileValues: map[string]string{
+			"server.extraConfig": extraConfigFile,
+		},
+	}, getter.Providers{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"global": map[string]interface{}{"name": "from-file"},
+		"server": map[string]interface{}{"replicas": int64(1), "extraArgs": "--log-level debug", "extraConfig": extraConfig},
+		"client": map[string]interface{}{"enabled": false},
+	}, vals)
+}
+
+func TestErrorIs(t *testing.T) {
+	err := error(&Error{Action: "status", Release: "test", Err: ErrReleaseNotFound})
+	require.True(t, errors.Is(err, ErrReleaseNotFound))
+	require.EqualError(t, err, "helm status of release test failed: release: not found")
+}
@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testContextGracePeriod is how long before the deadline of go test's
+// -timeout flag the contexts returned by TestContext are cancelled.
+// It leaves time for the test to report which call was cancelled,
+// write debug output and clean up before go test panics.
+const testContextGracePeriod = 1 * time.Minute
+
+var (
+	testContextsMu sync.Mutex
+	// testContexts are the contexts returned by TestContext keyed by their test.
+	testContexts = map[*testing.T]context.Context{}
+)
+
+// TestContext returns a context for the Kubernetes and Consul API calls
+// and kubectl commands of t. It's cancelled shortly before the deadline
+// of go test's -timeout flag so that a hung call fails the test with an
+// error that says which call it was, rather than go test panicking with
+// the stacks of all goroutines. It's also cancelled when t finishes.
+//
+// Once the context is cancelled, the next call returns a new context that
+// gets half of the time left before the deadline, so that debug output
+// and cleanup that run after a timeout still have time to make their calls.
+func TestContext(t *testing.T) context.Context {
+	testContextsMu.Lock()
+	defer testContextsMu.Unlock()
+
+	if ctx, ok := testContexts[t]; ok && ctx.Err() == nil {
+		return ctx
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if deadline, ok := t.Deadline(); ok {
+		ctx, cancel = context.WithDeadline(context.Background(), contextDeadline(deadline, time.Now()))
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	testContexts[t] = ctx
+	t.Cleanup(func() {
+		testContextsMu.Lock()
+		if testContexts[t] == ctx {
+			delete(testContexts, t)
+		}
+		testContextsMu.Unlock()
+		cancel()
+	})
+	return ctx
+}
+
+// contextDeadline returns the deadline of the contexts of a test that
+// go test stops at deadline. It's testContextGracePeriod before deadline
+// unless that would leave less time than the grace period, e.g. with
+// a short -timeout or after a context has already been cancelled,
+// in which case the remaining time is split in half.
+func contextDeadline(deadline, now time.Time) time.Time {
+	grace := testContextGracePeriod
+	if remaining := deadline.Sub(now); remaining < 2*grace {
+		grace = remaining / 2
+	}
+	return deadline.Add(-grace)
+}
@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextDeadline(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name     string
+		deadline time.Time
+		expected time.Time
+	}{
+		{"long timeout", now.Add(1 * time.Hour), now.Add(59 * time.Minute)},
+		{"short timeout", now.Add(1 * time.Minute), now.Add(30 * time.Second)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.expected, contextDeadline(c.deadline, now))
+		})
+	}
+}
+
+// Test that TestContext returns the same context for a test
+// until it finishes and that it's cancelled when it does.
+func TestTestContext(t *testing.T) {
+	var ctx context.Context
+	t.Run("subtest", func(t *testing.T) {
+		ctx = TestContext(t)
+		require.Equal(t, ctx, TestContext(t))
+		require.NoError(t, ctx.Err())
+
+		if deadline, ok := t.Deadline(); ok {
+			ctxDeadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			require.True(t, ctxDeadline.Before(deadline))
+		}
+	})
+	require.Equal(t, context.Canceled, ctx.Err())
+}
@@ -1,7 +1,6 @@
 package helpers
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -13,9 +12,12 @@ import (
 	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -25,37 +27,126 @@ func RandomName() string {
 	return fmt.Sprintf("test-%s", strings.ToLower(random.UniqueId()))
 }
 
-// WaitForAllPodsToBeReady waits until all pods with the provided podLabelSelector
-// are in the ready status. It checks every 5 seconds for a total of 20 tries.
-// If there is at least one container in a pod that isn't ready after that,
-// it fails the test.
-func WaitForAllPodsToBeReady(t *testing.T, client kubernetes.Interface, namespace, podLabelSelector string) {
+// KubernetesContext is the subset of a test context needed
+// by the helpers in this package. It is satisfied by environment.TestContext.
+type KubernetesContext interface {
+	KubectlOptions(t *testing.T) *terratestk8s.KubectlOptions
+	KubernetesClient(t *testing.T) kubernetes.Interface
+}
+
+// RandomNamespace creates a Kubernetes namespace with a random name
+// and registers a cleanup function to delete it when the test finishes.
+// It returns KubectlOptions that point to the same cluster as ctx
+// but are scoped to the new namespace. Tests that need their own namespace
+// should use this instead of a hard-coded name so that they can run in parallel.
+func RandomNamespace(t *testing.T, ctx KubernetesContext, noCleanupOnFailure, noCleanup bool) *terratestk8s.KubectlOptions {
 	t.Helper()
 
-	logger.Log(t, "Waiting for pods to be ready.")
+	client := ctx.KubernetesClient(t)
+	namespace := RandomName()
+
+	logger.Logf(t, "creating namespace %q", namespace)
+	_, err := client.CoreV1().Namespaces().Create(TestContext(t), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: testlabels.ForTest(t)},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	Cleanup(t, noCleanupOnFailure, noCleanup, func() {
+		logger.Logf(t, "deleting namespace %q", namespace)
+		err := client.CoreV1().Namespaces().Delete(TestContext(t), namespace, metav1.DeleteOptions{})
+		if !errors.IsNotFound(err) {
+			require.NoError(t, err)
+		}
+	})
+
+	options := ctx.KubectlOptions(t)
+	return &terratestk8s.KubectlOptions{
+		ContextName: options.ContextName,
+		ConfigPath:  options.ConfigPath,
+		Env:         options.Env,
+		Namespace:   namespace,
+	}
+}
+
+// Component labels of the pods created by the Helm chart,
+// for use with WaitForAllPodsRunning.
+const (
+	ComponentServer             = "server"
+	ComponentClient             = "client"
+	ComponentConnectInjector    = "connect-injector"
+	ComponentController         = "controller"
+	ComponentWebhookCertManager = "webhook-cert-manager"
+	ComponentSyncCatalog        = "sync-catalog"
+	ComponentMeshGateway        = "mesh-gateway"
+	ComponentIngressGateway     = "ingress-gateway"
+	ComponentTerminatingGateway = "terminating-gateway"
+)
+
+// WaitForAllPodsRunning waits until all pods of the Helm release releaseName
+// with one of the given component labels, e.g. ComponentServer, are ready.
+// If no components are given, it waits for all pods of the release.
+// Pods of completed jobs are ignored and pods that are being deleted count
+// as not ready, so it can be used right after restarting a component.
+// It checks every 5 seconds for up to -timeout-pods-ready and fails the test
+// if pods aren't ready or a component has no pods after that.
+func WaitForAllPodsRunning(t *testing.T, client kubernetes.Interface, namespace, releaseName string, components ...string) {
+	t.Helper()
 
-	// Wait up to 5m.
-	counter := &retry.Counter{Count: 60, Wait: 5 * time.Second}
-	retry.RunWith(counter, t, func(r *retry.R) {
-		pods, err := client.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: podLabelSelector})
+	selector := fmt.Sprintf("release=%s", releaseName)
+	if len(components) > 0 {
+		selector = fmt.Sprintf("%s,component in (%s)", selector, strings.Join(components, ","))
+		logger.Logf(t, "waiting for %s pods of release %s to be ready", strings.Join(components, ", "), releaseName)
+	} else {
+		logger.Logf(t, "waiting for pods of release %s to be ready", releaseName)
+	}
+
+	timer := &retry.Timer{Timeout: timeouts.PodsReady(), Wait: 5 * time.Second}
+	retry.RunWith(timer, t, func(r *retry.R) {
+		pods, err := client.CoreV1().Pods(namespace).List(TestContext(t), metav1.ListOptions{LabelSelector: selector})
 		require.NoError(r, err)
+		require.NoError(r, checkPodsReady(pods.Items, components))
+	})
+}
 
-		var notReadyPods []string
-		for _, pod := range pods.Items {
-			if !isReady(pod) {
-				notReadyPods = append(notReadyPods, pod.Name)
-			}
+// checkPodsReady returns an error listing the pods that aren't ready and
+// the components that have no pods.
+func checkPodsReady(pods []corev1.Pod, components []string) error {
+	found := make(map[string]bool)
+	var notReadyPods []string
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded {
+			continue
 		}
-		if len(notReadyPods) > 0 {
-			r.Errorf("%d pods are not ready: %s", len(notReadyPods), strings.Join(notReadyPods, ","))
+		found[pod.Labels["component"]] = true
+		if pod.DeletionTimestamp != nil || !IsReady(pod) {
+			notReadyPods = append(notReadyPods, pod.Name)
 		}
-	})
+	}
+
+	var missing []string
+	for _, component := range components {
+		if !found[component] {
+			missing = append(missing, component)
+		}
+	}
+
+	var errs []string
+	if len(notReadyPods) > 0 {
+		errs = append(errs, fmt.Sprintf("%d pods are not ready: %s", len(notReadyPods), strings.Join(notReadyPods, ",")))
+	}
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Sprintf("no pods found for components: %s", strings.Join(missing, ",")))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
 }
 
 // Sets up a goroutine that will wait for interrupt signals
 // and call cleanup function when it catches it.
 func SetupInterruptHandler(cleanup func()) {
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
@@ -68,7 +159,9 @@ func SetupInterruptHandler(cleanup func()) {
 // Cleanup will both register a cleanup function with t
 // and SetupInterruptHandler to make sure resources get cleaned up
 // if an interrupt signal is caught.
-func Cleanup(t *testing.T, noCleanupOnFailure bool, cleanup func()) {
+// If noCleanup is set, resources are never cleaned up when the test finishes,
+// and if noCleanupOnFailure is set, they are not cleaned up only if the test fails.
+func Cleanup(t *testing.T, noCleanupOnFailure, noCleanup bool, cleanup func()) {
 	t.Helper()
 
 	// Always clean up when an interrupt signal is caught.
@@ -78,7 +171,7 @@ func Cleanup(t *testing.T, noCleanupOnFailure bool, cleanup func()) {
 	// We need to wrap the cleanup function because t that is passed in to this function
 	// might not have the information on whether the test has failed yet.
 	wrappedCleanupFunc := func() {
-		if !(noCleanupOnFailure && t.Failed()) {
+		if !(noCleanup || (noCleanupOnFailure && t.Failed())) {
 			logger.Logf(t, "cleaning up resources for %s", t.Name())
 			cleanup()
 		} else {
@@ -124,8 +217,8 @@ func KubernetesContextFromOptions(t *testing.T, options *terratestk8s.KubectlOpt
 	return rawConfig.CurrentContext
 }
 
-// isReady returns true if pod is ready.
-func isReady(pod corev1.Pod) bool {
+// IsReady returns true if pod is ready.
+func IsReady(pod corev1.Pod) bool {
 	if len(pod.Status.ContainerStatuses) == 0 {
 		return false
 	}
@@ -0,0 +1,171 @@
+package helpers
+
+import (
+	"context"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Test that RandomNamespace creates a uniquely named namespace
+// and returns kubectl options scoped to it.
+func TestRandomNamespace(t *testing.T) {
+	ctx := &fakeContext{
+		client: fake.NewSimpleClientset(),
+		options: &terratestk8s.KubectlOptions{
+			ContextName: "test-context",
+			ConfigPath:  "/path/to/kubeconfig",
+			Namespace:   "default",
+		},
+	}
+
+	var first, second *terratestk8s.KubectlOptions
+	t.Run("create", func(t *testing.T) {
+		first = RandomNamespace(t, ctx, false, false)
+		second = RandomNamespace(t, ctx, false, false)
+
+		require.NotEqual(t, first.Namespace, second.Namespace)
+		require.NotEqual(t, "default", first.Namespace)
+		require.Equal(t, "test-context", first.ContextName)
+		require.Equal(t, "/path/to/kubeconfig", first.ConfigPath)
+
+		for _, ns := range []string{first.Namespace, second.Namespace} {
+			_, err := ctx.client.CoreV1().Namespaces().Get(context.Background(), ns, metav1.GetOptions{})
+			require.NoError(t, err)
+		}
+	})
+
+	// Namespaces should be deleted once the subtest that created them finishes.
+	namespaces, err := ctx.client.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, namespaces.Items)
+}
+
+type fakeContext struct {
+	client  kubernetes.Interface
+	options *terratestk8s.KubectlOptions
+}
+
+func (f *fakeContext) KubectlOptions(_ *testing.T) *terratestk8s.KubectlOptions {
+	return f.options
+}
+
+func (f *fakeContext) KubernetesClient(_ *testing.T) kubernetes.Interface {
+	return f.client
+}
+
+func TestCleanup(t *testing.T) {
+	cases := []struct {
+		name               string
+		noCleanupOnFailure bool
+		noCleanup          bool
+		expCleanup         bool
+	}{
+		{"cleans up by default", false, false, true},
+		{"cleans up a passing test when noCleanupOnFailure is set", true, false, true},
+		{"doesn't clean up when noCleanup is set", false, true, false},
+		{"doesn't clean up when both are set", true, true, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cleanedUp := false
+			t.Run("register", func(t *testing.T) {
+				Cleanup(t, c.noCleanupOnFailure, c.noCleanup, func() {
+					cleanedUp = true
+				})
+			})
+			require.Equal(t, c.expCleanup, cleanedUp)
+		})
+	}
+}
+
+func TestCheckPodsReady(t *testing.T) {
+	now := metav1.Now()
+	cases := map[string]struct {
+		pods       []corev1.Pod
+		components []string
+		expErr     string
+	}{
+		"all ready": {
+			pods:       []corev1.Pod{testPod("server-0", "server", true), testPod("client-abc", "client", true)},
+			components: []string{"server", "client"},
+		},
+		"not ready": {
+			pods:   []corev1.Pod{testPod("server-0", "server", true), testPod("server-1", "server", false)},
+			expErr: "1 pods are not ready: server-1",
+		},
+		"terminating": {
+			pods: []corev1.Pod{func() corev1.Pod {
+				pod := testPod("server-0", "server", true)
+				pod.DeletionTimestamp = &now
+				return pod
+			}()},
+			expErr: "1 pods are not ready: server-0",
+		},
+		"completed jobs are ignored": {
+			pods: []corev1.Pod{testPod("server-0", "server", true), func() corev1.Pod {
+				pod := testPod("server-acl-init-abc", "server-acl-init", false)
+				pod.Status.Phase = corev1.PodSucceeded
+				return pod
+			}()},
+		},
+		"missing component": {
+			pods:       []corev1.Pod{testPod("server-0", "server", true)},
+			components: []string{"server", "controller"},
+			expErr:     "no pods found for components: controller",
+		},
+		"not ready and missing component": {
+			pods:       []corev1.Pod{testPod("server-0", "server", false)},
+			components: []string{"server", "controller"},
+			expErr:     "1 pods are not ready: server-0; no pods found for components: controller",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := checkPodsReady(c.pods, c.components)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// Test that WaitForAllPodsRunning only considers the pods
+// of the release and the requested components.
+func TestWaitForAllPodsRunning(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	pods := []corev1.Pod{
+		testPod("release-server-0", "server", true),
+		testPod("release-controller-abc", "controller", true),
+		testPod("release-connect-injector-abc", "connect-injector", false),
+		testPod("other-server-0", "server", false),
+	}
+	pods[3].Labels["release"] = "other"
+	for _, pod := range pods {
+		_, err := client.CoreV1().Pods("default").Create(context.Background(), &pod, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	WaitForAllPodsRunning(t, client, "default", "release", "server", "controller")
+}
+
+func testPod(name, component string, ready bool) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"release": "release", "component": component},
+		},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Ready: ready}},
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package helpers
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Percentile returns the pth percentile of durations using the nearest-rank
+// method, e.g. Percentile(latencies, 95) returns the duration that 95% of
+// the latencies are less than or equal to. It returns 0 if durations is empty.
+// p must be in the range (0, 100].
+func Percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentile(t *testing.T) {
+	durations := []time.Duration{
+		5 * time.Second,
+		1 * time.Second,
+		4 * time.Second,
+		2 * time.Second,
+		3 * time.Second,
+		10 * time.Second,
+		6 * time.Second,
+		9 * time.Second,
+		7 * time.Second,
+		8 * time.Second,
+	}
+
+	cases := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{1, 1 * time.Second},
+		{50, 5 * time.Second},
+		{90, 9 * time.Second},
+		{95, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expected, Percentile(durations, c.p), "p%v", c.p)
+	}
+
+	// The input must not be reordered.
+	require.Equal(t, 5*time.Second, durations[0])
+}
+
+func TestPercentile_Empty(t *testing.T) {
+	require.Equal(t, time.Duration(0), Percentile(nil, 50))
+}
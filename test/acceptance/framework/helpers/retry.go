@@ -0,0 +1,135 @@
+package helpers
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+)
+
+const (
+	defaultBackoffInitialWait = 500 * time.Millisecond
+	defaultBackoffMaxWait     = 10 * time.Second
+	defaultBackoffMultiplier  = 2.0
+	defaultBackoffJitter      = 0.2
+)
+
+// Backoff implements retry.Retryer. It repeats an operation until
+// Timeout elapses or Context is cancelled, waiting exponentially longer
+// between subsequent attempts. Zero values for InitialWait, MaxWait,
+// Multiplier and Jitter are replaced with sensible defaults.
+type Backoff struct {
+	// Context, if set, stops retrying as soon as it is cancelled.
+	Context context.Context
+	// Timeout is the total amount of time to keep retrying for.
+	Timeout time.Duration
+	// InitialWait is how long to wait after the first failed attempt.
+	InitialWait time.Duration
+	// MaxWait caps the wait between any two attempts.
+	MaxWait time.Duration
+	// Multiplier is the factor the wait grows by after each attempt.
+	Multiplier float64
+	// Jitter is the fraction of each wait, between 0 and 1,
+	// that is randomized so that concurrent retries don't run in lockstep.
+	Jitter float64
+
+	// stop is the timeout deadline.
+	// Set on the first invocation of NextOr.
+	stop time.Time
+	// wait is the un-jittered wait before the next attempt.
+	wait time.Duration
+}
+
+// NextOr returns true if the operation should be repeated.
+// Otherwise, it calls fail and returns false.
+func (b *Backoff) NextOr(fail func()) bool {
+	if b.stop.IsZero() {
+		b.stop = time.Now().Add(b.Timeout)
+		return true
+	}
+
+	remaining := time.Until(b.stop)
+	if remaining <= 0 {
+		fail()
+		return false
+	}
+
+	wait := b.nextWait()
+	if wait > remaining {
+		wait = remaining
+	}
+
+	ctx := b.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		fail()
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// nextWait returns the jittered wait before the next attempt
+// and grows the wait for the attempt after that.
+func (b *Backoff) nextWait() time.Duration {
+	initialWait := b.InitialWait
+	if initialWait <= 0 {
+		initialWait = defaultBackoffInitialWait
+	}
+	maxWait := b.MaxWait
+	if maxWait <= 0 {
+		maxWait = defaultBackoffMaxWait
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultBackoffMultiplier
+	}
+	jitter := b.Jitter
+	if jitter <= 0 || jitter > 1 {
+		jitter = defaultBackoffJitter
+	}
+
+	if b.wait == 0 {
+		b.wait = initialWait
+	}
+	wait := b.wait
+	if wait > maxWait {
+		wait = maxWait
+	}
+
+	next := time.Duration(float64(b.wait) * multiplier)
+	if next > maxWait {
+		next = maxWait
+	}
+	b.wait = next
+
+	// Randomize the wait within +/- jitter of its value.
+	delta := jitter * float64(wait)
+	return wait - time.Duration(delta) + time.Duration(rand.Float64()*2*delta)
+}
+
+// RetryEventually runs fn until it succeeds or until timeout elapses,
+// backing off exponentially with jitter between attempts.
+// It should be preferred over a fixed retry.Counter for long waits,
+// such as waiting for the controller to perform leader election,
+// because it checks often at first and less often later.
+func RetryEventually(t *testing.T, timeout time.Duration, fn func(r *retry.R)) {
+	t.Helper()
+
+	RetryEventuallyWithContext(TestContext(t), t, timeout, fn)
+}
+
+// RetryEventuallyWithContext is the same as RetryEventually
+// but it also stops retrying and fails the test when ctx is cancelled.
+func RetryEventuallyWithContext(ctx context.Context, t *testing.T, timeout time.Duration, fn func(r *retry.R)) {
+	t.Helper()
+
+	retry.RunWith(&Backoff{Context: ctx, Timeout: timeout}, t, fn)
+}
@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff_nextWait(t *testing.T) {
+	b := &Backoff{
+		InitialWait: 100 * time.Millisecond,
+		MaxWait:     500 * time.Millisecond,
+		Multiplier:  2,
+		Jitter:      0.1,
+	}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		500 * time.Millisecond,
+		500 * time.Millisecond,
+	}
+	for _, exp := range expected {
+		wait := b.nextWait()
+		require.GreaterOrEqual(t, int64(wait), int64(float64(exp)*0.9))
+		require.LessOrEqual(t, int64(wait), int64(float64(exp)*1.1))
+	}
+}
+
+func TestBackoff_RetriesUntilSuccess(t *testing.T) {
+	attempts := 0
+	retry.RunWith(&Backoff{Timeout: 5 * time.Second, InitialWait: time.Millisecond}, t, func(r *retry.R) {
+		attempts++
+		if attempts < 3 {
+			r.Errorf("attempt %d", attempts)
+		}
+	})
+	require.Equal(t, 3, attempts)
+}
+
+func TestBackoff_StopsOnTimeout(t *testing.T) {
+	b := &Backoff{Timeout: 50 * time.Millisecond, InitialWait: 10 * time.Millisecond}
+
+	failed := false
+	start := time.Now()
+	for b.NextOr(func() { failed = true }) {
+	}
+	require.True(t, failed)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestBackoff_StopsOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Backoff{Context: ctx, Timeout: time.Minute, InitialWait: time.Minute}
+
+	// The first call always succeeds.
+	require.True(t, b.NextOr(func() {}))
+
+	cancel()
+	failed := false
+	start := time.Now()
+	require.False(t, b.NextOr(func() { failed = true }))
+	require.True(t, failed)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+}
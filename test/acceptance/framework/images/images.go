@@ -0,0 +1,72 @@
+// Package images rewrites references to container images so that they're
+// pulled from a mirror registry set with the -test-image-registry flag,
+// e.g. in air-gapped CI environments that can't reach public registries.
+//
+// Images are expected under the same name in the mirror without the
+// original registry, e.g. hashicorp/consul:1.9.0 and
+// gcr.io/google-containers/pause:3.2 are pulled as
+// <registry>/hashicorp/consul:1.9.0 and <registry>/google-containers/pause:3.2.
+package images
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	mu       sync.RWMutex
+	registry string
+)
+
+// SetRegistry sets the registry that all images used by tests are pulled from.
+// If it's empty, images are pulled from their original registries.
+func SetRegistry(r string) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry = r
+}
+
+// Registry returns the registry that all images used by tests are pulled from,
+// or an empty string if images are pulled from their original registries.
+func Registry() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return registry
+}
+
+// Rewrite returns image as it's pulled from registry. It returns image
+// unchanged if registry is empty or if image already is in registry.
+func Rewrite(registry, image string) string {
+	registry = strings.TrimSuffix(registry, "/")
+	if registry == "" || image == "" || strings.HasPrefix(image, registry+"/") {
+		return image
+	}
+
+	// The first component of the name is a registry if it's a host name,
+	// e.g. gcr.io or localhost:5000, rather than a Docker Hub user, e.g. hashicorp.
+	name := image
+	if i := strings.Index(image, "/"); i != -1 {
+		first := image[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			name = image[i+1:]
+		}
+	}
+	return registry + "/" + name
+}
+
+// imageFieldRegex matches the image fields of containers in YAML manifests,
+// capturing the field with any quotes around the image and the image.
+var imageFieldRegex = regexp.MustCompile(`(?m)^(\s*(?:-\s+)?image:\s*["']?)([^"'\s#]+)`)
+
+// RewriteManifest rewrites the images of all containers in
+// the YAML manifest to be pulled from registry, see Rewrite.
+func RewriteManifest(registry string, manifest []byte) []byte {
+	if registry == "" {
+		return manifest
+	}
+	return imageFieldRegex.ReplaceAllFunc(manifest, func(match []byte) []byte {
+		groups := imageFieldRegex.FindSubmatch(match)
+		return []byte(string(groups[1]) + Rewrite(registry, string(groups[2])))
+	})
+}
@@ -0,0 +1,97 @@
+package images
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewrite(t *testing.T) {
+	cases := map[string]struct {
+		registry string
+		image    string
+		expected string
+	}{
+		"no registry": {
+			registry: "",
+			image:    "hashicorp/consul:1.9.0",
+			expected: "hashicorp/consul:1.9.0",
+		},
+		"docker hub image": {
+			registry: "registry.example.com/mirror",
+			image:    "hashicorp/consul:1.9.0",
+			expected: "registry.example.com/mirror/hashicorp/consul:1.9.0",
+		},
+		"docker hub official image": {
+			registry: "registry.example.com/mirror",
+			image:    "busybox",
+			expected: "registry.example.com/mirror/busybox",
+		},
+		"registry with a trailing slash": {
+			registry: "registry.example.com/mirror/",
+			image:    "envoyproxy/envoy-alpine:v1.16.0",
+			expected: "registry.example.com/mirror/envoyproxy/envoy-alpine:v1.16.0",
+		},
+		"image from another registry": {
+			registry: "registry.example.com/mirror",
+			image:    "gcr.io/google-containers/pause:3.2",
+			expected: "registry.example.com/mirror/google-containers/pause:3.2",
+		},
+		"image from a registry with a port": {
+			registry: "registry.example.com",
+			image:    "localhost:5000/consul-k8s:dev",
+			expected: "registry.example.com/consul-k8s:dev",
+		},
+		"image digest": {
+			registry: "registry.example.com",
+			image:    "docker.io/hashicorp/http-echo@sha256:ba27d460cd1f22a1a4331bdf74f4fccbc025552357e8a3249c40ae216275de96",
+			expected: "registry.example.com/hashicorp/http-echo@sha256:ba27d460cd1f22a1a4331bdf74f4fccbc025552357e8a3249c40ae216275de96",
+		},
+		"image already in the registry": {
+			registry: "registry.example.com/mirror",
+			image:    "registry.example.com/mirror/hashicorp/consul:1.9.0",
+			expected: "registry.example.com/mirror/hashicorp/consul:1.9.0",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expected, Rewrite(c.registry, c.image))
+		})
+	}
+}
+
+func TestRewriteManifest(t *testing.T) {
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      initContainers:
+      - image: busybox # comment
+      containers:
+        - name: static-server
+          image: "kschoche/http-echo:latest"
+          args:
+            - -text="image: foo"
+        - name: static-client
+          image: 'tutum/curl:latest'
+`
+	expected := `apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      initContainers:
+      - image: mirror.local/busybox # comment
+      containers:
+        - name: static-server
+          image: "mirror.local/kschoche/http-echo:latest"
+          args:
+            - -text="image: foo"
+        - name: static-client
+          image: 'mirror.local/tutum/curl:latest'
+`
+	require.Equal(t, expected, string(RewriteManifest("mirror.local", []byte(manifest))))
+	require.Equal(t, manifest, string(RewriteManifest("", []byte(manifest))))
+}
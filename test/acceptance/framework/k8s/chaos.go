@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KillPod forcefully deletes the pod with the given name without waiting
+// for it to terminate gracefully, simulating a crash of the pod.
+func KillPod(t *testing.T, options *k8s.KubectlOptions, podName string) {
+	t.Helper()
+
+	logger.Logf(t, "killing pod %s", podName)
+	RunKubectl(t, options, "delete", "pod", podName, "--grace-period=0", "--force", "--wait=false")
+}
+
+// RestartDeployment performs a rolling restart of the deployment with the given name
+// and waits for the rollout to complete.
+func RestartDeployment(t *testing.T, options *k8s.KubectlOptions, deploymentName string) {
+	t.Helper()
+
+	logger.Logf(t, "restarting deployment %s", deploymentName)
+	RunKubectl(t, options, "rollout", "restart", fmt.Sprintf("deploy/%s", deploymentName))
+	RunKubectl(t, options, "rollout", "status", fmt.Sprintf("--timeout=%s", timeouts.PodsReady()), fmt.Sprintf("deploy/%s", deploymentName))
+}
+
+// RestartDaemonSet performs a rolling restart of the daemonset with the given name
+// and waits for the rollout to complete.
+func RestartDaemonSet(t *testing.T, options *k8s.KubectlOptions, daemonSetName string) {
+	t.Helper()
+
+	logger.Logf(t, "restarting daemonset %s", daemonSetName)
+	RunKubectl(t, options, "rollout", "restart", fmt.Sprintf("daemonset/%s", daemonSetName))
+	RunKubectl(t, options, "rollout", "status", fmt.Sprintf("--timeout=%s", timeouts.PodsReady()), fmt.Sprintf("daemonset/%s", daemonSetName))
+}
+
+// RestartStatefulSet performs a rolling restart of the statefulset with the given name
+// and waits for the rollout to complete.
+func RestartStatefulSet(t *testing.T, options *k8s.KubectlOptions, statefulSetName string) {
+	t.Helper()
+
+	logger.Logf(t, "restarting statefulset %s", statefulSetName)
+	RunKubectl(t, options, "rollout", "restart", fmt.Sprintf("statefulset/%s", statefulSetName))
+	RunKubectl(t, options, "rollout", "status", fmt.Sprintf("--timeout=%s", timeouts.PodsReady()), fmt.Sprintf("statefulset/%s", statefulSetName))
+}
+
+// CordonNode marks the node with the given name as unschedulable.
+// The node is uncordoned when the test finishes.
+func CordonNode(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure, noCleanup bool, nodeName string) {
+	t.Helper()
+
+	logger.Logf(t, "cordoning node %s", nodeName)
+	RunKubectl(t, options, "cordon", nodeName)
+	helpers.Cleanup(t, noCleanupOnFailure, noCleanup, func() {
+		UncordonNode(t, options, nodeName)
+	})
+}
+
+// UncordonNode marks the node with the given name as schedulable.
+func UncordonNode(t *testing.T, options *k8s.KubectlOptions, nodeName string) {
+	t.Helper()
+
+	logger.Logf(t, "uncordoning node %s", nodeName)
+	RunKubectl(t, options, "uncordon", nodeName)
+}
+
+// DrainNode cordons the node with the given name and evicts all pods from it,
+// respecting pod disruption budgets, similar to what happens during node maintenance.
+// The node is uncordoned when the test finishes so that pods can be scheduled on it again.
+func DrainNode(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure, noCleanup bool, nodeName string) {
+	t.Helper()
+
+	CordonNode(t, options, noCleanupOnFailure, noCleanup, nodeName)
+
+	logger.Logf(t, "draining node %s", nodeName)
+	RunKubectl(t, options, "drain", nodeName, "--ignore-daemonsets", "--delete-local-data", fmt.Sprintf("--timeout=%s", timeouts.PodsReady()))
+}
+
+// EvictPodE evicts the pod with the given name through the eviction API,
+// like kubectl drain does, so the eviction is refused if it would violate
+// a pod disruption budget. In that case, the error satisfies
+// errors.IsTooManyRequests from k8s.io/apimachinery/pkg/api/errors.
+func EvictPodE(t *testing.T, options *k8s.KubectlOptions, podName string) error {
+	t.Helper()
+
+	logger.Logf(t, "evicting pod %s", podName)
+	client := helpers.KubernetesClientFromOptions(t, options)
+	return client.PolicyV1beta1().Evictions(options.Namespace).Evict(helpers.TestContext(t), &policyv1beta1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: options.Namespace},
+	})
+}
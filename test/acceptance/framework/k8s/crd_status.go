@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	terratestLogger "github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// ConditionSynced is the type of the condition that the controller sets
+	// on custom resources to show whether they're synced to Consul.
+	ConditionSynced = "Synced"
+
+	// ReasonConsulAgentError is the reason of the Synced condition when
+	// the controller fails to sync a custom resource because of an error
+	// from Consul, for example, because the Consul servers are unavailable.
+	ReasonConsulAgentError = "ConsulAgentError"
+	// ReasonExternallyManagedConfigError is the reason of the Synced condition
+	// when the config entry already exists in Consul and wasn't created by the controller.
+	ReasonExternallyManagedConfigError = "ExternallyManagedConfigError"
+)
+
+// CRDCondition is a condition in the status of a Consul custom resource.
+type CRDCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// CRDStatus is the status of a Consul custom resource, such as ServiceDefaults,
+// which the controller updates when it syncs the resource to Consul.
+type CRDStatus struct {
+	Conditions     []CRDCondition `json:"conditions,omitempty"`
+	LastSyncedTime string         `json:"lastSyncedTime,omitempty"`
+}
+
+// Condition returns the condition of type conditionType,
+// or false if the status doesn't have that condition yet.
+func (s CRDStatus) Condition(conditionType string) (CRDCondition, bool) {
+	for _, condition := range s.Conditions {
+		if condition.Type == conditionType {
+			return condition, true
+		}
+	}
+	return CRDCondition{}, false
+}
+
+// GetCRDStatusE returns the status of the custom resource of kind,
+// e.g. servicedefaults, named name. If the controller hasn't
+// updated the status yet, it has no conditions.
+func GetCRDStatusE(t *testing.T, options *k8s.KubectlOptions, kind, name string) (CRDStatus, error) {
+	out, err := RunKubectlAndGetOutputWithLoggerE(t, options, terratestLogger.Discard, "get", kind, name, "-o", "json")
+	if err != nil {
+		return CRDStatus{}, err
+	}
+	return parseCRDStatus(out)
+}
+
+// GetCRDStatus is like GetCRDStatusE but fails the test if there's an error.
+func GetCRDStatus(t *testing.T, options *k8s.KubectlOptions, kind, name string) CRDStatus {
+	t.Helper()
+
+	status, err := GetCRDStatusE(t, options, kind, name)
+	require.NoError(t, err)
+	return status
+}
+
+// RequireCRDCondition checks that the custom resource of kind named name has
+// a condition of conditionType with the expected status and, if it's not empty,
+// the expected reason. It takes a require.TestingT so that it can be used
+// with retry.R to wait for the controller to update the status.
+func RequireCRDCondition(r require.TestingT, t *testing.T, options *k8s.KubectlOptions, kind, name, conditionType, status, reason string) {
+	crdStatus, err := GetCRDStatusE(t, options, kind, name)
+	require.NoError(r, err)
+	condition, ok := crdStatus.Condition(conditionType)
+	require.True(r, ok, "%s %s has no %s condition", kind, name, conditionType)
+	require.Equal(r, status, condition.Status, "%s %s: %s", kind, name, condition.Message)
+	if reason != "" {
+		require.Equal(r, reason, condition.Reason, "%s %s: %s", kind, name, condition.Message)
+	}
+}
+
+// parseCRDStatus returns the status of the custom resource in the JSON output of kubectl get.
+func parseCRDStatus(output string) (CRDStatus, error) {
+	var resource struct {
+		Status CRDStatus `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(output), &resource); err != nil {
+		return CRDStatus{}, fmt.Errorf("parsing custom resource %q: %s", output, err)
+	}
+	return resource.Status, nil
+}
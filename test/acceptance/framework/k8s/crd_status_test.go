@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCRDStatus(t *testing.T) {
+	cases := map[string]struct {
+		output  string
+		want    CRDStatus
+		wantErr bool
+	}{
+		"no status": {
+			output: `{"kind": "ServiceDefaults", "metadata": {"name": "defaults"}}`,
+			want:   CRDStatus{},
+		},
+		"synced": {
+			output: `{"kind": "ServiceDefaults", "status": {"conditions": [{"type": "Synced", "status": "True", "lastTransitionTime": "2020-12-01T10:00:00Z"}], "lastSyncedTime": "2020-12-01T10:00:00Z"}}`,
+			want: CRDStatus{
+				Conditions:     []CRDCondition{{Type: "Synced", Status: "True", LastTransitionTime: "2020-12-01T10:00:00Z"}},
+				LastSyncedTime: "2020-12-01T10:00:00Z",
+			},
+		},
+		"not synced": {
+			output: `{"kind": "ServiceDefaults", "status": {"conditions": [{"type": "Synced", "status": "False", "reason": "ExternallyManagedConfigError", "message": "config entry managed in different datacenter"}]}}`,
+			want: CRDStatus{
+				Conditions: []CRDCondition{{Type: "Synced", Status: "False", Reason: "ExternallyManagedConfigError", Message: "config entry managed in different datacenter"}},
+			},
+		},
+		"invalid output": {
+			output:  `error: servicedefaults "defaults" not found`,
+			wantErr: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			status, err := parseCRDStatus(c.output)
+			if c.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.want, status)
+		})
+	}
+}
+
+func TestCRDStatus_Condition(t *testing.T) {
+	status := CRDStatus{Conditions: []CRDCondition{{Type: ConditionSynced, Status: "False", Reason: ReasonConsulAgentError}}}
+
+	condition, ok := status.Condition(ConditionSynced)
+	require.True(t, ok)
+	require.Equal(t, ReasonConsulAgentError, condition.Reason)
+
+	_, ok = status.Condition("Ready")
+	require.False(t, ok)
+}
@@ -0,0 +1,103 @@
+package k8s
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// ApplyCRDs applies the custom resource definitions from the YAML or JSON files
+// in dir and waits for them to be established. This can be used together with
+// consul.SkipCRDInstall to manage CRDs separately from the Helm release,
+// for example, to test upgrading CRDs to a new version.
+// Applying a CRD that already exists updates it.
+// The CRDs are deleted when the test finishes, which deletes all custom resources of those kinds.
+func ApplyCRDs(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure, noCleanup bool, dir string) {
+	t.Helper()
+
+	names, err := crdNamesInDirectory(dir)
+	require.NoError(t, err)
+	require.NotEmpty(t, names, "no custom resource definitions found in %s", dir)
+
+	logger.Logf(t, "applying custom resource definitions from %s", dir)
+	KubectlApply(t, options, dir)
+	helpers.Cleanup(t, noCleanupOnFailure, noCleanup, func() {
+		// Ignore errors because the CRDs may have been deleted by the test
+		// or the directory removed, in which case we delete them by name.
+		for _, name := range names {
+			RunKubectlAndGetOutputE(t, options, "delete", "crd", name, "--ignore-not-found")
+		}
+	})
+
+	for _, name := range names {
+		RunKubectl(t, options, "wait", "--for=condition=established", fmt.Sprintf("--timeout=%s", timeouts.WebhookReady()), "crd/"+name)
+	}
+}
+
+// crdNamesInDirectory returns the names of the custom resource definitions
+// defined in the YAML or JSON files in dir.
+func crdNamesInDirectory(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		switch filepath.Ext(file.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		fileNames, err := crdNamesInFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, fileNames...)
+	}
+	return names, nil
+}
+
+// crdNamesInFile returns the names of the custom resource definitions
+// in the file, which may contain multiple YAML documents.
+func crdNamesInFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var names []string
+	decoder := yaml.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		var object struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		}
+		err := decoder.Decode(&object)
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %s", path, err)
+		}
+		if object.Kind == "CustomResourceDefinition" {
+			names = append(names, object.Metadata.Name)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRDNamesInDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"crd-servicedefaults.yaml": `---
+# Source: consul/templates/crd-servicedefaults.yaml
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: servicedefaults.consul.hashicorp.com
+`,
+		"multiple.yaml": `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: servicerouters.consul.hashicorp.com
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-a-crd
+---
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: servicesplitters.consul.hashicorp.com
+`,
+		"crd-proxydefaults.json": `{"apiVersion": "apiextensions.k8s.io/v1beta1", "kind": "CustomResourceDefinition", "metadata": {"name": "proxydefaults.consul.hashicorp.com"}}`,
+		"README.md":              "not a manifest",
+	}
+	for name, contents := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600))
+	}
+
+	names, err := crdNamesInDirectory(dir)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		"servicedefaults.consul.hashicorp.com",
+		"servicerouters.consul.hashicorp.com",
+		"servicesplitters.consul.hashicorp.com",
+		"proxydefaults.consul.hashicorp.com",
+	}, names)
+}
+
+func TestCRDNamesInDirectory_InvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "invalid.yaml"), []byte("kind: [CustomResourceDefinition"), 0600))
+
+	_, err := crdNamesInDirectory(dir)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid.yaml")
+}
@@ -0,0 +1,231 @@
+package k8s
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/metrics"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// statusCodeMarker prefixes the HTTP status code that curl writes
+// after the response body so that it can be parsed from the output.
+const statusCodeMarker = "CURL_HTTP_STATUS:"
+
+// HTTPRequest is an HTTP request made with curl from a pod.
+type HTTPRequest struct {
+	// URL is the URL to request.
+	URL string
+	// Method is the HTTP method, e.g. "POST". If it's empty, GET is used.
+	Method string
+	// Headers are request headers in the "Name: value" format,
+	// e.g. "Host: static-server.ingress.consul".
+	Headers []string
+	// MaxTime is the maximum time the request is allowed to take.
+	// If it's zero, curl's default is used.
+	MaxTime time.Duration
+	// Insecure skips the verification of the server's certificate for
+	// HTTPS URLs, e.g. of gateway listeners that use Consul's Connect CA.
+	Insecure bool
+}
+
+// HTTPResponse is the response to an HTTPRequest.
+type HTTPResponse struct {
+	StatusCode int
+	Body       string
+}
+
+// HTTPExpectation is what CheckHTTP expects in response to an HTTPRequest.
+type HTTPExpectation struct {
+	// StatusCode is the expected HTTP status code.
+	// If it's zero, any 2xx status code is expected.
+	StatusCode int
+	// Body is a string that the response body is expected to contain.
+	// If it's empty, the body is not checked.
+	Body string
+	// FailureMessages, if not empty, means that the request is expected
+	// to fail without a response, e.g. because it's denied by intentions,
+	// with curl output containing any one of these messages,
+	// e.g. "curl: (52) Empty reply from server".
+	FailureMessages []string
+	// Timeout is how long to retry the request until the expectation is met.
+	// If it's zero, the -timeout-traffic-check duration is used.
+	Timeout time.Duration
+	// Wait is the time to wait between requests. If it's zero, 500ms is used.
+	Wait time.Duration
+}
+
+// CurlE execs into a pod of the deployment given by deploymentName and makes the
+// HTTP request with curl from the container of the same name. It returns the
+// response and the output of the command. It returns an error if the request
+// fails without a response, but not for non-2xx responses.
+func CurlE(t *testing.T, options *k8s.KubectlOptions, deploymentName string, req HTTPRequest) (*HTTPResponse, string, error) {
+	t.Helper()
+
+	return curlE(t, options, deploymentName, req.curlArgs())
+}
+
+// CurlRepeatedE is like CurlE but makes the request count times in a row from a single
+// exec into the pod, which is much faster than exec'ing for each request, e.g. to
+// check how traffic is distributed between services. Requests that fail without
+// a response have a status code of 0 and the error message of curl as the body.
+func CurlRepeatedE(t *testing.T, options *k8s.KubectlOptions, deploymentName string, req HTTPRequest, count int) ([]*HTTPResponse, error) {
+	t.Helper()
+
+	output, err := RunKubectlAndGetOutputE(t, options, "exec", "deploy/"+deploymentName, "-c", deploymentName, "--",
+		"sh", "-c", req.repeatedCurlScript(count))
+	if err != nil {
+		return nil, err
+	}
+	resps, err := parseRepeatedCurlOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	if len(resps) != count {
+		return nil, fmt.Errorf("expected %d responses in curl output but got %d: %s", count, len(resps), output)
+	}
+	return resps, nil
+}
+
+// CheckHTTP makes the HTTP request from a pod of the deployment given by deploymentName,
+// retrying until the response meets the expectation or the expectation's timeout elapses.
+// It returns the last response, which is nil if the request was expected to fail.
+func CheckHTTP(t *testing.T, options *k8s.KubectlOptions, deploymentName string, req HTTPRequest, expected HTTPExpectation) *HTTPResponse {
+	t.Helper()
+
+	return checkHTTP(t, options, deploymentName, req.curlArgs(), expected)
+}
+
+func checkHTTP(t *testing.T, options *k8s.KubectlOptions, deploymentName string, curlArgs []string, expected HTTPExpectation) *HTTPResponse {
+	t.Helper()
+
+	timeout := expected.Timeout
+	if timeout == 0 {
+		timeout = timeouts.TrafficCheck()
+	}
+	wait := expected.Wait
+	if wait == 0 {
+		wait = 500 * time.Millisecond
+	}
+
+	defer metrics.Time(t, metrics.PhaseTrafficCheck)()
+
+	var resp *HTTPResponse
+	retry.RunWith(&retry.Timer{Timeout: timeout, Wait: wait}, t, func(r *retry.R) {
+		var output string
+		var err error
+		resp, output, err = curlE(t, options, deploymentName, curlArgs)
+		if len(expected.FailureMessages) > 0 {
+			require.Error(r, err, "expected request to fail")
+			require.True(r, containsAny(output, expected.FailureMessages), "expected output to contain one of %q but got: %s", expected.FailureMessages, output)
+			return
+		}
+
+		require.NoError(r, err)
+		if expected.StatusCode != 0 {
+			require.Equal(r, expected.StatusCode, resp.StatusCode, "unexpected status code, body: %s", resp.Body)
+		} else {
+			require.True(r, resp.StatusCode >= 200 && resp.StatusCode < 300, "expected a 2xx status code but got %d, body: %s", resp.StatusCode, resp.Body)
+		}
+		require.Contains(r, resp.Body, expected.Body)
+	})
+	return resp
+}
+
+func curlE(t *testing.T, options *k8s.KubectlOptions, deploymentName string, curlArgs []string) (*HTTPResponse, string, error) {
+	t.Helper()
+
+	args := []string{"exec", "deploy/" + deploymentName, "-c", deploymentName, "--", "curl", "-sS", "-w", "\n" + statusCodeMarker + "%{http_code}"}
+	args = append(args, curlArgs...)
+	output, err := RunKubectlAndGetOutputE(t, options, args...)
+	if err != nil {
+		return nil, output, err
+	}
+	resp, err := parseCurlOutput(output)
+	return resp, output, err
+}
+
+// curlArgs returns the curl arguments to make the request.
+func (r HTTPRequest) curlArgs() []string {
+	var args []string
+	if r.Method != "" {
+		args = append(args, "-X", r.Method)
+	}
+	for _, header := range r.Headers {
+		args = append(args, "-H", header)
+	}
+	if r.MaxTime != 0 {
+		args = append(args, "--max-time", strconv.FormatFloat(r.MaxTime.Seconds(), 'f', -1, 64))
+	}
+	if r.Insecure {
+		args = append(args, "--insecure")
+	}
+	return append(args, r.URL)
+}
+
+// repeatedCurlScript returns a shell script that makes the request count times,
+// writing the status code after each response body like curlE does.
+// Errors are written to stdout so that they end up in the body of the
+// failed request rather than before the next response.
+func (r HTTPRequest) repeatedCurlScript(count int) string {
+	args := []string{"curl", "-sS", "-w", "\n" + statusCodeMarker + "%{http_code}\n"}
+	var quoted []string
+	for _, arg := range append(args, r.curlArgs()...) {
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+	return fmt.Sprintf("for i in $(seq 1 %d); do %s 2>&1; done; true", count, strings.Join(quoted, " "))
+}
+
+// parseRepeatedCurlOutput parses the responses from the output of repeatedCurlScript.
+func parseRepeatedCurlOutput(output string) ([]*HTTPResponse, error) {
+	var resps []*HTTPResponse
+	for output != "" {
+		i := strings.Index(output, "\n"+statusCodeMarker)
+		if i == -1 {
+			return nil, fmt.Errorf("status code not found in curl output: %s", output)
+		}
+		body := output[:i]
+		rest := output[i+len(statusCodeMarker)+1:]
+		end := strings.Index(rest, "\n")
+		if end == -1 {
+			end = len(rest)
+		}
+		statusCode, err := strconv.Atoi(strings.TrimSpace(rest[:end]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code in curl output: %s", err)
+		}
+		resps = append(resps, &HTTPResponse{StatusCode: statusCode, Body: body})
+		output = strings.TrimPrefix(rest[end:], "\n")
+	}
+	return resps, nil
+}
+
+// parseCurlOutput parses the response body and the status code
+// written after it from the output of curl.
+func parseCurlOutput(output string) (*HTTPResponse, error) {
+	i := strings.LastIndex(output, "\n"+statusCodeMarker)
+	if i == -1 {
+		return nil, fmt.Errorf("status code not found in curl output: %s", output)
+	}
+	statusCode, err := strconv.Atoi(strings.TrimSpace(output[i+len(statusCodeMarker)+1:]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid status code in curl output: %s", err)
+	}
+	return &HTTPResponse{StatusCode: statusCode, Body: output[:i]}, nil
+}
+
+// containsAny returns true if s contains any of the substrings.
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPRequest_curlArgs(t *testing.T) {
+	cases := map[string]struct {
+		req      HTTPRequest
+		expected []string
+	}{
+		"url only": {
+			req:      HTTPRequest{URL: "http://localhost:1234"},
+			expected: []string{"http://localhost:1234"},
+		},
+		"headers": {
+			req: HTTPRequest{
+				URL:     "http://ingress-gateway:8080",
+				Headers: []string{"Host: static-server.ingress.consul", "X-Foo: bar"},
+			},
+			expected: []string{"-H", "Host: static-server.ingress.consul", "-H", "X-Foo: bar", "http://ingress-gateway:8080"},
+		},
+		"method": {
+			req:      HTTPRequest{URL: "http://localhost:1234/foo", Method: "POST"},
+			expected: []string{"-X", "POST", "http://localhost:1234/foo"},
+		},
+		"max time": {
+			req:      HTTPRequest{URL: "http://localhost:1234", MaxTime: 1500 * time.Millisecond},
+			expected: []string{"--max-time", "1.5", "http://localhost:1234"},
+		},
+		"insecure": {
+			req:      HTTPRequest{URL: "https://localhost:1234", Insecure: true},
+			expected: []string{"--insecure", "https://localhost:1234"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expected, c.req.curlArgs())
+		})
+	}
+}
+
+func TestParseCurlOutput(t *testing.T) {
+	cases := map[string]struct {
+		output   string
+		expected *HTTPResponse
+		expErr   string
+	}{
+		"body and status code": {
+			output:   "hello world\n\nCURL_HTTP_STATUS:200",
+			expected: &HTTPResponse{StatusCode: 200, Body: "hello world\n"},
+		},
+		"empty body": {
+			output:   "\nCURL_HTTP_STATUS:503",
+			expected: &HTTPResponse{StatusCode: 503, Body: ""},
+		},
+		"trailing whitespace": {
+			output:   "RBAC: access denied\nCURL_HTTP_STATUS:403\n",
+			expected: &HTTPResponse{StatusCode: 403, Body: "RBAC: access denied"},
+		},
+		"body containing the marker": {
+			output:   "CURL_HTTP_STATUS:500\nCURL_HTTP_STATUS:200",
+			expected: &HTTPResponse{StatusCode: 200, Body: "CURL_HTTP_STATUS:500"},
+		},
+		"no status code": {
+			output: "hello world",
+			expErr: "status code not found in curl output: hello world",
+		},
+		"invalid status code": {
+			output: "\nCURL_HTTP_STATUS:abc",
+			expErr: `invalid status code in curl output: strconv.Atoi: parsing "abc": invalid syntax`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			resp, err := parseCurlOutput(c.output)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, resp)
+		})
+	}
+}
+
+func TestHTTPRequest_repeatedCurlScript(t *testing.T) {
+	req := HTTPRequest{URL: "http://localhost:1234/it's", Method: "POST"}
+	require.Equal(t,
+		"for i in $(seq 1 3); do 'curl' '-sS' '-w' '\nCURL_HTTP_STATUS:%{http_code}\n' '-X' 'POST' 'http://localhost:1234/it'\\''s' 2>&1; done; true",
+		req.repeatedCurlScript(3))
+}
+
+func TestParseRepeatedCurlOutput(t *testing.T) {
+	cases := map[string]struct {
+		output   string
+		expected []*HTTPResponse
+		expErr   string
+	}{
+		"multiple responses": {
+			output: "v1\n\nCURL_HTTP_STATUS:200\nv2\n\nCURL_HTTP_STATUS:200\n\nCURL_HTTP_STATUS:503\n",
+			expected: []*HTTPResponse{
+				{StatusCode: 200, Body: "v1\n"},
+				{StatusCode: 200, Body: "v2\n"},
+				{StatusCode: 503, Body: ""},
+			},
+		},
+		"failed request": {
+			output: "curl: (52) Empty reply from server\n\nCURL_HTTP_STATUS:000\nv1\n\nCURL_HTTP_STATUS:200",
+			expected: []*HTTPResponse{
+				{StatusCode: 0, Body: "curl: (52) Empty reply from server\n"},
+				{StatusCode: 200, Body: "v1\n"},
+			},
+		},
+		"empty output": {
+			output:   "",
+			expected: nil,
+		},
+		"no status code after the last response": {
+			output: "v1\n\nCURL_HTTP_STATUS:200\nv2",
+			expErr: "status code not found in curl output: v2",
+		},
+		"invalid status code": {
+			output: "\nCURL_HTTP_STATUS:abc\n",
+			expErr: `invalid status code in curl output: strconv.Atoi: parsing "abc": invalid syntax`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			resps, err := parseRepeatedCurlOutput(c.output)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, resps)
+		})
+	}
+}
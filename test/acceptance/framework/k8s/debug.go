@@ -1,7 +1,6 @@
 package k8s
 
 import (
-	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -12,12 +11,15 @@ import (
 	terratestLogger "github.com/gruntwork-io/terratest/modules/logger"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/resources"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // WritePodsDebugInfoIfFailed calls kubectl describe and kubectl logs --all-containers
 // on pods filtered by the labelSelector and writes it to the debugDirectory.
+// If -collect-envoy-access-logs is set, it also writes the requests
+// that the Envoy sidecars of the pods handled.
 func WritePodsDebugInfoIfFailed(t *testing.T, kubectlOptions *k8s.KubectlOptions, debugDirectory, labelSelector string) {
 	t.Helper()
 
@@ -34,7 +36,7 @@ func WritePodsDebugInfoIfFailed(t *testing.T, kubectlOptions *k8s.KubectlOptions
 		logger.Logf(t, "dumping logs, pod info, and envoy config for %s to %s", labelSelector, testDebugDirectory)
 
 		// Describe and get logs for any pods.
-		pods, err := client.CoreV1().Pods(kubectlOptions.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+		pods, err := client.CoreV1().Pods(kubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: labelSelector})
 		require.NoError(t, err)
 
 		for _, pod := range pods.Items {
@@ -50,10 +52,14 @@ func WritePodsDebugInfoIfFailed(t *testing.T, kubectlOptions *k8s.KubectlOptions
 
 			// Describe pod and write it to a file.
 			writeResourceInfoToFile(t, pod.Name, "pod", testDebugDirectory, kubectlOptions)
+
+			if collectEnvoyAccessLogs() && ContainerImage(pod, resources.SidecarContainer) != "" {
+				writeEnvoyAccessLog(t, kubectlOptions, pod.Name, testDebugDirectory)
+			}
 		}
 
 		// Get envoy configuration from the mesh gateways, if there are any.
-		meshGatewayPods, err := client.CoreV1().Pods(kubectlOptions.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: "component=mesh-gateway"})
+		meshGatewayPods, err := client.CoreV1().Pods(kubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "component=mesh-gateway"})
 		require.NoError(t, err)
 
 		for _, mpod := range meshGatewayPods.Items {
@@ -77,21 +83,21 @@ func WritePodsDebugInfoIfFailed(t *testing.T, kubectlOptions *k8s.KubectlOptions
 		}
 
 		// Describe any stateful sets.
-		statefulSets, err := client.AppsV1().StatefulSets(kubectlOptions.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+		statefulSets, err := client.AppsV1().StatefulSets(kubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: labelSelector})
 		for _, statefulSet := range statefulSets.Items {
 			// Describe stateful set and write it to a file.
 			writeResourceInfoToFile(t, statefulSet.Name, "statefulset", testDebugDirectory, kubectlOptions)
 		}
 
 		// Describe any daemonsets.
-		daemonsets, err := client.AppsV1().DaemonSets(kubectlOptions.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+		daemonsets, err := client.AppsV1().DaemonSets(kubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: labelSelector})
 		for _, daemonSet := range daemonsets.Items {
 			// Describe daemon set and write it to a file.
 			writeResourceInfoToFile(t, daemonSet.Name, "daemonset", testDebugDirectory, kubectlOptions)
 		}
 
 		// Describe any deployments.
-		deployments, err := client.AppsV1().Deployments(kubectlOptions.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+		deployments, err := client.AppsV1().Deployments(kubectlOptions.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: labelSelector})
 		for _, deployment := range deployments.Items {
 			// Describe deployment and write it to a file.
 			writeResourceInfoToFile(t, deployment.Name, "deployment", testDebugDirectory, kubectlOptions)
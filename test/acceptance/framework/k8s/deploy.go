@@ -1,16 +1,20 @@
 package k8s
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"strings"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/gruntwork-io/terratest/modules/k8s"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
-	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/util/yaml"
@@ -18,7 +22,7 @@ import (
 
 // Deploy creates a Kubernetes deployment by applying configuration stored at filepath,
 // sets up a cleanup function and waits for the deployment to become available.
-func Deploy(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure bool, debugDirectory string, filepath string) {
+func Deploy(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure, noCleanup bool, debugDirectory string, filepath string) {
 	t.Helper()
 
 	KubectlApply(t, options, filepath)
@@ -30,7 +34,7 @@ func Deploy(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure bool,
 	err = yaml.NewYAMLOrJSONDecoder(file, 1024).Decode(&deployment)
 	require.NoError(t, err)
 
-	helpers.Cleanup(t, noCleanupOnFailure, func() {
+	helpers.Cleanup(t, noCleanupOnFailure, noCleanup, func() {
 		// Note: this delete command won't wait for pods to be fully terminated.
 		// This shouldn't cause any test pollution because the underlying
 		// objects are deployments, and so when other tests create these
@@ -42,9 +46,45 @@ func Deploy(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure bool,
 	RunKubectl(t, options, "wait", "--for=condition=available", fmt.Sprintf("deploy/%s", deployment.Name))
 }
 
+// DeployTemplate renders the Go template stored at templatePath with vars,
+// for example, to set image names, namespaces or annotations, and then
+// deploys the result the same way as Deploy.
+func DeployTemplate(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure, noCleanup bool, debugDirectory string, templatePath string, vars interface{}) {
+	t.Helper()
+
+	rendered, err := RenderTemplate(templatePath, vars)
+	require.NoError(t, err)
+
+	file, err := ioutil.TempFile("", "deployment-*.yaml")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.Remove(file.Name())
+	})
+	_, err = file.Write(rendered)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	Deploy(t, options, noCleanupOnFailure, noCleanup, debugDirectory, file.Name())
+}
+
+// RenderTemplate renders the Go template stored at templatePath with vars.
+// It returns an error if the template references a key that is missing from vars.
+func RenderTemplate(templatePath string, vars interface{}) ([]byte, error) {
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Option("missingkey=error").Execute(&buf, vars); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // DeployKustomize creates a Kubernetes deployment by applying the kustomize directory stored at kustomizeDir,
 // sets up a cleanup function and waits for the deployment to become available.
-func DeployKustomize(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure bool, debugDirectory string, kustomizeDir string) {
+func DeployKustomize(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure, noCleanup bool, debugDirectory string, kustomizeDir string) {
 	t.Helper()
 
 	KubectlApplyK(t, options, kustomizeDir)
@@ -56,7 +96,7 @@ func DeployKustomize(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailu
 	err = yaml.NewYAMLOrJSONDecoder(strings.NewReader(output), 1024).Decode(&deployment)
 	require.NoError(t, err)
 
-	helpers.Cleanup(t, noCleanupOnFailure, func() {
+	helpers.Cleanup(t, noCleanupOnFailure, noCleanup, func() {
 		// Note: this delete command won't wait for pods to be fully terminated.
 		// This shouldn't cause any test pollution because the underlying
 		// objects are deployments, and so when other tests create these
@@ -65,7 +105,7 @@ func DeployKustomize(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailu
 		KubectlDeleteK(t, options, kustomizeDir)
 	})
 
-	RunKubectl(t, options, "wait", "--for=condition=available", "--timeout=1m", fmt.Sprintf("deploy/%s", deployment.Name))
+	RunKubectl(t, options, "wait", "--for=condition=available", fmt.Sprintf("--timeout=%s", timeouts.PodsReady()), fmt.Sprintf("deploy/%s", deployment.Name))
 }
 
 // CheckStaticServerConnection execs into a pod of the deployment given by deploymentName
@@ -104,29 +144,11 @@ func CheckStaticServerConnectionMultipleFailureMessages(
 ) {
 	t.Helper()
 
-	retrier := &retry.Timer{Timeout: 20 * time.Second, Wait: 500 * time.Millisecond}
-
-	args := []string{"exec", "deploy/" + deploymentName, "-c", deploymentName, "--", "curl", "-vvvsSf"}
-	args = append(args, curlArgs...)
-
-	retry.RunWith(retrier, t, func(r *retry.R) {
-		output, err := RunKubectlAndGetOutputE(t, options, args...)
-		if expectSuccess {
-			require.NoError(r, err)
-			require.Contains(r, output, "hello world")
-		} else {
-			require.Error(r, err)
-			require.Condition(r, func() bool {
-				exists := false
-				for _, msg := range failureMessages {
-					if strings.Contains(output, msg) {
-						exists = true
-					}
-				}
-				return exists
-			})
-		}
-	})
+	expected := HTTPExpectation{Body: "hello world"}
+	if !expectSuccess {
+		expected = HTTPExpectation{FailureMessages: failureMessages}
+	}
+	checkHTTP(t, options, deploymentName, curlArgs, expected)
 }
 
 // CheckStaticServerConnectionSuccessful is just like CheckStaticServerConnection
@@ -143,6 +165,40 @@ func CheckStaticServerConnectionFailing(t *testing.T, options *k8s.KubectlOption
 	CheckStaticServerConnection(t, options, false, deploymentName, "curl: (52) Empty reply from server", curlArgs...)
 }
 
+// WatchStaticServerDowntime makes a request from a pod of the deployment given by
+// deploymentName to url every second until stop is closed and returns
+// the longest period of time during which requests were failing.
+// It's meant to run in a goroutine while the test changes something
+// that shouldn't interrupt traffic to the static-server.
+func WatchStaticServerDowntime(t *testing.T, options *k8s.KubectlOptions, deploymentName, url string, stop <-chan struct{}) time.Duration {
+	var maxDowntime time.Duration
+	var downSince time.Time
+	for {
+		select {
+		case <-stop:
+			if !downSince.IsZero() && time.Since(downSince) > maxDowntime {
+				maxDowntime = time.Since(downSince)
+			}
+			return maxDowntime
+		case <-time.After(1 * time.Second):
+		}
+
+		resp, _, err := CurlE(t, options, deploymentName, HTTPRequest{URL: url, MaxTime: 2 * time.Second})
+		if err != nil || resp.StatusCode != http.StatusOK {
+			if downSince.IsZero() {
+				downSince = time.Now()
+			}
+			continue
+		}
+		if !downSince.IsZero() {
+			if downtime := time.Since(downSince); downtime > maxDowntime {
+				maxDowntime = downtime
+			}
+			downSince = time.Time{}
+		}
+	}
+}
+
 // labelMapToString takes a label map[string]string
 // and returns the string-ified version of, e.g app=foo,env=dev.
 func labelMapToString(labelMap map[string]string) string {
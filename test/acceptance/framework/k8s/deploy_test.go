@@ -0,0 +1,65 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	cases := map[string]struct {
+		template string
+		vars     interface{}
+		expected string
+		expErr   string
+	}{
+		"no variables": {
+			template: "image: hashicorp/http-echo:latest",
+			vars:     nil,
+			expected: "image: hashicorp/http-echo:latest",
+		},
+		"map variables": {
+			template: "image: {{ .Image }}\nnamespace: {{ .Namespace }}",
+			vars:     map[string]string{"Image": "foo/bar:1.0", "Namespace": "ns"},
+			expected: "image: foo/bar:1.0\nnamespace: ns",
+		},
+		"struct variables": {
+			template: "image: {{ .Image }}",
+			vars:     struct{ Image string }{Image: "foo/bar:1.0"},
+			expected: "image: foo/bar:1.0",
+		},
+		"missing variable": {
+			template: "image: {{ .Image }}",
+			vars:     map[string]string{},
+			expErr:   `map has no entry for key "Image"`,
+		},
+		"invalid template": {
+			template: "image: {{ .Image",
+			vars:     map[string]string{},
+			expErr:   "unclosed action",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "deployment.yaml")
+			require.NoError(t, ioutil.WriteFile(path, []byte(c.template), 0644))
+
+			rendered, err := RenderTemplate(path, c.vars)
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, string(rendered))
+		})
+	}
+}
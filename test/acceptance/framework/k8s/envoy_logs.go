@@ -0,0 +1,87 @@
+package k8s
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	terratestLogger "github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/resources"
+	"github.com/stretchr/testify/require"
+)
+
+// envoyAccessLogComponents are the Envoy loggers whose debug logs describe
+// the requests that a sidecar handles: the request and response headers,
+// the cluster each request is routed to and the decisions of the RBAC
+// filters that enforce intentions.
+var envoyAccessLogComponents = map[string]bool{
+	"http":   true,
+	"router": true,
+	"rbac":   true,
+}
+
+// envoyLogLine matches the start of a line in Envoy's default log format,
+// "[<time>][<thread>][<level>][<component>] ...", and captures the component.
+var envoyLogLine = regexp.MustCompile(`^\[[^\]]*\]\[[^\]]*\]\[[^\]]*\]\[([^\]]+)\]`)
+
+var (
+	accessLogsMu      sync.RWMutex
+	collectAccessLogs bool
+)
+
+// SetCollectEnvoyAccessLogs sets whether WritePodsDebugInfoIfFailed
+// writes the access logs of the Envoy sidecars of failed tests.
+func SetCollectEnvoyAccessLogs(enabled bool) {
+	accessLogsMu.Lock()
+	defer accessLogsMu.Unlock()
+	collectAccessLogs = enabled
+}
+
+func collectEnvoyAccessLogs() bool {
+	accessLogsMu.RLock()
+	defer accessLogsMu.RUnlock()
+	return collectAccessLogs
+}
+
+// writeEnvoyAccessLog writes the requests that the Envoy sidecar of the pod
+// has logged to <pod>-envoy-access.log in testDebugDirectory. Consul's
+// proxy-defaults can't configure access logs in the Consul versions that
+// the chart supports, so the requests are taken from the sidecar's debug logs,
+// which test installs enable with connectInject.envoyExtraArgs by default.
+func writeEnvoyAccessLog(t *testing.T, kubectlOptions *k8s.KubectlOptions, podName, testDebugDirectory string) {
+	logs, err := RunKubectlAndGetOutputWithLoggerE(t, kubectlOptions, terratestLogger.Discard, "logs", podName, "-c", resources.SidecarContainer)
+	accessLog := envoyAccessLog(logs)
+	if err != nil {
+		accessLog = fmt.Sprintf("Error getting logs of %s: %s: %s", resources.SidecarContainer, err, logs)
+	}
+
+	accessLogFilename := filepath.Join(testDebugDirectory, fmt.Sprintf("%s-envoy-access.log", podName))
+	require.NoError(t, ioutil.WriteFile(accessLogFilename, []byte(accessLog), 0600))
+}
+
+// envoyAccessLog returns the lines of the envoyAccessLogComponents loggers
+// from the logs of an Envoy sidecar, including the headers of requests
+// and responses that follow them on their own lines.
+func envoyAccessLog(logs string) string {
+	var accessLog strings.Builder
+	keep := false
+	for _, line := range strings.Split(logs, "\n") {
+		if match := envoyLogLine.FindStringSubmatch(line); match != nil {
+			keep = envoyAccessLogComponents[match[1]]
+		} else if !strings.HasPrefix(line, "'") {
+			// Only the headers that Envoy logs are quoted
+			// on their own lines. Other lines aren't Envoy's.
+			keep = false
+		}
+		if keep && line != "" {
+			accessLog.WriteString(line)
+			accessLog.WriteString("\n")
+		}
+	}
+	return accessLog.String()
+}
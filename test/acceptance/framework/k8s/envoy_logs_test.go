@@ -0,0 +1,32 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvoyAccessLog(t *testing.T) {
+	logs := `[2020-11-10 10:00:00.000][1][info][main] [source/server/server.cc:305] initializing epoch 0
+[2020-11-10 10:00:01.000][15][debug][conn_handler] [source/server/connection_handler_impl.cc:501] [C3] new connection
+[2020-11-10 10:00:01.001][15][debug][http] [source/common/http/conn_manager_impl.cc:254] [C3] new stream
+[2020-11-10 10:00:01.001][15][debug][http] [source/common/http/conn_manager_impl.cc:886] [C3][S1] request headers complete (end_stream=true):
+':authority', 'localhost:1234'
+':path', '/'
+':method', 'GET'
+
+[2020-11-10 10:00:01.002][15][debug][router] [source/common/router/router.cc:429] [C3][S1] cluster 'static-server' match for URL '/'
+[2020-11-10 10:00:01.002][15][debug][pool] [source/common/http/http1/conn_pool.cc:51] creating a new connection
+[2020-11-10 10:00:01.003][15][debug][rbac] [source/extensions/filters/http/rbac/rbac_filter.cc:121] enforced denied, matched policy none
+2020-11-10T10:00:02.000Z [INFO]  envoy: not an envoy log line
+`
+
+	require.Equal(t, `[2020-11-10 10:00:01.001][15][debug][http] [source/common/http/conn_manager_impl.cc:254] [C3] new stream
+[2020-11-10 10:00:01.001][15][debug][http] [source/common/http/conn_manager_impl.cc:886] [C3][S1] request headers complete (end_stream=true):
+':authority', 'localhost:1234'
+':path', '/'
+':method', 'GET'
+[2020-11-10 10:00:01.002][15][debug][router] [source/common/router/router.cc:429] [C3][S1] cluster 'static-server' match for URL '/'
+[2020-11-10 10:00:01.003][15][debug][rbac] [source/extensions/filters/http/rbac/rbac_filter.cc:121] enforced denied, matched policy none
+`, envoyAccessLog(logs))
+}
@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RemoveHostPath removes the directory at path from the node with the given name,
+// e.g. the data that a hostPath volume leaves on the node after its pods are deleted.
+// The test can't reach the node's file system, so it runs a pod on the node
+// in the namespace of options that mounts the parent directory and removes path.
+// The image must contain rm, e.g. the Consul image, which the node has already pulled.
+func RemoveHostPath(t *testing.T, options *k8s.KubectlOptions, nodeName, image, path string) {
+	t.Helper()
+
+	client := helpers.KubernetesClientFromOptions(t, options)
+	pod := hostPathCleanupPod(nodeName, image, path)
+	logger.Logf(t, "removing %s from node %s", path, nodeName)
+	pod, err := client.CoreV1().Pods(options.Namespace).Create(helpers.TestContext(t), pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+	defer func() {
+		err := client.CoreV1().Pods(options.Namespace).Delete(helpers.TestContext(t), pod.Name, metav1.DeleteOptions{})
+		if !errors.IsNotFound(err) {
+			require.NoError(t, err)
+		}
+	}()
+
+	helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+		current, err := client.CoreV1().Pods(options.Namespace).Get(helpers.TestContext(t), pod.Name, metav1.GetOptions{})
+		require.NoError(r, err)
+		require.NotEqual(r, corev1.PodFailed, current.Status.Phase, "pod %s failed to remove %s", pod.Name, path)
+		require.Equal(r, corev1.PodSucceeded, current.Status.Phase, "pod %s has not completed", pod.Name)
+	})
+}
+
+// hostPathCleanupPod returns the pod that RemoveHostPath runs to remove path from the node.
+func hostPathCleanupPod(nodeName, image, path string) *corev1.Pod {
+	path = filepath.Clean(path)
+	const mountPath = "/host"
+	var rootUser int64 = 0
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("remove-host-path-%s-", strings.ToLower(nodeName)),
+		},
+		Spec: corev1.PodSpec{
+			// Setting the node name skips the scheduler, so the pod
+			// also runs on nodes that are cordoned.
+			NodeName:      nodeName,
+			RestartPolicy: corev1.RestartPolicyNever,
+			Tolerations:   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{{
+				Name:    "remove-host-path",
+				Image:   image,
+				Command: []string{"rm", "-rf", filepath.Join(mountPath, filepath.Base(path))},
+				// The files may be owned by users other than that of the image.
+				SecurityContext: &corev1.SecurityContext{RunAsUser: &rootUser},
+				VolumeMounts:    []corev1.VolumeMount{{Name: "parent", MountPath: mountPath}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "parent",
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{Path: filepath.Dir(path)},
+				},
+			}},
+		},
+	}
+}
@@ -0,0 +1,23 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostPathCleanupPod(t *testing.T) {
+	pod := hostPathCleanupPod("Node-1", "hashicorp/consul:1.9.0", "/tmp/consul-release/")
+
+	require.Equal(t, "remove-host-path-node-1-", pod.GenerateName)
+	require.Equal(t, "Node-1", pod.Spec.NodeName)
+	require.Len(t, pod.Spec.Containers, 1)
+	container := pod.Spec.Containers[0]
+	require.Equal(t, "hashicorp/consul:1.9.0", container.Image)
+	require.Equal(t, []string{"rm", "-rf", "/host/consul-release"}, container.Command)
+	require.Equal(t, int64(0), *container.SecurityContext.RunAsUser)
+	require.Equal(t, "/host", container.VolumeMounts[0].MountPath)
+	require.Len(t, pod.Spec.Volumes, 1)
+	require.Equal(t, container.VolumeMounts[0].Name, pod.Spec.Volumes[0].Name)
+	require.Equal(t, "/tmp", pod.Spec.Volumes[0].HostPath.Path)
+}
@@ -1,14 +1,22 @@
 package k8s
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gruntwork-io/terratest/modules/k8s"
 	terratestLogger "github.com/gruntwork-io/terratest/modules/logger"
-	"github.com/gruntwork-io/terratest/modules/shell"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/images"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/stretchr/testify/require"
 )
@@ -20,9 +28,27 @@ var kubeAPIConnectErrs = []string{
 	"Unable to connect to the server",
 }
 
+// KubectlError is returned when a kubectl command fails.
+// Output is the combined output of the command, including the error message.
+type KubectlError struct {
+	Args   []string
+	Output string
+	Err    error
+}
+
+func (e *KubectlError) Error() string {
+	return fmt.Sprintf("kubectl %s failed: %s", strings.Join(e.Args, " "), e.Err)
+}
+
+func (e *KubectlError) Unwrap() error {
+	return e.Err
+}
+
 // Functions included in this file already exist in terratest's k8s library, however,
 // we're re-implementing them because we don't want to use their default logger
 // as it logs everything regardless of verbosity level set via go test -v flags.
+// Unlike terratest, they run kubectl in-process using the kubectl
+// libraries rather than shelling out to a kubectl binary in the PATH.
 
 // RunKubectlAndGetOutputE runs an arbitrary kubectl command provided via args
 // and returns its output and error.
@@ -33,7 +59,28 @@ func RunKubectlAndGetOutputE(t *testing.T, options *k8s.KubectlOptions, args ...
 // RunKubectlAndGetOutputWithLoggerE is the same as RunKubectlAndGetOutputE but
 // it also allows you to provide a custom logger. This is useful if the command output
 // contains sensitive information, for example, when you can pass logger.Discard.
+// Setting the Env of the options is an error because the command doesn't run in a separate process.
 func RunKubectlAndGetOutputWithLoggerE(t *testing.T, options *k8s.KubectlOptions, logger *terratestLogger.Logger, args ...string) (string, error) {
+	return runKubectlWithRetries(helpers.TestContext(t), t, options, logger, args)
+}
+
+// RunKubectlAndGetOutputWithContextE is the same as RunKubectlAndGetOutputE
+// but the command fails with an error when ctx is cancelled
+// instead of when the context of the test, see helpers.TestContext, is.
+func RunKubectlAndGetOutputWithContextE(ctx context.Context, t *testing.T, options *k8s.KubectlOptions, args ...string) (string, error) {
+	return runKubectlWithRetries(ctx, t, options, terratestLogger.New(logger.TestLogger{}), args)
+}
+
+// runKubectlWithRetries runs kubectl with args and the flags for options,
+// retrying a few times if it can't connect to the Kubernetes API.
+func runKubectlWithRetries(ctx context.Context, t *testing.T, options *k8s.KubectlOptions, logger *terratestLogger.Logger, args []string) (string, error) {
+	if len(options.Env) > 0 {
+		return "", &KubectlError{
+			Args: args,
+			Err:  errors.New("the Env of the kubectl options isn't supported because kubectl runs in-process, set the kubeconfig and context instead"),
+		}
+	}
+
 	var cmdArgs []string
 	if options.ContextName != "" {
 		cmdArgs = append(cmdArgs, "--context", options.ContextName)
@@ -45,12 +92,6 @@ func RunKubectlAndGetOutputWithLoggerE(t *testing.T, options *k8s.KubectlOptions
 		cmdArgs = append(cmdArgs, "--namespace", options.Namespace)
 	}
 	cmdArgs = append(cmdArgs, args...)
-	command := shell.Command{
-		Command: "kubectl",
-		Args:    cmdArgs,
-		Env:     options.Env,
-		Logger:  logger,
-	}
 
 	counter := &retry.Counter{
 		Count: 3,
@@ -59,7 +100,11 @@ func RunKubectlAndGetOutputWithLoggerE(t *testing.T, options *k8s.KubectlOptions
 	var output string
 	var err error
 	retry.RunWith(counter, t, func(r *retry.R) {
-		output, err = shell.RunCommandAndGetOutputE(t, command)
+		logger.Logf(t, "Running command kubectl with args %v", cmdArgs)
+		output, err = runKubectlContext(ctx, cmdArgs)
+		if output != "" {
+			logger.Logf(t, "%s", output)
+		}
 		if err != nil {
 			// Want to retry on errors connecting to actual Kube API because
 			// these are intermittent.
@@ -76,18 +121,110 @@ func RunKubectlAndGetOutputWithLoggerE(t *testing.T, options *k8s.KubectlOptions
 
 // KubectlApply takes a path to a Kubernetes YAML file and
 // applies it to the cluster by running 'kubectl apply -f'.
+// The resources in the file are labeled with the test that applied them,
+// see the testlabels package, and if -test-image-registry is set,
+// their images are rewritten to be pulled from that registry.
 // If there's an error applying the file, fail the test.
 func KubectlApply(t *testing.T, options *k8s.KubectlOptions, configPath string) {
-	_, err := RunKubectlAndGetOutputE(t, options, "apply", "-f", configPath)
+	_, err := RunKubectlAndGetOutputE(t, options, "apply", "-f", prepareManifestFile(t, configPath))
+	require.NoError(t, err)
+}
+
+// KubectlApplyExpectError takes a path to a Kubernetes YAML file and
+// applies it to the cluster by running 'kubectl apply -f', expecting it to fail,
+// for example, because a validating webhook rejects it. It returns the output
+// of the command so that tests can assert on the error message.
+// Errors connecting to webhooks are retried because webhook endpoints may not be
+// ready yet. If applying the file succeeds, fail the test.
+// Because the resources are created if it succeeds, they're deleted when the test finishes.
+func KubectlApplyExpectError(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure, noCleanup bool, configPath string) string {
+	t.Helper()
+
+	// The manifest is copied because callers may remove it before the test finishes.
+	manifest, err := ioutil.ReadFile(configPath)
 	require.NoError(t, err)
+	deletePath := writeTempManifest(t, manifest)
+	helpers.Cleanup(t, noCleanupOnFailure, noCleanup, func() {
+		out, err := RunKubectlAndGetOutputE(t, options, "delete", "--ignore-not-found", "-f", deletePath)
+		require.NoError(t, err, out)
+	})
+
+	var output string
+	retry.RunWith(&retry.Timer{Timeout: timeouts.WebhookReady(), Wait: 2 * time.Second}, t, func(r *retry.R) {
+		var err error
+		output, err = RunKubectlAndGetOutputE(t, options, "apply", "-f", configPath)
+		require.Error(r, err, "expected applying %s to fail", configPath)
+		require.NotContains(r, output, "connection refused")
+	})
+	return output
 }
 
 // KubectlApplyK takes a path to a kustomize directory and
 // applies it to the cluster by running 'kubectl apply -k'.
+// If the resources need to be labeled with the test that applied them or
+// -test-image-registry is set, the directory is rendered with
+// 'kubectl kustomize' instead so that the resources can be changed
+// the same way as in KubectlApply before they're applied.
 // If there's an error applying the file, fail the test.
 func KubectlApplyK(t *testing.T, options *k8s.KubectlOptions, kustomizeDir string) {
-	_, err := RunKubectlAndGetOutputE(t, options, "apply", "-k", kustomizeDir)
+	labels := testlabels.ForTest(t)
+	registry := images.Registry()
+	if labels == nil && registry == "" {
+		_, err := RunKubectlAndGetOutputE(t, options, "apply", "-k", kustomizeDir)
+		require.NoError(t, err)
+		return
+	}
+
+	manifest, err := RunKubectlAndGetOutputE(t, options, "kustomize", kustomizeDir)
+	require.NoError(t, err)
+	_, err = RunKubectlAndGetOutputE(t, options, "apply", "-f", writeTempManifest(t, prepareManifest(t, []byte(manifest), labels, registry)))
+	require.NoError(t, err)
+}
+
+// prepareManifestFile returns the path of a copy of the file at configPath
+// with its resources labeled with the test and its images rewritten to be
+// pulled from the -test-image-registry. It returns configPath if neither
+// is needed or configPath is a directory.
+func prepareManifestFile(t *testing.T, configPath string) string {
+	labels := testlabels.ForTest(t)
+	registry := images.Registry()
+	if labels == nil && registry == "" {
+		return configPath
+	}
+	info, err := os.Stat(configPath)
+	require.NoError(t, err)
+	if info.IsDir() {
+		return configPath
+	}
+
+	manifest, err := ioutil.ReadFile(configPath)
+	require.NoError(t, err)
+	return writeTempManifest(t, prepareManifest(t, manifest, labels, registry))
+}
+
+// prepareManifest adds labels to the resources in manifest and
+// rewrites their images to be pulled from registry if it's not empty.
+func prepareManifest(t *testing.T, manifest []byte, labels map[string]string, registry string) []byte {
+	manifest, err := testlabels.AddToManifest(manifest, labels)
+	require.NoError(t, err)
+	if registry != "" {
+		manifest = images.RewriteManifest(registry, manifest)
+	}
+	return manifest
+}
+
+// writeTempManifest writes manifest to a temporary file that's
+// removed when the test finishes and returns its path.
+func writeTempManifest(t *testing.T, manifest []byte) string {
+	file, err := ioutil.TempFile("", "manifest-*.yaml")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.Remove(file.Name())
+	})
+	_, err = file.Write(manifest)
 	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	return file.Name()
 }
 
 // KubectlDelete takes a path to a Kubernetes YAML file and
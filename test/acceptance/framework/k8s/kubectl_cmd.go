@@ -0,0 +1,275 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	diskcached "k8s.io/client-go/discovery/cached/disk"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/transport"
+	"k8s.io/client-go/util/homedir"
+	"k8s.io/kubectl/pkg/cmd/annotate"
+	"k8s.io/kubectl/pkg/cmd/apply"
+	"k8s.io/kubectl/pkg/cmd/create"
+	"k8s.io/kubectl/pkg/cmd/delete"
+	"k8s.io/kubectl/pkg/cmd/describe"
+	"k8s.io/kubectl/pkg/cmd/drain"
+	"k8s.io/kubectl/pkg/cmd/exec"
+	"k8s.io/kubectl/pkg/cmd/kustomize"
+	"k8s.io/kubectl/pkg/cmd/label"
+	"k8s.io/kubectl/pkg/cmd/logs"
+	"k8s.io/kubectl/pkg/cmd/patch"
+	"k8s.io/kubectl/pkg/cmd/replace"
+	"k8s.io/kubectl/pkg/cmd/rollout"
+	"k8s.io/kubectl/pkg/cmd/run"
+	"k8s.io/kubectl/pkg/cmd/scale"
+	"k8s.io/kubectl/pkg/cmd/taint"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/cmd/wait"
+)
+
+// kubectlFatalError is raised as a panic by kubectl commands in place of
+// exiting the process when they fail. See acquireKubectlFatalHandler.
+type kubectlFatalError struct {
+	msg  string
+	code int
+}
+
+var (
+	fatalHandlerMu    sync.Mutex
+	fatalHandlerUsers int
+)
+
+// acquireKubectlFatalHandler makes kubectl commands panic with a kubectlFatalError
+// instead of calling os.Exit when they fail so that runKubectl can recover and
+// return the failure as an error. The handler is global to the kubectl libraries,
+// so it's only installed while commands run, and the returned function restores
+// kubectl's default handler once the last running command has finished.
+func acquireKubectlFatalHandler() (release func()) {
+	fatalHandlerMu.Lock()
+	defer fatalHandlerMu.Unlock()
+
+	if fatalHandlerUsers == 0 {
+		cmdutil.BehaviorOnFatal(func(msg string, code int) {
+			panic(kubectlFatalError{msg: msg, code: code})
+		})
+	}
+	fatalHandlerUsers++
+
+	return func() {
+		fatalHandlerMu.Lock()
+		defer fatalHandlerMu.Unlock()
+
+		fatalHandlerUsers--
+		if fatalHandlerUsers == 0 {
+			cmdutil.DefaultBehaviorOnFatal()
+		}
+	}
+}
+
+// contextRESTClientGetter is a RESTClientGetter whose REST configs
+// make every request of a command with ctx, so that its requests,
+// including discovery and watches, fail once ctx is cancelled.
+// The methods of ConfigFlags that create clients call its own
+// ToRESTConfig, so they're reimplemented in the same way here.
+type contextRESTClientGetter struct {
+	*genericclioptions.ConfigFlags
+	ctx context.Context
+}
+
+// overlyCautiousIllegalFileCharacters matches the characters that
+// ConfigFlags replaces in the API server host to name its discovery cache.
+var overlyCautiousIllegalFileCharacters = regexp.MustCompile(`[^(\w/\.)]`)
+
+func (g *contextRESTClientGetter) ToRESTConfig() (*rest.Config, error) {
+	config, err := g.ConfigFlags.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.WrapTransport = transport.Wrappers(config.WrapTransport, func(rt http.RoundTripper) http.RoundTripper {
+		return &contextRoundTripper{ctx: g.ctx, rt: rt}
+	})
+	return config, nil
+}
+
+func (g *contextRESTClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	config, err := g.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	// Discovery makes a request for every API group, see ConfigFlags.
+	config.Burst = 100
+
+	cacheDir := filepath.Join(homedir.HomeDir(), ".kube", "cache")
+	if g.CacheDir != nil && *g.CacheDir != "" {
+		cacheDir = *g.CacheDir
+	}
+	host := strings.Replace(strings.Replace(config.Host, "https://", "", 1), "http://", "", 1)
+	discoveryCacheDir := filepath.Join(cacheDir, "discovery", overlyCautiousIllegalFileCharacters.ReplaceAllString(host, "_"))
+	return diskcached.NewCachedDiscoveryClientForConfig(config, discoveryCacheDir, filepath.Join(cacheDir, "http"), 10*time.Minute)
+}
+
+func (g *contextRESTClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	discoveryClient, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
+	return restmapper.NewShortcutExpander(mapper, discoveryClient), nil
+}
+
+// contextRoundTripper makes requests with ctx.
+type contextRoundTripper struct {
+	ctx context.Context
+	rt  http.RoundTripper
+}
+
+func (c *contextRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return c.rt.RoundTrip(req.WithContext(c.ctx))
+}
+
+// newKubectlCommand returns a kubectl root command with the subcommands used
+// by the tests. Global flags like --kubeconfig, --context and --namespace
+// are parsed from the args in the same way as the kubectl binary.
+// Requests to the Kubernetes API are made with ctx.
+func newKubectlCommand(ctx context.Context, streams genericclioptions.IOStreams) *cobra.Command {
+	root := &cobra.Command{
+		Use:           "kubectl",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+
+	configFlags := genericclioptions.NewConfigFlags(true)
+	configFlags.AddFlags(root.PersistentFlags())
+	matchVersionFlags := cmdutil.NewMatchVersionFlags(&contextRESTClientGetter{ConfigFlags: configFlags, ctx: ctx})
+	matchVersionFlags.AddFlags(root.PersistentFlags())
+	f := cmdutil.NewFactory(matchVersionFlags)
+
+	root.AddCommand(
+		annotate.NewCmdAnnotate("kubectl", f, streams),
+		apply.NewCmdApply("kubectl", f, streams),
+		create.NewCmdCreate(f, streams),
+		delete.NewCmdDelete(f, streams),
+		describe.NewCmdDescribe("kubectl", f, streams),
+		drain.NewCmdCordon(f, streams),
+		drain.NewCmdDrain(f, streams),
+		drain.NewCmdUncordon(f, streams),
+		exec.NewCmdExec(f, streams),
+		newGetCommand(f, streams),
+		kustomize.NewCmdKustomize(streams),
+		label.NewCmdLabel(f, streams),
+		logs.NewCmdLogs(f, streams),
+		patch.NewCmdPatch(f, streams),
+		replace.NewCmdReplace(f, streams),
+		rollout.NewCmdRollout(f, streams),
+		run.NewCmdRun(f, streams),
+		scale.NewCmdScale(f, streams),
+		taint.NewCmdTaint(f, streams),
+		wait.NewCmdWait(f, streams),
+	)
+	return root
+}
+
+// runKubectlContext runs kubectl with args in-process and returns the combined
+// stdout and stderr of the command. If the command fails, the error is a
+// *KubectlError and the output includes the error message. The requests of
+// the command to the Kubernetes API are made with ctx, so once ctx is cancelled
+// they fail and the command returns an error that says it was cancelled instead
+// of the test hanging until go test's -timeout panics. Commands that wait, such
+// as kubectl wait and kubectl rollout status, return once their --timeout has
+// passed at the latest, and exec returns when the process in the container exits.
+func runKubectlContext(ctx context.Context, args []string) (string, error) {
+	cancelled := func(output string) error {
+		return &KubectlError{
+			Args:   args,
+			Output: output,
+			Err:    fmt.Errorf("command didn't finish before it was cancelled: %w", ctx.Err()),
+		}
+	}
+	if ctx.Err() != nil {
+		return "", cancelled("")
+	}
+
+	output, err := runKubectl(ctx, args)
+	if err != nil && ctx.Err() != nil {
+		return output, cancelled(output)
+	}
+	return output, err
+}
+
+// runKubectl runs kubectl with args in-process, making its requests to the
+// Kubernetes API with ctx, and returns the combined stdout and stderr of the
+// command. If the command fails, the error is a *KubectlError and the output
+// includes the error message.
+func runKubectl(ctx context.Context, args []string) (output string, err error) {
+	release := acquireKubectlFatalHandler()
+	defer release()
+
+	// Commands like exec copy stdout and stderr in separate goroutines,
+	// so writes to the combined output need to be synchronized.
+	var out syncBuffer
+	streams := genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: &out, ErrOut: &out}
+	cmd := newKubectlCommand(ctx, streams)
+	cmd.SetArgs(args)
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+
+	defer func() {
+		if r := recover(); r != nil {
+			fatal, ok := r.(kubectlFatalError)
+			if !ok {
+				panic(r)
+			}
+			out.WriteString(fatal.msg)
+			output = strings.TrimSuffix(out.String(), "\n")
+			err = &KubectlError{
+				Args:   args,
+				Output: output,
+				Err:    fmt.Errorf("exit code %d: %s", fatal.code, strings.TrimSpace(fatal.msg)),
+			}
+		}
+	}()
+
+	// Errors returned rather than handled by the commands are formatted
+	// and handled in the same way as kubectl does.
+	if err := cmd.Execute(); err != nil {
+		cmdutil.CheckErr(err)
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+// syncBuffer is a bytes.Buffer that's safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) WriteString(s string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.WriteString(s)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
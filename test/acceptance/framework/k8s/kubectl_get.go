@@ -0,0 +1,208 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/jsonpath"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// getOptions are the options of the get command.
+type getOptions struct {
+	printFlags    *genericclioptions.PrintFlags
+	labelSelector string
+	allNamespaces bool
+	sortBy        string
+	noHeaders     bool
+
+	genericclioptions.IOStreams
+}
+
+// newGetCommand returns a kubectl get command. kubectl's own get command
+// depends on a module that's no longer hosted at its import path, so this
+// implements the subset of it used by the tests: printing resources as
+// tables, including -o wide, or with the -o json, yaml, name, jsonpath
+// and go-template printers, and the --selector, --all-namespaces, --sort-by
+// and --no-headers flags.
+func newGetCommand(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := &getOptions{
+		printFlags: genericclioptions.NewPrintFlags(""),
+		IOStreams:  streams,
+	}
+	cmd := &cobra.Command{
+		Use:   "get TYPE [NAME ...]",
+		Short: "Display one or many resources",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(f, args)
+		},
+	}
+	o.printFlags.AddFlags(cmd)
+	cmd.Flags().StringVarP(&o.labelSelector, "selector", "l", "", "Selector (label query) to filter on.")
+	cmd.Flags().BoolVarP(&o.allNamespaces, "all-namespaces", "A", false, "List the requested objects across all namespaces.")
+	cmd.Flags().StringVar(&o.sortBy, "sort-by", "", "Sort tables by this JSONPath expression, e.g. .metadata.name.")
+	cmd.Flags().BoolVar(&o.noHeaders, "no-headers", false, "Don't print headers in tables.")
+	return cmd
+}
+
+func (o *getOptions) run(f cmdutil.Factory, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("you must specify the type of resource to get")
+	}
+	namespace, _, err := f.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return err
+	}
+
+	output := *o.printFlags.OutputFormat
+	table := output == "" || output == "wide"
+
+	singleItemImplied := false
+	builder := f.NewBuilder().
+		Unstructured().
+		NamespaceParam(namespace).DefaultNamespace().AllNamespaces(o.allNamespaces).
+		LabelSelectorParam(o.labelSelector).
+		ResourceTypeOrNameArgs(true, args...).
+		ContinueOnError().
+		Latest().
+		Flatten()
+	if table {
+		builder = builder.TransformRequests(o.requestTable)
+	}
+	infos, err := builder.Do().IntoSingleItemImplied(&singleItemImplied).Infos()
+	if err != nil {
+		return err
+	}
+
+	if table {
+		return o.printTables(infos, namespace, output == "wide")
+	}
+
+	printer, err := o.printFlags.ToPrinter()
+	if err != nil {
+		return err
+	}
+	if singleItemImplied && len(infos) == 1 {
+		return printer.PrintObj(infos[0].Object, o.Out)
+	}
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"metadata":   map[string]interface{}{"resourceVersion": ""},
+	}}
+	for _, info := range infos {
+		list.Items = append(list.Items, *info.Object.(*unstructured.Unstructured))
+	}
+	return printer.PrintObj(list, o.Out)
+}
+
+// requestTable asks the API server to return resources as tables so that
+// they're printed with the same columns as kubectl get.
+func (o *getOptions) requestTable(req *rest.Request) {
+	req.SetHeader("Accept", fmt.Sprintf("application/json;as=Table;v=%s;g=%s,application/json",
+		metav1.SchemeGroupVersion.Version, metav1.GroupName))
+	if o.sortBy != "" {
+		req.Param("includeObject", string(metav1.IncludeObject))
+	}
+}
+
+// printTables prints the tables returned by the API server for infos.
+func (o *getOptions) printTables(infos []*resource.Info, namespace string, wide bool) error {
+	printer := printers.NewTablePrinter(printers.PrintOptions{
+		Wide:          wide,
+		WithNamespace: o.allNamespaces,
+		NoHeaders:     o.noHeaders,
+	})
+
+	rows := 0
+	for _, info := range infos {
+		table, err := decodeTable(info.Object)
+		if err != nil {
+			return err
+		}
+		if o.sortBy != "" {
+			if err := sortTableRows(table, o.sortBy); err != nil {
+				return err
+			}
+		}
+		rows += len(table.Rows)
+		if len(table.Rows) == 0 {
+			continue
+		}
+		if err := printer.PrintObj(table, o.Out); err != nil {
+			return err
+		}
+	}
+
+	if rows == 0 {
+		if o.allNamespaces {
+			fmt.Fprintln(o.ErrOut, "No resources found")
+		} else {
+			fmt.Fprintf(o.ErrOut, "No resources found in %s namespace.\n", namespace)
+		}
+	}
+	return nil
+}
+
+// decodeTable converts a table returned by the API server as
+// an unstructured object into a metav1.Table.
+func decodeTable(obj runtime.Object) (*metav1.Table, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	table := &metav1.Table{}
+	if err := json.Unmarshal(data, table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// sortTableRows sorts the rows of table by the value of the JSONPath
+// expression sortBy in the object of each row.
+func sortTableRows(table *metav1.Table, sortBy string) error {
+	if !strings.HasPrefix(sortBy, "{") {
+		sortBy = fmt.Sprintf("{%s}", sortBy)
+	}
+	parser := jsonpath.New("sort-by").AllowMissingKeys(true)
+	if err := parser.Parse(sortBy); err != nil {
+		return err
+	}
+
+	keys := make([]string, len(table.Rows))
+	for i, row := range table.Rows {
+		var obj interface{}
+		if err := json.Unmarshal(row.Object.Raw, &obj); err != nil {
+			return fmt.Errorf("couldn't sort by %s: %s", sortBy, err)
+		}
+		var key strings.Builder
+		if err := parser.Execute(&key, obj); err != nil {
+			return err
+		}
+		keys[i] = key.String()
+	}
+
+	indexes := make([]int, len(table.Rows))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return keys[indexes[i]] < keys[indexes[j]]
+	})
+	rows := make([]metav1.TableRow, len(table.Rows))
+	for i, index := range indexes {
+		rows[i] = table.Rows[index]
+	}
+	table.Rows = rows
+	return nil
+}
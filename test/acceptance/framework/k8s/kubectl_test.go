@@ -0,0 +1,149 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/images"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestRunKubectl_Error(t *testing.T) {
+	cases := map[string]struct {
+		args       []string
+		wantOutput string
+	}{
+		"unknown command": {
+			args:       []string{"foo"},
+			wantOutput: `error: unknown command "foo" for "kubectl"`,
+		},
+		"invalid arguments": {
+			args:       []string{"get"},
+			wantOutput: "error: you must specify the type of resource to get",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			output, err := runKubectl(context.Background(), c.args)
+			require.Error(t, err)
+			require.Equal(t, c.wantOutput, output)
+
+			var kubectlErr *KubectlError
+			require.True(t, errors.As(err, &kubectlErr))
+			require.Equal(t, c.args, kubectlErr.Args)
+			require.Equal(t, c.wantOutput, kubectlErr.Output)
+		})
+	}
+}
+
+// Test that a command isn't run once its context is cancelled
+// and that the error says which command it was.
+func TestRunKubectlContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	args := []string{"get", "pods"}
+	_, err := runKubectlContext(ctx, args)
+	require.EqualError(t, err, "kubectl get pods failed: command didn't finish before it was cancelled: context canceled")
+	require.True(t, errors.Is(err, context.Canceled))
+
+	var kubectlErr *KubectlError
+	require.True(t, errors.As(err, &kubectlErr))
+	require.Equal(t, args, kubectlErr.Args)
+}
+
+// Test that a command that's waiting for the Kubernetes API
+// returns as soon as its context is cancelled.
+func TestRunKubectlContext_CancelledDuringRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, ioutil.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: `+server.URL+`
+contexts:
+- name: test
+  context:
+    cluster: test
+current-context: test
+`), 0600))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := runKubectlContext(ctx, []string{"--kubeconfig", kubeconfig, "--cache-dir", t.TempDir(), "get", "pods"})
+	require.Error(t, err)
+	require.True(t, errors.Is(err, context.DeadlineExceeded), err.Error())
+	require.Less(t, int64(time.Since(start)), int64(10*time.Second))
+	require.Zero(t, fatalHandlerUsers, "kubectl's default fatal handler should be restored")
+}
+
+func TestRunKubectlAndGetOutputE_Env(t *testing.T) {
+	options := &terratestk8s.KubectlOptions{Env: map[string]string{"KUBECONFIG": "/tmp/kubeconfig"}}
+	_, err := RunKubectlAndGetOutputE(t, options, "get", "pods")
+	require.EqualError(t, err, "kubectl get pods failed: the Env of the kubectl options isn't supported because kubectl runs in-process, set the kubeconfig and context instead")
+}
+
+func TestSortTableRows(t *testing.T) {
+	row := func(name, timestamp string) metav1.TableRow {
+		return metav1.TableRow{
+			Cells:  []interface{}{name},
+			Object: runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "` + name + `"}, "lastTimestamp": "` + timestamp + `"}`)},
+		}
+	}
+	table := &metav1.Table{Rows: []metav1.TableRow{
+		row("c", "2020-12-01T10:00:02Z"),
+		row("a", "2020-12-01T10:00:00Z"),
+		row("b", "2020-12-01T10:00:01Z"),
+	}}
+
+	require.NoError(t, sortTableRows(table, ".lastTimestamp"))
+	var names []interface{}
+	for _, r := range table.Rows {
+		names = append(names, r.Cells[0])
+	}
+	require.Equal(t, []interface{}{"a", "b", "c"}, names)
+}
+
+func TestPrepareManifestFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "deployment.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("kind: Pod\nspec:\n  containers:\n  - image: tutum/curl:latest\n"), 0600))
+
+	require.Equal(t, path, prepareManifestFile(t, path), "files shouldn't be rewritten without a registry or test run ID")
+
+	images.SetRegistry("registry.example.com/mirror")
+	t.Cleanup(func() { images.SetRegistry("") })
+
+	rewritten, err := ioutil.ReadFile(prepareManifestFile(t, path))
+	require.NoError(t, err)
+	require.Equal(t, "kind: Pod\nspec:\n  containers:\n  - image: registry.example.com/mirror/tutum/curl:latest\n", string(rewritten))
+	require.Equal(t, dir, prepareManifestFile(t, dir), "directories can't be rewritten")
+
+	testlabels.SetRunID("abc123")
+	t.Cleanup(func() { testlabels.SetRunID("") })
+
+	rewritten, err = ioutil.ReadFile(prepareManifestFile(t, path))
+	require.NoError(t, err)
+	require.Contains(t, string(rewritten), "test-name: TestPrepareManifestFile\n")
+	require.Contains(t, string(rewritten), "test-run-id: abc123\n")
+	require.Contains(t, string(rewritten), "image: registry.example.com/mirror/tutum/curl:latest\n")
+}
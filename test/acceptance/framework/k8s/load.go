@@ -0,0 +1,115 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+)
+
+// loadBatchSize is the number of requests GenerateLoad makes from a single
+// exec into the pod, so that the rate of requests isn't limited by
+// how fast kubectl can exec into the pod.
+const loadBatchSize = 5
+
+// LoadWindow is the number of requests made and failed during
+// a window of time while GenerateLoad was running.
+type LoadWindow struct {
+	Start    time.Time
+	Requests int
+	Failures int
+}
+
+// ErrorRate returns the fraction of requests in the window that failed.
+func (w LoadWindow) ErrorRate() float64 {
+	if w.Requests == 0 {
+		return 0
+	}
+	return float64(w.Failures) / float64(w.Requests)
+}
+
+// LoadReport is the result of GenerateLoad,
+// with one window for each period of time the load ran for.
+type LoadReport struct {
+	Windows []LoadWindow
+}
+
+// Total returns a single window that covers all the windows of the report.
+func (r LoadReport) Total() LoadWindow {
+	var total LoadWindow
+	for i, w := range r.Windows {
+		if i == 0 {
+			total.Start = w.Start
+		}
+		total.Requests += w.Requests
+		total.Failures += w.Failures
+	}
+	return total
+}
+
+// GenerateLoad makes the HTTP request from a pod of the deployment given by
+// deploymentName over and over until stop is closed, and returns the number
+// of requests that were made and that failed during each window of time.
+// A request fails if it gets no response or a response without a 2xx
+// status code. Requests that can't be made because kubectl can't exec into
+// the pod aren't counted, since they say nothing about the service mesh.
+// It's meant to run in a goroutine for a long time while the test
+// changes things that shouldn't interrupt traffic, such as restarting
+// Consul's components.
+func GenerateLoad(t *testing.T, options *k8s.KubectlOptions, deploymentName string, req HTTPRequest, window time.Duration, stop <-chan struct{}) LoadReport {
+	rec := newLoadRecorder(time.Now(), window)
+	for {
+		select {
+		case <-stop:
+			return rec.report()
+		default:
+		}
+
+		resps, err := CurlRepeatedE(t, options, deploymentName, req, loadBatchSize)
+		if err != nil {
+			logger.Logf(t, "couldn't make requests to %s from %s: %s", req.URL, deploymentName, err)
+			select {
+			case <-stop:
+			case <-time.After(1 * time.Second):
+			}
+			continue
+		}
+		now := time.Now()
+		for _, resp := range resps {
+			rec.record(now, resp.StatusCode < 200 || resp.StatusCode >= 300)
+		}
+	}
+}
+
+// loadRecorder counts requests in consecutive windows of the same length.
+type loadRecorder struct {
+	start   time.Time
+	window  time.Duration
+	windows []LoadWindow
+}
+
+func newLoadRecorder(start time.Time, window time.Duration) *loadRecorder {
+	return &loadRecorder{start: start, window: window}
+}
+
+// record counts a request that finished at the given time.
+// Requests that finished before the recorder's start are
+// counted in the first window.
+func (l *loadRecorder) record(at time.Time, failed bool) {
+	i := 0
+	if at.After(l.start) {
+		i = int(at.Sub(l.start) / l.window)
+	}
+	for len(l.windows) <= i {
+		l.windows = append(l.windows, LoadWindow{Start: l.start.Add(time.Duration(len(l.windows)) * l.window)})
+	}
+	l.windows[i].Requests++
+	if failed {
+		l.windows[i].Failures++
+	}
+}
+
+func (l *loadRecorder) report() LoadReport {
+	return LoadReport{Windows: l.windows}
+}
@@ -0,0 +1,29 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRecorder(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rec := newLoadRecorder(start, time.Minute)
+
+	rec.record(start.Add(-time.Second), false)
+	rec.record(start.Add(10*time.Second), true)
+	// Nothing is recorded in the second window.
+	rec.record(start.Add(2*time.Minute), false)
+	rec.record(start.Add(2*time.Minute+59*time.Second), true)
+
+	report := rec.report()
+	require.Equal(t, []LoadWindow{
+		{Start: start, Requests: 2, Failures: 1},
+		{Start: start.Add(time.Minute)},
+		{Start: start.Add(2 * time.Minute), Requests: 2, Failures: 1},
+	}, report.Windows)
+	require.Equal(t, LoadWindow{Start: start, Requests: 4, Failures: 2}, report.Total())
+	require.Equal(t, 0.5, report.Total().ErrorRate())
+	require.Equal(t, float64(0), report.Windows[1].ErrorRate())
+}
@@ -0,0 +1,125 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The functions in this file get Kubernetes objects in the namespace of the
+// options with client-go so that tests can make assertions on their fields
+// instead of on the output of kubectl. The E variants return the errors
+// from the Kubernetes API, so that tests can check them with functions like
+// errors.IsNotFound from k8s.io/apimachinery/pkg/api/errors.
+
+// GetPodsE returns the pods matching labelSelector.
+// If labelSelector is empty, it returns all pods.
+func GetPodsE(t *testing.T, options *k8s.KubectlOptions, labelSelector string) ([]corev1.Pod, error) {
+	return getPods(helpers.TestContext(t), helpers.KubernetesClientFromOptions(t, options), options.Namespace, labelSelector)
+}
+
+// GetPods is like GetPodsE but fails the test if there's an error.
+func GetPods(t *testing.T, options *k8s.KubectlOptions, labelSelector string) []corev1.Pod {
+	t.Helper()
+
+	pods, err := GetPodsE(t, options, labelSelector)
+	require.NoError(t, err)
+	return pods
+}
+
+// GetPodE returns the pod with the given name.
+func GetPodE(t *testing.T, options *k8s.KubectlOptions, name string) (*corev1.Pod, error) {
+	client := helpers.KubernetesClientFromOptions(t, options)
+	return client.CoreV1().Pods(options.Namespace).Get(helpers.TestContext(t), name, metav1.GetOptions{})
+}
+
+// GetPod is like GetPodE but fails the test if there's an error.
+func GetPod(t *testing.T, options *k8s.KubectlOptions, name string) *corev1.Pod {
+	t.Helper()
+
+	pod, err := GetPodE(t, options, name)
+	require.NoError(t, err)
+	return pod
+}
+
+// GetServiceE returns the service with the given name.
+func GetServiceE(t *testing.T, options *k8s.KubectlOptions, name string) (*corev1.Service, error) {
+	client := helpers.KubernetesClientFromOptions(t, options)
+	return client.CoreV1().Services(options.Namespace).Get(helpers.TestContext(t), name, metav1.GetOptions{})
+}
+
+// GetService is like GetServiceE but fails the test if there's an error.
+func GetService(t *testing.T, options *k8s.KubectlOptions, name string) *corev1.Service {
+	t.Helper()
+
+	service, err := GetServiceE(t, options, name)
+	require.NoError(t, err)
+	return service
+}
+
+// GetDeploymentE returns the deployment with the given name.
+func GetDeploymentE(t *testing.T, options *k8s.KubectlOptions, name string) (*appsv1.Deployment, error) {
+	client := helpers.KubernetesClientFromOptions(t, options)
+	return client.AppsV1().Deployments(options.Namespace).Get(helpers.TestContext(t), name, metav1.GetOptions{})
+}
+
+// GetDeployment is like GetDeploymentE but fails the test if there's an error.
+func GetDeployment(t *testing.T, options *k8s.KubectlOptions, name string) *appsv1.Deployment {
+	t.Helper()
+
+	deployment, err := GetDeploymentE(t, options, name)
+	require.NoError(t, err)
+	return deployment
+}
+
+// PodNames returns the names of pods.
+func PodNames(pods []corev1.Pod) []string {
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	return names
+}
+
+// PodRestarts returns the total container restart count of each pod,
+// keyed by the pod's UID, so that comparing the results for the same pods
+// at two points in time shows whether they were restarted or replaced in between.
+func PodRestarts(pods []corev1.Pod) map[string]int32 {
+	restarts := make(map[string]int32)
+	for _, pod := range pods {
+		var podRestarts int32
+		for _, status := range pod.Status.ContainerStatuses {
+			podRestarts += status.RestartCount
+		}
+		restarts[string(pod.UID)] = podRestarts
+	}
+	return restarts
+}
+
+// ContainerImage returns the image of the container or init container
+// of pod with the given name, or "" if pod doesn't have such a container.
+func ContainerImage(pod corev1.Pod, name string) string {
+	for _, containers := range [][]corev1.Container{pod.Spec.Containers, pod.Spec.InitContainers} {
+		for _, container := range containers {
+			if container.Name == name {
+				return container.Image
+			}
+		}
+	}
+	return ""
+}
+
+func getPods(ctx context.Context, client kubernetes.Interface, namespace, labelSelector string) ([]corev1.Pod, error) {
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetPods(t *testing.T) {
+	pod := func(name, namespace, app string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": app}}}
+	}
+	client := fake.NewSimpleClientset(
+		pod("static-server-1", "default", "static-server"),
+		pod("static-server-2", "default", "static-server"),
+		pod("static-client-1", "default", "static-client"),
+		pod("static-server-3", "other", "static-server"),
+	)
+
+	cases := map[string]struct {
+		labelSelector string
+		expPods       []string
+	}{
+		"all pods": {
+			labelSelector: "",
+			expPods:       []string{"static-client-1", "static-server-1", "static-server-2"},
+		},
+		"matching pods": {
+			labelSelector: "app=static-server",
+			expPods:       []string{"static-server-1", "static-server-2"},
+		},
+		"no matching pods": {
+			labelSelector: "app=foo",
+			expPods:       nil,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pods, err := getPods(context.Background(), client, "default", c.labelSelector)
+			require.NoError(t, err)
+			require.ElementsMatch(t, c.expPods, PodNames(pods))
+		})
+	}
+}
+
+func TestPodRestarts(t *testing.T) {
+	pod := func(uid string, restarts ...int32) corev1.Pod {
+		pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{UID: types.UID(uid)}}
+		for _, r := range restarts {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{RestartCount: r})
+		}
+		return pod
+	}
+
+	require.Equal(t, map[string]int32{"a": 3, "b": 0}, PodRestarts([]corev1.Pod{pod("a", 1, 2), pod("b", 0)}))
+	require.Empty(t, PodRestarts(nil))
+}
+
+func TestContainerImage(t *testing.T) {
+	pod := corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "consul-connect-inject-init", Image: "hashicorp/consul:1.9.0"}},
+			Containers: []corev1.Container{
+				{Name: "static-server", Image: "hashicorp/http-echo:latest"},
+				{Name: "envoy-sidecar", Image: "envoyproxy/envoy-alpine:v1.16.0"},
+			},
+		},
+	}
+
+	require.Equal(t, "envoyproxy/envoy-alpine:v1.16.0", ContainerImage(pod, "envoy-sidecar"))
+	require.Equal(t, "hashicorp/consul:1.9.0", ContainerImage(pod, "consul-connect-inject-init"))
+	require.Equal(t, "", ContainerImage(pod, "does-not-exist"))
+}
@@ -0,0 +1,43 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	terratestLogger "github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// PortForward port-forwards a local port to remotePort of the Kubernetes
+// resource of the given type and name, e.g. terratestk8s.ResourceTypeService,
+// and returns the local address, e.g. 127.0.0.1:12345.
+// The port-forward is closed when the test finishes.
+func PortForward(t *testing.T, options *terratestk8s.KubectlOptions, resourceType terratestk8s.KubeResourceType, name string, remotePort int) string {
+	t.Helper()
+
+	localPort := terratestk8s.GetAvailablePort(t)
+	tunnel := terratestk8s.NewTunnelWithLogger(
+		options,
+		resourceType,
+		name,
+		localPort,
+		remotePort,
+		terratestLogger.New(logger.TestLogger{}))
+
+	// Retry creating the port forward since it can fail occasionally.
+	retry.RunWith(&retry.Counter{Wait: 1 * time.Second, Count: 3}, t, func(r *retry.R) {
+		// NOTE: It's okay to pass in `t` to ForwardPortE despite being in a retry
+		// because we're using ForwardPortE (not ForwardPort) so the `t` won't
+		// get used to fail the test, just for logging.
+		require.NoError(r, tunnel.ForwardPortE(t))
+	})
+
+	t.Cleanup(func() {
+		tunnel.Close()
+	})
+
+	return tunnel.Endpoint()
+}
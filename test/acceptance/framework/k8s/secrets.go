@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CreateSecret creates a secret with the given name and data in the namespace
+// of options, e.g. for a gossip encryption key or an enterprise license that
+// the chart reads from a secret. The secret is deleted when the test finishes.
+func CreateSecret(t *testing.T, options *k8s.KubectlOptions, noCleanupOnFailure, noCleanup bool, name string, data map[string]string) {
+	t.Helper()
+
+	client := helpers.KubernetesClientFromOptions(t, options)
+	logger.Logf(t, "creating secret %s", name)
+	_, err := client.CoreV1().Secrets(options.Namespace).Create(helpers.TestContext(t), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: testlabels.ForTest(t)},
+		StringData: data,
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	helpers.Cleanup(t, noCleanupOnFailure, noCleanup, func() {
+		logger.Logf(t, "deleting secret %s", name)
+		err := client.CoreV1().Secrets(options.Namespace).Delete(helpers.TestContext(t), name, metav1.DeleteOptions{})
+		if !errors.IsNotFound(err) {
+			require.NoError(t, err)
+		}
+	})
+}
+
+// GetSecretE returns the secret with the given name.
+func GetSecretE(t *testing.T, options *k8s.KubectlOptions, name string) (*corev1.Secret, error) {
+	client := helpers.KubernetesClientFromOptions(t, options)
+	return client.CoreV1().Secrets(options.Namespace).Get(helpers.TestContext(t), name, metav1.GetOptions{})
+}
+
+// WaitForSecret waits for up to -timeout-pods-ready for the secret with
+// the given name to exist and to have all of keys, and returns it.
+// It's meant for secrets that the chart's jobs create, such as the ACL
+// tokens that server-acl-init stores in <release>-consul-<component>-acl-token.
+func WaitForSecret(t *testing.T, options *k8s.KubectlOptions, name string, keys ...string) *corev1.Secret {
+	t.Helper()
+
+	var secret *corev1.Secret
+	helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+		var err error
+		secret, err = GetSecretE(t, options, name)
+		require.NoError(r, err)
+		require.NoError(r, checkSecretKeys(secret, keys))
+	})
+	return secret
+}
+
+// checkSecretKeys returns an error listing the keys that secret has no data for.
+func checkSecretKeys(secret *corev1.Secret, keys []string) error {
+	var missing []string
+	for _, key := range keys {
+		if len(secret.Data[key]) == 0 && secret.StringData[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("secret %s has no data for keys %v", secret.Name, missing)
+	}
+	return nil
+}
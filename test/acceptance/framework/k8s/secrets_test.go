@@ -0,0 +1,43 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckSecretKeys(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-client-acl-token"},
+		Data:       map[string][]byte{"token": []byte("secret"), "empty": nil},
+	}
+
+	cases := map[string]struct {
+		keys   []string
+		expErr string
+	}{
+		"no keys": {
+			keys: nil,
+		},
+		"present key": {
+			keys: []string{"token"},
+		},
+		"missing keys": {
+			keys:   []string{"token", "replicationToken", "empty"},
+			expErr: "secret consul-client-acl-token has no data for keys [empty replicationToken]",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := checkSecretKeys(secret, c.keys)
+			if c.expErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expErr)
+			}
+		})
+	}
+}
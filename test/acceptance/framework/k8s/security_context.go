@@ -0,0 +1,101 @@
+package k8s
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SecurityContext is the security context that a container runs with.
+// Fields that neither the pod nor the container set are nil, in which
+// case the container runtime uses the defaults of the container's image,
+// e.g. the user of its USER instruction or root.
+type SecurityContext struct {
+	RunAsUser    *int64
+	RunAsGroup   *int64
+	RunAsNonRoot *bool
+	FSGroup      *int64
+	Privileged   *bool
+}
+
+// EffectiveSecurityContext returns the security context of the init container
+// or container named containerName in pod, combining the security context of
+// the pod with that of the container, which takes precedence like it does in
+// the kubelet. It returns an error if pod has no container with that name.
+func EffectiveSecurityContext(pod corev1.Pod, containerName string) (SecurityContext, error) {
+	var container *corev1.Container
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			if containers[i].Name == containerName {
+				container = &containers[i]
+			}
+		}
+	}
+	if container == nil {
+		return SecurityContext{}, fmt.Errorf("pod %s has no container %s", pod.Name, containerName)
+	}
+
+	var sc SecurityContext
+	if podContext := pod.Spec.SecurityContext; podContext != nil {
+		sc.RunAsUser = podContext.RunAsUser
+		sc.RunAsGroup = podContext.RunAsGroup
+		sc.RunAsNonRoot = podContext.RunAsNonRoot
+		sc.FSGroup = podContext.FSGroup
+	}
+	if containerContext := container.SecurityContext; containerContext != nil {
+		if containerContext.RunAsUser != nil {
+			sc.RunAsUser = containerContext.RunAsUser
+		}
+		if containerContext.RunAsGroup != nil {
+			sc.RunAsGroup = containerContext.RunAsGroup
+		}
+		if containerContext.RunAsNonRoot != nil {
+			sc.RunAsNonRoot = containerContext.RunAsNonRoot
+		}
+		sc.Privileged = containerContext.Privileged
+	}
+	return sc, nil
+}
+
+// ContainerProcessUIDE returns the effective user ID of the main process of the
+// container in the pod, which shows the user the container actually runs as even
+// if its security context doesn't set one. It reads /proc/1/status in the
+// container, so the container's image needs cat.
+func ContainerProcessUIDE(t *testing.T, options *k8s.KubectlOptions, podName, containerName string) (int64, error) {
+	output, err := RunKubectlAndGetOutputE(t, options, "exec", podName, "-c", containerName, "--", "cat", "/proc/1/status")
+	if err != nil {
+		return 0, err
+	}
+	return parseProcessUID(output)
+}
+
+// ContainerProcessUID is like ContainerProcessUIDE but fails the test if there's an error.
+func ContainerProcessUID(t *testing.T, options *k8s.KubectlOptions, podName, containerName string) int64 {
+	t.Helper()
+
+	uid, err := ContainerProcessUIDE(t, options, podName, containerName)
+	require.NoError(t, err)
+	return uid
+}
+
+// parseProcessUID returns the effective user ID from the contents of
+// /proc/<pid>/status, whose Uid line lists the real, effective,
+// saved and filesystem user IDs of the process.
+func parseProcessUID(status string) (int64, error) {
+	for _, line := range strings.Split(status, "\n") {
+		if !strings.HasPrefix(line, "Uid:") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "Uid:"))
+		if len(fields) < 2 {
+			return 0, fmt.Errorf("unexpected Uid line in process status: %q", line)
+		}
+		return strconv.ParseInt(fields[1], 10, 64)
+	}
+	return 0, fmt.Errorf("no Uid line in process status: %s", status)
+}
@@ -0,0 +1,90 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEffectiveSecurityContext(t *testing.T) {
+	int64Ptr := func(i int64) *int64 { return &i }
+	boolPtr := func(b bool) *bool { return &b }
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-server-0"},
+		Spec: corev1.PodSpec{
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsUser:    int64Ptr(100),
+				RunAsGroup:   int64Ptr(1000),
+				RunAsNonRoot: boolPtr(true),
+				FSGroup:      int64Ptr(1000),
+			},
+			InitContainers: []corev1.Container{{Name: "init"}},
+			Containers: []corev1.Container{
+				{Name: "consul"},
+				{Name: "sidecar", SecurityContext: &corev1.SecurityContext{
+					RunAsUser:    int64Ptr(0),
+					RunAsNonRoot: boolPtr(false),
+					Privileged:   boolPtr(false),
+				}},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		pod       corev1.Pod
+		container string
+		exp       SecurityContext
+		expErr    string
+	}{
+		"pod security context": {
+			pod:       pod,
+			container: "consul",
+			exp:       SecurityContext{RunAsUser: int64Ptr(100), RunAsGroup: int64Ptr(1000), RunAsNonRoot: boolPtr(true), FSGroup: int64Ptr(1000)},
+		},
+		"init container": {
+			pod:       pod,
+			container: "init",
+			exp:       SecurityContext{RunAsUser: int64Ptr(100), RunAsGroup: int64Ptr(1000), RunAsNonRoot: boolPtr(true), FSGroup: int64Ptr(1000)},
+		},
+		"container security context takes precedence": {
+			pod:       pod,
+			container: "sidecar",
+			exp:       SecurityContext{RunAsUser: int64Ptr(0), RunAsGroup: int64Ptr(1000), RunAsNonRoot: boolPtr(false), FSGroup: int64Ptr(1000), Privileged: boolPtr(false)},
+		},
+		"no security context": {
+			pod:       corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "consul"}}}},
+			container: "consul",
+			exp:       SecurityContext{},
+		},
+		"unknown container": {
+			pod:       pod,
+			container: "envoy-sidecar",
+			expErr:    "pod consul-server-0 has no container envoy-sidecar",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			sc, err := EffectiveSecurityContext(c.pod, c.container)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, sc)
+		})
+	}
+}
+
+func TestParseProcessUID(t *testing.T) {
+	status := "Name:\tconsul\nUmask:\t0022\nState:\tS (sleeping)\nPid:\t1\nUid:\t0\t100\t100\t100\nGid:\t1000\t1000\t1000\t1000\n"
+	uid, err := parseProcessUID(status)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), uid)
+
+	_, err = parseProcessUID("Name:\tconsul\n")
+	require.EqualError(t, err, "no Uid line in process status: Name:\tconsul\n")
+}
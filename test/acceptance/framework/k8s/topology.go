@@ -0,0 +1,142 @@
+package k8s
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// The functions in this file describe where pods run in the Kubernetes
+// cluster so that tests can make assertions on scheduling, e.g. that the
+// servers' anti-affinity spreads them across nodes or zones, and skip
+// themselves on clusters that are too small, like single-node kind clusters.
+
+// Well-known topology keys of nodes.
+const (
+	TopologyKeyHostname = corev1.LabelHostname
+	TopologyKeyZone     = corev1.LabelZoneFailureDomainStable
+)
+
+// SchedulableNodes returns the Linux nodes that pods without
+// tolerations can be scheduled on.
+func SchedulableNodes(t *testing.T, options *k8s.KubectlOptions) []corev1.Node {
+	t.Helper()
+
+	client := helpers.KubernetesClientFromOptions(t, options)
+	nodes, err := client.CoreV1().Nodes().List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "kubernetes.io/os=linux"})
+	require.NoError(t, err)
+	return schedulableNodes(nodes.Items)
+}
+
+// NodesByTopology returns the schedulable nodes, see SchedulableNodes,
+// keyed by the value of their topologyKey label, e.g. their zone for
+// TopologyKeyZone. Nodes without the label are keyed by "".
+func NodesByTopology(t *testing.T, options *k8s.KubectlOptions, topologyKey string) map[string][]corev1.Node {
+	t.Helper()
+
+	return groupByLabel(SchedulableNodes(t, options), topologyKey)
+}
+
+// PodTopology returns the names of pods keyed by the value of the topologyKey
+// label of the nodes they're scheduled on, e.g. the zone for TopologyKeyZone.
+// It fails the test if a pod isn't scheduled or its node doesn't have the label.
+func PodTopology(t *testing.T, options *k8s.KubectlOptions, pods []corev1.Pod, topologyKey string) map[string][]string {
+	t.Helper()
+
+	client := helpers.KubernetesClientFromOptions(t, options)
+	nodes, err := client.CoreV1().Nodes().List(helpers.TestContext(t), metav1.ListOptions{})
+	require.NoError(t, err)
+
+	topology, err := podTopology(pods, nodes.Items, topologyKey)
+	require.NoError(t, err)
+	return topology
+}
+
+// RequirePodsSpread fails the test unless each of pods is scheduled
+// on a node with a different value of the topologyKey label,
+// e.g. in a different zone for TopologyKeyZone.
+func RequirePodsSpread(t *testing.T, options *k8s.KubectlOptions, pods []corev1.Pod, topologyKey string) {
+	t.Helper()
+
+	for value, names := range PodTopology(t, options, pods, topologyKey) {
+		require.Len(t, names, 1, "pods %v are scheduled in the same %s %s", names, topologyKey, value)
+	}
+}
+
+// HasRequiredPodAntiAffinity returns true if pod has a required anti-affinity
+// term with topologyKey whose label selector matches the pod itself,
+// i.e. if it can't be scheduled in the same topology domain as its replicas.
+func HasRequiredPodAntiAffinity(pod corev1.Pod, topologyKey string) bool {
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.PodAntiAffinity == nil {
+		return false
+	}
+	for _, term := range pod.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		if term.TopologyKey != topologyKey || term.LabelSelector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+		if err != nil {
+			continue
+		}
+		if !selector.Empty() && selector.Matches(labels.Set(pod.Labels)) {
+			return true
+		}
+	}
+	return false
+}
+
+// schedulableNodes returns the nodes that aren't cordoned
+// and don't have taints that prevent scheduling.
+func schedulableNodes(nodes []corev1.Node) []corev1.Node {
+	var schedulable []corev1.Node
+	for _, node := range nodes {
+		if node.Spec.Unschedulable || hasNoScheduleTaint(node) {
+			continue
+		}
+		schedulable = append(schedulable, node)
+	}
+	return schedulable
+}
+
+func hasNoScheduleTaint(node corev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+			return true
+		}
+	}
+	return false
+}
+
+func groupByLabel(nodes []corev1.Node, label string) map[string][]corev1.Node {
+	groups := map[string][]corev1.Node{}
+	for _, node := range nodes {
+		groups[node.Labels[label]] = append(groups[node.Labels[label]], node)
+	}
+	return groups
+}
+
+func podTopology(pods []corev1.Pod, nodes []corev1.Node, topologyKey string) (map[string][]string, error) {
+	nodeLabels := map[string]map[string]string{}
+	for _, node := range nodes {
+		nodeLabels[node.Name] = node.Labels
+	}
+
+	topology := map[string][]string{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" {
+			return nil, fmt.Errorf("pod %s is not scheduled", pod.Name)
+		}
+		value, ok := nodeLabels[pod.Spec.NodeName][topologyKey]
+		if !ok {
+			return nil, fmt.Errorf("node %s of pod %s has no %s label", pod.Spec.NodeName, pod.Name, topologyKey)
+		}
+		topology[value] = append(topology[value], pod.Name)
+	}
+	return topology, nil
+}
@@ -0,0 +1,108 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSchedulableNodes(t *testing.T) {
+	nodes := []corev1.Node{
+		testNode("node-1", "zone-a"),
+		testNode("node-2", "zone-a"),
+		testNode("node-3", "zone-b"),
+		testNode("cordoned", "zone-b"),
+		testNode("tainted", "zone-c"),
+		testNode("no-zone", ""),
+	}
+	nodes[3].Spec.Unschedulable = true
+	nodes[4].Spec.Taints = []corev1.Taint{{Key: "node-role.kubernetes.io/master", Effect: corev1.TaintEffectNoSchedule}}
+
+	byZone := map[string][]string{}
+	for zone, nodes := range groupByLabel(schedulableNodes(nodes), TopologyKeyZone) {
+		for _, node := range nodes {
+			byZone[zone] = append(byZone[zone], node.Name)
+		}
+	}
+	require.Equal(t, map[string][]string{
+		"zone-a": {"node-1", "node-2"},
+		"zone-b": {"node-3"},
+		"":       {"no-zone"},
+	}, byZone)
+}
+
+func TestPodTopology(t *testing.T) {
+	nodes := []corev1.Node{testNode("node-1", "zone-a"), testNode("node-2", "zone-a"), testNode("node-3", "zone-b")}
+	pod := func(name, node string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: corev1.PodSpec{NodeName: node}}
+	}
+	pods := []corev1.Pod{pod("server-0", "node-1"), pod("server-1", "node-2"), pod("server-2", "node-3")}
+
+	topology, err := podTopology(pods, nodes, TopologyKeyHostname)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"node-1": {"server-0"}, "node-2": {"server-1"}, "node-3": {"server-2"}}, topology)
+
+	topology, err = podTopology(pods, nodes, TopologyKeyZone)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"zone-a": {"server-0", "server-1"}, "zone-b": {"server-2"}}, topology)
+
+	_, err = podTopology([]corev1.Pod{pod("pending", "")}, nodes, TopologyKeyZone)
+	require.EqualError(t, err, "pod pending is not scheduled")
+
+	_, err = podTopology(pods, nodes, "example.com/rack")
+	require.EqualError(t, err, "node node-1 of pod server-0 has no example.com/rack label")
+}
+
+func TestHasRequiredPodAntiAffinity(t *testing.T) {
+	labels := map[string]string{"app": "consul", "component": "server"}
+	antiAffinity := func(topologyKey string, matchLabels map[string]string) *corev1.Affinity {
+		return &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: matchLabels},
+				TopologyKey:   topologyKey,
+			}},
+		}}
+	}
+
+	cases := map[string]struct {
+		affinity *corev1.Affinity
+		exp      bool
+	}{
+		"no affinity": {
+			affinity: nil,
+			exp:      false,
+		},
+		"matching term": {
+			affinity: antiAffinity(TopologyKeyHostname, map[string]string{"component": "server"}),
+			exp:      true,
+		},
+		"other topology key": {
+			affinity: antiAffinity(TopologyKeyZone, map[string]string{"component": "server"}),
+			exp:      false,
+		},
+		"selector doesn't match the pod": {
+			affinity: antiAffinity(TopologyKeyHostname, map[string]string{"component": "client"}),
+			exp:      false,
+		},
+		"empty selector": {
+			affinity: antiAffinity(TopologyKeyHostname, nil),
+			exp:      false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: labels}, Spec: corev1.PodSpec{Affinity: c.affinity}}
+			require.Equal(t, c.exp, HasRequiredPodAntiAffinity(pod, TopologyKeyHostname))
+		})
+	}
+}
+
+func testNode(name, zone string) corev1.Node {
+	labels := map[string]string{TopologyKeyHostname: name}
+	if zone != "" {
+		labels[TopologyKeyZone] = zone
+	}
+	return corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
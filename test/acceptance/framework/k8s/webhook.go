@@ -0,0 +1,172 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// webhookCallFailure is the message in the API server's response
+// when it can't call a webhook, e.g. because of "connection refused"
+// or "no endpoints available for service".
+const webhookCallFailure = "failed calling webhook"
+
+// webhook holds the fields we need from either a mutating or validating webhook.
+type webhook struct {
+	name          string
+	service       *admissionv1.ServiceReference
+	failurePolicy *admissionv1.FailurePolicyType
+	rules         []admissionv1.RuleWithOperations
+}
+
+// WaitForWebhook waits until the webhooks of the mutating or validating
+// webhook configuration with the given name are ready to serve admission requests.
+// A webhook is ready when its service has ready endpoints and, if the webhook's
+// failure policy is Fail, the API server is able to call it for a server-side
+// dry-run create of a resource that it intercepts. The result of the admission
+// call itself is ignored since the webhook may reject the placeholder resource.
+// Webhooks with the Ignore failure policy are only checked for endpoints
+// because failing calls to them don't fail the request.
+func WaitForWebhook(t *testing.T, options *terratestk8s.KubectlOptions, webhookConfigName string) {
+	t.Helper()
+
+	ctx := helpers.TestContext(t)
+	client := helpers.KubernetesClientFromOptions(t, options)
+	manifestDir := t.TempDir()
+
+	logger.Logf(t, "waiting for webhooks of %s to be ready", webhookConfigName)
+	helpers.RetryEventually(t, timeouts.WebhookReady(), func(r *retry.R) {
+		webhooks, err := webhooksFromConfig(ctx, client, webhookConfigName)
+		require.NoError(r, err)
+
+		for _, w := range webhooks {
+			if w.service != nil {
+				ready, err := serviceHasReadyEndpoints(ctx, client, w.service.Namespace, w.service.Name)
+				require.NoError(r, err)
+				require.True(r, ready, "service %s of webhook %s has no ready endpoints", w.service.Name, w.name)
+			}
+
+			if w.failurePolicy == nil || *w.failurePolicy != admissionv1.Fail {
+				continue
+			}
+			gvr, ok := createRuleResource(w.rules)
+			if !ok {
+				continue
+			}
+			manifest, err := dryRunManifest(client, gvr)
+			require.NoError(r, err)
+			file := filepath.Join(manifestDir, w.name+".yaml")
+			require.NoError(r, ioutil.WriteFile(file, []byte(manifest), 0644))
+
+			// The error is expected if the webhook rejects the resource,
+			// so only check that the API server was able to call the webhook.
+			out, _ := RunKubectlAndGetOutputE(t, options, "create", "--dry-run=server", "-f", file)
+			require.NotContains(r, out, webhookCallFailure, "webhook %s is not serving yet", w.name)
+		}
+	})
+}
+
+// webhooksFromConfig returns the webhooks of the mutating
+// or validating webhook configuration with the given name.
+func webhooksFromConfig(ctx context.Context, client kubernetes.Interface, name string) ([]webhook, error) {
+	mutating, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		var webhooks []webhook
+		for _, w := range mutating.Webhooks {
+			webhooks = append(webhooks, webhook{name: w.Name, service: w.ClientConfig.Service, failurePolicy: w.FailurePolicy, rules: w.Rules})
+		}
+		return webhooks, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	validating, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var webhooks []webhook
+	for _, w := range validating.Webhooks {
+		webhooks = append(webhooks, webhook{name: w.Name, service: w.ClientConfig.Service, failurePolicy: w.FailurePolicy, rules: w.Rules})
+	}
+	return webhooks, nil
+}
+
+// serviceHasReadyEndpoints returns true if the service has at least one ready endpoint.
+func serviceHasReadyEndpoints(ctx context.Context, client kubernetes.Interface, namespace, name string) (bool, error) {
+	endpoints, err := client.CoreV1().Endpoints(namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// createRuleResource returns the first resource that the rules
+// intercept on create, skipping wildcards and subresources.
+func createRuleResource(rules []admissionv1.RuleWithOperations) (schema.GroupVersionResource, bool) {
+	for _, rule := range rules {
+		if !interceptsCreate(rule.Operations) || len(rule.APIGroups) == 0 || len(rule.APIVersions) == 0 {
+			continue
+		}
+		group, version := rule.APIGroups[0], rule.APIVersions[0]
+		if group == "*" || version == "*" {
+			continue
+		}
+		for _, resource := range rule.Resources {
+			if resource == "*" || strings.Contains(resource, "/") {
+				continue
+			}
+			return schema.GroupVersionResource{Group: group, Version: version, Resource: resource}, true
+		}
+	}
+	return schema.GroupVersionResource{}, false
+}
+
+func interceptsCreate(operations []admissionv1.OperationType) bool {
+	for _, op := range operations {
+		if op == admissionv1.Create || op == admissionv1.OperationAll {
+			return true
+		}
+	}
+	return false
+}
+
+// dryRunManifest returns a manifest for a placeholder resource
+// of the given group, version and resource. It uses the discovery API
+// to find the kind of the resource.
+func dryRunManifest(client kubernetes.Interface, gvr schema.GroupVersionResource) (string, error) {
+	groupVersion := gvr.GroupVersion().String()
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return "", err
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == gvr.Resource {
+			return fmt.Sprintf("apiVersion: %s\nkind: %s\nmetadata:\n  name: webhook-readiness-check\n", groupVersion, resource.Kind), nil
+		}
+	}
+	return "", fmt.Errorf("resource %s not found in %s", gvr.Resource, groupVersion)
+}
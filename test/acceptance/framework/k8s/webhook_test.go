@@ -0,0 +1,149 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWebhooksFromConfig(t *testing.T) {
+	fail := admissionv1.Fail
+	service := &admissionv1.ServiceReference{Namespace: "default", Name: "webhook-svc"}
+	client := fake.NewSimpleClientset(
+		&admissionv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "mutating"},
+			Webhooks: []admissionv1.MutatingWebhook{
+				{Name: "mutate.example.com", ClientConfig: admissionv1.WebhookClientConfig{Service: service}, FailurePolicy: &fail},
+			},
+		},
+		&admissionv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "validating"},
+			Webhooks: []admissionv1.ValidatingWebhook{
+				{Name: "validate.example.com", ClientConfig: admissionv1.WebhookClientConfig{Service: service}},
+			},
+		},
+	)
+
+	webhooks, err := webhooksFromConfig(context.Background(), client, "mutating")
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	require.Equal(t, "mutate.example.com", webhooks[0].name)
+	require.Equal(t, service, webhooks[0].service)
+	require.Equal(t, &fail, webhooks[0].failurePolicy)
+
+	webhooks, err = webhooksFromConfig(context.Background(), client, "validating")
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	require.Equal(t, "validate.example.com", webhooks[0].name)
+
+	_, err = webhooksFromConfig(context.Background(), client, "does-not-exist")
+	require.Error(t, err)
+}
+
+func TestServiceHasReadyEndpoints(t *testing.T) {
+	cases := map[string]struct {
+		endpoints *corev1.Endpoints
+		expected  bool
+	}{
+		"no endpoints object": {
+			nil,
+			false,
+		},
+		"only not ready addresses": {
+			&corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: "webhook-svc", Namespace: "default"},
+				Subsets:    []corev1.EndpointSubset{{NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+			},
+			false,
+		},
+		"ready addresses": {
+			&corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: "webhook-svc", Namespace: "default"},
+				Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+			},
+			true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			if c.endpoints != nil {
+				_, err := client.CoreV1().Endpoints("default").Create(context.Background(), c.endpoints, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+			ready, err := serviceHasReadyEndpoints(context.Background(), client, "default", "webhook-svc")
+			require.NoError(t, err)
+			require.Equal(t, c.expected, ready)
+		})
+	}
+}
+
+func TestCreateRuleResource(t *testing.T) {
+	cases := map[string]struct {
+		rules       []admissionv1.RuleWithOperations
+		expected    schema.GroupVersionResource
+		expectFound bool
+	}{
+		"no rules": {
+			nil,
+			schema.GroupVersionResource{},
+			false,
+		},
+		"update only": {
+			[]admissionv1.RuleWithOperations{
+				{Operations: []admissionv1.OperationType{admissionv1.Update}, Rule: admissionv1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"pods"}}},
+			},
+			schema.GroupVersionResource{},
+			false,
+		},
+		"skips wildcards and subresources": {
+			[]admissionv1.RuleWithOperations{
+				{Operations: []admissionv1.OperationType{admissionv1.Create}, Rule: admissionv1.Rule{APIGroups: []string{"*"}, APIVersions: []string{"v1"}, Resources: []string{"pods"}}},
+				{Operations: []admissionv1.OperationType{admissionv1.OperationAll}, Rule: admissionv1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"*", "pods/status", "pods"}}},
+			},
+			schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+			true,
+		},
+		"custom resource": {
+			[]admissionv1.RuleWithOperations{
+				{Operations: []admissionv1.OperationType{admissionv1.Create, admissionv1.Update}, Rule: admissionv1.Rule{APIGroups: []string{"consul.hashicorp.com"}, APIVersions: []string{"v1alpha1"}, Resources: []string{"servicedefaults"}}},
+			},
+			schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "servicedefaults"},
+			true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			gvr, found := createRuleResource(c.rules)
+			require.Equal(t, c.expectFound, found)
+			require.Equal(t, c.expected, gvr)
+		})
+	}
+}
+
+func TestDryRunManifest(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "consul.hashicorp.com/v1alpha1",
+			APIResources: []metav1.APIResource{
+				{Name: "proxydefaults", Kind: "ProxyDefaults"},
+				{Name: "servicedefaults", Kind: "ServiceDefaults"},
+			},
+		},
+	}
+
+	manifest, err := dryRunManifest(client, schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "servicedefaults"})
+	require.NoError(t, err)
+	require.Equal(t, "apiVersion: consul.hashicorp.com/v1alpha1\nkind: ServiceDefaults\nmetadata:\n  name: webhook-readiness-check\n", manifest)
+
+	_, err = dryRunManifest(client, schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "servicerouters"})
+	require.EqualError(t, err, "resource servicerouters not found in consul.hashicorp.com/v1alpha1")
+}
@@ -0,0 +1,114 @@
+// Package metrics reports how long the phases of each test, such as
+// installing Consul or waiting for traffic to flow, take to a time-series
+// backend, so that slowdowns can be tracked across test runs rather than
+// only spotted in the logs of a single run.
+//
+// Sinks are configured once from flags when the test suite runs. If no
+// sink is configured, recording a phase does nothing.
+package metrics
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+)
+
+// Prefix is the prefix of the names of all metrics.
+const Prefix = "consul_helm_acceptance"
+
+// Phase is a phase of a test whose duration is recorded.
+type Phase string
+
+const (
+	// PhaseInstall is the time to install the Helm chart
+	// and for the pods of the release to start running.
+	PhaseInstall Phase = "install"
+	// PhaseWebhookReady is the time for the webhooks
+	// of the release to start serving requests.
+	PhaseWebhookReady Phase = "webhook-ready"
+	// PhaseFirstSync is the time for the controller to sync
+	// the custom resources that a test creates to Consul.
+	PhaseFirstSync Phase = "first-sync"
+	// PhaseTrafficCheck is the time for a connection between
+	// services to succeed or fail as expected.
+	PhaseTrafficCheck Phase = "traffic-check"
+)
+
+// Measurement is the duration of a phase of a test.
+type Measurement struct {
+	// Suite is the name of the test suite, e.g. "controller".
+	Suite string
+	// Test is the name of the test or subtest.
+	Test     string
+	Phase    Phase
+	Duration time.Duration
+}
+
+// Sink sends measurements to a time-series backend.
+// Its methods can be called by parallel tests at the same time.
+type Sink interface {
+	// Record sends or buffers the measurement.
+	Record(m Measurement) error
+	// Close sends any buffered measurements and releases the resources of the sink.
+	Close() error
+}
+
+var (
+	mu        sync.RWMutex
+	suiteName string
+	sinks     []Sink
+)
+
+// Configure sets the sinks that measurements of the test suite with the
+// given name are sent to, replacing any sinks that were set before.
+func Configure(suite string, s ...Sink) {
+	mu.Lock()
+	defer mu.Unlock()
+	suiteName = suite
+	sinks = s
+}
+
+// Close closes the configured sinks and returns the first error.
+// Measurements recorded after Close are dropped.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var firstErr error
+	for _, s := range sinks {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	sinks = nil
+	return firstErr
+}
+
+// Record sends the duration of the phase of the test t to the configured sinks.
+// Errors are logged rather than failing the test, because the metrics are
+// only informational.
+func Record(t *testing.T, phase Phase, d time.Duration) {
+	t.Helper()
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	m := Measurement{Suite: suiteName, Test: t.Name(), Phase: phase, Duration: d}
+	for _, s := range sinks {
+		if err := s.Record(m); err != nil {
+			logger.Logf(t, "failed to record the duration of the %s phase: %s", phase, err)
+		}
+	}
+}
+
+// Time starts timing the phase of the test t and returns a function that
+// records its duration when called, e.g. defer metrics.Time(t, metrics.PhaseInstall)().
+func Time(t *testing.T, phase Phase) func() {
+	start := time.Now()
+	return func() {
+		t.Helper()
+		Record(t, phase, time.Since(start))
+	}
+}
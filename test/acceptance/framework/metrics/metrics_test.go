@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord_Statsd(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sink, err := NewStatsdSink(listener.LocalAddr().String())
+	require.NoError(t, err)
+	Configure("suite", sink)
+	defer Close()
+
+	t.Run("table|case,1", func(t *testing.T) {
+		Record(t, PhaseInstall, 1500*time.Millisecond)
+	})
+
+	buf := make([]byte, 1024)
+	require.NoError(t, listener.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := listener.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "consul_helm_acceptance.phase_duration:1500|ms|#suite:suite,test:TestRecord_Statsd/table_case_1,phase:install", string(buf[:n]))
+}
+
+func TestRecord_Pushgateway(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	Configure("suite", NewPushgatewaySink(server.URL+"/", "run-1", "suite"))
+
+	t.Run("second", func(t *testing.T) {
+		Record(t, PhaseTrafficCheck, 2*time.Second)
+	})
+	t.Run("first", func(t *testing.T) {
+		// Phases that run more than once in a test are added up.
+		Record(t, PhaseInstall, 1*time.Second)
+		Record(t, PhaseInstall, 500*time.Millisecond)
+	})
+
+	require.Empty(t, method, "metrics should only be pushed when the sink is closed")
+	require.NoError(t, Close())
+
+	require.Equal(t, http.MethodPut, method)
+	require.Equal(t, "/metrics/job/consul_helm_acceptance/run_id/run-1/suite/suite", path)
+	require.Equal(t, `# HELP consul_helm_acceptance_phase_duration_seconds Total duration of the phase of the test.
+# TYPE consul_helm_acceptance_phase_duration_seconds gauge
+consul_helm_acceptance_phase_duration_seconds{test="TestRecord_Pushgateway/first",phase="install"} 1.5
+consul_helm_acceptance_phase_duration_seconds{test="TestRecord_Pushgateway/second",phase="traffic-check"} 2
+# HELP consul_helm_acceptance_phase_count Number of times the phase of the test ran.
+# TYPE consul_helm_acceptance_phase_count gauge
+consul_helm_acceptance_phase_count{test="TestRecord_Pushgateway/first",phase="install"} 2
+consul_helm_acceptance_phase_count{test="TestRecord_Pushgateway/second",phase="traffic-check"} 1
+`, body)
+}
+
+func TestPushgatewaySink_CloseError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad metrics", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sink := NewPushgatewaySink(server.URL, "run-1", "suite")
+	require.NoError(t, sink.Record(Measurement{Test: "TestFoo", Phase: PhaseInstall, Duration: time.Second}))
+	err := sink.Close()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unexpected status 400 Bad Request: bad metrics")
+}
+
+func TestRecord_NoSinks(t *testing.T) {
+	Configure("suite")
+	// Recording without sinks should do nothing.
+	Time(t, PhaseFirstSync)()
+	require.NoError(t, Close())
+}
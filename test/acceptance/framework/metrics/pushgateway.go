@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pushgatewayTimeout is the time to wait for the Pushgateway to accept the metrics.
+const pushgatewayTimeout = 30 * time.Second
+
+// pushgatewaySink buffers measurements and pushes them to
+// a Prometheus Pushgateway when the test suite finishes,
+// because Prometheus can't scrape the short-lived test binary.
+type pushgatewaySink struct {
+	groupURL string
+	client   *http.Client
+
+	mu     sync.Mutex
+	phases map[phaseKey]*phaseTotal
+}
+
+// phaseKey identifies the phase of a test.
+type phaseKey struct {
+	test  string
+	phase Phase
+}
+
+// phaseTotal is the total duration of a phase of a test,
+// which can run more than once, e.g. when a test installs
+// Consul into both clusters of a multi-cluster test.
+type phaseTotal struct {
+	duration time.Duration
+	count    int
+}
+
+// NewPushgatewaySink returns a sink that pushes the total duration of each phase
+// of each test to the Pushgateway at pushgatewayURL, e.g. http://localhost:9091,
+// when it's closed. The metrics are pushed to the group of the test run and suite,
+// so that suites of the same run and runs of the same suite don't replace each other's metrics.
+func NewPushgatewaySink(pushgatewayURL, runID, suite string) Sink {
+	groupURL := fmt.Sprintf("%s/metrics/job/%s/run_id/%s/suite/%s",
+		strings.TrimSuffix(pushgatewayURL, "/"), Prefix, url.PathEscape(runID), url.PathEscape(suite))
+	return &pushgatewaySink{
+		groupURL: groupURL,
+		client:   &http.Client{Timeout: pushgatewayTimeout},
+		phases:   map[phaseKey]*phaseTotal{},
+	}
+}
+
+func (p *pushgatewaySink) Record(m Measurement) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := phaseKey{test: m.Test, phase: m.Phase}
+	total, ok := p.phases[key]
+	if !ok {
+		total = &phaseTotal{}
+		p.phases[key] = total
+	}
+	total.duration += m.Duration
+	total.count++
+	return nil
+}
+
+func (p *pushgatewaySink) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.phases) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPut, p.groupURL, bytes.NewBufferString(p.exposition()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushing metrics to %s: %s", p.groupURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("pushing metrics to %s: unexpected status %s: %s", p.groupURL, resp.Status, body)
+	}
+	return nil
+}
+
+// exposition returns the buffered measurements in the Prometheus text format,
+// sorted so that the output doesn't depend on the order tests ran in.
+func (p *pushgatewaySink) exposition() string {
+	keys := make([]phaseKey, 0, len(p.phases))
+	for key := range p.phases {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].test != keys[j].test {
+			return keys[i].test < keys[j].test
+		}
+		return keys[i].phase < keys[j].phase
+	})
+
+	var durations, counts strings.Builder
+	for _, key := range keys {
+		labels := fmt.Sprintf(`{test="%s",phase="%s"}`, prometheusLabelValue(key.test), prometheusLabelValue(string(key.phase)))
+		fmt.Fprintf(&durations, "%s_phase_duration_seconds%s %g\n", Prefix, labels, p.phases[key].duration.Seconds())
+		fmt.Fprintf(&counts, "%s_phase_count%s %d\n", Prefix, labels, p.phases[key].count)
+	}
+
+	return fmt.Sprintf("# HELP %[1]s_phase_duration_seconds Total duration of the phase of the test.\n"+
+		"# TYPE %[1]s_phase_duration_seconds gauge\n%[2]s"+
+		"# HELP %[1]s_phase_count Number of times the phase of the test ran.\n"+
+		"# TYPE %[1]s_phase_count gauge\n%[3]s", Prefix, durations.String(), counts.String())
+}
+
+// prometheusLabelReplacer escapes label values in the Prometheus text format.
+var prometheusLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func prometheusLabelValue(value string) string {
+	return prometheusLabelReplacer.Replace(value)
+}
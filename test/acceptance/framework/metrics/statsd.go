@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// statsdSink sends each measurement to a statsd server over UDP as soon as it's recorded.
+type statsdSink struct {
+	conn net.Conn
+}
+
+// NewStatsdSink returns a sink that sends measurements to the statsd server at
+// addr, e.g. localhost:8125, as timers named consul_helm_acceptance.phase_duration
+// in milliseconds. The suite, test and phase are sent as DogStatsD tags,
+// which the Datadog agent, Telegraf and the Prometheus statsd_exporter understand.
+func NewStatsdSink(addr string) (Sink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to statsd at %s: %s", addr, err)
+	}
+	return &statsdSink{conn: conn}, nil
+}
+
+func (s *statsdSink) Record(m Measurement) error {
+	_, err := s.conn.Write([]byte(statsdLine(m)))
+	return err
+}
+
+func (s *statsdSink) Close() error {
+	return s.conn.Close()
+}
+
+// statsdLine returns the measurement in the DogStatsD format, e.g.
+// consul_helm_acceptance.phase_duration:1500|ms|#suite:controller,test:TestController,phase:install.
+func statsdLine(m Measurement) string {
+	return fmt.Sprintf("%s.phase_duration:%d|ms|#suite:%s,test:%s,phase:%s",
+		Prefix, m.Duration.Milliseconds(), statsdTagValue(m.Suite), statsdTagValue(m.Test), statsdTagValue(string(m.Phase)))
+}
+
+// statsdTagReplacer replaces the characters that separate
+// the fields and tags of a DogStatsD line.
+var statsdTagReplacer = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_")
+
+func statsdTagValue(value string) string {
+	return statsdTagReplacer.Replace(value)
+}
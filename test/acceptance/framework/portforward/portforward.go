@@ -0,0 +1,410 @@
+// Package portforward provides port-forwards to Kubernetes pods that
+// survive the pods being restarted or replaced.
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// Tunnel is a port-forward from a stable local address to a port of
+// a pod matching a label selector. Unlike a plain port-forward, which
+// breaks when its pod is deleted, a Tunnel detects when its pod is gone
+// or the connection to it is lost and port-forwards to a new ready pod
+// matching the selector the next time a connection is made. This makes it
+// suitable for API clients in tests that restart or kill pods.
+type Tunnel struct {
+	t          *testing.T
+	namespace  string
+	selector   string
+	remotePort int
+
+	client     kubernetes.Interface
+	restConfig *rest.Config
+
+	listener net.Listener
+
+	// reconnectTimeout is how long a connection waits for a ready pod
+	// when the port-forward is broken, e.g. while the only server restarts.
+	reconnectTimeout time.Duration
+
+	// connect port-forwards to a pod. It's a field so that it
+	// can be replaced in tests that don't have a Kubernetes cluster.
+	connect func() (*forwarder, error)
+
+	// ctx is cancelled when the tunnel is closed or the test finishes
+	// so that requests to Kubernetes and port-forwards in progress stop.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// connectMu makes connections wait for a port-forward that's being
+	// established instead of each establishing one. It's separate from mu
+	// so that the tunnel isn't locked while talking to Kubernetes.
+	connectMu sync.Mutex
+
+	mu        sync.Mutex
+	current   *forwarder
+	conns     map[net.Conn]struct{}
+	closed    bool
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// forwarder is a port-forward to a single pod.
+type forwarder struct {
+	podName string
+	podUID  types.UID
+	// address is the local address that forwards to the pod.
+	address string
+	stopCh  chan struct{}
+	// doneCh is closed when the port-forward stops,
+	// for example, because the connection to the pod was lost.
+	doneCh chan struct{}
+}
+
+// NewTunnel port-forwards a local address to remotePort of a ready pod
+// matching labelSelector in the namespace of options, e.g.
+// "release=foo,component=server", and keeps forwarding to a ready pod
+// matching it when the pod is replaced. The tunnel is closed when the test finishes.
+func NewTunnel(t *testing.T, options *terratestk8s.KubectlOptions, labelSelector string, remotePort int) *Tunnel {
+	t.Helper()
+
+	configPath, err := options.GetConfigPath(t)
+	require.NoError(t, err)
+	restConfig, err := terratestk8s.LoadApiClientConfigE(configPath, options.ContextName)
+	require.NoError(t, err)
+	client, err := kubernetes.NewForConfig(restConfig)
+	require.NoError(t, err)
+
+	tunnel := newTunnel(t, options.Namespace, labelSelector, remotePort)
+	tunnel.client = client
+	tunnel.restConfig = restConfig
+	tunnel.connect = tunnel.forwardToReadyPod
+	tunnel.start(t)
+	return tunnel
+}
+
+func newTunnel(t *testing.T, namespace, labelSelector string, remotePort int) *Tunnel {
+	ctx, cancel := context.WithCancel(helpers.TestContext(t))
+	return &Tunnel{
+		t:          t,
+		namespace:  namespace,
+		selector:   labelSelector,
+		remotePort: remotePort,
+		conns:      make(map[net.Conn]struct{}),
+		ctx:        ctx,
+		cancel:     cancel,
+
+		reconnectTimeout: timeouts.PodsReady(),
+	}
+}
+
+// start listens on the local address, waits for the first
+// port-forward to be established and registers the cleanup.
+func (tun *Tunnel) start(t *testing.T) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tun.listener = listener
+	t.Cleanup(tun.Close)
+
+	// Wait for a pod to be ready and establish the first port-forward
+	// so that the tunnel is usable as soon as it's returned.
+	retry.RunWith(&retry.Timer{Timeout: timeouts.PodsReady(), Wait: 1 * time.Second}, t, func(r *retry.R) {
+		_, err := tun.forwarder(false)
+		require.NoError(r, err)
+	})
+
+	tun.wg.Add(1)
+	go tun.acceptLoop()
+}
+
+// Endpoint returns the local address of the tunnel, e.g. 127.0.0.1:12345.
+// It doesn't change when the tunnel reconnects to a different pod.
+func (tun *Tunnel) Endpoint() string {
+	return tun.listener.Addr().String()
+}
+
+// Close stops the tunnel and closes all connections through it.
+func (tun *Tunnel) Close() {
+	tun.closeOnce.Do(func() {
+		tun.cancel()
+
+		tun.mu.Lock()
+		tun.closed = true
+		if tun.current != nil {
+			close(tun.current.stopCh)
+			tun.current = nil
+		}
+		for conn := range tun.conns {
+			conn.Close()
+		}
+		tun.mu.Unlock()
+
+		tun.listener.Close()
+		tun.wg.Wait()
+	})
+}
+
+func (tun *Tunnel) acceptLoop() {
+	defer tun.wg.Done()
+
+	for {
+		conn, err := tun.listener.Accept()
+		if err != nil {
+			// The listener is only closed by Close.
+			return
+		}
+		if !tun.track(conn) {
+			conn.Close()
+			return
+		}
+		tun.wg.Add(1)
+		go func() {
+			defer tun.wg.Done()
+			defer tun.untrack(conn)
+			tun.handle(conn)
+		}()
+	}
+}
+
+// handle proxies conn to the pod, reconnecting to
+// a new pod if the current port-forward is broken.
+func (tun *Tunnel) handle(conn net.Conn) {
+	defer conn.Close()
+
+	upstream, err := tun.dial()
+	if err != nil {
+		logger.Logf(tun.t, "port-forward to pods matching %q failed: %s", tun.selector, err)
+		return
+	}
+	if !tun.track(upstream) {
+		upstream.Close()
+		return
+	}
+	defer tun.untrack(upstream)
+	defer upstream.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	// When either side closes, close both connections
+	// so that the other copy returns too.
+	<-done
+}
+
+// dial connects to the pod through the current port-forward.
+// If dialing fails, the port-forward is broken even if it hasn't noticed
+// yet, so it reconnects and tries again until reconnectTimeout elapses.
+// This keeps the connection open while no pod is ready, e.g. while
+// a single server is restarted, so that clients only see a slow
+// request instead of an error.
+func (tun *Tunnel) dial() (net.Conn, error) {
+	deadline := time.Now().Add(tun.reconnectTimeout)
+	for attempt := 0; ; attempt++ {
+		fwd, err := tun.forwarder(attempt > 0)
+		if err == nil {
+			var upstream net.Conn
+			upstream, err = net.Dial("tcp", fwd.address)
+			if err == nil {
+				return upstream, nil
+			}
+		}
+		if tun.isClosed() || time.Now().After(deadline) {
+			return nil, err
+		}
+		// Reconnect right away the first time so that a port-forward
+		// to a deleted pod is replaced without waiting.
+		if attempt == 0 {
+			continue
+		}
+		if attempt == 1 {
+			logger.Logf(tun.t, "waiting up to %s for a ready pod matching %q: %s", tun.reconnectTimeout, tun.selector, err)
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+func (tun *Tunnel) isClosed() bool {
+	tun.mu.Lock()
+	defer tun.mu.Unlock()
+
+	return tun.closed
+}
+
+// forwarder returns the current port-forward if it's still working,
+// or port-forwards to a new pod. If reconnect is true,
+// it always port-forwards to a new pod.
+func (tun *Tunnel) forwarder(reconnect bool) (*forwarder, error) {
+	tun.connectMu.Lock()
+	defer tun.connectMu.Unlock()
+
+	tun.mu.Lock()
+	closed, current := tun.closed, tun.current
+	tun.mu.Unlock()
+
+	if closed {
+		return nil, fmt.Errorf("tunnel is closed")
+	}
+	if current != nil && !reconnect && tun.healthy(current) {
+		return current, nil
+	}
+	if current != nil {
+		tun.mu.Lock()
+		// Close may have stopped it already.
+		if tun.current == current {
+			close(current.stopCh)
+			tun.current = nil
+		}
+		tun.mu.Unlock()
+	}
+
+	fwd, err := tun.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	tun.mu.Lock()
+	defer tun.mu.Unlock()
+	if tun.closed {
+		close(fwd.stopCh)
+		return nil, fmt.Errorf("tunnel is closed")
+	}
+	logger.Logf(tun.t, "port-forwarding %s to port %d of pod %s", tun.Endpoint(), tun.remotePort, fwd.podName)
+	tun.current = fwd
+	return fwd, nil
+}
+
+// healthy returns false if the port-forward has stopped or its pod
+// has been deleted, replaced or is terminating.
+func (tun *Tunnel) healthy(fwd *forwarder) bool {
+	select {
+	case <-fwd.doneCh:
+		return false
+	default:
+	}
+	if tun.client == nil {
+		return true
+	}
+	pod, err := tun.client.CoreV1().Pods(tun.namespace).Get(tun.ctx, fwd.podName, metav1.GetOptions{})
+	if err != nil {
+		return false
+	}
+	return pod.UID == fwd.podUID && pod.DeletionTimestamp == nil && helpers.IsReady(*pod)
+}
+
+func (tun *Tunnel) track(conn net.Conn) bool {
+	tun.mu.Lock()
+	defer tun.mu.Unlock()
+
+	if tun.closed {
+		return false
+	}
+	tun.conns[conn] = struct{}{}
+	return true
+}
+
+func (tun *Tunnel) untrack(conn net.Conn) {
+	tun.mu.Lock()
+	defer tun.mu.Unlock()
+
+	delete(tun.conns, conn)
+}
+
+// forwardToReadyPod port-forwards to remotePort of a ready pod matching the selector.
+func (tun *Tunnel) forwardToReadyPod() (*forwarder, error) {
+	pods, err := tun.client.CoreV1().Pods(tun.namespace).List(tun.ctx, metav1.ListOptions{LabelSelector: tun.selector})
+	if err != nil {
+		return nil, err
+	}
+	pod, err := readyPod(pods.Items)
+	if err != nil {
+		return nil, fmt.Errorf("pods matching %q: %s", tun.selector, err)
+	}
+
+	transport, upgrader, err := spdy.RoundTripperFor(tun.restConfig)
+	if err != nil {
+		return nil, err
+	}
+	url := tun.client.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	pf, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", tun.remotePort)}, stopCh, readyCh, ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		return nil, err
+	}
+
+	fwd := &forwarder{
+		podName: pod.Name,
+		podUID:  pod.UID,
+		stopCh:  stopCh,
+		doneCh:  make(chan struct{}),
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pf.ForwardPorts()
+		close(fwd.doneCh)
+	}()
+
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		return nil, fmt.Errorf("port-forwarding to pod %s: %s", pod.Name, err)
+	case <-tun.ctx.Done():
+		close(stopCh)
+		return nil, fmt.Errorf("port-forwarding to pod %s: %s", pod.Name, tun.ctx.Err())
+	}
+	ports, err := pf.GetPorts()
+	if err != nil {
+		close(stopCh)
+		return nil, err
+	}
+	fwd.address = fmt.Sprintf("127.0.0.1:%d", ports[0].Local)
+	return fwd, nil
+}
+
+// readyPod returns the ready pod that isn't terminating with the lowest
+// name, so that, for example, server-0 is preferred when it's ready.
+func readyPod(pods []corev1.Pod) (*corev1.Pod, error) {
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	for i := range pods {
+		if pods[i].DeletionTimestamp == nil && helpers.IsReady(pods[i]) {
+			return &pods[i], nil
+		}
+	}
+	return nil, fmt.Errorf("none of %d pods are ready", len(pods))
+}
@@ -0,0 +1,315 @@
+package portforward
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Test that the tunnel keeps the same endpoint and reconnects
+// to a new "pod" when the port-forward to the current one stops.
+func TestTunnel_Reconnects(t *testing.T) {
+	servers := []net.Listener{echoServer(t, "server-0"), echoServer(t, "server-1")}
+
+	connects := 0
+	var current *forwarder
+	tunnel := newTunnel(t, "default", "component=server", 8500)
+	tunnel.connect = func() (*forwarder, error) {
+		server := servers[connects%len(servers)]
+		connects++
+		current = &forwarder{
+			podName: fmt.Sprintf("pod-%d", connects),
+			address: server.Addr().String(),
+			stopCh:  make(chan struct{}),
+			doneCh:  make(chan struct{}),
+		}
+		return current, nil
+	}
+	tunnel.start(t)
+	endpoint := tunnel.Endpoint()
+
+	require.Equal(t, "server-0", request(t, endpoint))
+	require.Equal(t, "server-0", request(t, endpoint))
+	require.Equal(t, 1, connects)
+
+	// Simulate the connection to the pod being lost.
+	close(current.doneCh)
+	require.Equal(t, "server-1", request(t, endpoint))
+	require.Equal(t, 2, connects)
+	require.Equal(t, endpoint, tunnel.Endpoint())
+}
+
+// Test that the tunnel reconnects if it can't dial the current port-forward.
+func TestTunnel_ReconnectsOnDialError(t *testing.T) {
+	server := echoServer(t, "server-1")
+
+	// Reserve an address that nothing listens on.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddress := closed.Addr().String()
+	require.NoError(t, closed.Close())
+
+	connects := 0
+	tunnel := newTunnel(t, "default", "component=server", 8500)
+	tunnel.connect = func() (*forwarder, error) {
+		connects++
+		address := server.Addr().String()
+		if connects == 1 {
+			address = closedAddress
+		}
+		return &forwarder{
+			podName: fmt.Sprintf("pod-%d", connects),
+			address: address,
+			stopCh:  make(chan struct{}),
+			doneCh:  make(chan struct{}),
+		}, nil
+	}
+	tunnel.start(t)
+
+	require.Equal(t, "server-1", request(t, tunnel.Endpoint()))
+	require.Equal(t, 2, connects)
+}
+
+// Test that a connection made while no "pod" is ready waits for one
+// to become ready instead of failing.
+func TestTunnel_WaitsForReadyPod(t *testing.T) {
+	server := echoServer(t, "server-0")
+
+	connects := 0
+	var current *forwarder
+	tunnel := newTunnel(t, "default", "component=server", 8500)
+	tunnel.connect = func() (*forwarder, error) {
+		connects++
+		// The first attempt is made by start. Attempts 2 to 4
+		// are made while the only pod is restarting.
+		if connects > 1 && connects < 5 {
+			return nil, errors.New("none of 1 pods are ready")
+		}
+		current = &forwarder{
+			podName: fmt.Sprintf("pod-%d", connects),
+			address: server.Addr().String(),
+			stopCh:  make(chan struct{}),
+			doneCh:  make(chan struct{}),
+		}
+		return current, nil
+	}
+	tunnel.start(t)
+
+	// Simulate the pod being restarted.
+	close(current.doneCh)
+	require.Equal(t, "server-0", request(t, tunnel.Endpoint()))
+	require.Equal(t, 5, connects)
+}
+
+// Test that a connection is closed if no "pod"
+// becomes ready within the reconnect timeout.
+func TestTunnel_ReconnectTimeout(t *testing.T) {
+	server := echoServer(t, "server-0")
+
+	connects := 0
+	var current *forwarder
+	tunnel := newTunnel(t, "default", "component=server", 8500)
+	tunnel.reconnectTimeout = 2 * time.Second
+	tunnel.connect = func() (*forwarder, error) {
+		connects++
+		if connects > 1 {
+			return nil, errors.New("none of 1 pods are ready")
+		}
+		current = &forwarder{
+			podName: "pod",
+			address: server.Addr().String(),
+			stopCh:  make(chan struct{}),
+			doneCh:  make(chan struct{}),
+		}
+		return current, nil
+	}
+	tunnel.start(t)
+
+	close(current.doneCh)
+	conn, err := net.Dial("tcp", tunnel.Endpoint())
+	require.NoError(t, err)
+	defer conn.Close()
+	fmt.Fprintln(conn, "ping")
+	_, err = bufio.NewReader(conn).ReadString('\n')
+	require.Error(t, err)
+}
+
+// Test that the tunnel's state isn't locked while
+// it port-forwards to a new "pod", which can be slow.
+func TestTunnel_NotLockedWhileConnecting(t *testing.T) {
+	server := echoServer(t, "server-0")
+
+	connecting := make(chan struct{})
+	unblock := make(chan struct{})
+	connects := 0
+	var current *forwarder
+	tunnel := newTunnel(t, "default", "component=server", 8500)
+	tunnel.connect = func() (*forwarder, error) {
+		connects++
+		if connects == 2 {
+			close(connecting)
+			<-unblock
+		}
+		current = &forwarder{
+			podName: fmt.Sprintf("pod-%d", connects),
+			address: server.Addr().String(),
+			stopCh:  make(chan struct{}),
+			doneCh:  make(chan struct{}),
+		}
+		return current, nil
+	}
+	tunnel.start(t)
+
+	// Simulate the connection to the pod being lost
+	// so that the next connection reconnects.
+	close(current.doneCh)
+	conn, err := net.Dial("tcp", tunnel.Endpoint())
+	require.NoError(t, err)
+	defer conn.Close()
+	fmt.Fprintln(conn, "ping")
+	<-connecting
+
+	locked := make(chan struct{})
+	go func() {
+		tunnel.isClosed()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the tunnel is locked while port-forwarding")
+	}
+
+	close(unblock)
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "server-0", strings.TrimSpace(reply))
+}
+
+func TestTunnel_Close(t *testing.T) {
+	server := echoServer(t, "server-0")
+
+	fwd := &forwarder{
+		podName: "pod",
+		address: server.Addr().String(),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	tunnel := newTunnel(t, "default", "component=server", 8500)
+	tunnel.connect = func() (*forwarder, error) {
+		return fwd, nil
+	}
+	tunnel.start(t)
+
+	// Hold a connection open so Close has to close it.
+	conn, err := net.Dial("tcp", tunnel.Endpoint())
+	require.NoError(t, err)
+	defer conn.Close()
+	fmt.Fprintln(conn, "ping")
+	_, err = bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+
+	tunnel.Close()
+	select {
+	case <-fwd.stopCh:
+	default:
+		t.Fatal("expected the port-forward to be stopped")
+	}
+	_, err = net.Dial("tcp", tunnel.Endpoint())
+	require.Error(t, err)
+	_, err = tunnel.forwarder(false)
+	require.EqualError(t, err, "tunnel is closed")
+}
+
+func TestReadyPod(t *testing.T) {
+	now := metav1.Now()
+	cases := map[string]struct {
+		pods    []corev1.Pod
+		want    string
+		wantErr string
+	}{
+		"no pods": {
+			wantErr: "none of 0 pods are ready",
+		},
+		"lowest name": {
+			pods: []corev1.Pod{pod("server-2", true, nil), pod("server-0", true, nil), pod("server-1", true, nil)},
+			want: "server-0",
+		},
+		"skips pods that aren't ready": {
+			pods: []corev1.Pod{pod("server-0", false, nil), pod("server-1", true, nil)},
+			want: "server-1",
+		},
+		"skips terminating pods": {
+			pods: []corev1.Pod{pod("server-0", true, &now), pod("server-1", true, nil)},
+			want: "server-1",
+		},
+		"none ready": {
+			pods:    []corev1.Pod{pod("server-0", false, nil), pod("server-1", true, &now)},
+			wantErr: "none of 2 pods are ready",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := readyPod(c.pods)
+			if c.wantErr != "" {
+				require.EqualError(t, err, c.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.want, got.Name)
+		})
+	}
+}
+
+func pod(name string, ready bool, deletionTimestamp *metav1.Time) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, DeletionTimestamp: deletionTimestamp},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{Ready: ready}},
+		},
+	}
+}
+
+// echoServer starts a TCP server that replies to each line with its name.
+func echoServer(t *testing.T, name string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					fmt.Fprintln(conn, name)
+				}
+			}()
+		}
+	}()
+	return listener
+}
+
+// request sends a line to address on a new connection and returns the reply.
+func request(t *testing.T, address string) string {
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprintln(conn, "ping")
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	return strings.TrimSpace(reply)
+}
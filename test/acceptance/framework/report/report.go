@@ -0,0 +1,222 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// TestCase is the result of a single test or subtest.
+type TestCase struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Reporter records results of tests and writes them
+// as JUnit XML and JSON reports.
+type Reporter struct {
+	suiteName string
+
+	mu        sync.Mutex
+	testCases []*TestCase
+}
+
+// NewReporter returns a Reporter for the test suite with the given name.
+func NewReporter(suiteName string) *Reporter {
+	return &Reporter{suiteName: suiteName}
+}
+
+// resultLine matches the lines in which `go test -v` reports
+// the result of a test or subtest, e.g. "    --- PASS: TestFoo/bar (1.50s)".
+// With `go test -json`, the lines start with a framing byte.
+var resultLine = regexp.MustCompile(`^\x16?\s*--- (PASS|FAIL|SKIP): (\S+) \((\d+(?:\.\d+)?)s\)`)
+
+// resultStatuses maps the results of `go test -v` to statuses.
+var resultStatuses = map[string]string{
+	"PASS": StatusPassed,
+	"FAIL": StatusFailed,
+	"SKIP": StatusSkipped,
+}
+
+// RunAndRecord calls run, e.g. testing.M.Run with -test.v set,
+// and records the result of every test and subtest that it reports
+// on stdout, which is passed through. Unlike recording from within tests,
+// this includes tests that are skipped or fail before they do anything,
+// and the durations are measured by the testing package from the start
+// of each test.
+func (r *Reporter) RunAndRecord(run func() int) (int, error) {
+	stdout := os.Stdout
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	os.Stdout = writer
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.recordOutput(io.TeeReader(reader, stdout))
+	}()
+
+	code := run()
+	os.Stdout = stdout
+	writer.Close()
+	<-done
+	reader.Close()
+	return code, nil
+}
+
+// recordOutput records the results reported in the output of `go test -v`.
+// It reads output to the end so that the output is always passed through.
+func (r *Reporter) recordOutput(output io.Reader) {
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if tc, ok := parseResult(scanner.Text()); ok {
+			r.mu.Lock()
+			r.testCases = append(r.testCases, tc)
+			r.mu.Unlock()
+		}
+	}
+	// Lines longer than the buffer stop the scanner.
+	io.Copy(ioutil.Discard, output)
+}
+
+// parseResult returns the result reported by line if it's
+// a result line of `go test -v`.
+func parseResult(line string) (*TestCase, bool) {
+	match := resultLine.FindStringSubmatch(line)
+	if match == nil {
+		return nil, false
+	}
+	seconds, err := strconv.ParseFloat(match[3], 64)
+	if err != nil {
+		return nil, false
+	}
+	return &TestCase{
+		Name:     match[2],
+		Status:   resultStatuses[match[1]],
+		Duration: time.Duration(seconds * float64(time.Second)),
+	}, true
+}
+
+// TestCases returns the results recorded so far.
+func (r *Reporter) TestCases() []*TestCase {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*TestCase(nil), r.testCases...)
+}
+
+// WriteReports writes the recorded results to <directory>/<suite name>.xml
+// in JUnit XML format and to <directory>/<suite name>.json in JSON format.
+func (r *Reporter) WriteReports(directory string) error {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return err
+	}
+
+	junitReport, err := r.JUnit()
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(directory, r.suiteName+".xml"), junitReport, 0644); err != nil {
+		return err
+	}
+
+	jsonReport, err := r.JSON()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(directory, r.suiteName+".json"), jsonReport, 0644)
+}
+
+// JSON returns the recorded results as JSON.
+func (r *Reporter) JSON() ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Suite     string      `json:"suite"`
+		TestCases []*TestCase `json:"testCases"`
+	}{
+		Suite:     r.suiteName,
+		TestCases: r.TestCases(),
+	}, "", "  ")
+}
+
+// JUnit returns the recorded results in JUnit XML format.
+func (r *Reporter) JUnit() ([]byte, error) {
+	suite := junitTestSuite{Name: r.suiteName}
+
+	var total time.Duration
+	for _, tc := range r.TestCases() {
+		junitCase := junitTestCase{
+			ClassName: r.suiteName,
+			Name:      tc.Name,
+			Time:      formatSeconds(tc.Duration),
+		}
+		switch tc.Status {
+		case StatusFailed:
+			suite.Failures++
+			junitCase.Failure = &junitMessage{Message: fmt.Sprintf("%s failed; see test output for details", tc.Name)}
+		case StatusSkipped:
+			suite.Skipped++
+			junitCase.Skipped = &junitMessage{Message: fmt.Sprintf("%s was skipped", tc.Name)}
+		}
+		total += tc.Duration
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, junitCase)
+	}
+	suite.Time = formatSeconds(total)
+
+	out, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// formatSeconds formats d as seconds with millisecond precision
+// as expected by the JUnit format.
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReporter_RunAndRecord(t *testing.T) {
+	reporter := NewReporter("suite")
+
+	code, err := reporter.RunAndRecord(func() int {
+		fmt.Println("=== RUN   TestFoo")
+		fmt.Println("=== RUN   TestFoo/case_1")
+		fmt.Println("    foo_test.go:10: a log line that says --- FAIL: TestBar (1.00s)")
+		fmt.Println("=== RUN   TestFoo/case_2")
+		fmt.Println("    foo_test.go:20: skipping")
+		fmt.Println("--- FAIL: TestFoo (3.50s)")
+		fmt.Println("    --- PASS: TestFoo/case_1 (1.25s)")
+		fmt.Println("    --- SKIP: TestFoo/case_2 (0.00s)")
+		fmt.Println("--- PASS: TestBaz (0.01s)")
+		fmt.Println("FAIL")
+		return 1
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, code)
+
+	require.Equal(t, []*TestCase{
+		{Name: "TestFoo", Status: StatusFailed, Duration: 3500 * time.Millisecond},
+		{Name: "TestFoo/case_1", Status: StatusPassed, Duration: 1250 * time.Millisecond},
+		{Name: "TestFoo/case_2", Status: StatusSkipped, Duration: 0},
+		{Name: "TestBaz", Status: StatusPassed, Duration: 10 * time.Millisecond},
+	}, reporter.TestCases())
+}
+
+func TestParseResult(t *testing.T) {
+	cases := []struct {
+		line     string
+		expected *TestCase
+	}{
+		{"--- PASS: TestFoo (12.34s)", &TestCase{Name: "TestFoo", Status: StatusPassed, Duration: 12340 * time.Millisecond}},
+		{"        --- FAIL: TestFoo/bar/baz (0.50s)", &TestCase{Name: "TestFoo/bar/baz", Status: StatusFailed, Duration: 500 * time.Millisecond}},
+		{"\x16    --- SKIP: TestFoo/bar (0.00s)", &TestCase{Name: "TestFoo/bar", Status: StatusSkipped}},
+		{"=== RUN   TestFoo", nil},
+		{"    foo_test.go:10: --- PASS: TestFoo (1.00s)", nil},
+		{"PASS", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.line, func(t *testing.T) {
+			tc, ok := parseResult(c.line)
+			require.Equal(t, c.expected != nil, ok)
+			require.Equal(t, c.expected, tc)
+		})
+	}
+}
+
+func TestReporter_WriteReports(t *testing.T) {
+	reporter := NewReporter("suite")
+	reporter.testCases = []*TestCase{
+		{Name: "TestFoo/passed", Status: StatusPassed},
+		{Name: "TestFoo/failed", Status: StatusFailed},
+		{Name: "TestFoo/skipped", Status: StatusSkipped},
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, reporter.WriteReports(dir))
+
+	junitReport, err := ioutil.ReadFile(filepath.Join(dir, "suite.xml"))
+	require.NoError(t, err)
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(junitReport, &suites))
+	require.Len(t, suites.Suites, 1)
+	require.Equal(t, "suite", suites.Suites[0].Name)
+	require.Equal(t, 3, suites.Suites[0].Tests)
+	require.Equal(t, 1, suites.Suites[0].Failures)
+	require.Equal(t, 1, suites.Suites[0].Skipped)
+	require.Len(t, suites.Suites[0].TestCases, 3)
+	require.Nil(t, suites.Suites[0].TestCases[0].Failure)
+	require.NotNil(t, suites.Suites[0].TestCases[1].Failure)
+	require.NotNil(t, suites.Suites[0].TestCases[2].Skipped)
+
+	jsonReport, err := ioutil.ReadFile(filepath.Join(dir, "suite.json"))
+	require.NoError(t, err)
+	var result struct {
+		Suite     string      `json:"suite"`
+		TestCases []*TestCase `json:"testCases"`
+	}
+	require.NoError(t, json.Unmarshal(jsonReport, &result))
+	require.Equal(t, "suite", result.Suite)
+	require.Equal(t, reporter.testCases, result.TestCases)
+}
@@ -0,0 +1,138 @@
+// Package resources samples the CPU and memory usage of Consul components
+// while tests run and checks it against configured budgets, so that resource
+// regressions, for example, in the injected sidecar or the controller,
+// fail the tests instead of going unnoticed.
+//
+// Budgets are set with the -resource-budgets flag. If it's not set,
+// usage isn't sampled.
+package resources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// SidecarContainer is the name of the Envoy sidecar container
+// that the connect injector adds to pods.
+const SidecarContainer = "envoy-sidecar"
+
+// Components are the names of the components that budgets can be set for.
+// Except for the sidecar, they are the values of the component label
+// of the chart's pods, and usage is the sum of all containers of a pod.
+// The sidecar budget applies to each Envoy sidecar container on its own.
+var Components = []string{"server", "client", "controller", "connect-injector", SidecarContainer}
+
+// Budget is the maximum CPU and memory usage of a single pod of a component.
+// A nil quantity means that usage isn't limited.
+type Budget struct {
+	CPU    *resource.Quantity
+	Memory *resource.Quantity
+}
+
+// Budgets are the budgets of each component, keyed by the component name.
+type Budgets map[string]Budget
+
+// ParseBudgets parses a comma-separated list of budgets like
+// "server.memory=200Mi,server.cpu=500m,envoy-sidecar.memory=64Mi".
+// Each element sets the cpu or memory budget of one of Components
+// to a Kubernetes resource quantity.
+func ParseBudgets(s string) (Budgets, error) {
+	budgets := Budgets{}
+	for _, elem := range strings.Split(s, ",") {
+		elem = strings.TrimSpace(elem)
+		if elem == "" {
+			continue
+		}
+		kv := strings.SplitN(elem, "=", 2)
+		dot := strings.LastIndex(kv[0], ".")
+		if len(kv) != 2 || dot < 0 {
+			return nil, fmt.Errorf("invalid resource budget %q: must be <component>.<cpu|memory>=<quantity>", elem)
+		}
+		component, name := kv[0][:dot], kv[0][dot+1:]
+		if !sliceContains(Components, component) {
+			return nil, fmt.Errorf("invalid resource budget %q: component must be one of: %s", elem, strings.Join(Components, ", "))
+		}
+		quantity, err := resource.ParseQuantity(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource budget %q: %s", elem, err)
+		}
+
+		budget := budgets[component]
+		switch name {
+		case "cpu":
+			budget.CPU = &quantity
+		case "memory":
+			budget.Memory = &quantity
+		default:
+			return nil, fmt.Errorf("invalid resource budget %q: resource must be cpu or memory", elem)
+		}
+		budgets[component] = budget
+	}
+	return budgets, nil
+}
+
+// String returns the budgets in the format accepted by ParseBudgets.
+func (b Budgets) String() string {
+	var elems []string
+	for component, budget := range b {
+		if budget.CPU != nil {
+			elems = append(elems, fmt.Sprintf("%s.cpu=%s", component, budget.CPU))
+		}
+		if budget.Memory != nil {
+			elems = append(elems, fmt.Sprintf("%s.memory=%s", component, budget.Memory))
+		}
+	}
+	sort.Strings(elems)
+	return strings.Join(elems, ",")
+}
+
+// Usage is the CPU and memory usage of a pod or container.
+type Usage struct {
+	CPU    resource.Quantity
+	Memory resource.Quantity
+}
+
+func (u Usage) String() string {
+	return fmt.Sprintf("cpu=%s memory=%s", u.CPU.String(), u.Memory.String())
+}
+
+// add adds other to u.
+func (u *Usage) add(other Usage) {
+	u.CPU.Add(other.CPU)
+	u.Memory.Add(other.Memory)
+}
+
+// max sets each resource of u to the larger of u and other.
+func (u *Usage) max(other Usage) {
+	if other.CPU.Cmp(u.CPU) > 0 {
+		u.CPU = other.CPU.DeepCopy()
+	}
+	if other.Memory.Cmp(u.Memory) > 0 {
+		u.Memory = other.Memory.DeepCopy()
+	}
+}
+
+// exceeded returns a description of each resource of usage that exceeds b.
+func (b Budget) exceeded(usage Usage) []string {
+	var exceeded []string
+	if b.CPU != nil && usage.CPU.Cmp(*b.CPU) > 0 {
+		exceeded = append(exceeded, fmt.Sprintf("cpu %s exceeds budget %s", usage.CPU.String(), b.CPU))
+	}
+	if b.Memory != nil && usage.Memory.Cmp(*b.Memory) > 0 {
+		exceeded = append(exceeded, fmt.Sprintf("memory %s exceeds budget %s", usage.Memory.String(), b.Memory))
+	}
+	return exceeded
+}
+
+// sliceContains returns true if s contains target.
+func sliceContains(s []string, target string) bool {
+	for _, elem := range s {
+		if elem == target {
+			return true
+		}
+	}
+	return false
+}
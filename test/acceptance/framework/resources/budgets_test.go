@@ -0,0 +1,76 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestParseBudgets(t *testing.T) {
+	cases := map[string]struct {
+		budgets    string
+		expBudgets string
+		expErr     string
+	}{
+		"empty": {
+			budgets:    "",
+			expBudgets: "",
+		},
+		"cpu and memory of multiple components": {
+			budgets:    "server.memory=200Mi, server.cpu=500m,envoy-sidecar.memory=64Mi,",
+			expBudgets: "envoy-sidecar.memory=64Mi,server.cpu=500m,server.memory=200Mi",
+		},
+		"component with a dash": {
+			budgets:    "connect-injector.cpu=0.1",
+			expBudgets: "connect-injector.cpu=100m",
+		},
+		"missing quantity": {
+			budgets: "server.memory",
+			expErr:  `invalid resource budget "server.memory": must be <component>.<cpu|memory>=<quantity>`,
+		},
+		"missing resource": {
+			budgets: "server=200Mi",
+			expErr:  `invalid resource budget "server=200Mi": must be <component>.<cpu|memory>=<quantity>`,
+		},
+		"unknown component": {
+			budgets: "mesh-gateway.cpu=100m",
+			expErr:  `invalid resource budget "mesh-gateway.cpu=100m": component must be one of: server, client, controller, connect-injector, envoy-sidecar`,
+		},
+		"unknown resource": {
+			budgets: "server.disk=1Gi",
+			expErr:  `invalid resource budget "server.disk=1Gi": resource must be cpu or memory`,
+		},
+		"invalid quantity": {
+			budgets: "server.memory=lots",
+			expErr:  `invalid resource budget "server.memory=lots": quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			budgets, err := ParseBudgets(c.budgets)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expBudgets, budgets.String())
+		})
+	}
+}
+
+func TestBudget_exceeded(t *testing.T) {
+	cpu := resource.MustParse("100m")
+	memory := resource.MustParse("64Mi")
+	budget := Budget{CPU: &cpu, Memory: &memory}
+
+	require.Empty(t, budget.exceeded(Usage{CPU: resource.MustParse("100m"), Memory: resource.MustParse("64Mi")}))
+	require.Equal(t, []string{"cpu 101m exceeds budget 100m"},
+		budget.exceeded(Usage{CPU: resource.MustParse("101m"), Memory: resource.MustParse("10Mi")}))
+	require.Equal(t, []string{"cpu 1 exceeds budget 100m", "memory 65Mi exceeds budget 64Mi"},
+		budget.exceeded(Usage{CPU: resource.MustParse("1"), Memory: resource.MustParse("65Mi")}))
+
+	// Resources without a budget are not limited.
+	require.Empty(t, Budget{}.exceeded(Usage{CPU: resource.MustParse("8"), Memory: resource.MustParse("8Gi")}))
+}
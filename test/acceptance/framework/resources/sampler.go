@@ -0,0 +1,313 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// MetricsGroupVersion is the group version of the resource metrics API
+	// served by metrics-server.
+	MetricsGroupVersion = "metrics.k8s.io/v1beta1"
+	// MetricsKind is the kind of the pod resources of the resource metrics API.
+	MetricsKind = "PodMetrics"
+)
+
+// Peak is the highest CPU and memory usage of a pod of a component
+// seen by a Sampler. The CPU and memory peaks may come from different samples.
+type Peak struct {
+	Component string
+	Pod       string
+	Usage     Usage
+}
+
+// Sampler samples the CPU and memory usage of the pods of components
+// that have budgets and keeps track of the peak usage of each pod.
+// It reads usage from metrics-server if it's installed in the cluster
+// and from the kubelet summary API of each node otherwise.
+type Sampler struct {
+	client           kubernetes.Interface
+	namespace        string
+	budgets          Budgets
+	useMetricsServer bool
+
+	// get returns the response body of a GET request to the given path of
+	// the Kubernetes API server. It's a field so that tests can replace it.
+	get func(ctx context.Context, path string) ([]byte, error)
+
+	mu    sync.Mutex
+	peaks map[string]*Peak
+}
+
+// NewSampler returns a Sampler for the pods in namespace. useMetricsServer
+// should be true if the cluster serves the MetricsGroupVersion API.
+func NewSampler(client kubernetes.Interface, namespace string, budgets Budgets, useMetricsServer bool) *Sampler {
+	return &Sampler{
+		client:           client,
+		namespace:        namespace,
+		budgets:          budgets,
+		useMetricsServer: useMetricsServer,
+		get: func(ctx context.Context, path string) ([]byte, error) {
+			return client.CoreV1().RESTClient().Get().AbsPath(path).DoRaw(ctx)
+		},
+		peaks: map[string]*Peak{},
+	}
+}
+
+// Sample records the current usage of the running pods of components
+// that have budgets. Pods that don't have metrics yet are skipped.
+func (s *Sampler) Sample(ctx context.Context) error {
+	podList, err := s.client.CoreV1().Pods(s.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var pods []corev1.Pod
+	for _, pod := range podList.Items {
+		if pod.Status.Phase == corev1.PodRunning && (s.component(pod) != "" || s.hasSidecarBudget(pod)) {
+			pods = append(pods, pod)
+		}
+	}
+	if len(pods) == 0 {
+		return nil
+	}
+
+	usage, err := s.containerUsage(ctx, pods)
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		containers, ok := usage[pod.Name]
+		if !ok {
+			continue
+		}
+		if component := s.component(pod); component != "" {
+			var total Usage
+			for _, containerUsage := range containers {
+				total.add(containerUsage)
+			}
+			s.record(component, pod.Name, total)
+		}
+		if sidecarUsage, ok := containers[SidecarContainer]; ok && s.hasSidecarBudget(pod) {
+			s.record(SidecarContainer, pod.Name, sidecarUsage)
+		}
+	}
+	return nil
+}
+
+// Peaks returns the peak usage of each sampled pod, sorted by component and pod name.
+func (s *Sampler) Peaks() []Peak {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var peaks []Peak
+	for _, peak := range s.peaks {
+		peaks = append(peaks, *peak)
+	}
+	sort.Slice(peaks, func(i, j int) bool {
+		if peaks[i].Component != peaks[j].Component {
+			return peaks[i].Component < peaks[j].Component
+		}
+		return peaks[i].Pod < peaks[j].Pod
+	})
+	return peaks
+}
+
+// Violations returns a description of each pod whose peak usage
+// exceeded the budget of its component.
+func (s *Sampler) Violations() []string {
+	var violations []string
+	for _, peak := range s.Peaks() {
+		if exceeded := s.budgets[peak.Component].exceeded(peak.Usage); len(exceeded) > 0 {
+			violations = append(violations, fmt.Sprintf("%s pod %s: %s", peak.Component, peak.Pod, strings.Join(exceeded, ", ")))
+		}
+	}
+	return violations
+}
+
+// component returns the component of pod if it has a budget.
+func (s *Sampler) component(pod corev1.Pod) string {
+	component := pod.Labels["component"]
+	if _, ok := s.budgets[component]; !ok || component == SidecarContainer {
+		return ""
+	}
+	return component
+}
+
+// hasSidecarBudget returns true if pod has an Envoy sidecar
+// and there's a budget for sidecars.
+func (s *Sampler) hasSidecarBudget(pod corev1.Pod) bool {
+	if _, ok := s.budgets[SidecarContainer]; !ok {
+		return false
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name == SidecarContainer {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sampler) record(component, pod string, usage Usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := component + "/" + pod
+	peak, ok := s.peaks[key]
+	if !ok {
+		peak = &Peak{Component: component, Pod: pod}
+		s.peaks[key] = peak
+	}
+	peak.Usage.max(usage)
+}
+
+// containerUsage returns the usage of each container of pods
+// keyed by pod name and container name.
+func (s *Sampler) containerUsage(ctx context.Context, pods []corev1.Pod) (map[string]map[string]Usage, error) {
+	if s.useMetricsServer {
+		body, err := s.get(ctx, fmt.Sprintf("/apis/%s/namespaces/%s/pods", MetricsGroupVersion, s.namespace))
+		if err != nil {
+			return nil, err
+		}
+		return parsePodMetrics(body)
+	}
+
+	usage := map[string]map[string]Usage{}
+	nodes := map[string]bool{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || nodes[pod.Spec.NodeName] {
+			continue
+		}
+		nodes[pod.Spec.NodeName] = true
+		body, err := s.get(ctx, fmt.Sprintf("/api/v1/nodes/%s/proxy/stats/summary", pod.Spec.NodeName))
+		if err != nil {
+			return nil, err
+		}
+		if err := parseSummary(body, s.namespace, usage); err != nil {
+			return nil, err
+		}
+	}
+	return usage, nil
+}
+
+// parsePodMetrics parses a PodMetricsList from the resource metrics API.
+func parsePodMetrics(body []byte) (map[string]map[string]Usage, error) {
+	var metrics struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Containers []struct {
+				Name  string `json:"name"`
+				Usage struct {
+					CPU    resource.Quantity `json:"cpu"`
+					Memory resource.Quantity `json:"memory"`
+				} `json:"usage"`
+			} `json:"containers"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &metrics); err != nil {
+		return nil, fmt.Errorf("failed to parse pod metrics: %s", err)
+	}
+
+	usage := map[string]map[string]Usage{}
+	for _, pod := range metrics.Items {
+		containers := map[string]Usage{}
+		for _, container := range pod.Containers {
+			containers[container.Name] = Usage{CPU: container.Usage.CPU, Memory: container.Usage.Memory}
+		}
+		usage[pod.Metadata.Name] = containers
+	}
+	return usage, nil
+}
+
+// parseSummary parses the kubelet summary API response of a node
+// and adds the usage of the containers of pods in namespace to usage.
+// Memory usage is the working set, which is what metrics-server reports.
+func parseSummary(body []byte, namespace string, usage map[string]map[string]Usage) error {
+	var summary struct {
+		Pods []struct {
+			PodRef struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"podRef"`
+			Containers []struct {
+				Name string `json:"name"`
+				CPU  *struct {
+					UsageNanoCores *int64 `json:"usageNanoCores"`
+				} `json:"cpu"`
+				Memory *struct {
+					WorkingSetBytes *int64 `json:"workingSetBytes"`
+				} `json:"memory"`
+			} `json:"containers"`
+		} `json:"pods"`
+	}
+	if err := json.Unmarshal(body, &summary); err != nil {
+		return fmt.Errorf("failed to parse kubelet summary: %s", err)
+	}
+
+	for _, pod := range summary.Pods {
+		if pod.PodRef.Namespace != namespace {
+			continue
+		}
+		containers := map[string]Usage{}
+		for _, container := range pod.Containers {
+			var containerUsage Usage
+			if container.CPU != nil && container.CPU.UsageNanoCores != nil {
+				containerUsage.CPU = *resource.NewScaledQuantity(*container.CPU.UsageNanoCores, resource.Nano)
+			}
+			if container.Memory != nil && container.Memory.WorkingSetBytes != nil {
+				containerUsage.Memory = *resource.NewQuantity(*container.Memory.WorkingSetBytes, resource.BinarySI)
+			}
+			containers[container.Name] = containerUsage
+		}
+		usage[pod.PodRef.Name] = containers
+	}
+	return nil
+}
+
+// Watch samples usage with sampler every interval until t finishes.
+// Then it logs the peak usage of each pod and fails t if any pod
+// exceeded the budget of its component.
+func Watch(t *testing.T, sampler *Sampler, interval time.Duration) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if err := sampler.Sample(ctx); err != nil && ctx.Err() == nil {
+				logger.Logf(t, "failed to sample resource usage: %s", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+
+		for _, peak := range sampler.Peaks() {
+			logger.Logf(t, "peak resource usage of %s pod %s: %s", peak.Component, peak.Pod, peak.Usage)
+		}
+		for _, violation := range sampler.Violations() {
+			t.Errorf("resource budget exceeded by %s", violation)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const podMetrics = `{
+  "kind": "PodMetricsList",
+  "apiVersion": "metrics.k8s.io/v1beta1",
+  "items": [
+    {
+      "metadata": {"name": "consul-server-0", "namespace": "default"},
+      "containers": [
+        {"name": "consul", "usage": {"cpu": "150m", "memory": "100Mi"}}
+      ]
+    },
+    {
+      "metadata": {"name": "static-server-abc", "namespace": "default"},
+      "containers": [
+        {"name": "static-server", "usage": {"cpu": "1m", "memory": "5Mi"}},
+        {"name": "envoy-sidecar", "usage": {"cpu": "20m", "memory": "80Mi"}}
+      ]
+    },
+    {
+      "metadata": {"name": "consul-controller-abc", "namespace": "default"},
+      "containers": [
+        {"name": "controller", "usage": {"cpu": "10m", "memory": "30Mi"}},
+        {"name": "sidecar", "usage": {"cpu": "5m", "memory": "10Mi"}}
+      ]
+    }
+  ]
+}`
+
+const summary = `{
+  "node": {"nodeName": "node-1"},
+  "pods": [
+    {
+      "podRef": {"name": "consul-server-0", "namespace": "default"},
+      "containers": [
+        {"name": "consul", "cpu": {"usageNanoCores": 150000000}, "memory": {"workingSetBytes": 104857600}}
+      ]
+    },
+    {
+      "podRef": {"name": "consul-server-0", "namespace": "other"},
+      "containers": [
+        {"name": "consul", "cpu": {"usageNanoCores": 900000000}, "memory": {"workingSetBytes": 904857600}}
+      ]
+    },
+    {
+      "podRef": {"name": "static-server-abc", "namespace": "default"},
+      "containers": [
+        {"name": "static-server", "cpu": {}, "memory": {}},
+        {"name": "envoy-sidecar", "cpu": {"usageNanoCores": 20000000}, "memory": {"workingSetBytes": 83886080}}
+      ]
+    }
+  ]
+}`
+
+func TestSampler(t *testing.T) {
+	cases := map[string]struct {
+		useMetricsServer bool
+		expPaths         []string
+		expPeaks         []string
+	}{
+		"metrics-server": {
+			useMetricsServer: true,
+			expPaths:         []string{"/apis/metrics.k8s.io/v1beta1/namespaces/default/pods"},
+			expPeaks: []string{
+				"controller consul-controller-abc cpu=15m memory=40Mi",
+				"envoy-sidecar static-server-abc cpu=20m memory=80Mi",
+				"server consul-server-0 cpu=150m memory=100Mi",
+			},
+		},
+		"kubelet summary": {
+			useMetricsServer: false,
+			expPaths:         []string{"/api/v1/nodes/node-1/proxy/stats/summary"},
+			expPeaks: []string{
+				"envoy-sidecar static-server-abc cpu=20m memory=80Mi",
+				"server consul-server-0 cpu=150m memory=100Mi",
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(
+				testPod("consul-server-0", "server", "consul"),
+				testPod("consul-controller-abc", "controller", "controller", "sidecar"),
+				testPod("consul-client-abc", "client", "consul"),
+				testPod("static-server-abc", "", "static-server", SidecarContainer),
+			)
+			budgets, err := ParseBudgets("server.memory=90Mi,controller.cpu=20m,envoy-sidecar.cpu=50m,envoy-sidecar.memory=64Mi")
+			require.NoError(t, err)
+
+			sampler := NewSampler(client, "default", budgets, c.useMetricsServer)
+			var paths []string
+			sampler.get = func(_ context.Context, path string) ([]byte, error) {
+				paths = append(paths, path)
+				if c.useMetricsServer {
+					return []byte(podMetrics), nil
+				}
+				return []byte(summary), nil
+			}
+
+			require.NoError(t, sampler.Sample(context.Background()))
+			require.Equal(t, c.expPaths, paths)
+
+			var peaks []string
+			for _, peak := range sampler.Peaks() {
+				peaks = append(peaks, fmt.Sprintf("%s %s %s", peak.Component, peak.Pod, peak.Usage))
+			}
+			require.Equal(t, c.expPeaks, peaks)
+			require.Equal(t, []string{
+				"envoy-sidecar pod static-server-abc: memory 80Mi exceeds budget 64Mi",
+				"server pod consul-server-0: memory 100Mi exceeds budget 90Mi",
+			}, sampler.Violations())
+		})
+	}
+}
+
+func TestSampler_KeepsPeakUsage(t *testing.T) {
+	client := fake.NewSimpleClientset(testPod("consul-server-0", "server", "consul"))
+	budgets, err := ParseBudgets("server.memory=1Gi")
+	require.NoError(t, err)
+
+	sampler := NewSampler(client, "default", budgets, true)
+	samples := []string{"300m", "100Mi", "100m", "200Mi"}
+	sampler.get = func(_ context.Context, _ string) ([]byte, error) {
+		cpu, memory := samples[0], samples[1]
+		samples = samples[2:]
+		return []byte(fmt.Sprintf(`{"items": [{"metadata": {"name": "consul-server-0"}, "containers": [{"name": "consul", "usage": {"cpu": %q, "memory": %q}}]}]}`, cpu, memory)), nil
+	}
+
+	require.NoError(t, sampler.Sample(context.Background()))
+	require.NoError(t, sampler.Sample(context.Background()))
+	peaks := sampler.Peaks()
+	require.Len(t, peaks, 1)
+	require.Equal(t, "cpu=300m memory=200Mi", peaks[0].Usage.String())
+	require.Empty(t, sampler.Violations())
+}
+
+func TestSampler_NoPods(t *testing.T) {
+	budgets, err := ParseBudgets("server.memory=1Gi")
+	require.NoError(t, err)
+
+	sampler := NewSampler(fake.NewSimpleClientset(), "default", budgets, true)
+	sampler.get = func(_ context.Context, path string) ([]byte, error) {
+		return nil, fmt.Errorf("unexpected request to %s", path)
+	}
+	require.NoError(t, sampler.Sample(context.Background()))
+	require.Empty(t, sampler.Peaks())
+}
+
+func testPod(name, component string, containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{}},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if component != "" {
+		pod.Labels["component"] = component
+	}
+	for _, container := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: container})
+	}
+	return pod
+}
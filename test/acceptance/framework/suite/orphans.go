@@ -0,0 +1,48 @@
+package suite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// cleanupOrphans deletes the resources that other test runs left behind
+// in the Kubernetes clusters from the test config, see -cleanup-orphans.
+func (s *suite) cleanupOrphans() error {
+	clusters := [][2]string{{s.cfg.Kubeconfig, s.cfg.KubeContext}}
+	if s.cfg.EnableMultiCluster {
+		clusters = append(clusters, [2]string{s.cfg.SecondaryKubeconfig, s.cfg.SecondaryKubeContext})
+	}
+
+	for _, cluster := range clusters {
+		kubeconfig, kubeContext := cluster[0], cluster[1]
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		loadingRules.ExplicitPath = kubeconfig
+		restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext}).ClientConfig()
+		if err != nil {
+			return err
+		}
+		dynamicClient, err := dynamic.NewForConfig(restConfig)
+		if err != nil {
+			return err
+		}
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Deleting resources left behind by other test runs for at least %s from %s\n", s.cfg.CleanupOrphansMinAge, restConfig.Host)
+		deleted, err := testlabels.CleanupOrphans(context.Background(), dynamicClient, discoveryClient, s.cfg.TestRunID, s.cfg.CleanupOrphansMinAge)
+		for _, resource := range deleted {
+			fmt.Printf("Deleted %s\n", resource)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
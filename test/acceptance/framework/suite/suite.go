@@ -4,39 +4,98 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/flags"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/images"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/metrics"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/report"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/resources"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
 )
 
 type suite struct {
-	m     *testing.M
-	env   *environment.KubernetesEnvironment
-	cfg   *config.TestConfig
-	flags *flags.TestFlags
+	m        *testing.M
+	env      *environment.KubernetesEnvironment
+	cfg      *config.TestConfig
+	flags    *flags.TestFlags
+	reporter *report.Reporter
+
+	budgetWatcher *budgetWatcher
+	smokeTracker  *smokeTracker
 }
 
 type Suite interface {
 	Run() int
 	Environment() environment.TestEnvironment
 	Config() *config.TestConfig
+	// TestSuite returns a TestSuite that installs Consul for the cases of
+	// the test t and can share installs between them if -reuse-clusters is set.
+	TestSuite(t *testing.T) TestSuite
 }
 
-func NewSuite(m *testing.M) Suite {
+// Option configures optional settings of a Suite.
+type Option func(*suite)
+
+// SmokeCases registers the tests and subtests that run when -smoke-only
+// or -short is set, by the names that (*testing.T).Name() returns for
+// them, e.g. "TestConnectInject/secure: false; auto-encrypt: false".
+// Registering a test runs all of its subtests, and registering a subtest
+// runs its parent test up to that subtest. In smoke mode, the suite
+// fails if a registered case doesn't run, e.g. because it was renamed,
+// unless -run selects the tests to run. Suites register one
+// representative case that installs Consul once so that the smoke tier
+// of all suites gives quick signal, e.g. on pull requests, while the
+// full matrices run nightly.
+func SmokeCases(names ...string) Option {
+	return func(s *suite) {
+		s.cfg.SmokeCases = append(s.cfg.SmokeCases, names...)
+	}
+}
+
+func NewSuite(m *testing.M, options ...Option) Suite {
 	flags := flags.NewTestFlags()
 
 	flag.Parse()
 
 	testConfig := flags.TestConfigFromFlags()
+	testConfig.SmokeOnly = testConfig.SmokeOnly || testing.Short()
+	timeouts.Set(testConfig.Timeouts)
+	images.SetRegistry(testConfig.TestImageRegistry)
+	k8s.SetCollectEnvoyAccessLogs(testConfig.CollectEnvoyAccessLogs)
+	if testConfig.TestRunID == "" {
+		testConfig.TestRunID = testlabels.NewRunID()
+	}
+	testlabels.SetRunID(testConfig.TestRunID)
 
-	return &suite{
+	s := &suite{
 		m:     m,
 		env:   environment.NewKubernetesEnvironmentFromConfig(testConfig),
 		cfg:   testConfig,
 		flags: flags,
 	}
+	if testConfig.JUnitOutDirectory != "" {
+		s.reporter = report.NewReporter(suiteName())
+	}
+	if len(testConfig.ResourceBudgets) > 0 {
+		s.budgetWatcher = &budgetWatcher{cfg: testConfig, watched: map[string]bool{}}
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if testConfig.SmokeOnly {
+		s.smokeTracker = &smokeTracker{cfg: testConfig, ran: map[string]bool{}}
+	}
+	return s
 }
 
 func (s *suite) Run() int {
@@ -56,13 +115,287 @@ func (s *suite) Run() int {
 		}
 	}
 
-	return s.m.Run()
+	if s.cfg.Provider != "" {
+		deprovision, err := s.provisionClusters()
+		if err != nil {
+			fmt.Printf("Failed to provision Kubernetes clusters: %s\n", err)
+			deprovision()
+			return 1
+		}
+
+		code := s.runTests()
+		if s.cfg.NoCleanup {
+			fmt.Println("Skipping deprovisioning of Kubernetes clusters because -no-cleanup is set")
+		} else if code != 0 && s.cfg.NoCleanupOnFailure {
+			fmt.Println("Skipping deprovisioning of Kubernetes clusters because tests failed and -no-cleanup-on-failure is set")
+		} else {
+			deprovision()
+		}
+		return code
+	}
+
+	return s.runTests()
+}
+
+// runTests deletes orphaned resources if -cleanup-orphans is set,
+// runs the tests and writes test reports if -junit-out is set.
+func (s *suite) runTests() int {
+	if s.cfg.CleanupOrphans {
+		if err := s.cleanupOrphans(); err != nil {
+			fmt.Printf("Failed to clean up orphaned resources: %s\n", err)
+			return 1
+		}
+	}
+
+	if err := s.configureMetrics(); err != nil {
+		fmt.Printf("Failed to configure metrics: %s\n", err)
+		return 1
+	}
+
+	if s.smokeTracker != nil && len(s.cfg.SmokeCases) == 0 {
+		fmt.Println("Skipping all tests because -smoke-only is set and the suite has no smoke cases")
+	}
+
+	fmt.Printf("Test run ID: %s\n", s.cfg.TestRunID)
+	code, err := s.run()
+	if err != nil {
+		fmt.Printf("Failed to record test results: %s\n", err)
+		return 1
+	}
+
+	if s.smokeTracker != nil && !runFlagSet() {
+		if missing := s.smokeTracker.missing(); len(missing) > 0 {
+			fmt.Printf("Smoke cases of the suite didn't run, check that they match the names of its tests and subtests: %s\n", strings.Join(missing, ", "))
+			code = 1
+		}
+	}
+
+	// Failing to send metrics doesn't fail the test run
+	// because the metrics are only informational.
+	if err := metrics.Close(); err != nil {
+		fmt.Printf("Failed to send metrics: %s\n", err)
+	}
+
+	if s.reporter != nil {
+		if err := s.reporter.WriteReports(s.cfg.JUnitOutDirectory); err != nil {
+			fmt.Printf("Failed to write test reports: %s\n", err)
+			return 1
+		}
+	}
+	return code
+}
+
+// run runs the tests. If -junit-out is set, it runs them in verbose mode
+// and records their results from the output.
+func (s *suite) run() (int, error) {
+	if s.reporter == nil {
+		return s.m.Run(), nil
+	}
+	// Keep the mode that `go test -json` sets.
+	if verbose := flag.Lookup("test.v"); verbose != nil && verbose.Value.String() == "false" {
+		if err := verbose.Value.Set("true"); err != nil {
+			return 0, err
+		}
+	}
+	return s.reporter.RunAndRecord(s.m.Run)
+}
+
+// configureMetrics configures the sinks that the durations of the phases
+// of tests are sent to from -metrics-statsd-addr and -metrics-pushgateway-url.
+func (s *suite) configureMetrics() error {
+	var sinks []metrics.Sink
+	if s.cfg.MetricsStatsdAddr != "" {
+		sink, err := metrics.NewStatsdSink(s.cfg.MetricsStatsdAddr)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, sink)
+	}
+	if s.cfg.MetricsPushgatewayURL != "" {
+		sinks = append(sinks, metrics.NewPushgatewaySink(s.cfg.MetricsPushgatewayURL, s.cfg.TestRunID, suiteName()))
+	}
+	metrics.Configure(suiteName(), sinks...)
+	return nil
+}
+
+// provisionClusters provisions the primary and, if multi-cluster tests are enabled,
+// the secondary Kubernetes cluster using the provider from the test config.
+// It updates the test config and environment to point to the provisioned clusters
+// and returns a function that deprovisions all clusters that have been provisioned.
+func (s *suite) provisionClusters() (func(), error) {
+	var deprovisionFuncs []func()
+	deprovision := func() {
+		for _, f := range deprovisionFuncs {
+			f()
+		}
+	}
+
+	provision := func(kubeconfig, kubeContext *string) error {
+		provisioner, err := environment.NewProvisioner(s.cfg.Provider, *kubeconfig, *kubeContext)
+		if err != nil {
+			return err
+		}
+
+		name := helpers.RandomName()
+		fmt.Printf("Provisioning %s Kubernetes cluster %s\n", s.cfg.Provider, name)
+		*kubeconfig, *kubeContext, err = provisioner.Provision(name)
+		deprovisionFuncs = append(deprovisionFuncs, func() {
+			fmt.Printf("Deprovisioning %s Kubernetes cluster %s\n", s.cfg.Provider, name)
+			if err := provisioner.Deprovision(name); err != nil {
+				fmt.Printf("Failed to deprovision Kubernetes cluster %s: %s\n", name, err)
+			}
+		})
+		return err
+	}
+
+	if err := provision(&s.cfg.Kubeconfig, &s.cfg.KubeContext); err != nil {
+		return deprovision, err
+	}
+	if s.cfg.EnableMultiCluster {
+		if err := provision(&s.cfg.SecondaryKubeconfig, &s.cfg.SecondaryKubeContext); err != nil {
+			return deprovision, err
+		}
+	}
+
+	// Re-create the environment so that its contexts point to the provisioned clusters.
+	s.env = environment.NewKubernetesEnvironmentFromConfig(s.cfg)
+
+	return deprovision, nil
 }
 
 func (s *suite) Environment() environment.TestEnvironment {
-	return s.env
+	var env environment.TestEnvironment = s.env
+	if s.budgetWatcher != nil {
+		env = &budgetEnvironment{TestEnvironment: env, watcher: s.budgetWatcher}
+	}
+	// The smoke environment wraps the budget environment so that
+	// skipped cases don't start sampling resource usage.
+	if s.smokeTracker != nil {
+		env = &smokeEnvironment{TestEnvironment: env, tracker: s.smokeTracker}
+	}
+	return env
 }
 
 func (s *suite) Config() *config.TestConfig {
 	return s.cfg
 }
+
+func (s *suite) TestSuite(t *testing.T) TestSuite {
+	return &testSuite{owner: t, cfg: s.cfg, shared: map[string]*sharedCluster{}}
+}
+
+// smokeEnvironment skips every test that requests a test context
+// from the environment unless it is a smoke case of the suite,
+// and records the smoke cases that run.
+type smokeEnvironment struct {
+	environment.TestEnvironment
+	tracker *smokeTracker
+}
+
+func (s *smokeEnvironment) DefaultContext(t *testing.T) environment.TestContext {
+	s.tracker.skipOrRecord(t)
+	return s.TestEnvironment.DefaultContext(t)
+}
+
+func (s *smokeEnvironment) Context(t *testing.T, name string) environment.TestContext {
+	s.tracker.skipOrRecord(t)
+	return s.TestEnvironment.Context(t, name)
+}
+
+// smokeTracker records which smoke cases of the suite ran
+// so that the suite can fail if a registered case never ran.
+type smokeTracker struct {
+	cfg *config.TestConfig
+
+	mu  sync.Mutex
+	ran map[string]bool
+}
+
+// skipOrRecord skips t if it isn't a smoke case of the suite,
+// its parent test or one of its subtests, and otherwise records
+// the smoke cases that t runs.
+func (s *smokeTracker) skipOrRecord(t *testing.T) {
+	t.Helper()
+
+	s.cfg.SkipNonSmokeCase(t)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, smokeCase := range s.cfg.SmokeCases {
+		if config.IsSmokeCaseRun(smokeCase, t.Name()) {
+			s.ran[smokeCase] = true
+		}
+	}
+}
+
+// missing returns the smoke cases of the suite that never ran.
+func (s *smokeTracker) missing() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var missing []string
+	for _, smokeCase := range s.cfg.SmokeCases {
+		if !s.ran[smokeCase] {
+			missing = append(missing, smokeCase)
+		}
+	}
+	return missing
+}
+
+// runFlagSet returns true if -run selects the tests to run,
+// in which case smoke cases are expected not to run.
+func runFlagSet() bool {
+	f := flag.Lookup("test.run")
+	return f != nil && f.Value.String() != ""
+}
+
+// budgetEnvironment samples the resource usage of Consul components
+// in every test context that a test requests while the test runs
+// and fails the test if it exceeds the budgets from -resource-budgets.
+type budgetEnvironment struct {
+	environment.TestEnvironment
+	watcher *budgetWatcher
+}
+
+func (b *budgetEnvironment) DefaultContext(t *testing.T) environment.TestContext {
+	ctx := b.TestEnvironment.DefaultContext(t)
+	b.watcher.watch(t, environment.DefaultContextName, ctx)
+	return ctx
+}
+
+func (b *budgetEnvironment) Context(t *testing.T, name string) environment.TestContext {
+	ctx := b.TestEnvironment.Context(t, name)
+	b.watcher.watch(t, name, ctx)
+	return ctx
+}
+
+// budgetWatcher starts sampling a test context at most once per test
+// because tests can request the same context more than once.
+type budgetWatcher struct {
+	cfg *config.TestConfig
+
+	mu      sync.Mutex
+	watched map[string]bool
+}
+
+func (b *budgetWatcher) watch(t *testing.T, contextName string, ctx environment.TestContext) {
+	t.Helper()
+
+	key := t.Name() + "/" + contextName
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.watched[key] {
+		return
+	}
+	b.watched[key] = true
+
+	useMetricsServer := ctx.HasAPIResource(t, resources.MetricsGroupVersion, resources.MetricsKind)
+	sampler := resources.NewSampler(ctx.KubernetesClient(t), ctx.KubectlOptions(t).Namespace, b.cfg.ResourceBudgets, useMetricsServer)
+	resources.Watch(t, sampler, b.cfg.ResourceSampleInterval)
+}
+
+// suiteName returns the name of the test suite derived from the
+// name of the test binary, e.g. "controller" for "controller.test".
+func suiteName() string {
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".test")
+}
@@ -0,0 +1,29 @@
+package suite
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmokeTracker(t *testing.T) {
+	tracker := &smokeTracker{
+		cfg: &config.TestConfig{
+			SmokeOnly:  true,
+			SmokeCases: []string{"TestSmokeTracker/secure: false", "TestSmokeTracker/renamed case"},
+		},
+		ran: map[string]bool{},
+	}
+
+	var ran []string
+	for _, name := range []string{"secure: false", "secure: true"} {
+		t.Run(name, func(t *testing.T) {
+			tracker.skipOrRecord(t)
+			ran = append(ran, name)
+		})
+	}
+
+	require.Equal(t, []string{"secure: false"}, ran)
+	require.Equal(t, []string{"TestSmokeTracker/renamed case"}, tracker.missing())
+}
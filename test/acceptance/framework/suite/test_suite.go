@@ -0,0 +1,147 @@
+package suite
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+)
+
+// TestSuite installs Consul for the cases of a table-driven test.
+//
+// By default, it installs Consul for every case, like consul.NewHelmCluster.
+// If -reuse-clusters is set, consecutive cases with the same Helm values
+// share one Helm release, which is destroyed when a case needs different
+// values or when the test finishes. Each case then gets its own Kubernetes
+// namespace to create its resources in, and the config entries it creates
+// are deleted when it finishes, as are the Consul namespaces it creates
+// if -consul-namespace-prefix is set.
+// To share as many installs as possible, order cases with the same
+// Helm values next to each other. Cases must not run in parallel
+// or upgrade the cluster, since later cases expect the Helm values they
+// asked for. Cases that enable TLS must also enable ACLs, and the other way
+// around, because the Consul client that resets the state is set up with
+// SetupConsulClient.
+type TestSuite interface {
+	// HelmCluster returns a Consul cluster installed with helmValues
+	// into the namespace of ctx, and the test context that the case t should
+	// create its resources in. Tests should use the returned context
+	// instead of ctx so that they work whether or not clusters are reused.
+	HelmCluster(t *testing.T, ctx environment.TestContext, helmValues map[string]string) (consul.Cluster, environment.TestContext)
+}
+
+type testSuite struct {
+	owner *testing.T
+	cfg   *config.TestConfig
+
+	mu sync.Mutex
+	// shared holds the shared cluster of each Kubernetes context, keyed by clusterContextKey.
+	shared map[string]*sharedCluster
+}
+
+// sharedCluster is a Helm release shared by consecutive cases
+// and the Consul state that each case is reset to.
+type sharedCluster struct {
+	valuesKey string
+	cluster   consul.Cluster
+	secure    bool
+	state     *consul.State
+}
+
+func (s *testSuite) HelmCluster(t *testing.T, ctx environment.TestContext, helmValues map[string]string) (consul.Cluster, environment.TestContext) {
+	t.Helper()
+
+	// Skip before the shared release is destroyed for different Helm values.
+	traits := config.CaseTraitsFromHelmValues(helmValues)
+	s.cfg.SkipFilteredCase(t, traits)
+
+	if !s.cfg.ReuseClusters {
+		cluster := consul.NewHelmCluster(t, helmValues, ctx, s.cfg, helpers.RandomName())
+		cluster.Create(t)
+		return cluster, ctx
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contextKey := clusterContextKey(ctx.KubectlOptions(t))
+	valuesKey := helmValuesKey(helmValues)
+	shared := s.shared[contextKey]
+	if shared != nil && shared.valuesKey != valuesKey {
+		// Only keep one shared release per Kubernetes cluster because,
+		// for example, the connect injectors of all releases inject pods
+		// in all namespaces.
+		logger.Logf(t, "destroying shared Consul cluster because this case uses different Helm values")
+		delete(s.shared, contextKey)
+		shared.cluster.Destroy(t)
+		shared = nil
+	}
+
+	secure := traits.Secure
+	consulNamespaces := helmValues["global.enableConsulNamespaces"] == "true"
+	if shared == nil {
+		cluster := consul.NewHelmCluster(t, helmValues, ctx, s.cfg, helpers.RandomName(), consul.WithCleanupScope(s.owner))
+		// If the install fails, destroy the release right away rather than when
+		// the test finishes so that it doesn't conflict with later cases.
+		installed := false
+		defer func() {
+			if !installed && !s.cfg.NoCleanupOnFailure && !s.cfg.NoCleanup {
+				cluster.Destroy(t)
+			}
+		}()
+		cluster.Create(t)
+		shared = &sharedCluster{
+			valuesKey: valuesKey,
+			cluster:   cluster,
+			secure:    secure,
+			state:     consul.SaveState(t, cluster.SetupConsulClient(t, secure), consulNamespaces),
+		}
+		s.shared[contextKey] = shared
+		installed = true
+	} else {
+		logger.Logf(t, "reusing shared Consul cluster")
+	}
+
+	// Register the reset before creating the namespace so that
+	// it runs after the case's Kubernetes resources are deleted.
+	helpers.Cleanup(t, s.cfg.NoCleanupOnFailure, s.cfg.NoCleanup, func() {
+		shared.state.Reset(t, shared.cluster.SetupConsulClient(t, shared.secure), s.cfg.ConsulNamespacePrefix)
+	})
+	options := helpers.RandomNamespace(t, ctx, s.cfg.NoCleanupOnFailure, s.cfg.NoCleanup)
+	return shared.cluster, &namespacedContext{TestContext: ctx, options: options}
+}
+
+// namespacedContext is a test context whose kubectl options
+// use a different namespace than the context it wraps.
+type namespacedContext struct {
+	environment.TestContext
+	options *terratestk8s.KubectlOptions
+}
+
+func (n *namespacedContext) KubectlOptions(*testing.T) *terratestk8s.KubectlOptions {
+	return n.options
+}
+
+// clusterContextKey returns a key that identifies the Kubernetes cluster
+// and namespace that options point to.
+func clusterContextKey(options *terratestk8s.KubectlOptions) string {
+	return fmt.Sprintf("%s/%s/%s", options.ConfigPath, options.ContextName, options.Namespace)
+}
+
+// helmValuesKey returns a key that is the same for equal Helm values.
+func helmValuesKey(helmValues map[string]string) string {
+	var values []string
+	for k, v := range helmValues {
+		values = append(values, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,186 @@
+package testlabels
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+// consulResourceGroup is the API group of the custom resources of the controller.
+const consulResourceGroup = "consul.hashicorp.com"
+
+// CleanupOrphans deletes the resources in all namespaces that were labeled by
+// test runs other than runID and that no test has created or updated in
+// the last minAge. minAge keeps it from deleting the resources of test runs
+// that are running at the same time against the same cluster.
+// Custom resource definitions are never deleted because they're shared
+// between Helm releases. The controller that would remove the finalizers of
+// orphaned Consul custom resources has usually been deleted itself, so their
+// finalizers are removed. Resources that belong to the Helm releases of
+// the deleted resources but aren't labeled, i.e. the secrets and config maps
+// in which Helm stores the releases and the persistent volume claims of their
+// stateful sets, are deleted too. It returns the resources it deleted in the
+// form "<resource> <namespace>/<name>".
+func CleanupOrphans(ctx context.Context, client dynamic.Interface, discoveryClient discovery.DiscoveryInterface, runID string, minAge time.Duration) ([]string, error) {
+	resourceLists, err := discovery.ServerPreferredResources(discoveryClient)
+	// Some API groups, like metrics.k8s.io, may be unavailable.
+	// Resources from the other groups can still be cleaned up.
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+
+	selector := fmt.Sprintf("%s,%s!=%s", TestRunIDLabel, TestRunIDLabel, runID)
+	now := time.Now()
+	var deleted []string
+	releases := map[helmRelease]bool{}
+	for _, gvr := range deletableResources(resourceLists) {
+		list, err := client.Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return deleted, fmt.Errorf("listing %s: %s", gvr.Resource, err)
+		}
+		for _, obj := range list.Items {
+			if !isOrphan(&obj, minAge, now) {
+				continue
+			}
+			if err := deleteOrphan(ctx, client.Resource(gvr).Namespace(obj.GetNamespace()), gvr, &obj); err != nil {
+				return deleted, err
+			}
+			deleted = append(deleted, fmt.Sprintf("%s %s/%s", gvr.Resource, obj.GetNamespace(), obj.GetName()))
+			if release, ok := helmReleaseOf(&obj); ok {
+				releases[release] = true
+			}
+		}
+	}
+
+	for release := range releases {
+		for gvr, selector := range release.unlabeledResources() {
+			list, err := client.Resource(gvr).Namespace(release.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+			if err != nil {
+				return deleted, fmt.Errorf("listing %s: %s", gvr.Resource, err)
+			}
+			for _, obj := range list.Items {
+				if err := deleteOrphan(ctx, client.Resource(gvr).Namespace(obj.GetNamespace()), gvr, &obj); err != nil {
+					return deleted, err
+				}
+				deleted = append(deleted, fmt.Sprintf("%s %s/%s", gvr.Resource, obj.GetNamespace(), obj.GetName()))
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// helmRelease is a Helm release that orphaned resources belonged to.
+type helmRelease struct {
+	namespace string
+	name      string
+}
+
+// helmReleaseOf returns the Helm release that obj was installed with,
+// according to the heritage and release labels of the chart's resources.
+// Cluster-scoped resources are ignored because they don't tell which
+// namespace the release is stored in.
+func helmReleaseOf(obj metav1.Object) (helmRelease, bool) {
+	labels := obj.GetLabels()
+	if labels["heritage"] != "Helm" || labels["release"] == "" || obj.GetNamespace() == "" {
+		return helmRelease{}, false
+	}
+	return helmRelease{namespace: obj.GetNamespace(), name: labels["release"]}, true
+}
+
+// unlabeledResources returns the label selectors of the resources of the
+// release that tests can't label: the secrets or config maps, depending on
+// Helm's storage driver, in which Helm stores the release, and the persistent
+// volume claims that stateful sets create from their volume claim templates,
+// which have the labels of the stateful set's selector. Labeled resources
+// are left to the age check of CleanupOrphans.
+func (r helmRelease) unlabeledResources() map[schema.GroupVersionResource]string {
+	releaseRecords := fmt.Sprintf("owner=helm,name=%s,!%s", r.name, TestRunIDLabel)
+	return map[schema.GroupVersionResource]string{
+		{Version: "v1", Resource: "secrets"}:                releaseRecords,
+		{Version: "v1", Resource: "configmaps"}:             releaseRecords,
+		{Version: "v1", Resource: "persistentvolumeclaims"}: fmt.Sprintf("release=%s,!%s", r.name, TestRunIDLabel),
+	}
+}
+
+// deleteOrphan deletes obj and removes the finalizers of Consul custom resources.
+func deleteOrphan(ctx context.Context, client dynamic.ResourceInterface, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	propagation := metav1.DeletePropagationBackground
+	err := client.Delete(ctx, obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("deleting %s %s/%s: %s", gvr.Resource, obj.GetNamespace(), obj.GetName(), err)
+	}
+	if gvr.Group != consulResourceGroup || len(obj.GetFinalizers()) == 0 {
+		return nil
+	}
+	_, err = client.Patch(ctx, obj.GetName(), types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`), metav1.PatchOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("removing finalizers of %s %s/%s: %s", gvr.Resource, obj.GetNamespace(), obj.GetName(), err)
+	}
+	return nil
+}
+
+// deletableResources returns the resources from resourceLists that can be
+// listed and deleted, except for custom resource definitions and events.
+// Namespaces are last so that the resources in them are deleted individually,
+// and their finalizers removed, before the namespace deletion waits for them.
+func deletableResources(resourceLists []*metav1.APIResourceList) []schema.GroupVersionResource {
+	var gvrs []schema.GroupVersionResource
+	var namespaces []schema.GroupVersionResource
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			if !hasVerbs(resource.Verbs, "list", "delete") {
+				continue
+			}
+			gvr := gv.WithResource(resource.Name)
+			switch gvr.GroupResource().String() {
+			case "customresourcedefinitions.apiextensions.k8s.io", "events", "events.events.k8s.io":
+				continue
+			case "namespaces":
+				namespaces = append(namespaces, gvr)
+			default:
+				gvrs = append(gvrs, gvr)
+			}
+		}
+	}
+	return append(gvrs, namespaces...)
+}
+
+// isOrphan returns true if obj was last created or updated by a test at least
+// minAge before now, according to its timestamp label or, if it doesn't
+// have a valid one, its creation timestamp.
+func isOrphan(obj metav1.Object, minAge time.Duration, now time.Time) bool {
+	timestamp := obj.GetCreationTimestamp().Time
+	if unix, err := strconv.ParseInt(obj.GetLabels()[TimestampLabel], 10, 64); err == nil {
+		timestamp = time.Unix(unix, 0)
+	}
+	return now.Sub(timestamp) >= minAge
+}
+
+func hasVerbs(verbs metav1.Verbs, required ...string) bool {
+	for _, r := range required {
+		found := false
+		for _, v := range verbs {
+			if v == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
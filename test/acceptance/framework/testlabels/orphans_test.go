@@ -0,0 +1,158 @@
+package testlabels
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestCleanupOrphans(t *testing.T) {
+	old := strconv.FormatInt(time.Now().Add(-3*time.Hour).Unix(), 10)
+	recent := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+
+	serviceAccounts := schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"}
+	namespaces := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	serviceDefaults := schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "servicedefaults"}
+	crds := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	secrets := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	pvcs := schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}
+
+	client := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme())
+	for gvr, obj := range map[schema.GroupVersionResource]*unstructured.Unstructured{
+		serviceAccounts: testObject("v1", "ServiceAccount", "default", "old-other-run", map[string]string{TestRunIDLabel: "other", TimestampLabel: old}),
+		namespaces:      testObject("v1", "Namespace", "", "old-namespace", map[string]string{TestRunIDLabel: "other", TimestampLabel: old}),
+		serviceDefaults: testObject("consul.hashicorp.com/v1alpha1", "ServiceDefaults", "default", "old-defaults", map[string]string{TestRunIDLabel: "other", TimestampLabel: old}),
+		crds:            testObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "servicedefaults.consul.hashicorp.com", map[string]string{TestRunIDLabel: "other", TimestampLabel: old}),
+		// The chart's resources have the release and heritage labels, but
+		// Helm's release records and the volume claims of stateful sets
+		// don't have the test labels.
+		configMaps: testObject("v1", "ConfigMap", "default", "old-release-consul-server-config", map[string]string{TestRunIDLabel: "other", TimestampLabel: old, "heritage": "Helm", "release": "old-release"}),
+		pvcs:       testObject("v1", "PersistentVolumeClaim", "default", "data-default-old-release-consul-server-0", map[string]string{"release": "old-release", "component": "server"}),
+	} {
+		_, err := client.Resource(gvr).Namespace(obj.GetNamespace()).Create(context.Background(), obj, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	for _, obj := range []*unstructured.Unstructured{
+		testObject("v1", "ServiceAccount", "default", "recent-other-run", map[string]string{TestRunIDLabel: "other", TimestampLabel: recent}),
+		testObject("v1", "ServiceAccount", "default", "old-this-run", map[string]string{TestRunIDLabel: "current", TimestampLabel: old}),
+		testObject("v1", "ServiceAccount", "default", "unlabeled", nil),
+	} {
+		_, err := client.Resource(serviceAccounts).Namespace(obj.GetNamespace()).Create(context.Background(), obj, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	for _, obj := range []*unstructured.Unstructured{
+		testObject("v1", "Secret", "default", "sh.helm.release.v1.old-release.v1", map[string]string{"owner": "helm", "name": "old-release"}),
+		testObject("v1", "Secret", "default", "sh.helm.release.v1.live-release.v1", map[string]string{"owner": "helm", "name": "live-release"}),
+	} {
+		_, err := client.Resource(secrets).Namespace(obj.GetNamespace()).Create(context.Background(), obj, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	verbs := metav1.Verbs{"get", "list", "delete", "patch"}
+	discoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "namespaces", Kind: "Namespace", Verbs: verbs},
+				{Name: "serviceaccounts", Namespaced: true, Kind: "ServiceAccount", Verbs: verbs},
+				{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: verbs},
+				{Name: "serviceaccounts/token", Namespaced: true, Kind: "TokenRequest", Verbs: metav1.Verbs{"create"}},
+				{Name: "events", Namespaced: true, Kind: "Event", Verbs: verbs},
+			},
+		},
+		{
+			GroupVersion: "consul.hashicorp.com/v1alpha1",
+			APIResources: []metav1.APIResource{{Name: "servicedefaults", Namespaced: true, Kind: "ServiceDefaults", Verbs: verbs}},
+		},
+		{
+			GroupVersion: "apiextensions.k8s.io/v1",
+			APIResources: []metav1.APIResource{{Name: "customresourcedefinitions", Kind: "CustomResourceDefinition", Verbs: verbs}},
+		},
+	}}}
+
+	deleted, err := CleanupOrphans(context.Background(), client, discoveryClient, "current", 1*time.Hour)
+	require.NoError(t, err)
+	sort.Strings(deleted)
+	require.Equal(t, []string{
+		"configmaps default/old-release-consul-server-config",
+		"namespaces /old-namespace",
+		"persistentvolumeclaims default/data-default-old-release-consul-server-0",
+		"secrets default/sh.helm.release.v1.old-release.v1",
+		"serviceaccounts default/old-other-run",
+		"servicedefaults default/old-defaults",
+	}, deleted)
+
+	_, err = client.Resource(secrets).Namespace("default").Get(context.Background(), "sh.helm.release.v1.live-release.v1", metav1.GetOptions{})
+	require.NoError(t, err, "the records of releases without orphaned resources should not be deleted")
+
+	_, err = client.Resource(crds).Get(context.Background(), "servicedefaults.consul.hashicorp.com", metav1.GetOptions{})
+	require.NoError(t, err, "custom resource definitions should never be deleted")
+
+	remaining, err := client.Resource(serviceAccounts).List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, obj := range remaining.Items {
+		names = append(names, obj.GetName())
+	}
+	sort.Strings(names)
+	require.Equal(t, []string{"old-this-run", "recent-other-run", "unlabeled"}, names)
+}
+
+func TestDeletableResources(t *testing.T) {
+	verbs := metav1.Verbs{"list", "delete"}
+	gvrs := deletableResources([]*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "namespaces", Verbs: verbs},
+				{Name: "pods", Verbs: verbs},
+				{Name: "pods/log", Verbs: metav1.Verbs{"get"}},
+				{Name: "componentstatuses", Verbs: metav1.Verbs{"list"}},
+				{Name: "events", Verbs: verbs},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{{Name: "deployments", Verbs: verbs}},
+		},
+	})
+	require.Equal(t, []schema.GroupVersionResource{
+		{Version: "v1", Resource: "pods"},
+		{Group: "apps", Version: "v1", Resource: "deployments"},
+		{Version: "v1", Resource: "namespaces"},
+	}, gvrs)
+}
+
+func TestIsOrphan(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	obj := &metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))}
+	require.True(t, isOrphan(obj, time.Hour, now), "objects without a timestamp label should use their creation timestamp")
+
+	obj.Labels = map[string]string{TimestampLabel: strconv.FormatInt(now.Add(-30*time.Minute).Unix(), 10)}
+	require.False(t, isOrphan(obj, time.Hour, now), "objects updated recently by a test aren't orphans")
+
+	obj.Labels[TimestampLabel] = "invalid"
+	require.True(t, isOrphan(obj, time.Hour, now))
+}
+
+func testObject(apiVersion, kind, namespace, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(labels)
+	return obj
+}
@@ -0,0 +1,198 @@
+// Package testlabels labels the Kubernetes resources that tests create
+// with the test that created them, the test run and the time they were
+// created, so that resources left behind by previous test runs, e.g. because
+// a CI job was cancelled before the tests cleaned up, can be found and
+// deleted with CleanupOrphans. This keeps long-lived clusters that are
+// shared between CI runs from filling up with leftovers.
+package testlabels
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// TestNameLabel is the name of the test that created the resource.
+	// Characters that aren't allowed in label values are replaced with dashes.
+	TestNameLabel = "test-name"
+	// TestRunIDLabel is the ID of the test run that created the resource.
+	TestRunIDLabel = "test-run-id"
+	// TimestampLabel is the time, in Unix seconds, when a test last
+	// created or updated the resource.
+	TimestampLabel = "test-timestamp"
+)
+
+// maxValueLength is the maximum length of a label value.
+const maxValueLength = 63
+
+var (
+	mu    sync.RWMutex
+	runID string
+)
+
+// invalidValueChars matches the characters that aren't allowed in label values.
+var invalidValueChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// validValue matches valid label values.
+var validValue = regexp.MustCompile(`^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$`)
+
+// SetRunID sets the ID of the test run that resources are labeled with.
+// If it's empty, resources are not labeled.
+func SetRunID(id string) {
+	mu.Lock()
+	defer mu.Unlock()
+	runID = id
+}
+
+// RunID returns the ID of the test run that resources are labeled with,
+// or an empty string if resources are not labeled.
+func RunID() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return runID
+}
+
+// NewRunID returns a random test run ID.
+func NewRunID() string {
+	return strings.ToLower(random.UniqueId())
+}
+
+// ValidateRunID returns an error if id can't be used as a label value.
+func ValidateRunID(id string) error {
+	if len(id) > maxValueLength || !validValue.MatchString(id) {
+		return fmt.Errorf("%q must be at most %d alphanumeric characters, dashes, underscores or dots "+
+			"and start and end with an alphanumeric character", id, maxValueLength)
+	}
+	return nil
+}
+
+// ForTest returns the labels for resources that t creates now,
+// or nil if the test run ID isn't set.
+func ForTest(t *testing.T) map[string]string {
+	return forTest(t.Name(), RunID(), time.Now())
+}
+
+func forTest(testName, runID string, now time.Time) map[string]string {
+	if runID == "" {
+		return nil
+	}
+	return map[string]string{
+		TestNameLabel:  labelValue(testName),
+		TestRunIDLabel: runID,
+		TimestampLabel: strconv.FormatInt(now.Unix(), 10),
+	}
+}
+
+// RefreshTimestamp sets the timestamp label in labels, if it's there,
+// to the current time. Tests that keep the labels of resources they created,
+// e.g. to upgrade a Helm release, call it before updating the resources
+// so that the resources aren't mistaken for orphans.
+func RefreshTimestamp(labels map[string]string) {
+	refreshTimestamp(labels, time.Now())
+}
+
+func refreshTimestamp(labels map[string]string, now time.Time) {
+	if _, ok := labels[TimestampLabel]; ok {
+		labels[TimestampLabel] = strconv.FormatInt(now.Unix(), 10)
+	}
+}
+
+// Merge adds labels to existing, allocating it if it's nil,
+// and returns it, e.g. to label the ObjectMeta of an object.
+func Merge(existing, labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return existing
+	}
+	if existing == nil {
+		existing = make(map[string]string, len(labels))
+	}
+	for k, v := range labels {
+		existing[k] = v
+	}
+	return existing
+}
+
+// AddToManifest adds labels to the metadata of every object in the
+// multi-document YAML manifest, including the items of lists.
+// It returns manifest unchanged if there are no labels.
+func AddToManifest(manifest []byte, labels map[string]string) ([]byte, error) {
+	if len(labels) == 0 {
+		return manifest, nil
+	}
+
+	var docs []string
+	for _, doc := range splitDocuments(string(manifest)) {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, err
+		}
+		// Skip empty documents, e.g. ones that only contain comments.
+		if obj == nil {
+			continue
+		}
+		addLabels(obj, labels)
+		if items, ok := obj["items"].([]interface{}); ok {
+			for _, item := range items {
+				if itemObj, ok := item.(map[string]interface{}); ok {
+					addLabels(itemObj, labels)
+				}
+			}
+		}
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, string(out))
+	}
+	return []byte(strings.Join(docs, "---\n")), nil
+}
+
+// addLabels adds labels to the metadata of obj.
+func addLabels(obj map[string]interface{}, labels map[string]string) {
+	metadata, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		obj["metadata"] = metadata
+	}
+	objLabels, ok := metadata["labels"].(map[string]interface{})
+	if !ok {
+		objLabels = map[string]interface{}{}
+		metadata["labels"] = objLabels
+	}
+	for k, v := range labels {
+		objLabels[k] = v
+	}
+}
+
+// splitDocuments splits a YAML stream into its documents.
+func splitDocuments(manifest string) []string {
+	var docs []string
+	var doc strings.Builder
+	for _, line := range strings.SplitAfter(manifest, "\n") {
+		if strings.HasPrefix(line, "---") {
+			docs = append(docs, doc.String())
+			doc.Reset()
+			continue
+		}
+		doc.WriteString(line)
+	}
+	return append(docs, doc.String())
+}
+
+// labelValue turns s into a valid label value by replacing invalid characters
+// with dashes, truncating it and trimming characters that can't start or end it.
+func labelValue(s string) string {
+	s = invalidValueChars.ReplaceAllString(s, "-")
+	if len(s) > maxValueLength {
+		s = s[:maxValueLength]
+	}
+	return strings.Trim(s, "-_.")
+}
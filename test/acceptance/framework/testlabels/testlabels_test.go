@@ -0,0 +1,129 @@
+package testlabels
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForTest(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	require.Nil(t, forTest("TestFoo", "", now), "resources shouldn't be labeled without a run ID")
+	require.Equal(t, map[string]string{
+		TestNameLabel:  "TestController-secure-_true-_auto-encrypt-_false",
+		TestRunIDLabel: "abc123",
+		TimestampLabel: "1600000000",
+	}, forTest("TestController/secure:_true;_auto-encrypt:_false", "abc123", now))
+}
+
+func TestRefreshTimestamp(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	labels := forTest("TestFoo", "abc123", now.Add(-time.Hour))
+	refreshTimestamp(labels, now)
+	require.Equal(t, forTest("TestFoo", "abc123", now), labels)
+
+	// Resources that aren't labeled stay unlabeled.
+	refreshTimestamp(nil, now)
+}
+
+func TestLabelValue(t *testing.T) {
+	cases := map[string]string{
+		"TestFoo":            "TestFoo",
+		"TestFoo/bar:_baz":   "TestFoo-bar-_baz",
+		"TestFoo/(parens)":   "TestFoo--parens",
+		"_leading/trailing/": "leading-trailing",
+		"TestAVeryLongTestNameThatIsLongerThanSixtyThreeCharacters/subtest": "TestAVeryLongTestNameThatIsLongerThanSixtyThreeCharacters-subte",
+	}
+	for name, exp := range cases {
+		value := labelValue(name)
+		require.Equal(t, exp, value)
+		require.NoError(t, ValidateRunID(value), "labelValue should return valid label values")
+	}
+}
+
+func TestValidateRunID(t *testing.T) {
+	require.NoError(t, ValidateRunID("abc123"))
+	require.NoError(t, ValidateRunID("ci-build_42.1"))
+	require.Error(t, ValidateRunID("-abc"))
+	require.Error(t, ValidateRunID("abc/def"))
+	require.Error(t, ValidateRunID("a234567890123456789012345678901234567890123456789012345678901234"))
+}
+
+func TestMerge(t *testing.T) {
+	labels := map[string]string{TestRunIDLabel: "abc123"}
+	require.Equal(t, labels, Merge(nil, labels))
+	require.Equal(t, map[string]string{"app": "foo", TestRunIDLabel: "abc123"}, Merge(map[string]string{"app": "foo"}, labels))
+	require.Nil(t, Merge(nil, nil))
+}
+
+func TestAddToManifest(t *testing.T) {
+	manifest := `# A comment before the first document.
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: static-client
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: static-client
+  labels:
+    app: static-client
+spec:
+  template:
+    metadata:
+      labels:
+        app: static-client
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: static-server
+`
+	labels := map[string]string{TestRunIDLabel: "abc123"}
+
+	out, err := AddToManifest([]byte(manifest), labels)
+	require.NoError(t, err)
+	require.Equal(t, `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  labels:
+    test-run-id: abc123
+  name: static-client
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: static-client
+    test-run-id: abc123
+  name: static-client
+spec:
+  template:
+    metadata:
+      labels:
+        app: static-client
+---
+apiVersion: v1
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    labels:
+      test-run-id: abc123
+    name: static-server
+kind: List
+metadata:
+  labels:
+    test-run-id: abc123
+`, string(out))
+
+	out, err = AddToManifest([]byte(manifest), nil)
+	require.NoError(t, err)
+	require.Equal(t, manifest, string(out), "manifests shouldn't be changed without labels")
+}
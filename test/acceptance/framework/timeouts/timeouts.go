@@ -0,0 +1,97 @@
+// Package timeouts holds the durations that tests and framework helpers wait
+// for asynchronous operations, such as pods becoming ready or the controller
+// syncing custom resources, to complete.
+//
+// The durations are set once from flags when the test suite is created so that
+// slow environments can raise them without editing each test.
+// The time to wait for Helm installs is configured separately
+// with the -helm-install-timeout flag.
+package timeouts
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Timeouts holds the durations to wait for asynchronous operations to complete.
+type Timeouts struct {
+	// PodsReady is the time to wait for pods and deployments to become ready.
+	PodsReady time.Duration
+	// WebhookReady is the time to wait for webhooks, such as the controller's
+	// validating webhook, to start serving requests.
+	WebhookReady time.Duration
+	// ControllerSync is the time to wait for the controller to sync
+	// custom resources to Consul. On startup, the controller can take upwards
+	// of 1m to perform leader election, so this needs to account for that.
+	ControllerSync time.Duration
+	// TrafficCheck is the time to wait for a connection between services
+	// to succeed or fail as expected.
+	TrafficCheck time.Duration
+}
+
+// Defaults returns the default timeouts.
+func Defaults() Timeouts {
+	return Timeouts{
+		PodsReady:      5 * time.Minute,
+		WebhookReady:   1 * time.Minute,
+		ControllerSync: 1 * time.Minute,
+		TrafficCheck:   20 * time.Second,
+	}
+}
+
+// Validate returns an error if any of the timeouts is not positive.
+func (t Timeouts) Validate() error {
+	for name, d := range map[string]time.Duration{
+		"pods-ready":      t.PodsReady,
+		"webhook-ready":   t.WebhookReady,
+		"controller-sync": t.ControllerSync,
+		"traffic-check":   t.TrafficCheck,
+	} {
+		if d <= 0 {
+			return fmt.Errorf("-timeout-%s must be positive, got %s", name, d)
+		}
+	}
+	return nil
+}
+
+var (
+	mu      sync.RWMutex
+	current = Defaults()
+)
+
+// Set sets the timeouts used by all tests.
+func Set(t Timeouts) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = t
+}
+
+// Get returns the timeouts used by all tests.
+func Get() Timeouts {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// PodsReady returns the time to wait for pods and deployments to become ready.
+func PodsReady() time.Duration {
+	return Get().PodsReady
+}
+
+// WebhookReady returns the time to wait for webhooks to start serving requests.
+func WebhookReady() time.Duration {
+	return Get().WebhookReady
+}
+
+// ControllerSync returns the time to wait for the controller
+// to sync custom resources to Consul.
+func ControllerSync() time.Duration {
+	return Get().ControllerSync
+}
+
+// TrafficCheck returns the time to wait for a connection between services
+// to succeed or fail as expected.
+func TrafficCheck() time.Duration {
+	return Get().TrafficCheck
+}
@@ -0,0 +1,67 @@
+package timeouts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeouts_Validate(t *testing.T) {
+	cases := map[string]struct {
+		modify   func(*Timeouts)
+		expError string
+	}{
+		"defaults": {
+			modify: func(*Timeouts) {},
+		},
+		"zero pods-ready": {
+			modify:   func(t *Timeouts) { t.PodsReady = 0 },
+			expError: "-timeout-pods-ready must be positive, got 0s",
+		},
+		"negative webhook-ready": {
+			modify:   func(t *Timeouts) { t.WebhookReady = -time.Second },
+			expError: "-timeout-webhook-ready must be positive, got -1s",
+		},
+		"zero controller-sync": {
+			modify:   func(t *Timeouts) { t.ControllerSync = 0 },
+			expError: "-timeout-controller-sync must be positive, got 0s",
+		},
+		"zero traffic-check": {
+			modify:   func(t *Timeouts) { t.TrafficCheck = 0 },
+			expError: "-timeout-traffic-check must be positive, got 0s",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			timeouts := Defaults()
+			c.modify(&timeouts)
+
+			err := timeouts.Validate()
+			if c.expError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expError)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	t.Cleanup(func() { Set(Defaults()) })
+
+	require.Equal(t, 5*time.Minute, PodsReady())
+
+	Set(Timeouts{
+		PodsReady:      10 * time.Minute,
+		WebhookReady:   2 * time.Minute,
+		ControllerSync: 3 * time.Minute,
+		TrafficCheck:   time.Minute,
+	})
+
+	require.Equal(t, 10*time.Minute, PodsReady())
+	require.Equal(t, 2*time.Minute, WebhookReady())
+	require.Equal(t, 3*time.Minute, ControllerSync())
+	require.Equal(t, time.Minute, TrafficCheck())
+}
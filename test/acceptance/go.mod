@@ -6,11 +6,17 @@ require (
 	github.com/gruntwork-io/terratest v0.31.0
 	github.com/hashicorp/consul/api v1.4.1-0.20201015173526-812fe06d6c64
 	github.com/hashicorp/consul/sdk v0.6.0
-	github.com/stretchr/testify v1.5.1
+	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.5
+	github.com/stretchr/testify v1.6.1
 	gopkg.in/yaml.v2 v2.2.8
-	k8s.io/api v0.19.3
-	k8s.io/apimachinery v0.19.3
-	k8s.io/client-go v0.19.3
+	helm.sh/helm/v3 v3.4.2
+	k8s.io/api v0.19.4
+	k8s.io/apimachinery v0.19.4
+	k8s.io/cli-runtime v0.19.4
+	k8s.io/client-go v0.19.4
+	k8s.io/kubectl v0.19.4
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace github.com/gruntwork-io/terratest => github.com/ndhanushkodi/terratest v0.31.1-0.20201209054802-bd90ebf8ddad
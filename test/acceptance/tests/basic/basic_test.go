@@ -43,7 +43,11 @@ func TestBasicInstallation(t *testing.T) {
 				"global.tls.enabled":           strconv.FormatBool(c.secure),
 				"global.tls.enableAutoEncrypt": strconv.FormatBool(c.autoEncrypt),
 			}
-			consulCluster := consul.NewHelmCluster(t, helmValues, suite.Environment().DefaultContext(t), suite.Config(), releaseName)
+			ctx := suite.Environment().DefaultContext(t)
+			// Install each case into a namespace of its own so that
+			// the leftovers of a case, e.g. the PVCs of its servers,
+			// can't affect the next one.
+			consulCluster := consul.NewHelmClusterInNamespace(t, helmValues, ctx, suite.Config(), releaseName, helpers.RandomName())
 
 			consulCluster.Create(t)
 
@@ -63,6 +67,15 @@ func TestBasicInstallation(t *testing.T) {
 			kv, _, err := client.KV().Get(randomKey, nil)
 			require.NoError(t, err)
 			require.Equal(t, kv.Value, randomValue)
+
+			// The CLI in the server pods is configured by the chart
+			// and uses the bootstrap token if ACLs are enabled.
+			logger.Log(t, "checking that the servers are members of the cluster with the consul CLI")
+			serverPods := consulCluster.ServerPods(t)
+			members := consul.ExecCLI(t, ctx, serverPods[0], "members")
+			for _, pod := range serverPods {
+				require.Contains(t, members, pod.Name)
+			}
 		})
 	}
 }
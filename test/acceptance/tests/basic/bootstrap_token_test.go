@@ -0,0 +1,104 @@
+package basic
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// anonymousTokenAccessorID is the accessor ID of the built-in anonymous token.
+const anonymousTokenAccessorID = "00000000-0000-0000-0000-000000000002"
+
+// Test that when global.acls.bootstrapToken points at a secret with an
+// existing management token, server-acl-init uses that token to create
+// the tokens of the other components instead of bootstrapping ACLs itself.
+func TestACLBootstrapTokenFromSecret(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	// The servers accept the token as their master token
+	// so that it's a management token before server-acl-init runs.
+	bootstrapToken := consul.GenerateACLToken(t)
+	serverExtraConfig := fmt.Sprintf(`{"acl": {"tokens": {"master": %q}}}`, bootstrapToken)
+
+	releaseName := helpers.RandomName()
+	helmValues := map[string]string{
+		"global.acls.manageSystemACLs": "true",
+		"global.tls.enabled":           "true",
+		"connectInject.enabled":        "true",
+		"syncCatalog.enabled":          "true",
+	}
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName,
+		consul.WithBootstrapToken(bootstrapToken),
+		consul.WithValues(map[string]interface{}{
+			"server": map[string]interface{}{"extraConfig": serverExtraConfig},
+		}))
+
+	consulCluster.Create(t)
+
+	logger.Log(t, "checking that server-acl-init didn't bootstrap ACLs")
+	_, err := k8s.GetSecretE(t, ctx.KubectlOptions(t), releaseName+"-consul-bootstrap-acl-token")
+	require.True(t, errors.IsNotFound(err), "bootstrap token secret should not have been created")
+
+	consulClient := consulCluster.SetupConsulClient(t, true)
+
+	logger.Log(t, "checking that the provided token is the only management token")
+	self, _, err := consulClient.ACL().TokenReadSelf(nil)
+	require.NoError(t, err)
+	require.Equal(t, bootstrapToken, self.SecretID)
+
+	tokens, _, err := consulClient.ACL().TokenList(nil)
+	require.NoError(t, err)
+	tokensByAccessorID := make(map[string]*api.ACLTokenListEntry)
+	for _, token := range tokens {
+		tokensByAccessorID[token.AccessorID] = token
+		if token.AccessorID == self.AccessorID || token.AccessorID == anonymousTokenAccessorID {
+			continue
+		}
+		for _, policy := range token.Policies {
+			require.NotEqual(t, consul.GlobalManagementPolicyID, policy.ID, "token %q is a management token", token.Description)
+		}
+	}
+
+	logger.Log(t, "checking that the component tokens have been created")
+	for _, component := range []string{"client", "catalog-sync"} {
+		secretName := fmt.Sprintf("%s-consul-%s-acl-token", releaseName, component)
+		secret := k8s.WaitForSecret(t, ctx.KubectlOptions(t), secretName, "token")
+		componentToken, _, err := consulClient.ACL().TokenReadSelf(&api.QueryOptions{Token: string(secret.Data["token"])})
+		require.NoError(t, err)
+		token, ok := tokensByAccessorID[componentToken.AccessorID]
+		require.True(t, ok, "token in secret %s is not in the token list", secretName)
+		require.NotEmpty(t, token.Policies, "token in secret %s has no policies", secretName)
+	}
+
+	authMethods, _, err := consulClient.ACL().AuthMethodList(nil)
+	require.NoError(t, err)
+	var kubernetesAuthMethods []string
+	for _, authMethod := range authMethods {
+		if authMethod.Type == "kubernetes" {
+			kubernetesAuthMethods = append(kubernetesAuthMethods, authMethod.Name)
+		}
+	}
+	require.Len(t, kubernetesAuthMethods, 1, "expected the auth method for connect injection to be created")
+
+	// The clients can only register their nodes with their ACL tokens.
+	logger.Log(t, "checking that the clients registered their nodes using their tokens")
+	clientPods := consulCluster.ClientPods(t)
+	require.NotEmpty(t, clientPods, "no client pods found")
+	helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+		for _, pod := range clientPods {
+			node, _, err := consulClient.Catalog().Node(pod.Spec.NodeName, nil)
+			require.NoError(r, err)
+			require.NotNil(r, node, "client node %s is not registered", pod.Spec.NodeName)
+		}
+	})
+}
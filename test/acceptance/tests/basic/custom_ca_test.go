@@ -0,0 +1,79 @@
+package basic
+
+import (
+	"crypto/tls"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const customCACommonName = "Consul Acceptance Test CA"
+
+// Test that when a CA is provided via the global.tls.caCert and global.tls.caKey
+// values, the chart doesn't generate its own CA and the HTTPS certificates
+// of both servers and clients are signed by the provided CA.
+func TestCustomCA(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	ca := consul.GenerateCA(t, customCACommonName)
+
+	helmValues := map[string]string{
+		"global.tls.enabled": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName, consul.WithCustomCA(ca))
+
+	consulCluster.Create(t)
+
+	// The chart should only generate a CA if one isn't provided.
+	_, err := ctx.KubernetesClient(t).CoreV1().Secrets(ctx.KubectlOptions(t).Namespace).Get(helpers.TestContext(t), releaseName+"-consul-ca-cert", metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err), "expected the auto-generated CA secret not to exist")
+
+	pods := append(consulCluster.ServerPods(t), consulCluster.ClientPods(t)...)
+	require.NotEmpty(t, pods)
+	for _, pod := range pods {
+		requireCertSignedByCA(t, ctx, pod.Name, ca)
+	}
+
+	// Check that the servers are functional over HTTPS.
+	consulClient := consulCluster.SetupConsulClient(t, true)
+	leader, err := consulClient.Status().Leader()
+	require.NoError(t, err)
+	require.NotEmpty(t, leader)
+}
+
+// requireCertSignedByCA port-forwards to the HTTPS port of the Consul agent
+// running in the pod and checks that the certificate it presents
+// is valid for localhost and signed by the provided CA.
+func requireCertSignedByCA(t *testing.T, ctx environment.TestContext, podName string, ca *consul.CA) {
+	t.Helper()
+
+	endpoint := k8s.PortForward(t, ctx.KubectlOptions(t), terratestk8s.ResourceTypePod, podName, 8501)
+
+	logger.Logf(t, "verifying the TLS certificate of pod %s", podName)
+	retry.Run(t, func(r *retry.R) {
+		// Agent certificates generated by 'consul tls cert create'
+		// always include localhost as a DNS SAN.
+		conn, err := tls.Dial("tcp", endpoint, &tls.Config{
+			RootCAs:    ca.CertPool(t),
+			ServerName: "localhost",
+		})
+		require.NoError(r, err)
+		defer conn.Close()
+
+		peerCerts := conn.ConnectionState().PeerCertificates
+		require.NotEmpty(r, peerCerts)
+		require.Equal(r, customCACommonName, peerCerts[0].Issuer.CommonName)
+	})
+}
@@ -0,0 +1,77 @@
+package basic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/stretchr/testify/require"
+)
+
+// extraConfig is passed as server.extraConfig and client.extraConfig.
+// The node_meta values check that the chart renders the extra config
+// with tpl and doesn't mangle quotes and other characters that need escaping.
+const extraConfig = `{
+  "node_meta": {
+    "release": "{{ .Release.Name }}",
+    "escaped": "say \"hello\" & <wave>"
+  },
+  "telemetry": {
+    "disable_hostname": true,
+    "metrics_prefix": "extraconfig"
+  },
+  "limits": {
+    "http_max_conns_per_client": 250
+  }
+}`
+
+// Test that server.extraConfig and client.extraConfig end up in the
+// configuration of every server and client agent. The multi-line values
+// are passed like helm's --set-file, the server's from a string and
+// the client's from a file, rather than escaped in --set strings.
+func TestExtraConfig(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	dir, err := ioutil.TempDir("", "extra-config")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	clientExtraConfig := filepath.Join(dir, "client.json")
+	require.NoError(t, ioutil.WriteFile(clientExtraConfig, []byte(extraConfig), 0644))
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, nil, ctx, cfg, releaseName,
+		consul.WithFileValue("server.extraConfig", extraConfig),
+		consul.WithSetFile("client.extraConfig", clientExtraConfig))
+
+	consulCluster.Create(t)
+
+	agents := append(consulCluster.ServerPods(t), consulCluster.ClientPods(t)...)
+	for _, pod := range agents {
+		logger.Logf(t, "checking the configuration of agent %s", pod.Name)
+		requireExtraConfig(t, consul.AgentSelf(t, ctx, pod), releaseName)
+	}
+}
+
+// requireExtraConfig checks that the response of /v1/agent/self
+// contains the settings from extraConfig.
+func requireExtraConfig(t *testing.T, self map[string]map[string]interface{}, releaseName string) {
+	t.Helper()
+
+	require.Equal(t, releaseName, self["Meta"]["release"])
+	require.Equal(t, `say "hello" & <wave>`, self["Meta"]["escaped"])
+
+	debugConfig := self["DebugConfig"]
+	require.NotNil(t, debugConfig, "agent/self response has no DebugConfig")
+	telemetry, ok := debugConfig["Telemetry"].(map[string]interface{})
+	require.True(t, ok, "DebugConfig has no Telemetry: %v", debugConfig["Telemetry"])
+	require.Equal(t, true, telemetry["DisableHostname"])
+	require.Equal(t, "extraconfig", telemetry["MetricsPrefix"])
+	require.Equal(t, float64(250), debugConfig["HTTPMaxConnsPerClient"])
+}
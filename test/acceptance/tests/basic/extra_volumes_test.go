@@ -0,0 +1,85 @@
+package basic
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const extraVolumeServiceName = "extra-volume-service"
+
+// extraVolumeConfig is the agent config in the ConfigMap that is mounted
+// with server.extraVolumes and client.extraVolumes. Consul only loads
+// files with a .json or .hcl extension from a config directory,
+// so it's stored under a key with a .json extension.
+const extraVolumeConfig = `{
+  "services": [
+    {
+      "name": "extra-volume-service",
+      "port": 9090,
+      "tags": ["extra-volume"]
+    }
+  ],
+  "telemetry": {
+    "metrics_prefix": "extravolume"
+  }
+}`
+
+// Test that a ConfigMap mounted with server.extraVolumes and
+// client.extraVolumes with load set to true is added to the config
+// directories of the agents, so that they register the service
+// and use the telemetry settings defined in it.
+func TestExtraVolumes(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	releaseName := helpers.RandomName()
+	configMapName := fmt.Sprintf("%s-extra-config", releaseName)
+	createConfigMap := func(t *testing.T, cluster *consul.HelmCluster) {
+		namespace := cluster.KubectlOptions().Namespace
+		logger.Logf(t, "creating config map %s", configMapName)
+		_, err := ctx.KubernetesClient(t).CoreV1().ConfigMaps(namespace).Create(helpers.TestContext(t), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: configMapName},
+			Data:       map[string]string{"extra.json": extraVolumeConfig},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+		helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+			ctx.KubernetesClient(t).CoreV1().ConfigMaps(namespace).Delete(helpers.TestContext(t), configMapName, metav1.DeleteOptions{})
+		})
+	}
+
+	helmValues := map[string]string{}
+	for _, component := range []string{"server", "client"} {
+		helmValues[component+".extraVolumes[0].type"] = "configMap"
+		helmValues[component+".extraVolumes[0].name"] = configMapName
+		helmValues[component+".extraVolumes[0].load"] = "true"
+	}
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName, consul.WithPreInstall(createConfigMap))
+
+	consulCluster.Create(t)
+
+	agents := append(consulCluster.ServerPods(t), consulCluster.ClientPods(t)...)
+	for _, pod := range agents {
+		logger.Logf(t, "checking that agent %s loaded the config from the extra volume", pod.Name)
+		client := consul.AgentClient(t, ctx, pod)
+
+		services, err := client.Agent().Services()
+		require.NoError(t, err)
+		service, ok := services[extraVolumeServiceName]
+		require.True(t, ok, "agent %s has not registered service %s", pod.Name, extraVolumeServiceName)
+		require.Equal(t, 9090, service.Port)
+		require.Equal(t, []string{"extra-volume"}, service.Tags)
+
+		self, err := client.Agent().Self()
+		require.NoError(t, err)
+		telemetry, ok := self["DebugConfig"]["Telemetry"].(map[string]interface{})
+		require.True(t, ok, "DebugConfig has no Telemetry: %v", self["DebugConfig"]["Telemetry"])
+		require.Equal(t, "extravolume", telemetry["MetricsPrefix"])
+	}
+}
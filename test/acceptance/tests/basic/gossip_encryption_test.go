@@ -0,0 +1,99 @@
+package basic
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	gossipSecretName = "consul-gossip-encryption-key"
+	gossipSecretKey  = "key"
+
+	// serfStatusAlive is the status of a healthy gossip pool member.
+	serfStatusAlive = 1
+)
+
+// Test that an installation with gossip encryption has encryption enabled,
+// and that the gossip key can be rotated through the keyring API
+// without affecting the health of the cluster.
+func TestGossipEncryption(t *testing.T) {
+	cases := []struct {
+		secure bool
+	}{
+		{false},
+		{true},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("secure: %t", c.secure)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			gossipKey := consul.GenerateGossipKey(t)
+			secretName := fmt.Sprintf("%s-%s", helpers.RandomName(), gossipSecretName)
+			createGossipSecret := func(t *testing.T, cluster *consul.HelmCluster) {
+				k8s.CreateSecret(t, cluster.KubectlOptions(), cfg.NoCleanupOnFailure, cfg.NoCleanup, secretName, map[string]string{gossipSecretKey: gossipKey})
+			}
+
+			releaseName := helpers.RandomName()
+			helmValues := map[string]string{
+				"global.gossipEncryption.secretName": secretName,
+				"global.gossipEncryption.secretKey":  gossipSecretKey,
+
+				"global.acls.manageSystemACLs": strconv.FormatBool(c.secure),
+				"global.tls.enabled":           strconv.FormatBool(c.secure),
+			}
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName, consul.WithPreInstall(createGossipSecret))
+
+			consulCluster.Create(t)
+
+			client := consulCluster.SetupConsulClient(t, c.secure)
+
+			logger.Log(t, "checking that gossip encryption is enabled")
+			require.True(t, consul.GossipEncryptionEnabled(t, client), "gossip encryption is not enabled")
+			consul.RequireOnlyGossipKey(t, consul.ListGossipKeys(t, client), gossipKey)
+
+			newGossipKey := consul.GenerateGossipKey(t)
+			consul.RotateGossipKey(t, client, newGossipKey)
+
+			logger.Log(t, "checking that the new gossip key is in use and the cluster is healthy")
+			helpers.RetryEventually(t, 30*time.Second, func(r *retry.R) {
+				keyring, err := client.Operator().KeyringList(nil)
+				require.NoError(r, err)
+				for _, resp := range keyring {
+					if !resp.WAN {
+						consul.RequireOnlyGossipKey(r, resp, newGossipKey)
+					}
+				}
+
+				members, err := client.Agent().Members(false)
+				require.NoError(r, err)
+				for _, member := range members {
+					require.Equal(r, serfStatusAlive, member.Status, "member %s is not alive", member.Name)
+				}
+			})
+
+			randomKey := helpers.RandomName()
+			randomValue := []byte(helpers.RandomName())
+			logger.Logf(t, "creating KV entry with key %s", randomKey)
+			_, err := client.KV().Put(&api.KVPair{Key: randomKey, Value: randomValue}, nil)
+			require.NoError(t, err)
+
+			logger.Logf(t, "reading value for key %s", randomKey)
+			kv, _, err := client.KV().Get(randomKey, nil)
+			require.NoError(t, err)
+			require.Equal(t, randomValue, kv.Value)
+		})
+	}
+}
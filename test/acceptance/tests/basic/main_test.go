@@ -10,6 +10,6 @@ import (
 var suite testsuite.Suite
 
 func TestMain(m *testing.M) {
-	suite = testsuite.NewSuite(m)
+	suite = testsuite.NewSuite(m, testsuite.SmokeCases("TestBasicInstallation/secure: false, auto-encrypt: false"))
 	os.Exit(suite.Run())
 }
@@ -0,0 +1,87 @@
+package basic
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Test that the server certificates include the names and IP addresses from
+// global.tls.serverAdditionalDNSSANs and global.tls.serverAdditionalIPSANs.
+// These are needed when the servers are reached through a load balancer,
+// e.g. by servers in other datacenters when federating with WAN federation,
+// so clients connecting via the load balancer's name or IP address
+// can verify the servers' certificates.
+func TestServerAdditionalSANs(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	dnsSANs := []string{"consul.example.com", "*.consul.example.com"}
+	ipSANs := []string{"192.0.2.10", "2001:db8::10"}
+
+	helmValues := map[string]string{
+		"global.tls.enabled": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName,
+		consul.WithValues(map[string]interface{}{
+			"global": map[string]interface{}{
+				"tls": map[string]interface{}{
+					"serverAdditionalDNSSANs": dnsSANs,
+					"serverAdditionalIPSANs":  ipSANs,
+				},
+			},
+		}))
+
+	consulCluster.Create(t)
+
+	caSecret, err := ctx.KubernetesClient(t).CoreV1().Secrets(ctx.KubectlOptions(t).Namespace).Get(helpers.TestContext(t), releaseName+"-consul-ca-cert", metav1.GetOptions{})
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(caSecret.Data["tls.crt"]), "failed to parse the CA certificate")
+
+	serverPod := releaseName + "-consul-server-0"
+	endpoint := k8s.PortForward(t, ctx.KubectlOptions(t), terratestk8s.ResourceTypePod, serverPod, 8501)
+
+	logger.Logf(t, "verifying the additional SANs of the TLS certificate of pod %s", serverPod)
+	var serverCert *x509.Certificate
+	retry.Run(t, func(r *retry.R) {
+		// Connecting with the additional DNS name as the server name checks that
+		// clients using it can verify the certificate, not only that it's listed.
+		conn, err := tls.Dial("tcp", endpoint, &tls.Config{
+			RootCAs:    roots,
+			ServerName: "consul.example.com",
+		})
+		require.NoError(r, err)
+		defer conn.Close()
+
+		peerCerts := conn.ConnectionState().PeerCertificates
+		require.NotEmpty(r, peerCerts)
+		serverCert = peerCerts[0]
+	})
+
+	for _, dnsSAN := range dnsSANs {
+		require.Contains(t, serverCert.DNSNames, dnsSAN)
+	}
+	require.NoError(t, serverCert.VerifyHostname("server.consul.example.com"), "the wildcard DNS SAN should match subdomains")
+
+	var certIPs []string
+	for _, ip := range serverCert.IPAddresses {
+		certIPs = append(certIPs, ip.String())
+	}
+	for _, ipSAN := range ipSANs {
+		require.Contains(t, certIPs, net.ParseIP(ipSAN).String())
+		require.NoError(t, serverCert.VerifyHostname(ipSAN))
+	}
+}
@@ -0,0 +1,94 @@
+package basic
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const serverAffinityReplicas = 3
+
+// Test that the servers' pod anti-affinity spreads them across topology
+// domains: across nodes with the default server.affinity and across zones
+// with an affinity that uses the zone as the topology key.
+// Each case is skipped if the cluster doesn't have enough schedulable
+// nodes or zones for the servers, e.g. on single-node kind clusters.
+func TestServerAffinity(t *testing.T) {
+	cases := []struct {
+		name        string
+		topologyKey string
+		// zoneAffinity sets server.affinity to spread the servers
+		// across zones instead of using the chart's default.
+		zoneAffinity bool
+	}{
+		{"default affinity", k8s.TopologyKeyHostname, false},
+		{"zone affinity", k8s.TopologyKeyZone, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			domains := k8s.NodesByTopology(t, ctx.KubectlOptions(t), c.topologyKey)
+			// Nodes without the label aren't in any domain.
+			delete(domains, "")
+			if len(domains) < serverAffinityReplicas {
+				t.Skipf("skipping this test because it needs schedulable nodes in at least %d different %s domains, found %d",
+					serverAffinityReplicas, c.topologyKey, len(domains))
+			}
+
+			releaseName := helpers.RandomName()
+			helmValues := map[string]string{
+				"server.replicas":        fmt.Sprint(serverAffinityReplicas),
+				"server.bootstrapExpect": fmt.Sprint(serverAffinityReplicas),
+			}
+			if c.zoneAffinity {
+				helmValues["server.affinity"] = zoneAntiAffinity(releaseName)
+			}
+
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+
+			servers := consulCluster.ServerPods(t)
+			require.Len(t, servers, serverAffinityReplicas)
+
+			logger.Logf(t, "checking that the servers have a required anti-affinity with topology key %s", c.topologyKey)
+			for _, pod := range servers {
+				require.True(t, k8s.HasRequiredPodAntiAffinity(pod, c.topologyKey),
+					"server pod %s has no required anti-affinity with topology key %s", pod.Name, c.topologyKey)
+			}
+
+			logger.Logf(t, "checking that the servers are spread across %s domains", c.topologyKey)
+			k8s.RequirePodsSpread(t, ctx.KubectlOptions(t), servers, c.topologyKey)
+
+			client := consulCluster.SetupConsulClient(t, false)
+			helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+				consul.RequireRaftPeers(r, client, serverAffinityReplicas)
+			})
+		})
+	}
+}
+
+// zoneAntiAffinity returns a server.affinity that doesn't allow
+// two servers of the release to be scheduled in the same zone.
+// It doesn't use template functions like the chart's default
+// because braces and commas can't be passed with --set.
+func zoneAntiAffinity(releaseName string) string {
+	return fmt.Sprintf(`podAntiAffinity:
+  requiredDuringSchedulingIgnoredDuringExecution:
+    - labelSelector:
+        matchLabels:
+          release: %s
+          component: server
+      topologyKey: %s
+`, releaseName, k8s.TopologyKeyZone)
+}
@@ -0,0 +1,116 @@
+package basic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Test that single-server and odd-sized server topologies form a Raft
+// cluster with every server as a voter, and that upgrades with
+// server.updatePartition only roll the servers above the partition.
+func TestServerTopologies(t *testing.T) {
+	cases := []struct {
+		replicas int
+	}{
+		{1},
+		{5},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("replicas: %d", c.replicas)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"server.replicas":        strconv.Itoa(c.replicas),
+				"server.bootstrapExpect": strconv.Itoa(c.replicas),
+				// Allow scheduling servers on the same node so that
+				// the test can run against single-node clusters.
+				"server.affinity": "null",
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+
+			client := consulCluster.SetupConsulClient(t, false)
+
+			logger.Logf(t, "checking that there are %d raft peers", c.replicas)
+			helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+				consul.RequireRaftPeers(r, client, c.replicas)
+			})
+
+			randomKey := helpers.RandomName()
+			logger.Logf(t, "creating KV entry with key %s", randomKey)
+			_, err := client.KV().Put(&api.KVPair{Key: randomKey, Value: []byte(randomKey)}, nil)
+			require.NoError(t, err)
+
+			// Roll only the last server. With a single server the partition
+			// is 0, which doesn't set a partition, so the server is rolled.
+			partition := c.replicas - 1
+			logger.Logf(t, "upgrading servers with update partition %d", partition)
+			consulCluster.Upgrade(t, map[string]string{
+				"server.updatePartition":                    strconv.Itoa(partition),
+				"server.extraEnvironmentVars.TOPOLOGY_TEST": "upgraded",
+			})
+
+			statefulSetName := fmt.Sprintf("%s-consul-server", releaseName)
+			helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+				requireServersRolled(t, r, ctx.KubernetesClient(t), ctx.KubectlOptions(t).Namespace, statefulSetName, partition)
+			})
+
+			logger.Logf(t, "checking that there are still %d raft peers", c.replicas)
+			helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+				consul.RequireRaftPeers(r, client, c.replicas)
+			})
+
+			logger.Logf(t, "reading value for key %s", randomKey)
+			kv, _, err := client.KV().Get(randomKey, nil)
+			require.NoError(t, err)
+			require.NotNil(t, kv, "KV entry %s was lost", randomKey)
+			require.Equal(t, randomKey, string(kv.Value))
+		})
+	}
+}
+
+// requireServersRolled fails unless the server pods with an ordinal of at least
+// partition are ready and on the StatefulSet's update revision and the
+// rest are still on its current revision.
+func requireServersRolled(t *testing.T, r *retry.R, client kubernetes.Interface, namespace, statefulSetName string, partition int) {
+	statefulSet, err := client.AppsV1().StatefulSets(namespace).Get(helpers.TestContext(t), statefulSetName, metav1.GetOptions{})
+	require.NoError(r, err)
+
+	pods, err := client.CoreV1().Pods(namespace).List(helpers.TestContext(t), metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(statefulSet.Spec.Selector),
+	})
+	require.NoError(r, err)
+	require.Len(r, pods.Items, int(*statefulSet.Spec.Replicas))
+
+	for _, pod := range pods.Items {
+		ordinal, err := strconv.Atoi(pod.Name[strings.LastIndex(pod.Name, "-")+1:])
+		require.NoError(r, err)
+		require.True(r, helpers.IsReady(pod), "pod %s is not ready", pod.Name)
+
+		revision := pod.Labels[appsv1.StatefulSetRevisionLabel]
+		if ordinal >= partition {
+			require.Equal(r, statefulSet.Status.UpdateRevision, revision, "pod %s was not rolled", pod.Name)
+		} else {
+			require.NotEqual(r, statefulSet.Status.UpdateRevision, revision, "pod %s is below the partition but was rolled", pod.Name)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package clientpersistence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const staticClientName = "static-client"
+const staticServerName = "static-server"
+
+// appSelector selects the static-server and static-client pods.
+const appSelector = "app in (" + staticServerName + "," + staticClientName + ")"
+
+// Test that client agents with client.dataDirectoryHostPath store their data
+// on their node and keep it across restarts of the client daemonset.
+// When a client restarts, it keeps its node ID and restores the connect
+// services registered with it from its data directory, so the services
+// and their proxies come back with the same instances rather than
+// being registered again as new ones, and traffic between them works
+// without restarting the application pods.
+func TestClientDataDirectoryHostPath(t *testing.T) {
+	cases := []struct {
+		secure      bool
+		autoEncrypt bool
+	}{
+		{false, false},
+		{true, false},
+		{true, true},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("secure: %t; auto-encrypt: %t", c.secure, c.autoEncrypt)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			releaseName := helpers.RandomName()
+			// The path contains the release name so that agents don't
+			// pick up data left on the nodes by previous test runs.
+			hostPath := "/tmp/consul-" + releaseName
+			helmValues := map[string]string{
+				"connectInject.enabled":        "true",
+				"client.dataDirectoryHostPath": hostPath,
+				"global.tls.enabled":           strconv.FormatBool(c.secure),
+				"global.tls.enableAutoEncrypt": strconv.FormatBool(c.autoEncrypt),
+				"global.acls.manageSystemACLs": strconv.FormatBool(c.secure),
+			}
+
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			// The chart doesn't remove the data directories from the nodes when
+			// the release is deleted. This cleanup is registered before the install
+			// so that it runs once the clients are gone.
+			var clientImage string
+			clientNodes := make(map[string]bool)
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				for node := range clientNodes {
+					k8s.RemoveHostPath(t, ctx.KubectlOptions(t), node, clientImage, hostPath)
+				}
+			})
+
+			consulCluster.Create(t)
+
+			logger.Log(t, "checking that the client data directory is a hostPath volume")
+			clientPods := consulCluster.ClientPods(t)
+			require.NotEmpty(t, clientPods, "no client pods found")
+			for _, pod := range clientPods {
+				found := false
+				for _, volume := range pod.Spec.Volumes {
+					if volume.Name == "data" {
+						found = true
+						require.NotNil(t, volume.HostPath, "data volume of client pod %s is not a hostPath volume", pod.Name)
+						require.Equal(t, hostPath, volume.HostPath.Path)
+					}
+				}
+				require.True(t, found, "client pod %s has no data volume", pod.Name)
+				clientNodes[pod.Spec.NodeName] = true
+				clientImage = k8s.ContainerImage(pod, "consul")
+			}
+
+			logger.Log(t, "creating static-server and static-client deployments")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+			consulClient := consulCluster.SetupConsulClient(t, c.secure)
+			if c.secure {
+				logger.Log(t, "creating intention")
+				_, _, err := consulClient.Connect().IntentionCreate(&api.Intention{
+					SourceName:      staticClientName,
+					DestinationName: staticServerName,
+					Action:          api.IntentionActionAllow,
+				}, nil)
+				require.NoError(t, err)
+			}
+
+			logger.Log(t, "checking that connection is successful")
+			k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+			nodeIDsBefore := nodeIDs(t, consulClient, clientNodes)
+			instancesBefore := serviceInstances(t, consulClient)
+			appPodsBefore := k8s.PodRestarts(k8s.GetPods(t, ctx.KubectlOptions(t), appSelector))
+
+			k8s.RestartDaemonSet(t, ctx.KubectlOptions(t), releaseName+"-consul-client")
+
+			logger.Log(t, "checking that the clients kept their node IDs")
+			helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+				require.Equal(r, nodeIDsBefore, nodeIDs(r, consulClient, clientNodes))
+			})
+
+			logger.Log(t, "checking that the services and their proxies were restored with the same instances")
+			helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+				require.Equal(r, instancesBefore, serviceInstances(r, consulClient))
+			})
+
+			logger.Log(t, "checking that connection is successful after the clients restarted")
+			k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+			logger.Log(t, "checking that the application pods were not restarted")
+			require.Equal(t, appPodsBefore, k8s.PodRestarts(k8s.GetPods(t, ctx.KubectlOptions(t), appSelector)))
+		})
+	}
+}
+
+// nodeIDs returns the Consul node IDs of nodes, keyed by node name.
+// The client agents use the name of their Kubernetes node as their Consul node name.
+// It takes a require.TestingT so that it can be used with retry.R.
+func nodeIDs(r require.TestingT, client *api.Client, nodes map[string]bool) map[string]string {
+	catalogNodes, _, err := client.Catalog().Nodes(nil)
+	require.NoError(r, err)
+
+	ids := make(map[string]string)
+	for _, node := range catalogNodes {
+		if nodes[node.Node] {
+			ids[node.Node] = node.ID
+		}
+	}
+	require.Len(r, ids, len(nodes), "not all client nodes are registered in the catalog")
+	return ids
+}
+
+// serviceInstances returns the IDs of the healthy instances of the
+// static-server and static-client services and their proxies,
+// keyed by service name, in the form "<node>/<service ID>".
+// It takes a require.TestingT so that it can be used with retry.R.
+func serviceInstances(r require.TestingT, client *api.Client) map[string][]string {
+	instances := make(map[string][]string)
+	for _, service := range []string{staticServerName, staticClientName} {
+		for _, name := range []string{service, service + "-sidecar-proxy"} {
+			entries, _, err := client.Health().Service(name, "", true, nil)
+			require.NoError(r, err)
+			require.NotEmpty(r, entries, "service %s has no healthy instances", name)
+			var ids []string
+			for _, entry := range entries {
+				ids = append(ids, entry.Node.Node+"/"+entry.Service.ID)
+			}
+			sort.Strings(ids)
+			instances[name] = ids
+		}
+	}
+	return instances
+}
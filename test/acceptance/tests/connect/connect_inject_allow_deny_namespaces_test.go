@@ -0,0 +1,159 @@
+package connect
+
+import (
+	"fmt"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// injectStatusAnnotation is the annotation the connect injector
+// sets on the pods that it injects.
+const injectStatusAnnotation = "consul.hashicorp.com/connect-inject-status"
+
+// injectNamespaceLabel is the namespace label that the connect injector's
+// namespaceSelector matches on in the tests below.
+const injectNamespaceLabel = "connect-inject"
+
+// Test that the connect injector only injects pods in the Kubernetes namespaces
+// allowed by connectInject.k8sAllowNamespaces, connectInject.k8sDenyNamespaces
+// and connectInject.namespaceSelector. The same static-server deployment,
+// which requests injection, is deployed into an allowed and a denied namespace.
+// When mirroring is enabled, only the allowed namespace should be
+// mirrored into a Consul namespace.
+func TestConnectInject_AllowDenyNamespaces(t *testing.T) {
+	cases := []struct {
+		name string
+		// helmValues returns the Helm values for the names
+		// of the allowed and denied namespaces.
+		helmValues func(allowed, denied string) map[string]string
+		// labelAllowed is true if the allowed namespace needs
+		// to be labeled to match the namespace selector.
+		labelAllowed bool
+		mirrorK8S    bool
+	}{
+		{
+			name: "allow list",
+			helmValues: func(allowed, _ string) map[string]string {
+				return map[string]string{
+					"connectInject.k8sAllowNamespaces": fmt.Sprintf("{%s}", allowed),
+				}
+			},
+		},
+		{
+			name: "deny list takes precedence over wildcard allow list",
+			helmValues: func(_, denied string) map[string]string {
+				return map[string]string{
+					"connectInject.k8sAllowNamespaces": "{*}",
+					"connectInject.k8sDenyNamespaces":  fmt.Sprintf("{%s}", denied),
+				}
+			},
+		},
+		{
+			name: "namespace selector",
+			helmValues: func(_, _ string) map[string]string {
+				return map[string]string{
+					"connectInject.k8sAllowNamespaces": "{*}",
+					"connectInject.namespaceSelector":  fmt.Sprintf("matchLabels:\n  %s: enabled", injectNamespaceLabel),
+				}
+			},
+			labelAllowed: true,
+		},
+		{
+			name: "deny list with mirroring",
+			helmValues: func(_, denied string) map[string]string {
+				return map[string]string{
+					"connectInject.k8sAllowNamespaces": "{*}",
+					"connectInject.k8sDenyNamespaces":  fmt.Sprintf("{%s}", denied),
+				}
+			},
+			mirrorK8S: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := suite.Config()
+			if c.mirrorK8S && !cfg.EnableEnterprise {
+				t.Skipf("skipping this test because -enable-enterprise is not set")
+			}
+			ctx := suite.Environment().DefaultContext(t)
+
+			// The namespaces need to exist before installing
+			// so that their names can be set in the Helm values.
+			allowedOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+			deniedOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+			if c.labelAllowed {
+				k8s.RunKubectl(t, ctx.KubectlOptions(t), "label", "namespace", allowedOpts.Namespace, injectNamespaceLabel+"=enabled")
+			}
+
+			helmValues := c.helmValues(allowedOpts.Namespace, deniedOpts.Namespace)
+			helmValues["connectInject.enabled"] = "true"
+			if c.mirrorK8S {
+				helmValues["global.enableConsulNamespaces"] = "true"
+				helmValues["connectInject.consulNamespaces.mirroringK8S"] = "true"
+			}
+
+			// The Helm values include the random names of the namespaces,
+			// so no two cases could share a cluster from a TestSuite.
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+
+			logger.Log(t, "creating static-server deployments in the allowed and denied namespaces")
+			k8s.DeployKustomize(t, allowedOpts, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			k8s.DeployKustomize(t, deniedOpts, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+
+			logger.Log(t, "checking that only the pods in the allowed namespace are injected")
+			requireInjected(t, ctx, allowedOpts, true)
+			requireInjected(t, ctx, deniedOpts, false)
+
+			consulClient := consulCluster.SetupConsulClient(t, false)
+			var allowedQueryOpts *api.QueryOptions
+			if c.mirrorK8S {
+				namespaces, _, err := consulClient.Namespaces().List(nil)
+				require.NoError(t, err)
+				var names []string
+				for _, ns := range namespaces {
+					names = append(names, ns.Name)
+				}
+				require.Contains(t, names, allowedOpts.Namespace)
+				require.NotContains(t, names, deniedOpts.Namespace)
+
+				allowedQueryOpts = &api.QueryOptions{Namespace: allowedOpts.Namespace}
+			}
+
+			services, _, err := consulClient.Catalog().Service(staticServerName, "", allowedQueryOpts)
+			require.NoError(t, err)
+			require.Len(t, services, 1, "expected only the injected static-server to be registered")
+			require.Equal(t, allowedOpts.Namespace, services[0].ServiceMeta["k8s-namespace"])
+		})
+	}
+}
+
+// requireInjected checks whether the static-server pods
+// in the namespace of options were injected.
+func requireInjected(t *testing.T, ctx environment.TestContext, options *terratestk8s.KubectlOptions, injected bool) {
+	t.Helper()
+
+	pods, err := ctx.KubernetesClient(t).CoreV1().Pods(options.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "app=" + staticServerName})
+	require.NoError(t, err)
+	require.NotEmpty(t, pods.Items)
+	for _, pod := range pods.Items {
+		if injected {
+			require.Equal(t, "injected", pod.Annotations[injectStatusAnnotation], "pod %s/%s was not injected", pod.Namespace, pod.Name)
+		} else {
+			require.NotContains(t, pod.Annotations, injectStatusAnnotation, "pod %s/%s was injected", pod.Namespace, pod.Name)
+			require.Len(t, pod.Spec.Containers, 1, "pod %s/%s has sidecars", pod.Namespace, pod.Name)
+		}
+	}
+}
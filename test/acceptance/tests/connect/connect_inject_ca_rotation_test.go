@@ -0,0 +1,193 @@
+package connect
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/envoy"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Test that when the root of the Connect CA is rotated, client agents using
+// auto-encrypt and Envoy sidecars pick up certificates signed by the new root
+// without being restarted, and that traffic between services in the mesh
+// isn't interrupted while they do.
+func TestConnectInject_CARotation(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"connectInject.enabled":        "true",
+		"global.tls.enabled":           "true",
+		"global.tls.enableAutoEncrypt": "true",
+		"global.acls.manageSystemACLs": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	logger.Log(t, "creating static-server and static-client deployments")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+	consulClient := consulCluster.SetupConsulClient(t, true)
+
+	logger.Log(t, "creating intention")
+	_, _, err := consulClient.Connect().IntentionCreate(&api.Intention{
+		SourceName:      staticClientName,
+		DestinationName: staticServerName,
+		Action:          api.IntentionActionAllow,
+	}, nil)
+	require.NoError(t, err)
+
+	logger.Log(t, "checking that connection is successful")
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+	clientSelector := consulCluster.ComponentSelector(helpers.ComponentClient)
+	appSelectors := []string{"app=" + staticServerName, "app=" + staticClientName}
+	podsBefore := podRestarts(t, ctx.KubectlOptions(t), append(appSelectors, clientSelector)...)
+
+	leafSerials := make(map[string]string)
+	for _, pod := range podNames(t, ctx.KubectlOptions(t), appSelectors...) {
+		certs := envoy.NewAdmin(t, ctx.KubectlOptions(t), pod).Certs(t)
+		require.NotEmpty(t, certs)
+		require.NotEmpty(t, certs[0].CertChain)
+		leafSerials[pod] = certs[0].CertChain[0].SerialNumber
+	}
+
+	// If the rotation fails, the watcher has to be stopped before the test finishes as well.
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var wg sync.WaitGroup
+	stopWatching := func() {
+		stopOnce.Do(func() { close(stop) })
+		wg.Wait()
+	}
+	t.Cleanup(stopWatching)
+
+	downtime := make(chan time.Duration, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		downtime <- k8s.WatchStaticServerDowntime(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234", stop)
+	}()
+
+	newCA := consul.GenerateCA(t, "Consul Connect CA")
+	caCert := parseCertificate(t, newCA.CertPEM)
+	consul.RotateCA(t, consulClient, newCA)
+
+	for _, pod := range podNames(t, ctx.KubectlOptions(t), clientSelector) {
+		logger.Logf(t, "checking that client agent %s serves a certificate signed by the new root", pod)
+		endpoint := k8s.PortForward(t, ctx.KubectlOptions(t), terratestk8s.ResourceTypePod, pod, 8501)
+		helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+			require.NoError(r, verifyServerCertificate(endpoint, newCA.CertPool(t)))
+		})
+	}
+
+	for _, pod := range podNames(t, ctx.KubectlOptions(t), appSelectors...) {
+		logger.Logf(t, "checking that the sidecar of %s trusts the new root and has a new leaf certificate", pod)
+		admin := envoy.NewAdmin(t, ctx.KubectlOptions(t), pod)
+		helpers.RetryEventually(t, timeouts.TrafficCheck(), func(r *retry.R) {
+			certs := admin.Certs(r)
+			require.NotEmpty(r, certs)
+			require.True(r, hasSerialNumber(certs[0].CACert, caCert), "sidecar does not trust the new root")
+			require.NotEmpty(r, certs[0].CertChain)
+			require.NotEqual(r, leafSerials[pod], certs[0].CertChain[0].SerialNumber, "sidecar has not replaced its leaf certificate")
+		})
+	}
+
+	logger.Log(t, "checking that connection is successful after the rotation")
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+	stopWatching()
+	require.Zero(t, <-downtime, "connect traffic was interrupted during the CA rotation")
+
+	logger.Log(t, "checking that no pods were restarted or replaced")
+	require.Equal(t, podsBefore, podRestarts(t, ctx.KubectlOptions(t), append(appSelectors, clientSelector)...))
+}
+
+// podNames returns the names of the pods matching any of the label selectors.
+func podNames(t *testing.T, options *terratestk8s.KubectlOptions, labelSelectors ...string) []string {
+	t.Helper()
+
+	var names []string
+	for _, selector := range labelSelectors {
+		pods := k8s.GetPods(t, options, selector)
+		require.NotEmpty(t, pods, "no pods match %q", selector)
+		names = append(names, k8s.PodNames(pods)...)
+	}
+	return names
+}
+
+// podRestarts returns k8s.PodRestarts of the pods matching any of the label selectors.
+func podRestarts(t *testing.T, options *terratestk8s.KubectlOptions, labelSelectors ...string) map[string]int32 {
+	t.Helper()
+
+	var pods []corev1.Pod
+	for _, selector := range labelSelectors {
+		pods = append(pods, k8s.GetPods(t, options, selector)...)
+	}
+	return k8s.PodRestarts(pods)
+}
+
+// verifyServerCertificate connects to the TLS server at endpoint and returns
+// an error unless its certificate chains up to a root in roots. The hostname
+// isn't verified because the agent is reached through a port-forward.
+func verifyServerCertificate(endpoint string, roots *x509.CertPool) error {
+	conn, err := tls.Dial("tcp", endpoint, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	peerCerts := conn.ConnectionState().PeerCertificates
+	if len(peerCerts) == 0 {
+		return fmt.Errorf("%s did not present a certificate", endpoint)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range peerCerts[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = peerCerts[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+func parseCertificate(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block, "failed to decode certificate PEM")
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+// hasSerialNumber returns true if any of certs is cert.
+func hasSerialNumber(certs []envoy.CertificateDetails, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.HasSerialNumber(cert.SerialNumber) {
+			return true
+		}
+	}
+	return false
+}
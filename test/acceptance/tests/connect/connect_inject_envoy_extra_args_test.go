@@ -0,0 +1,125 @@
+package connect
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/envoy"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/images"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/resources"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/testlabels"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const envoyExtraArgsAnnotation = "consul.hashicorp.com/envoy-extra-args"
+
+// Test that the arguments from connectInject.envoyExtraArgs are passed to the
+// injected Envoy sidecar and that the consul.hashicorp.com/envoy-extra-args
+// annotation replaces them per pod. The log level is checked both in the
+// sidecar's command and in the running Envoy's admin API.
+// It also tests that arguments that can't be parsed fail the injection with
+// a clear error instead of creating a pod whose proxy doesn't start.
+func TestConnectInject_EnvoyExtraArgs(t *testing.T) {
+	helmValues := map[string]string{
+		"connectInject.enabled":        "true",
+		"connectInject.envoyExtraArgs": "--log-level error",
+	}
+
+	cases := []struct {
+		name     string
+		fixture  string
+		expArgs  string
+		expLevel string
+	}{
+		{
+			"chart value",
+			"../fixtures/cases/static-server-inject",
+			"--log-level error",
+			"error",
+		},
+		{
+			"annotation",
+			"../fixtures/cases/static-server-envoy-extra-args",
+			"--log-level critical --disable-hot-restart",
+			"critical",
+		},
+	}
+
+	// All cases use the same Helm values, so they can share a Consul cluster.
+	testSuite := suite.TestSuite(t)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := suite.Config()
+			_, ctx := testSuite.HelmCluster(t, suite.Environment().DefaultContext(t), helmValues)
+
+			logger.Log(t, "creating static-server deployment")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, c.fixture)
+
+			pods := k8s.GetPods(t, ctx.KubectlOptions(t), "app="+staticServerName)
+			require.Len(t, pods, 1)
+
+			logger.Log(t, "checking the command of the injected sidecar")
+			sidecar := findContainer(pods[0].Spec.Containers, resources.SidecarContainer)
+			require.NotNil(t, sidecar, "pod %s has no %s container", pods[0].Name, resources.SidecarContainer)
+			command := strings.Join(sidecar.Command, " ")
+			require.True(t, strings.HasSuffix(command, " "+c.expArgs), "unexpected sidecar command: %s", command)
+
+			logger.Log(t, "checking the log levels of the running Envoy")
+			admin := envoy.NewAdmin(t, ctx.KubectlOptions(t), pods[0].Name)
+			helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+				levels := admin.LogLevels(r)
+				require.NotEmpty(r, levels)
+				for name, level := range levels {
+					require.Equal(r, c.expLevel, level, "unexpected log level of logger %s", name)
+				}
+			})
+		})
+	}
+
+	t.Run("invalid annotation", func(t *testing.T) {
+		cfg := suite.Config()
+		_, ctx := testSuite.HelmCluster(t, suite.Environment().DefaultContext(t), helmValues)
+
+		// The unterminated quote can't be split into arguments,
+		// so the injector should reject the pod.
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "static-server-invalid-envoy-args",
+				Annotations: map[string]string{
+					"consul.hashicorp.com/connect-inject": "true",
+					envoyExtraArgsAnnotation:              `--log-level "debug`,
+				},
+				Labels: testlabels.ForTest(t),
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:  staticServerName,
+					Image: images.Rewrite(cfg.TestImageRegistry, "hashicorp/http-echo:latest"),
+					Args:  []string{`-text="hello world"`, "-listen=:8080"},
+				}},
+				NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+			},
+		}
+
+		logger.Log(t, "creating pod with invalid Envoy extra args")
+		pods := ctx.KubernetesClient(t).CoreV1().Pods(ctx.KubectlOptions(t).Namespace)
+		_, err := pods.Create(helpers.TestContext(t), pod, metav1.CreateOptions{})
+		helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+			err := pods.Delete(helpers.TestContext(t), pod.Name, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				logger.Logf(t, "could not delete pod %s: %s", pod.Name, err)
+			}
+		})
+		require.Error(t, err, "the injector should reject pods with Envoy extra args that can't be parsed")
+		require.Contains(t, err.Error(), "denied the request")
+		require.Contains(t, err.Error(), "closing quote")
+	})
+}
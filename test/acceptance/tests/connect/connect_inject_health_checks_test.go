@@ -0,0 +1,107 @@
+package connect
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the readiness status of connect-injected pods is synced to Consul
+// health checks, that unhealthy instances are removed from the mesh,
+// and that they are added back once they become healthy again.
+func TestConnectInject_HealthChecks(t *testing.T) {
+	cases := []struct {
+		secure      bool
+		autoEncrypt bool
+	}{
+		{false, false},
+		{true, true},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("secure: %t; auto-encrypt: %t", c.secure, c.autoEncrypt)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"connectInject.enabled":              "true",
+				"connectInject.healthChecks.enabled": "true",
+				"global.tls.enabled":                 strconv.FormatBool(c.secure),
+				"global.tls.enableAutoEncrypt":       strconv.FormatBool(c.autoEncrypt),
+				"global.acls.manageSystemACLs":       strconv.FormatBool(c.secure),
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+
+			consulClient := consulCluster.SetupConsulClient(t, c.secure)
+
+			logger.Log(t, "creating static-server and static-client deployments")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+			if c.secure {
+				logger.Log(t, "creating intention")
+				_, _, err := consulClient.Connect().IntentionCreate(&api.Intention{
+					SourceName:      staticClientName,
+					DestinationName: staticServerName,
+					Action:          api.IntentionActionAllow,
+				}, nil)
+				require.NoError(t, err)
+			}
+
+			logger.Log(t, "checking that the static-server health checks are passing")
+			requireHealthCheckStatus(t, consulClient, api.HealthPassing)
+			k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+			// Create the file so that the readiness probe of the static-server pod fails.
+			logger.Log(t, "making the static-server unhealthy")
+			k8s.RunKubectl(t, ctx.KubectlOptions(t), "exec", "deploy/"+staticServerName, "--", "touch", "/tmp/unhealthy")
+
+			logger.Log(t, "checking that the static-server health checks are critical")
+			requireHealthCheckStatus(t, consulClient, api.HealthCritical)
+
+			logger.Log(t, "checking that the static-server has been removed from the mesh")
+			k8s.CheckStaticServerConnectionMultipleFailureMessages(
+				t,
+				ctx.KubectlOptions(t),
+				false,
+				staticClientName,
+				[]string{"curl: (56) Recv failure: Connection reset by peer", "curl: (52) Empty reply from server"},
+				"http://localhost:1234")
+
+			// Remove the file so that the readiness probe passes again.
+			logger.Log(t, "making the static-server healthy")
+			k8s.RunKubectl(t, ctx.KubectlOptions(t), "exec", "deploy/"+staticServerName, "--", "rm", "/tmp/unhealthy")
+
+			logger.Log(t, "checking that the static-server health checks are passing again")
+			requireHealthCheckStatus(t, consulClient, api.HealthPassing)
+			k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+		})
+	}
+}
+
+// requireHealthCheckStatus waits for the aggregated status of the static-server's
+// health checks in Consul to become the expected status.
+func requireHealthCheckStatus(t *testing.T, consulClient *api.Client, expectedStatus string) {
+	t.Helper()
+
+	helpers.RetryEventually(t, 1*time.Minute, func(r *retry.R) {
+		checks, _, err := consulClient.Health().Checks(staticServerName, nil)
+		require.NoError(r, err)
+		require.NotEmpty(r, checks)
+		require.Equal(r, expectedStatus, checks.AggregatedStatus())
+	})
+}
@@ -0,0 +1,108 @@
+package connect
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/metrics"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+)
+
+// rbacDenied is the body of the response Envoy returns
+// when a request is denied by an L7 intention.
+const rbacDenied = "RBAC: access denied"
+
+// Test that L7 intentions with HTTP path and method permissions that are
+// created as ServiceIntentions custom resources are enforced by Envoy
+// on real traffic between connect-injected pods.
+func TestConnectInject_L7Intentions(t *testing.T) {
+	cases := []struct {
+		secure      bool
+		autoEncrypt bool
+	}{
+		{false, false},
+		{true, false},
+		{true, true},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("secure: %t; auto-encrypt: %t", c.secure, c.autoEncrypt)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"connectInject.enabled":        "true",
+				"controller.enabled":           "true",
+				"global.tls.enabled":           strconv.FormatBool(c.secure),
+				"global.tls.enableAutoEncrypt": strconv.FormatBool(c.autoEncrypt),
+				"global.acls.manageSystemACLs": strconv.FormatBool(c.secure),
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+
+			// L7 intentions require the destination to use the http protocol,
+			// which is set by the service-defaults.
+			serviceDefaults := fixtures.NewServiceDefaults(staticServerName).WithProtocol("http")
+			// Permissions are evaluated in order and the first match applies.
+			// The last permission denies everything that isn't allowed
+			// because the default intention action is allow without ACLs.
+			serviceIntentions := fixtures.NewServiceIntentions(staticServerName, staticServerName).
+				WithSourcePermissions(staticClientName,
+					fixtures.HTTPPermission{Action: "deny", PathPrefix: "/allowed/admin"},
+					fixtures.HTTPPermission{Action: "allow", PathPrefix: "/allowed", Methods: []string{"GET"}},
+					fixtures.HTTPPermission{Action: "deny", PathPrefix: "/"},
+				)
+
+			logger.Log(t, "creating service-defaults and service-intentions custom resources")
+			fixtures.Apply(t, ctx.KubectlOptions(t), serviceDefaults, serviceIntentions)
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				fixtures.Delete(t, ctx.KubectlOptions(t), serviceDefaults, serviceIntentions)
+			})
+
+			recordFirstSync := metrics.Time(t, metrics.PhaseFirstSync)
+			helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+				for _, kind := range []string{"servicedefaults", "serviceintentions"} {
+					k8s.RequireCRDCondition(r, t, ctx.KubectlOptions(t), kind, staticServerName, k8s.ConditionSynced, "True", "")
+				}
+			})
+			recordFirstSync()
+
+			logger.Log(t, "creating static-server and static-client deployments")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+			requests := []struct {
+				method  string
+				path    string
+				allowed bool
+			}{
+				{"GET", "/allowed", true},
+				{"GET", "/allowed/foo", true},
+				{"POST", "/allowed", false},
+				{"GET", "/allowed/admin", false},
+				{"GET", "/", false},
+			}
+			for _, req := range requests {
+				logger.Logf(t, "checking that %s %s is allowed: %t", req.method, req.path, req.allowed)
+				expected := k8s.HTTPExpectation{StatusCode: 403, Body: rbacDenied}
+				if req.allowed {
+					expected = k8s.HTTPExpectation{StatusCode: 200, Body: "hello world"}
+				}
+				k8s.CheckHTTP(t, ctx.KubectlOptions(t), staticClientName,
+					k8s.HTTPRequest{URL: "http://localhost:1234" + req.path, Method: req.method},
+					expected)
+			}
+		})
+	}
+}
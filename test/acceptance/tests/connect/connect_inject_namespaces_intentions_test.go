@@ -0,0 +1,198 @@
+package connect
+
+import (
+	"fmt"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const staticClientUpstreamTemplate = "../fixtures/templates/static-client-upstream-namespace.yaml"
+
+// Test that a ServiceIntentions custom resource with a source in a different
+// Consul namespace than the destination is enforced on real traffic
+// when Kubernetes namespaces are mirrored into Consul namespaces.
+func TestConnectInjectNamespaces_CrossNamespaceIntentions(t *testing.T) {
+	cfg := suite.Config()
+	if !cfg.EnableEnterprise {
+		t.Skipf("skipping this test because -enable-enterprise is not set")
+	}
+
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"global.enableConsulNamespaces":               "true",
+		"connectInject.enabled":                       "true",
+		"connectInject.consulNamespaces.mirroringK8S": "true",
+		"controller.enabled":                          "true",
+
+		"global.acls.manageSystemACLs": "true",
+		"global.tls.enabled":           "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	staticServerOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+	staticClientOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+
+	logger.Log(t, "creating static-server and static-client deployments")
+	k8s.DeployKustomize(t, staticServerOpts, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+	deployStaticClient(t, staticClientOpts, staticServerOpts.Namespace)
+
+	logger.Log(t, "checking that the connection is not successful because there's no intention")
+	k8s.CheckStaticServerConnectionFailing(t, staticClientOpts, staticClientName, "http://localhost:1234")
+
+	// The service-intentions custom resource is created in the destination's
+	// Kubernetes namespace, so with mirroring the config entry will be created
+	// in the static-server's Consul namespace with a source in the
+	// static-client's Consul namespace.
+	logger.Log(t, "creating service-intentions custom resource")
+	fixtures.NewServiceIntentions(staticServerName, staticServerName).
+		WithSourceInNamespace(staticClientName, staticClientOpts.Namespace, "allow").
+		Apply(t, staticServerOpts)
+	// NOTE: No need to clean up because the namespace will be deleted.
+
+	logger.Log(t, "checking that connection is successful")
+	k8s.CheckStaticServerConnectionSuccessful(t, staticClientOpts, staticClientName, "http://localhost:1234")
+
+	// Point the intention at a source in a different namespace, so that
+	// the static-client is no longer allowed to connect.
+	logger.Log(t, "updating the service-intentions source namespace")
+	fixtures.NewServiceIntentions(staticServerName, staticServerName).
+		WithSourceInNamespace(staticClientName, "default", "allow").
+		Apply(t, staticServerOpts)
+
+	logger.Log(t, "checking that the connection is not successful because the intention source is in a different namespace")
+	k8s.CheckStaticServerConnectionFailing(t, staticClientOpts, staticClientName, "http://localhost:1234")
+}
+
+// Test that a ServiceIntentions custom resource with a wildcard source namespace
+// is synced to Consul as a wildcard and allows traffic from clients in every
+// mirrored namespace, and that a more specific source takes precedence over it.
+// JWT sources aren't covered because intentions don't support them
+// in the Consul versions the chart supports.
+func TestConnectInjectNamespaces_WildcardNamespaceIntentions(t *testing.T) {
+	cfg := suite.Config()
+	if !cfg.EnableEnterprise {
+		t.Skipf("skipping this test because -enable-enterprise is not set")
+	}
+
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"global.enableConsulNamespaces":               "true",
+		"connectInject.enabled":                       "true",
+		"connectInject.consulNamespaces.mirroringK8S": "true",
+		"controller.enabled":                          "true",
+
+		"global.acls.manageSystemACLs": "true",
+		"global.tls.enabled":           "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	consulClient := consulCluster.SetupConsulClient(t, true)
+
+	staticServerOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+	staticClientOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+	secondStaticClientOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+	clientOpts := []*terratestk8s.KubectlOptions{staticClientOpts, secondStaticClientOpts}
+
+	logger.Log(t, "creating static-server and static-client deployments")
+	k8s.DeployKustomize(t, staticServerOpts, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+	for _, opts := range clientOpts {
+		deployStaticClient(t, opts, staticServerOpts.Namespace)
+	}
+
+	for _, opts := range clientOpts {
+		logger.Logf(t, "checking that the connection from namespace %s is not successful because there's no intention", opts.Namespace)
+		k8s.CheckStaticServerConnectionFailing(t, opts, staticClientName, "http://localhost:1234")
+	}
+
+	logger.Log(t, "creating service-intentions custom resource with a wildcard source")
+	intentions := fixtures.NewServiceIntentions(staticServerName, staticServerName).
+		WithSourceInNamespace("*", "*", "allow")
+	intentions.Apply(t, staticServerOpts)
+	// NOTE: No need to clean up because the namespace will be deleted.
+
+	requireIntentionSources(t, staticServerOpts, consulClient, []*api.SourceIntention{
+		{Name: "*", Namespace: "*", Action: api.IntentionActionAllow},
+	})
+
+	for _, opts := range clientOpts {
+		logger.Logf(t, "checking that the connection from namespace %s is successful", opts.Namespace)
+		k8s.CheckStaticServerConnectionSuccessful(t, opts, staticClientName, "http://localhost:1234")
+	}
+
+	// The exact source takes precedence over the wildcard source,
+	// so only the static-client in the second namespace is denied.
+	logger.Logf(t, "adding a source that denies the static-client in namespace %s", secondStaticClientOpts.Namespace)
+	intentions.WithSourceInNamespace(staticClientName, secondStaticClientOpts.Namespace, "deny").
+		Apply(t, staticServerOpts)
+
+	requireIntentionSources(t, staticServerOpts, consulClient, []*api.SourceIntention{
+		{Name: "*", Namespace: "*", Action: api.IntentionActionAllow},
+		{Name: staticClientName, Namespace: secondStaticClientOpts.Namespace, Action: api.IntentionActionDeny},
+	})
+
+	logger.Logf(t, "checking that the connection from namespace %s is still successful", staticClientOpts.Namespace)
+	k8s.CheckStaticServerConnectionSuccessful(t, staticClientOpts, staticClientName, "http://localhost:1234")
+
+	logger.Logf(t, "checking that the connection from namespace %s is not successful because the exact source takes precedence", secondStaticClientOpts.Namespace)
+	k8s.CheckStaticServerConnectionFailing(t, secondStaticClientOpts, staticClientName, "http://localhost:1234")
+}
+
+// requireIntentionSources waits for the static-server service-intentions custom
+// resource to be synced and checks that the sources of its config entry in the
+// static-server's Consul namespace, which the namespace of staticServerOpts
+// is mirrored to, match expected.
+func requireIntentionSources(t *testing.T, staticServerOpts *terratestk8s.KubectlOptions, consulClient *api.Client, expected []*api.SourceIntention) {
+	t.Helper()
+
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		k8s.RequireCRDCondition(r, t, staticServerOpts, "serviceintentions", staticServerName, k8s.ConditionSynced, "True", "")
+
+		entry, _, err := consulClient.ConfigEntries().Get(api.ServiceIntentions, staticServerName, &api.QueryOptions{Namespace: staticServerOpts.Namespace})
+		require.NoError(r, err)
+		intentions, ok := entry.(*api.ServiceIntentionsConfigEntry)
+		require.True(r, ok)
+		// Consul may reorder the sources by precedence.
+		require.ElementsMatch(r, intentionSources(expected), intentionSources(intentions.Sources))
+	})
+}
+
+// deployStaticClient deploys the static-client into the namespace of options
+// with an upstream to the static-server in the Consul namespace serverNamespace.
+func deployStaticClient(t *testing.T, options *terratestk8s.KubectlOptions, serverNamespace string) {
+	t.Helper()
+
+	cfg := suite.Config()
+	k8s.DeployTemplate(t, options, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, staticClientUpstreamTemplate, struct {
+		ServerNamespace string
+	}{serverNamespace})
+}
+
+// intentionSources returns the name, namespace and action of sources
+// in the form "<namespace>/<name>: <action>".
+func intentionSources(sources []*api.SourceIntention) []string {
+	var result []string
+	for _, source := range sources {
+		result = append(result, fmt.Sprintf("%s/%s: %s", source.Namespace, source.Name, source.Action))
+	}
+	return result
+}
@@ -4,8 +4,6 @@ import (
 	"strconv"
 	"testing"
 
-	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
-	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
@@ -13,8 +11,9 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-const staticServerNamespace = "ns1"
-const staticClientNamespace = "ns2"
+// destinationNamespace is the Consul namespace that services are registered
+// in when Kubernetes namespaces aren't mirrored.
+const destinationNamespace = "ns1"
 
 // Test that Connect works with Consul Enterprise namespaces.
 // These tests currently only test non-secure and secure without auto-encrypt installations
@@ -34,33 +33,33 @@ func TestConnectInjectNamespaces(t *testing.T) {
 	}{
 		{
 			"single destination namespace",
-			staticServerNamespace,
+			destinationNamespace,
 			false,
 			false,
 		},
 		{
 			"single destination namespace; secure",
-			staticServerNamespace,
+			destinationNamespace,
 			false,
 			true,
 		},
 		{
 			"mirror k8s namespaces",
-			staticServerNamespace,
+			destinationNamespace,
 			true,
 			false,
 		},
 		{
 			"mirror k8s namespaces; secure",
-			staticServerNamespace,
+			destinationNamespace,
 			true,
 			true,
 		},
 	}
 
+	testSuite := suite.TestSuite(t)
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			ctx := suite.Environment().DefaultContext(t)
 			cfg := suite.Config()
 
 			helmValues := map[string]string{
@@ -74,40 +73,10 @@ func TestConnectInjectNamespaces(t *testing.T) {
 				"global.tls.enabled":           strconv.FormatBool(c.secure),
 			}
 
-			releaseName := helpers.RandomName()
-			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+			consulCluster, ctx := testSuite.HelmCluster(t, suite.Environment().DefaultContext(t), helmValues)
 
-			consulCluster.Create(t)
-
-			staticServerOpts := &terratestk8s.KubectlOptions{
-				ContextName: ctx.KubectlOptions(t).ContextName,
-				ConfigPath:  ctx.KubectlOptions(t).ConfigPath,
-				Namespace:   staticServerNamespace,
-			}
-			staticClientOpts := &terratestk8s.KubectlOptions{
-				ContextName: ctx.KubectlOptions(t).ContextName,
-				ConfigPath:  ctx.KubectlOptions(t).ConfigPath,
-				Namespace:   staticClientNamespace,
-			}
-
-			logger.Logf(t, "creating namespaces %s and %s", staticServerNamespace, staticClientNamespace)
-			k8s.RunKubectl(t, ctx.KubectlOptions(t), "create", "ns", staticServerNamespace)
-			helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "ns", staticServerNamespace)
-			})
-
-			k8s.RunKubectl(t, ctx.KubectlOptions(t), "create", "ns", staticClientNamespace)
-			helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
-				// Note: this deletion will take longer in cases when the static-client deployment
-				// hasn't yet fully terminated.
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "ns", staticClientNamespace)
-			})
-
-			logger.Log(t, "creating static-server and static-client deployments")
-			k8s.DeployKustomize(t, staticServerOpts, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
-			k8s.DeployKustomize(t, staticClientOpts, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-client-namespaces")
-
-			consulClient := consulCluster.SetupConsulClient(t, c.secure)
+			staticServerOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+			staticClientOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
 
 			// Make sure that services are registered in the correct namespace.
 			// If mirroring is enabled, we expect services to be registered in the
@@ -115,18 +84,24 @@ func TestConnectInjectNamespaces(t *testing.T) {
 			// Kubernetes namespace.
 			// If a single destination namespace is set, we expect all services
 			// to be registered in that destination Consul namespace.
-			serverQueryOpts := &api.QueryOptions{Namespace: staticServerNamespace}
-			clientQueryOpts := &api.QueryOptions{Namespace: staticClientNamespace}
-
+			serverNamespace := staticServerOpts.Namespace
+			clientNamespace := staticClientOpts.Namespace
 			if !c.mirrorK8S {
-				serverQueryOpts = &api.QueryOptions{Namespace: c.destinationNamespace}
-				clientQueryOpts = &api.QueryOptions{Namespace: c.destinationNamespace}
+				serverNamespace = c.destinationNamespace
+				clientNamespace = c.destinationNamespace
 			}
-			services, _, err := consulClient.Catalog().Service(staticServerName, "", serverQueryOpts)
+
+			logger.Log(t, "creating static-server and static-client deployments")
+			k8s.DeployKustomize(t, staticServerOpts, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			deployStaticClient(t, staticClientOpts, serverNamespace)
+
+			consulClient := consulCluster.SetupConsulClient(t, c.secure)
+
+			services, _, err := consulClient.Catalog().Service(staticServerName, "", &api.QueryOptions{Namespace: serverNamespace})
 			require.NoError(t, err)
 			require.Len(t, services, 1)
 
-			services, _, err = consulClient.Catalog().Service(staticClientName, "", clientQueryOpts)
+			services, _, err = consulClient.Catalog().Service(staticClientName, "", &api.QueryOptions{Namespace: clientNamespace})
 			require.NoError(t, err)
 			require.Len(t, services, 1)
 
@@ -136,19 +111,12 @@ func TestConnectInjectNamespaces(t *testing.T) {
 
 				intention := &api.Intention{
 					SourceName:      staticClientName,
-					SourceNS:        staticClientNamespace,
+					SourceNS:        clientNamespace,
 					DestinationName: staticServerName,
-					DestinationNS:   staticServerNamespace,
+					DestinationNS:   serverNamespace,
 					Action:          api.IntentionActionAllow,
 				}
 
-				// Set the destination namespace to be the same
-				// unless mirrorK8S is true.
-				if !c.mirrorK8S {
-					intention.SourceNS = c.destinationNamespace
-					intention.DestinationNS = c.destinationNamespace
-				}
-
 				logger.Log(t, "creating intention")
 				_, _, err := consulClient.Connect().IntentionCreate(intention, nil)
 				require.NoError(t, err)
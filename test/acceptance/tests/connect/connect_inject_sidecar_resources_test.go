@@ -0,0 +1,106 @@
+package connect
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/resources"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Test that the resources of the injected Envoy sidecar are set from
+// connectInject.sidecarProxy.resources and that the
+// consul.hashicorp.com/sidecar-proxy-* annotations override them per pod.
+// The annotations in the static-server-sidecar-resources fixture only set
+// the CPU limit and the memory request, so the other resources
+// must still come from the chart defaults.
+func TestConnectInject_SidecarResources(t *testing.T) {
+	chartDefaults := map[string]string{
+		"requests.cpu":    "50m",
+		"requests.memory": "32Mi",
+		"limits.cpu":      "100m",
+		"limits.memory":   "128Mi",
+	}
+	annotations := map[string]string{
+		"limits.cpu":      "200m",
+		"requests.memory": "64Mi",
+	}
+
+	cases := []struct {
+		chartDefaults bool
+		annotations   bool
+	}{
+		{false, false},
+		{false, true},
+		{true, false},
+		{true, true},
+	}
+
+	// Cases with the same chart defaults can share a Consul cluster.
+	testSuite := suite.TestSuite(t)
+	for _, c := range cases {
+		name := fmt.Sprintf("chart defaults: %t; annotations: %t", c.chartDefaults, c.annotations)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+
+			helmValues := map[string]string{
+				"connectInject.enabled": "true",
+			}
+			expected := map[string]string{}
+			if c.chartDefaults {
+				for resource, value := range chartDefaults {
+					helmValues["connectInject.sidecarProxy.resources."+resource] = value
+					expected[resource] = value
+				}
+			}
+			fixture := "../fixtures/cases/static-server-inject"
+			if c.annotations {
+				fixture = "../fixtures/cases/static-server-sidecar-resources"
+				for resource, value := range annotations {
+					expected[resource] = value
+				}
+			}
+
+			_, ctx := testSuite.HelmCluster(t, suite.Environment().DefaultContext(t), helmValues)
+
+			logger.Log(t, "creating static-server deployment")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, fixture)
+
+			pods := k8s.GetPods(t, ctx.KubectlOptions(t), "app="+staticServerName)
+			require.Len(t, pods, 1)
+
+			logger.Log(t, "checking the resources of the injected sidecar")
+			sidecar := findContainer(pods[0].Spec.Containers, resources.SidecarContainer)
+			require.NotNil(t, sidecar, "pod %s has no %s container", pods[0].Name, resources.SidecarContainer)
+			require.Equal(t, expected, resourceRequirements(sidecar.Resources))
+		})
+	}
+}
+
+// findContainer returns the container with the given name
+// or nil if there isn't one.
+func findContainer(containers []corev1.Container, name string) *corev1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// resourceRequirements returns the requests and limits of requirements
+// keyed like "requests.cpu", so they can be compared with the values
+// of the chart and the annotations.
+func resourceRequirements(requirements corev1.ResourceRequirements) map[string]string {
+	result := map[string]string{}
+	for name, quantity := range requirements.Requests {
+		result["requests."+string(name)] = quantity.String()
+	}
+	for name, quantity := range requirements.Limits {
+		result["limits."+string(name)] = quantity.String()
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+package consuldns
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const dnsClientName = "dns-client"
+const staticServerName = "static-server"
+
+// Test that with ACLs enabled, DNS lookups of mesh services from pods outside
+// the mesh are only allowed if dns.enabled is true. In that case, server-acl-init
+// gives the anonymous token a policy that can read services and nodes, which is
+// the token Consul uses for DNS requests. Without that policy, Consul filters out
+// all services from DNS responses, so lookups fail with NXDOMAIN.
+func TestConsulDNS_ACLs(t *testing.T) {
+	cases := []struct {
+		dnsEnabled bool
+	}{
+		{true},
+		{false},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("dns enabled: %t", c.dnsEnabled)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"connectInject.enabled":        "true",
+				"dns.enabled":                  strconv.FormatBool(c.dnsEnabled),
+				"global.tls.enabled":           "true",
+				"global.acls.manageSystemACLs": "true",
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+
+			logger.Log(t, "creating static-server and dns-client deployments")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/bases/dns-client")
+
+			// Wait for static-server to be healthy in Consul so that
+			// failing lookups can't be explained by it not being registered yet.
+			consulClient := consulCluster.SetupConsulClient(t, true)
+			logger.Log(t, "waiting for static-server to be healthy in Consul")
+			helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+				entries, _, err := consulClient.Health().Service(staticServerName, "", true, nil)
+				require.NoError(r, err)
+				require.NotEmpty(r, entries, "static-server has no healthy instances")
+			})
+
+			serverPods := consulCluster.ServerPods(t)
+			require.NotEmpty(t, serverPods, "no server pods found")
+			staticServerPods := k8s.GetPods(t, ctx.KubectlOptions(t), "app="+staticServerName)
+			require.Len(t, staticServerPods, 1)
+			answer := fmt.Sprintf("%s.service.consul.\t0\tIN\tA\t%s", staticServerName, staticServerPods[0].Status.PodIP)
+
+			// The servers answer DNS requests on their DNS port whether or not the
+			// consul-dns service exists, so query them directly to check the
+			// anonymous token's policy. The consul-dns service listens on port 53.
+			dnsServers := []string{serverPods[0].Status.PodIP + ":8600"}
+			if c.dnsEnabled {
+				dnsServers = append(dnsServers, releaseName+"-consul-dns:53")
+			}
+
+			for _, dnsServer := range dnsServers {
+				if c.dnsEnabled {
+					logger.Logf(t, "checking that static-server can be looked up from %s", dnsServer)
+					helpers.RetryEventually(t, timeouts.TrafficCheck(), func(r *retry.R) {
+						out := dig(r, t, ctx.KubectlOptions(t), dnsServer, staticServerName+".service.consul")
+						require.Contains(r, out, "status: NOERROR")
+						require.Contains(r, out, answer)
+					})
+				} else {
+					logger.Logf(t, "checking that looking up static-server from %s is denied", dnsServer)
+					out := dig(t, t, ctx.KubectlOptions(t), dnsServer, staticServerName+".service.consul")
+					require.Contains(t, out, "status: NXDOMAIN")
+					require.NotContains(t, out, answer)
+				}
+			}
+		})
+	}
+}
+
+// dig looks up name from the dns-client pod by sending a DNS request to
+// dnsServer, in the form host:port, and returns the output of dig.
+// It takes a require.TestingT so that it can be used with retry.R.
+func dig(r require.TestingT, t *testing.T, options *terratestk8s.KubectlOptions, dnsServer, name string) string {
+	host, port, err := net.SplitHostPort(dnsServer)
+	require.NoError(r, err)
+	out, err := k8s.RunKubectlAndGetOutputE(t, options, "exec", "deploy/"+dnsClientName, "--", "dig", "@"+host, "-p", port, name)
+	require.NoError(r, err)
+	return out
+}
@@ -0,0 +1,75 @@
+package consuldns
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/config"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Test that connect injection and Consul DNS work with the cluster domain
+// of -cluster-domain, which is what clusters with a domain other than
+// cluster.local need to run the tests. The names of Kubernetes services
+// are looked up by their fully qualified names in the cluster domain,
+// and Consul DNS is reached through the fully qualified name of the
+// consul-dns service, while names in Consul's own .consul domain
+// don't depend on the cluster domain. The other tests already cover
+// cluster.local, so the test is skipped unless the cluster uses another domain.
+func TestConsulDNS_ClusterDomain(t *testing.T) {
+	cfg := suite.Config()
+	if cfg.ClusterDomain == "" || cfg.ClusterDomain == config.DefaultClusterDomain {
+		t.Skipf("skipping this test because -cluster-domain is not set to a domain other than %s", config.DefaultClusterDomain)
+	}
+	ctx := suite.Environment().DefaultContext(t)
+	namespace := ctx.KubectlOptions(t).Namespace
+
+	helmValues := map[string]string{
+		"connectInject.enabled": "true",
+		"dns.enabled":           "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	logger.Log(t, "creating static-server, static-client and dns-client deployments")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/bases/dns-client")
+
+	logger.Log(t, "checking that the injected static-client can connect to static-server")
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), "static-client", "http://localhost:1234")
+
+	staticServerService, err := ctx.KubernetesClient(t).CoreV1().Services(namespace).Get(helpers.TestContext(t), staticServerName, metav1.GetOptions{})
+	require.NoError(t, err)
+	staticServerFQDN := cfg.ServiceFQDN(staticServerName, namespace)
+
+	logger.Logf(t, "checking that %s can be looked up with the cluster's DNS", staticServerFQDN)
+	helpers.RetryEventually(t, timeouts.TrafficCheck(), func(r *retry.R) {
+		out, err := k8s.RunKubectlAndGetOutputE(t, ctx.KubectlOptions(t), "exec", "deploy/"+dnsClientName, "--", "dig", staticServerFQDN)
+		require.NoError(r, err)
+		require.Contains(r, out, "status: NOERROR")
+		require.Contains(r, out, "\tIN\tA\t"+staticServerService.Spec.ClusterIP)
+	})
+
+	staticServerPods := k8s.GetPods(t, ctx.KubectlOptions(t), "app="+staticServerName)
+	require.Len(t, staticServerPods, 1)
+	answer := fmt.Sprintf("%s.service.consul.\t0\tIN\tA\t%s", staticServerName, staticServerPods[0].Status.PodIP)
+	dnsServer := cfg.ServiceFQDN(releaseName+"-consul-dns", namespace) + ":53"
+
+	logger.Logf(t, "checking that static-server can be looked up from %s", dnsServer)
+	helpers.RetryEventually(t, timeouts.TrafficCheck(), func(r *retry.R) {
+		out := dig(r, t, ctx.KubectlOptions(t), dnsServer, staticServerName+".service.consul")
+		require.Contains(r, out, "status: NOERROR")
+		require.Contains(r, out, answer)
+	})
+}
@@ -0,0 +1,82 @@
+package consuldns
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Test that Consul DNS forwards lookups of names outside of the .consul
+// domain to the recursors the agents are configured with. The recursor is
+// the cluster's DNS service, so pods that use Consul DNS can resolve both
+// Consul services and Kubernetes services. The chart (0.27.0) has no
+// global.recursors or other recursors value, so the recursors are set
+// with server.extraConfig and client.extraConfig.
+func TestConsulDNS_Recursors(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+	k8sClient := ctx.KubernetesClient(t)
+
+	clusterDNS, err := k8sClient.CoreV1().Services("kube-system").Get(helpers.TestContext(t), "kube-dns", metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		t.Skipf("skipping this test because the cluster has no kube-dns service in kube-system")
+	}
+	require.NoError(t, err)
+	recursor := clusterDNS.Spec.ClusterIP
+
+	// The name of the kubernetes service is outside of the .consul
+	// domain, so only the cluster's DNS service can resolve it.
+	externalName := cfg.ServiceFQDN("kubernetes", "default")
+	kubernetesService, err := k8sClient.CoreV1().Services("default").Get(helpers.TestContext(t), "kubernetes", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	extraConfig := fmt.Sprintf(`{"recursors": [%q]}`, recursor)
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, map[string]string{"dns.enabled": "true"}, ctx, cfg, releaseName,
+		consul.WithValues(map[string]interface{}{
+			"server": map[string]interface{}{"extraConfig": extraConfig},
+			"client": map[string]interface{}{"extraConfig": extraConfig},
+		}))
+
+	consulCluster.Create(t)
+
+	// The consul-dns service sends requests to both servers and clients,
+	// so all of them need to forward to the recursor.
+	agents := append(consulCluster.ServerPods(t), consulCluster.ClientPods(t)...)
+	for _, pod := range agents {
+		logger.Logf(t, "checking the recursors of agent %s", pod.Name)
+		self := consul.AgentSelf(t, ctx, pod)
+		require.Equal(t, []interface{}{recursor}, self["DebugConfig"]["DNSRecursors"])
+	}
+
+	logger.Log(t, "creating dns-client deployment")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/bases/dns-client")
+
+	serverPods := consulCluster.ServerPods(t)
+	require.NotEmpty(t, serverPods, "no server pods found")
+	dnsServer := releaseName + "-consul-dns:53"
+
+	logger.Logf(t, "checking that the Consul servers can be looked up from %s", dnsServer)
+	helpers.RetryEventually(t, timeouts.TrafficCheck(), func(r *retry.R) {
+		out := dig(r, t, ctx.KubectlOptions(t), dnsServer, "consul.service.consul")
+		require.Contains(r, out, "status: NOERROR")
+		require.Contains(r, out, fmt.Sprintf("consul.service.consul.\t0\tIN\tA\t%s", serverPods[0].Status.PodIP))
+	})
+
+	logger.Logf(t, "checking that %s is forwarded from %s to the recursor %s", externalName, dnsServer, recursor)
+	helpers.RetryEventually(t, timeouts.TrafficCheck(), func(r *retry.R) {
+		out := dig(r, t, ctx.KubectlOptions(t), dnsServer, externalName)
+		require.Contains(r, out, "status: NOERROR")
+		// The TTL of the answer is set by the recursor, so only its end is checked.
+		require.Contains(r, out, "\tIN\tA\t"+kubernetesService.Spec.ClusterIP)
+	})
+}
@@ -1,7 +1,6 @@
 package consuldns
 
 import (
-	"context"
 	"fmt"
 	"testing"
 
@@ -45,18 +44,14 @@ func TestConsulDNS(t *testing.T) {
 			k8sClient := ctx.KubernetesClient(t)
 			contextNamespace := ctx.KubectlOptions(t).Namespace
 
-			dnsService, err := k8sClient.CoreV1().Services(contextNamespace).Get(context.Background(), fmt.Sprintf("%s-%s", releaseName, "consul-dns"), metav1.GetOptions{})
+			dnsService, err := k8sClient.CoreV1().Services(contextNamespace).Get(helpers.TestContext(t), fmt.Sprintf("%s-%s", releaseName, "consul-dns"), metav1.GetOptions{})
 			require.NoError(t, err)
 
 			dnsIP := dnsService.Spec.ClusterIP
 
-			consulServerList, err := k8sClient.CoreV1().Pods(contextNamespace).List(context.Background(), metav1.ListOptions{
-				LabelSelector: "app=consul,component=server",
-			})
-			require.NoError(t, err)
-
-			serverIPs := make([]string, len(consulServerList.Items))
-			for _, serverPod := range consulServerList.Items {
+			serverPods := cluster.ServerPods(t)
+			serverIPs := make([]string, len(serverPods))
+			for _, serverPod := range serverPods {
 				serverIPs = append(serverIPs, serverPod.Status.PodIP)
 			}
 
@@ -64,7 +59,7 @@ func TestConsulDNS(t *testing.T) {
 				"run", "-i", podName, "--restart", "Never", "--image", "anubhavmishra/tiny-tools", "--", "dig", fmt.Sprintf("@%s-consul-dns", releaseName), "consul.service.consul",
 			}
 
-			helpers.Cleanup(t, suite.Config().NoCleanupOnFailure, func() {
+			helpers.Cleanup(t, suite.Config().NoCleanupOnFailure, suite.Config().NoCleanup, func() {
 				// Note: this delete command won't wait for pods to be fully terminated.
 				// This shouldn't cause any test pollution because the underlying
 				// objects are deployments, and so when other tests create these
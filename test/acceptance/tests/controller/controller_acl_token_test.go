@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/metrics"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the ACL token that server-acl-init creates for the controller
+// only grants what the controller needs to sync config entries.
+// It checks the policies of the token and that the token is denied
+// operations that the controller never performs, so that over-broad
+// policies are caught.
+func TestController_ACLTokenLeastPrivilege(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"controller.enabled":           "true",
+		"global.tls.enabled":           "true",
+		"global.acls.manageSystemACLs": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+	consulCluster.Create(t)
+
+	consulClient := consulCluster.SetupConsulClient(t, true)
+
+	controllerToken := consulCluster.ComponentACLToken(t, "controller")
+
+	logger.Log(t, "checking the policies of the controller's token")
+	consul.RequireNotGlobalManagement(t, consulClient, controllerToken)
+	for _, policy := range consul.TokenPolicies(t, consulClient, controllerToken) {
+		logger.Logf(t, "rules of policy %s:\n%s", policy.Name, policy.Rules)
+		rules := strings.Join(strings.Fields(policy.Rules), " ")
+		require.NotContains(t, rules, "key", "policy %s grants access to the KV store", policy.Name)
+		require.NotContains(t, rules, `acl = "write"`, "policy %s grants ACL write access", policy.Name)
+	}
+
+	writeOptions := &api.WriteOptions{Token: controllerToken}
+
+	logger.Log(t, "checking that the controller's token is denied operations outside its scope")
+	_, err := consulClient.KV().Put(&api.KVPair{Key: "controller-acl-test", Value: []byte("denied")}, writeOptions)
+	consul.RequirePermissionDenied(t, err, "KV write with the controller's token should be denied")
+
+	_, err = consulClient.Catalog().Register(&api.CatalogRegistration{Node: "controller-acl-test", Address: "127.0.0.1"}, writeOptions)
+	consul.RequirePermissionDenied(t, err, "node registration with the controller's token should be denied")
+
+	_, _, err = consulClient.ACL().TokenCreate(&api.ACLToken{Description: "controller-acl-test"}, writeOptions)
+	consul.RequirePermissionDenied(t, err, "ACL token creation with the controller's token should be denied")
+
+	logger.Log(t, "checking that the controller's token can still write config entries")
+	_, _, err = consulClient.ConfigEntries().Set(&api.ServiceConfigEntry{
+		Kind:     api.ServiceDefaults,
+		Name:     "controller-acl-test",
+		Protocol: "http",
+	}, writeOptions)
+	require.NoError(t, err)
+	_, err = consulClient.ConfigEntries().Delete(api.ServiceDefaults, "controller-acl-test", writeOptions)
+	require.NoError(t, err)
+
+	logger.Log(t, "checking that the controller syncs custom resources with its token")
+	serviceDefaults := fixtures.NewServiceDefaults("defaults").WithProtocol("http")
+	serviceDefaults.Apply(t, ctx.KubectlOptions(t))
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+		serviceDefaults.Delete(t, ctx.KubectlOptions(t))
+	})
+	recordFirstSync := metrics.Time(t, metrics.PhaseFirstSync)
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		k8s.RequireCRDCondition(r, t, ctx.KubectlOptions(t), "servicedefaults", "defaults", k8s.ConditionSynced, "True", "")
+	})
+	recordFirstSync()
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/metrics"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// churnIterations is the number of times TestControllerChurn
+// creates and deletes the custom resources.
+const churnIterations = 20
+
+// Test that creating and deleting the same custom resources in quick succession
+// leaves Consul in the same state as Kubernetes. Each resource is deleted
+// before the controller has had time to reconcile its creation, so that
+// reconciles of old and new versions of a resource race with each other.
+// Delete waits for the resources to be gone, so if the controller leaks
+// a finalizer, the test hangs rather than moving on to the next iteration.
+func TestControllerChurn(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"controller.enabled": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+	consulClient := consulCluster.SetupConsulClient(t, false)
+
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+		fixtures.Delete(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+	})
+
+	// The controller can take upwards of 1m to perform leader election, so
+	// wait until it has synced the custom resources once before churning them.
+	// Otherwise, the churn would happen before the controller is running.
+	logger.Log(t, "creating custom resources and waiting for the controller to sync them")
+	fixtures.Apply(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		requireChurnConfigEntries(r, consulClient, true)
+	})
+	fixtures.Delete(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+
+	logger.Logf(t, "creating and deleting custom resources %d times", churnIterations)
+	start := time.Now()
+	for i := 0; i < churnIterations; i++ {
+		fixtures.Apply(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+		fixtures.Delete(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+	}
+	logger.Logf(t, "churned custom resources in %s", time.Since(start))
+
+	logger.Log(t, "checking that no config entries are left in Consul")
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		requireChurnConfigEntries(r, consulClient, false)
+	})
+
+	logger.Log(t, "creating custom resources after the churn")
+	fixtures.Apply(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+
+	logger.Log(t, "checking that the custom resources are synced to Consul")
+	recordFirstSync := metrics.Time(t, metrics.PhaseFirstSync)
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		for kind, name := range churnCustomResources {
+			k8s.RequireCRDCondition(r, t, ctx.KubectlOptions(t), kind, name, k8s.ConditionSynced, "True", "")
+		}
+		requireChurnConfigEntries(r, consulClient, true)
+
+		entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
+		require.NoError(r, err)
+		svcDefaultEntry, ok := entry.(*api.ServiceConfigEntry)
+		require.True(r, ok, "could not cast to ServiceConfigEntry")
+		require.Equal(r, "http", svcDefaultEntry.Protocol)
+	})
+	recordFirstSync()
+
+	logger.Log(t, "deleting custom resources")
+	fixtures.Delete(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+
+	logger.Log(t, "checking that the config entries have been deleted")
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		requireChurnConfigEntries(r, consulClient, false)
+	})
+}
+
+// churnCustomResources are the kinds and names of
+// the custom resources in fixtures.DefaultCustomResources.
+var churnCustomResources = map[string]string{
+	"servicedefaults":   "defaults",
+	"serviceresolver":   "resolver",
+	"proxydefaults":     "global",
+	"servicerouter":     "router",
+	"servicesplitter":   "splitter",
+	"serviceintentions": "intentions",
+}
+
+// requireChurnConfigEntries checks that the config entries created from
+// fixtures.DefaultCustomResources exist in Consul if exist is true,
+// and that none of them exist otherwise.
+func requireChurnConfigEntries(r *retry.R, client *api.Client, exist bool) {
+	kindNames := append([][2]string{{api.ProxyDefaults, "global"}}, namespacedConfigEntries...)
+	for _, kindName := range kindNames {
+		_, _, err := client.ConfigEntries().Get(kindName[0], kindName[1], nil)
+		if exist {
+			require.NoError(r, err, "%s %q does not exist", kindName[0], kindName[1])
+		} else {
+			require.Error(r, err, "%s %q still exists", kindName[0], kindName[1])
+			require.Contains(r, err.Error(), "404 (Config entry not found")
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the controller doesn't overwrite a config entry that was created
+// in Consul outside of Kubernetes, and that it takes ownership of the config entry
+// once the externally managed config entry is deleted.
+func TestControllerConfigEntryConflict(t *testing.T) {
+	cfg := suite.Config()
+
+	cases := []struct {
+		secure      bool
+		autoEncrypt bool
+	}{
+		{false, false},
+		{true, false},
+		{true, true},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("secure: %t; auto-encrypt: %t", c.secure, c.autoEncrypt)
+		t.Run(name, func(t *testing.T) {
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"controller.enabled":           "true",
+				"connectInject.enabled":        "true",
+				"global.tls.enabled":           strconv.FormatBool(c.secure),
+				"global.tls.enableAutoEncrypt": strconv.FormatBool(c.autoEncrypt),
+				"global.acls.manageSystemACLs": strconv.FormatBool(c.secure),
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+			consulClient := consulCluster.SetupConsulClient(t, c.secure)
+
+			// Create the config entry in Consul first so that it is not managed by Kubernetes.
+			consul.CreateConfigEntry(t, consulClient, cfg.NoCleanupOnFailure, cfg.NoCleanup, &api.ServiceConfigEntry{
+				Kind:     api.ServiceDefaults,
+				Name:     "defaults",
+				Protocol: "grpc",
+			}, nil)
+
+			logger.Log(t, "creating service-defaults custom resource")
+			serviceDefaults := fixtures.NewServiceDefaults("defaults").WithProtocol("http")
+			serviceDefaults.Apply(t, ctx.KubectlOptions(t))
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				serviceDefaults.Delete(t, ctx.KubectlOptions(t))
+			})
+
+			// On startup, the controller can take upwards of 1m to perform
+			// leader election so we may need to wait a long time for
+			// the reconcile loop to run (hence the -timeout-controller-sync timeout here).
+			logger.Log(t, "checking that the custom resource fails to sync and the config entry is unchanged")
+			helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+				k8s.RequireCRDCondition(r, t, ctx.KubectlOptions(t), "servicedefaults", "defaults", k8s.ConditionSynced, "False", k8s.ReasonExternallyManagedConfigError)
+			})
+
+			entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
+			require.NoError(t, err)
+			svcDefaultEntry, ok := entry.(*api.ServiceConfigEntry)
+			require.True(t, ok, "could not cast to ServiceConfigEntry")
+			require.Equal(t, "grpc", svcDefaultEntry.Protocol)
+
+			// Once the externally managed config entry is deleted,
+			// the controller should create it from the custom resource.
+			consul.DeleteConfigEntry(t, consulClient, api.ServiceDefaults, "defaults", nil)
+
+			logger.Log(t, "checking that the custom resource takes over the config entry")
+			helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+				entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
+				require.NoError(r, err)
+				svcDefaultEntry, ok := entry.(*api.ServiceConfigEntry)
+				require.True(r, ok, "could not cast to ServiceConfigEntry")
+				require.Equal(r, "http", svcDefaultEntry.Protocol)
+
+				k8s.RequireCRDCondition(r, t, ctx.KubectlOptions(t), "servicedefaults", "defaults", k8s.ConditionSynced, "True", "")
+			})
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// leaderAnnotation is the annotation on the leader election config map
+// that records the current leader.
+const leaderAnnotation = "control-plane.alpha.kubernetes.io/leader"
+
+// Test that when the controller runs with more than one replica
+// and the leader is killed while it's reconciling custom resources,
+// a standby replica takes over leadership and finishes syncing
+// all config entries.
+func TestControllerHA(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"controller.enabled":  "true",
+		"controller.replicas": "2",
+	}
+
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, helpers.RandomName())
+
+	consulCluster.Create(t)
+	consulClient := consulCluster.SetupConsulClient(t, false)
+
+	logger.Log(t, "waiting for a controller leader to be elected")
+	var leader string
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		leader = controllerLeader(t, r, ctx, consulCluster)
+		require.NotEmpty(r, leader, "no controller leader elected")
+	})
+	logger.Logf(t, "controller leader is %s", leader)
+
+	logger.Log(t, "creating custom resources")
+	fixtures.Apply(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+		fixtures.Delete(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+	})
+
+	// Kill the leader while it's reconciling the custom resources.
+	k8s.KillPod(t, ctx.KubectlOptions(t), leader)
+	killedAt := time.Now()
+
+	logger.Log(t, "waiting for a new controller leader to be elected")
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		newLeader := controllerLeader(t, r, ctx, consulCluster)
+		require.NotEmpty(r, newLeader, "no controller leader elected")
+		require.NotEqual(r, leader, newLeader, "the killed pod is still the leader")
+		logger.Logf(t, "new controller leader is %s", newLeader)
+	})
+
+	logger.Log(t, "checking that all config entries are synced")
+	configEntries := append([][2]string{{api.ProxyDefaults, "global"}}, namespacedConfigEntries...)
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		for _, kindName := range configEntries {
+			_, _, err := consulClient.ConfigEntries().Get(kindName[0], kindName[1], nil)
+			require.NoError(r, err, "%s %s has not been synced", kindName[0], kindName[1])
+		}
+	})
+	logger.Logf(t, "took %s to sync all config entries after killing the leader", time.Since(killedAt))
+}
+
+// controllerLeader returns the name of the controller pod of consulCluster that
+// holds the leader election lock, or an empty string if there's no leader.
+// The lock is a config map whose leader annotation holds the identity of the
+// leader, which is prefixed with the name of the leader's pod.
+func controllerLeader(t *testing.T, r *retry.R, ctx environment.TestContext, consulCluster consul.Cluster) string {
+	client := ctx.KubernetesClient(t)
+	namespace := ctx.KubectlOptions(t).Namespace
+
+	// The pods are listed with r rather than with consulCluster.ControllerPod
+	// so that errors are retried.
+	pods, err := client.CoreV1().Pods(namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: consulCluster.ComponentSelector(helpers.ComponentController)})
+	require.NoError(r, err)
+
+	configMaps, err := client.CoreV1().ConfigMaps(namespace).List(helpers.TestContext(t), metav1.ListOptions{})
+	require.NoError(r, err)
+
+	for _, configMap := range configMaps.Items {
+		record, ok := configMap.Annotations[leaderAnnotation]
+		if !ok {
+			continue
+		}
+		var leaderRecord struct {
+			HolderIdentity string `json:"holderIdentity"`
+		}
+		require.NoError(r, json.Unmarshal([]byte(record), &leaderRecord))
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp == nil && strings.HasPrefix(leaderRecord.HolderIdentity, pod.Name+"_") {
+				return pod.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that, with a single Consul destination namespace, the ACL tokens
+// that server-acl-init creates for the connect injector and the controller
+// aren't management tokens, and that the controller's token can only
+// write config entries in the destination namespace.
+func TestControllerNamespaces_ACLTokenScoping(t *testing.T) {
+	cfg := suite.Config()
+	if !cfg.EnableEnterprise {
+		t.Skipf("skipping this test because -enable-enterprise is not set")
+	}
+	ctx := suite.Environment().DefaultContext(t)
+
+	destinationNamespace := cfg.ConsulNamespace(ConsulDestNS)
+	helmValues := map[string]string{
+		"global.enableConsulNamespaces": "true",
+		"controller.enabled":            "true",
+		"connectInject.enabled":         "true",
+
+		"connectInject.consulNamespaces.consulDestinationNamespace": destinationNamespace,
+		"connectInject.consulNamespaces.mirroringK8S":               "false",
+
+		"global.acls.manageSystemACLs": "true",
+		"global.tls.enabled":           "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	consulClient := consulCluster.SetupConsulClient(t, true)
+
+	// The destination namespace may only be created by the controller or
+	// the injector when they first need it, and the other namespace is
+	// one that neither of them should be able to write to.
+	otherNamespace := cfg.ConsulNamespace("other-" + helpers.RandomName())
+	for _, namespace := range []string{destinationNamespace, otherNamespace} {
+		existing, _, err := consulClient.Namespaces().Read(namespace, nil)
+		require.NoError(t, err)
+		if existing != nil {
+			continue
+		}
+		logger.Logf(t, "creating Consul namespace %s", namespace)
+		_, _, err = consulClient.Namespaces().Create(&api.Namespace{Name: namespace}, nil)
+		require.NoError(t, err)
+		ns := namespace
+		helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+			consulClient.Namespaces().Delete(ns, nil)
+		})
+	}
+
+	for _, component := range []string{"connect-inject", "controller"} {
+		logger.Logf(t, "checking that the %s token is not a management token", component)
+		consul.RequireNotGlobalManagement(t, consulClient, consulCluster.ComponentACLToken(t, component))
+	}
+
+	controllerToken := consulCluster.ComponentACLToken(t, "controller")
+	entry := &api.ServiceConfigEntry{
+		Kind:     api.ServiceDefaults,
+		Name:     "namespace-acl-test",
+		Protocol: "http",
+	}
+
+	logger.Logf(t, "checking that the controller's token can write config entries in %s", destinationNamespace)
+	writeOptions := &api.WriteOptions{Token: controllerToken, Namespace: destinationNamespace}
+	_, _, err := consulClient.ConfigEntries().Set(entry, writeOptions)
+	require.NoError(t, err)
+	_, err = consulClient.ConfigEntries().Delete(api.ServiceDefaults, entry.Name, writeOptions)
+	require.NoError(t, err)
+
+	for _, namespace := range []string{"default", otherNamespace} {
+		logger.Logf(t, "checking that the controller's token can't write config entries in %s", namespace)
+		_, _, err := consulClient.ConfigEntries().Set(entry, &api.WriteOptions{Token: controllerToken, Namespace: namespace})
+		consul.RequirePermissionDenied(t, err, "writing a config entry in namespace %s with the controller's token should be denied", namespace)
+	}
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// mirroringPrefix is the prefix of the Consul namespaces
+// that Kubernetes namespaces are mirrored to in the test below.
+const mirroringPrefix = "k8s-"
+
+// Test that the controller syncs custom resources from Kubernetes namespaces
+// that connectInject.k8sAllowNamespaces and connectInject.k8sDenyNamespaces
+// exclude from injection. Those lists only apply to the connect injector:
+// the controller watches all namespaces, so the custom resources are synced
+// and get the Synced condition, to the Consul namespace that the namespace
+// would be mirrored to with connectInject.consulNamespaces.mirroringK8SPrefix,
+// or to connectInject.consulNamespaces.consulDestinationNamespace if mirroring
+// is disabled. They must never fall back to the default Consul namespace.
+func TestControllerNamespaces_ExcludedFromInjection(t *testing.T) {
+	cfg := suite.Config()
+	if !cfg.EnableEnterprise {
+		t.Skipf("skipping this test because -enable-enterprise is not set")
+	}
+
+	cases := []struct {
+		name string
+		// helmValues returns the Helm values that exclude
+		// the namespace with the given name from injection.
+		helmValues func(excluded string) map[string]string
+		mirrorK8S  bool
+	}{
+		{
+			name: "mirroring with prefix; namespace not in allow list",
+			helmValues: func(_ string) map[string]string {
+				return map[string]string{"connectInject.k8sAllowNamespaces": "{default}"}
+			},
+			mirrorK8S: true,
+		},
+		{
+			name: "mirroring with prefix; namespace in deny list",
+			helmValues: func(excluded string) map[string]string {
+				return map[string]string{
+					"connectInject.k8sAllowNamespaces": "{*}",
+					"connectInject.k8sDenyNamespaces":  fmt.Sprintf("{%s}", excluded),
+				}
+			},
+			mirrorK8S: true,
+		},
+		{
+			name: "single destination namespace; namespace in deny list",
+			helmValues: func(excluded string) map[string]string {
+				return map[string]string{
+					"connectInject.k8sAllowNamespaces": "{*}",
+					"connectInject.k8sDenyNamespaces":  fmt.Sprintf("{%s}", excluded),
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := suite.Environment().DefaultContext(t)
+
+			// The namespace needs to exist before installing
+			// so that its name can be set in the Helm values.
+			excludedOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+			excludedNS := excludedOpts.Namespace
+
+			helmValues := c.helmValues(excludedNS)
+			helmValues["global.enableConsulNamespaces"] = "true"
+			helmValues["controller.enabled"] = "true"
+			helmValues["connectInject.enabled"] = "true"
+			helmValues["connectInject.consulNamespaces.consulDestinationNamespace"] = cfg.ConsulNamespace(ConsulDestNS)
+			helmValues["connectInject.consulNamespaces.mirroringK8S"] = strconv.FormatBool(c.mirrorK8S)
+			helmValues["connectInject.consulNamespaces.mirroringK8SPrefix"] = cfg.ConsulNamespace(mirroringPrefix)
+
+			// The Helm values include the random name of the excluded namespace,
+			// so no two cases could share a cluster from a TestSuite.
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+
+			consulNS := cfg.ConsulNamespace(ConsulDestNS)
+			if c.mirrorK8S {
+				consulNS = cfg.ConsulNamespace(mirroringPrefix + excludedNS)
+			}
+			consulClient := consulCluster.SetupConsulClientInNamespace(t, false, consulNS)
+			defaultNSClient := consulCluster.SetupConsulClientInNamespace(t, false, DefaultConsulNamespace)
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				consul.CleanupNamespaces(t, defaultNSClient, cfg.ConsulNamespacePrefix)
+			})
+
+			logger.Logf(t, "creating service-defaults in namespace %s, which is excluded from injection", excludedNS)
+			serviceDefaults := fixtures.NewServiceDefaults("excluded").WithProtocol("http")
+			serviceDefaults.Apply(t, excludedOpts)
+			// NOTE: No need to clean up because the namespace will be deleted.
+
+			logger.Logf(t, "checking that the service-defaults are synced to Consul namespace %s", consulNS)
+			helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+				k8s.RequireCRDCondition(r, t, excludedOpts, "servicedefaults", "excluded", k8s.ConditionSynced, "True", "")
+
+				entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "excluded", nil)
+				require.NoError(r, err)
+				svcDefaultEntry, ok := entry.(*api.ServiceConfigEntry)
+				require.True(r, ok, "could not cast to ServiceConfigEntry")
+				require.Equal(r, "http", svcDefaultEntry.Protocol)
+				require.Equal(r, consulNS, svcDefaultEntry.Namespace)
+			})
+
+			logger.Log(t, "checking that the service-defaults aren't synced to the default Consul namespace")
+			_, _, err := defaultNSClient.ConfigEntries().Get(api.ServiceDefaults, "excluded", nil)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "404 (Config entry not found")
+		})
+	}
+}
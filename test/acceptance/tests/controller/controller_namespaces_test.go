@@ -1,23 +1,24 @@
 package controller
 
 import (
-	"fmt"
 	"strconv"
-	"strings"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
-	KubeNS                 = "ns1"
 	ConsulDestNS           = "from-k8s"
 	DefaultConsulNamespace = "default"
 
@@ -57,48 +58,41 @@ func TestControllerNamespaces(t *testing.T) {
 		},
 		{
 			"mirror k8s namespaces",
-			KubeNS,
+			"",
 			true,
 			false,
 		},
 		{
 			"mirror k8s namespaces; secure",
-			KubeNS,
+			"",
 			true,
 			true,
 		},
 	}
 
+	testSuite := suite.TestSuite(t)
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			ctx := suite.Environment().DefaultContext(t)
-
 			helmValues := map[string]string{
 				"global.enableConsulNamespaces": "true",
 				"controller.enabled":            "true",
 				"connectInject.enabled":         "true",
 
 				// When mirroringK8S is set, this setting is ignored.
-				"connectInject.consulNamespaces.consulDestinationNamespace": c.destinationNamespace,
+				"connectInject.consulNamespaces.consulDestinationNamespace": cfg.ConsulNamespace(c.destinationNamespace),
 				"connectInject.consulNamespaces.mirroringK8S":               strconv.FormatBool(c.mirrorK8S),
+				"connectInject.consulNamespaces.mirroringK8SPrefix":         cfg.ConsulNamespacePrefix,
 
 				"global.acls.manageSystemACLs": strconv.FormatBool(c.secure),
 				"global.tls.enabled":           strconv.FormatBool(c.secure),
 			}
 
-			releaseName := helpers.RandomName()
-			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+			consulCluster, ctx := testSuite.HelmCluster(t, suite.Environment().DefaultContext(t), helmValues)
 
-			consulCluster.Create(t)
-
-			logger.Logf(t, "creating namespace %q", KubeNS)
-			out, err := k8s.RunKubectlAndGetOutputE(t, ctx.KubectlOptions(t), "create", "ns", KubeNS)
-			if err != nil && !strings.Contains(out, "(AlreadyExists)") {
-				require.NoError(t, err)
-			}
-			helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "ns", KubeNS)
-			})
+			// Use a random namespace so that config entries created by this test
+			// don't collide with the ones created by other tests.
+			kubeNSOptions := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+			kubeNS := kubeNSOptions.Namespace
 
 			// Make sure that config entries are created in the correct namespace.
 			// If mirroring is enabled, we expect config entries to be created in the
@@ -106,69 +100,64 @@ func TestControllerNamespaces(t *testing.T) {
 			// Kubernetes namespace.
 			// If a single destination namespace is set, we expect all config entries
 			// to be created in that destination Consul namespace.
-			queryOpts := &api.QueryOptions{Namespace: KubeNS}
+			consulNS := cfg.ConsulNamespace(kubeNS)
 			if !c.mirrorK8S {
-				queryOpts = &api.QueryOptions{Namespace: c.destinationNamespace}
-			}
-			defaultOpts := &api.QueryOptions{
-				Namespace: DefaultConsulNamespace,
+				consulNS = cfg.ConsulNamespace(c.destinationNamespace)
 			}
-			consulClient := consulCluster.SetupConsulClient(t, c.secure)
+			consulClient := consulCluster.SetupConsulClientInNamespace(t, c.secure, consulNS)
+			// Proxy defaults are always created in the default Consul namespace.
+			defaultNSClient := consulCluster.SetupConsulClientInNamespace(t, c.secure, DefaultConsulNamespace)
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				consul.CleanupNamespaces(t, defaultNSClient, cfg.ConsulNamespacePrefix)
+			})
 
 			// Test creation.
 			{
 				logger.Log(t, "creating custom resources")
-				retry.Run(t, func(r *retry.R) {
-					// Retry the kubectl apply because we've seen sporadic
-					// "connection refused" errors where the mutating webhook
-					// endpoint fails initially.
-					out, err := k8s.RunKubectlAndGetOutputE(t, ctx.KubectlOptions(t), "apply", "-n", KubeNS, "-f", "../fixtures/crds")
-					require.NoError(r, err, out)
-					// NOTE: No need to clean up because the namespace will be deleted.
-				})
+				fixtures.Apply(t, kubeNSOptions, fixtures.DefaultCustomResources()...)
+				// NOTE: No need to clean up because the namespace will be deleted.
 
 				// On startup, the controller can take upwards of 1m to perform
 				// leader election so we may need to wait a long time for
-				// the reconcile loop to run (hence the 1m timeout here).
-				counter := &retry.Counter{Count: 60, Wait: 1 * time.Second}
-				retry.RunWith(counter, t, func(r *retry.R) {
+				// the reconcile loop to run (hence the -timeout-controller-sync timeout here).
+				helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
 					// service-defaults
-					entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", queryOpts)
+					entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
 					require.NoError(r, err)
 					svcDefaultEntry, ok := entry.(*api.ServiceConfigEntry)
 					require.True(r, ok, "could not cast to ServiceConfigEntry")
 					require.Equal(r, "http", svcDefaultEntry.Protocol)
 
 					// service-resolver
-					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceResolver, "resolver", queryOpts)
+					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceResolver, "resolver", nil)
 					require.NoError(r, err)
 					svcResolverEntry, ok := entry.(*api.ServiceResolverConfigEntry)
 					require.True(r, ok, "could not cast to ServiceResolverConfigEntry")
 					require.Equal(r, "bar", svcResolverEntry.Redirect.Service)
 
 					// proxy-defaults
-					entry, _, err = consulClient.ConfigEntries().Get(api.ProxyDefaults, "global", defaultOpts)
+					entry, _, err = defaultNSClient.ConfigEntries().Get(api.ProxyDefaults, "global", nil)
 					require.NoError(r, err)
 					proxyDefaultEntry, ok := entry.(*api.ProxyConfigEntry)
 					require.True(r, ok, "could not cast to ProxyConfigEntry")
 					require.Equal(r, api.MeshGatewayModeLocal, proxyDefaultEntry.MeshGateway.Mode)
 
 					// service-router
-					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceRouter, "router", queryOpts)
+					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceRouter, "router", nil)
 					require.NoError(r, err)
 					svcRouterEntry, ok := entry.(*api.ServiceRouterConfigEntry)
 					require.True(r, ok, "could not cast to ServiceRouterConfigEntry")
 					require.Equal(r, "/foo", svcRouterEntry.Routes[0].Match.HTTP.PathPrefix)
 
 					// service-splitter
-					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceSplitter, "splitter", queryOpts)
+					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceSplitter, "splitter", nil)
 					require.NoError(r, err)
 					svcSplitterEntry, ok := entry.(*api.ServiceSplitterConfigEntry)
 					require.True(r, ok, "could not cast to ServiceSplitterConfigEntry")
 					require.Equal(r, float32(100), svcSplitterEntry.Splits[0].Weight)
 
 					// service-intentions
-					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceIntentions, IntentionName, queryOpts)
+					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceIntentions, IntentionName, nil)
 					require.NoError(r, err)
 					svcIntentions, ok := entry.(*api.ServiceIntentionsConfigEntry)
 					require.True(r, ok, "could not cast to ServiceSplitterConfigEntry")
@@ -178,60 +167,58 @@ func TestControllerNamespaces(t *testing.T) {
 
 			// Test updates.
 			{
-				logger.Log(t, "patching service-defaults custom resource")
+				logger.Log(t, "updating service-defaults custom resource")
 				patchProtocol := "tcp"
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "-n", KubeNS, "servicedefaults", "defaults", "-p", fmt.Sprintf(`{"spec":{"protocol":"%s"}}`, patchProtocol), "--type=merge")
+				fixtures.NewServiceDefaults("defaults").WithProtocol(patchProtocol).Apply(t, kubeNSOptions)
 
-				logger.Log(t, "patching service-resolver custom resource")
+				logger.Log(t, "updating service-resolver custom resource")
 				patchRedirectSvc := "baz"
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "-n", KubeNS, "serviceresolver", "resolver", "-p", fmt.Sprintf(`{"spec":{"redirect":{"service": "%s"}}}`, patchRedirectSvc), "--type=merge")
+				fixtures.NewServiceResolver("resolver").WithRedirect(patchRedirectSvc).Apply(t, kubeNSOptions)
 
-				logger.Log(t, "patching proxy-defaults custom resource")
-				patchMeshGatewayMode := "remote"
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "-n", KubeNS, "proxydefaults", "global", "-p", fmt.Sprintf(`{"spec":{"meshGateway":{"mode": "%s"}}}`, patchMeshGatewayMode), "--type=merge")
+				logger.Log(t, "updating proxy-defaults custom resource")
+				fixtures.NewProxyDefaults().WithMeshGatewayMode("remote").Apply(t, kubeNSOptions)
 
-				logger.Log(t, "patching service-router custom resource")
+				logger.Log(t, "updating service-router custom resource")
 				patchPathPrefix := "/baz"
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "-n", KubeNS, "servicerouter", "router", "-p", fmt.Sprintf(`{"spec":{"routes":[{"match":{"http":{"pathPrefix":"%s"}}}]}}`, patchPathPrefix), "--type=merge")
+				fixtures.NewServiceRouter("router").WithPathPrefixRoute(patchPathPrefix).Apply(t, kubeNSOptions)
 
-				logger.Log(t, "patching service-splitter custom resource")
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "-n", KubeNS, "servicesplitter", "splitter", "-p", `{"spec": {"splits": [{"weight": 50}, {"weight": 50, "service": "other-splitter"}]}}`, "--type=merge")
+				logger.Log(t, "updating service-splitter custom resource")
+				fixtures.NewServiceSplitter("splitter").WithSplit(50, "").WithSplit(50, "other-splitter").Apply(t, kubeNSOptions)
 
-				logger.Log(t, "patching service-intentions custom resource")
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "-n", KubeNS, "serviceintentions", "intentions", "-p", `{"spec": {"sources": [{"name": "svc2", "action": "deny"}]}}`, "--type=merge")
+				logger.Log(t, "updating service-intentions custom resource")
+				fixtures.NewServiceIntentions("intentions", IntentionName).WithSource("svc2", "deny").Apply(t, kubeNSOptions)
 
-				counter := &retry.Counter{Count: 10, Wait: 500 * time.Millisecond}
-				retry.RunWith(counter, t, func(r *retry.R) {
+				helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
 					// service-defaults
-					entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", queryOpts)
+					entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
 					require.NoError(r, err)
 					svcDefaultEntry, ok := entry.(*api.ServiceConfigEntry)
 					require.True(r, ok, "could not cast to ServiceConfigEntry")
 					require.Equal(r, patchProtocol, svcDefaultEntry.Protocol)
 
 					// service-resolver
-					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceResolver, "resolver", queryOpts)
+					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceResolver, "resolver", nil)
 					require.NoError(r, err)
 					svcResolverEntry, ok := entry.(*api.ServiceResolverConfigEntry)
 					require.True(r, ok, "could not cast to ServiceResolverConfigEntry")
 					require.Equal(r, patchRedirectSvc, svcResolverEntry.Redirect.Service)
 
 					// proxy-defaults
-					entry, _, err = consulClient.ConfigEntries().Get(api.ProxyDefaults, "global", defaultOpts)
+					entry, _, err = defaultNSClient.ConfigEntries().Get(api.ProxyDefaults, "global", nil)
 					require.NoError(r, err)
 					proxyDefaultsEntry, ok := entry.(*api.ProxyConfigEntry)
 					require.True(r, ok, "could not cast to ProxyConfigEntry")
 					require.Equal(r, api.MeshGatewayModeRemote, proxyDefaultsEntry.MeshGateway.Mode)
 
 					// service-router
-					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceRouter, "router", queryOpts)
+					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceRouter, "router", nil)
 					require.NoError(r, err)
 					svcRouterEntry, ok := entry.(*api.ServiceRouterConfigEntry)
 					require.True(r, ok, "could not cast to ServiceRouterConfigEntry")
 					require.Equal(r, patchPathPrefix, svcRouterEntry.Routes[0].Match.HTTP.PathPrefix)
 
 					// service-splitter
-					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceSplitter, "splitter", queryOpts)
+					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceSplitter, "splitter", nil)
 					require.NoError(r, err)
 					svcSplitter, ok := entry.(*api.ServiceSplitterConfigEntry)
 					require.True(r, ok, "could not cast to ServiceSplitterConfigEntry")
@@ -240,7 +227,7 @@ func TestControllerNamespaces(t *testing.T) {
 					require.Equal(r, "other-splitter", svcSplitter.Splits[1].Service)
 
 					// service-intentions
-					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceIntentions, IntentionName, queryOpts)
+					entry, _, err = consulClient.ConfigEntries().Get(api.ServiceIntentions, IntentionName, nil)
 					require.NoError(r, err)
 					svcIntentions, ok := entry.(*api.ServiceIntentionsConfigEntry)
 					require.True(r, ok, "could not cast to ServiceIntentionsConfigEntry")
@@ -251,52 +238,51 @@ func TestControllerNamespaces(t *testing.T) {
 			// Test a delete.
 			{
 				logger.Log(t, "deleting service-defaults custom resource")
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", KubeNS, "servicedefaults", "defaults")
+				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", kubeNS, "servicedefaults", "defaults")
 
 				logger.Log(t, "deleting service-resolver custom resource")
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", KubeNS, "serviceresolver", "resolver")
+				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", kubeNS, "serviceresolver", "resolver")
 
 				logger.Log(t, "deleting proxy-defaults custom resource")
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", KubeNS, "proxydefaults", "global")
+				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", kubeNS, "proxydefaults", "global")
 
 				logger.Log(t, "deleting service-router custom resource")
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", KubeNS, "servicerouter", "router")
+				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", kubeNS, "servicerouter", "router")
 
 				logger.Log(t, "deleting service-splitter custom resource")
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", KubeNS, "servicesplitter", "splitter")
+				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", kubeNS, "servicesplitter", "splitter")
 
 				logger.Log(t, "deleting service-intentions custom resource")
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", KubeNS, "serviceintentions", "intentions")
+				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "-n", kubeNS, "serviceintentions", "intentions")
 
-				counter := &retry.Counter{Count: 10, Wait: 500 * time.Millisecond}
-				retry.RunWith(counter, t, func(r *retry.R) {
+				helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
 					// service-defaults
-					_, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", queryOpts)
+					_, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
 					require.Error(r, err)
 					require.Contains(r, err.Error(), "404 (Config entry not found")
 
 					// service-resolver
-					_, _, err = consulClient.ConfigEntries().Get(api.ServiceResolver, "resolver", queryOpts)
+					_, _, err = consulClient.ConfigEntries().Get(api.ServiceResolver, "resolver", nil)
 					require.Error(r, err)
 					require.Contains(r, err.Error(), "404 (Config entry not found")
 
 					// proxy-defaults
-					_, _, err = consulClient.ConfigEntries().Get(api.ProxyDefaults, "global", defaultOpts)
+					_, _, err = defaultNSClient.ConfigEntries().Get(api.ProxyDefaults, "global", nil)
 					require.Error(r, err)
 					require.Contains(r, err.Error(), "404 (Config entry not found")
 
 					// service-router
-					_, _, err = consulClient.ConfigEntries().Get(api.ServiceRouter, "router", queryOpts)
+					_, _, err = consulClient.ConfigEntries().Get(api.ServiceRouter, "router", nil)
 					require.Error(r, err)
 					require.Contains(r, err.Error(), "404 (Config entry not found")
 
 					// service-splitter
-					_, _, err = consulClient.ConfigEntries().Get(api.ServiceSplitter, "splitter", queryOpts)
+					_, _, err = consulClient.ConfigEntries().Get(api.ServiceSplitter, "splitter", nil)
 					require.Error(r, err)
 					require.Contains(r, err.Error(), "404 (Config entry not found")
 
 					// service-intentions
-					_, _, err = consulClient.ConfigEntries().Get(api.ServiceIntentions, IntentionName, queryOpts)
+					_, _, err = consulClient.ConfigEntries().Get(api.ServiceIntentions, IntentionName, nil)
 					require.Error(r, err)
 					require.Contains(r, err.Error(), "404 (Config entry not found")
 				})
@@ -304,3 +290,114 @@ func TestControllerNamespaces(t *testing.T) {
 		})
 	}
 }
+
+// Test that deleting a whole Kubernetes namespace with custom resources in it
+// isn't blocked by the controller's finalizers and that the corresponding
+// config entries are deleted from Consul.
+func TestControllerNamespaces_NamespaceDeletion(t *testing.T) {
+	cfg := suite.Config()
+	if !cfg.EnableEnterprise {
+		t.Skipf("skipping this test because -enable-enterprise is not set")
+	}
+
+	cases := []struct {
+		name                 string
+		destinationNamespace string
+		mirrorK8S            bool
+		secure               bool
+	}{
+		{
+			"single destination namespace (non-default)",
+			ConsulDestNS,
+			false,
+			false,
+		},
+		{
+			"mirror k8s namespaces; secure",
+			"",
+			true,
+			true,
+		},
+	}
+
+	testSuite := suite.TestSuite(t)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			helmValues := map[string]string{
+				"global.enableConsulNamespaces": "true",
+				"controller.enabled":            "true",
+				"connectInject.enabled":         "true",
+
+				// When mirroringK8S is set, this setting is ignored.
+				"connectInject.consulNamespaces.consulDestinationNamespace": cfg.ConsulNamespace(c.destinationNamespace),
+				"connectInject.consulNamespaces.mirroringK8S":               strconv.FormatBool(c.mirrorK8S),
+				"connectInject.consulNamespaces.mirroringK8SPrefix":         cfg.ConsulNamespacePrefix,
+
+				"global.acls.manageSystemACLs": strconv.FormatBool(c.secure),
+				"global.tls.enabled":           strconv.FormatBool(c.secure),
+			}
+
+			consulCluster, ctx := testSuite.HelmCluster(t, suite.Environment().DefaultContext(t), helmValues)
+
+			kubeNSOptions := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+			kubeNS := kubeNSOptions.Namespace
+
+			consulNS := cfg.ConsulNamespace(kubeNS)
+			if !c.mirrorK8S {
+				consulNS = cfg.ConsulNamespace(c.destinationNamespace)
+			}
+			consulClient := consulCluster.SetupConsulClientInNamespace(t, c.secure, consulNS)
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				consul.CleanupNamespaces(t, consulClient, cfg.ConsulNamespacePrefix)
+			})
+
+			logger.Log(t, "creating custom resources")
+			fixtures.Apply(t, kubeNSOptions, fixtures.DefaultCustomResources()...)
+
+			// On startup, the controller can take upwards of 1m to perform
+			// leader election so we may need to wait a long time for
+			// the reconcile loop to run (hence the -timeout-controller-sync timeout here).
+			logger.Log(t, "waiting for config entries to be created")
+			helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+				for _, kindName := range namespacedConfigEntries {
+					_, _, err := consulClient.ConfigEntries().Get(kindName[0], kindName[1], nil)
+					require.NoError(r, err)
+				}
+				_, _, err := consulClient.ConfigEntries().Get(api.ProxyDefaults, "global", &api.QueryOptions{Namespace: DefaultConsulNamespace})
+				require.NoError(r, err)
+			})
+
+			// Delete the namespace rather than the individual custom resources
+			// and wait for it to be gone. If the controller's finalizers wedge
+			// namespace termination, this will time out.
+			logger.Logf(t, "deleting namespace %q", kubeNS)
+			k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "namespace", kubeNS, "--wait=false")
+			helpers.RetryEventually(t, 2*time.Minute, func(r *retry.R) {
+				_, err := ctx.KubernetesClient(t).CoreV1().Namespaces().Get(helpers.TestContext(t), kubeNS, metav1.GetOptions{})
+				require.True(r, errors.IsNotFound(err), "namespace %q has not been deleted", kubeNS)
+			})
+
+			logger.Log(t, "checking that config entries have been deleted")
+			helpers.RetryEventually(t, 30*time.Second, func(r *retry.R) {
+				for _, kindName := range namespacedConfigEntries {
+					_, _, err := consulClient.ConfigEntries().Get(kindName[0], kindName[1], nil)
+					require.Error(r, err)
+					require.Contains(r, err.Error(), "404 (Config entry not found")
+				}
+				_, _, err := consulClient.ConfigEntries().Get(api.ProxyDefaults, "global", &api.QueryOptions{Namespace: DefaultConsulNamespace})
+				require.Error(r, err)
+				require.Contains(r, err.Error(), "404 (Config entry not found")
+			})
+		})
+	}
+}
+
+// namespacedConfigEntries are the kinds and names of the config entries
+// created from fixtures.DefaultCustomResources that live in the destination Consul namespace.
+var namespacedConfigEntries = [][2]string{
+	{api.ServiceDefaults, "defaults"},
+	{api.ServiceResolver, "resolver"},
+	{api.ServiceRouter, "router"},
+	{api.ServiceSplitter, "splitter"},
+	{api.ServiceIntentions, IntentionName},
+}
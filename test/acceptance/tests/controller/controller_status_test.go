@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the controller sets the Synced condition of a custom resource
+// to False when Consul is unavailable, and back to True once Consul
+// is available again and the resource has been synced.
+func TestControllerStatus_ConsulOutage(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"controller.enabled":    "true",
+		"connectInject.enabled": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+	consulCluster.Create(t)
+
+	logger.Log(t, "creating service-defaults custom resource")
+	serviceDefaults := fixtures.NewServiceDefaults("defaults").WithProtocol("http")
+	serviceDefaults.Apply(t, ctx.KubectlOptions(t))
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+		serviceDefaults.Delete(t, ctx.KubectlOptions(t))
+	})
+
+	logger.Log(t, "checking that the custom resource is synced")
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		k8s.RequireCRDCondition(r, t, ctx.KubectlOptions(t), "servicedefaults", "defaults", k8s.ConditionSynced, "True", "")
+	})
+
+	// The client is created before the servers are scaled down
+	// so that the test also checks that it works after they're back.
+	consulClient := consulCluster.SetupConsulClient(t, false)
+
+	serverStatefulSet := fmt.Sprintf("%s-consul-server", releaseName)
+	logger.Log(t, "scaling down the Consul servers")
+	k8s.RunKubectl(t, ctx.KubectlOptions(t), "scale", "statefulset", serverStatefulSet, "--replicas=0")
+	k8s.RunKubectl(t, ctx.KubectlOptions(t), "wait", "--for=delete", "pod", "-l", consulCluster.ComponentSelector(helpers.ComponentServer), fmt.Sprintf("--timeout=%s", timeouts.PodsReady()))
+
+	logger.Log(t, "updating service-defaults custom resource while Consul is unavailable")
+	serviceDefaults.WithProtocol("tcp").Apply(t, ctx.KubectlOptions(t))
+
+	logger.Log(t, "checking that the custom resource fails to sync")
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		k8s.RequireCRDCondition(r, t, ctx.KubectlOptions(t), "servicedefaults", "defaults", k8s.ConditionSynced, "False", k8s.ReasonConsulAgentError)
+	})
+
+	logger.Log(t, "scaling up the Consul servers")
+	k8s.RunKubectl(t, ctx.KubectlOptions(t), "scale", "statefulset", serverStatefulSet, "--replicas=1")
+	helpers.WaitForAllPodsRunning(t, ctx.KubernetesClient(t), ctx.KubectlOptions(t).Namespace, releaseName, helpers.ComponentServer)
+
+	logger.Log(t, "checking that the custom resource is synced once Consul is available")
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		k8s.RequireCRDCondition(r, t, ctx.KubectlOptions(t), "servicedefaults", "defaults", k8s.ConditionSynced, "True", "")
+	})
+
+	entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
+	require.NoError(t, err)
+	svcDefaultEntry, ok := entry.(*api.ServiceConfigEntry)
+	require.True(t, ok, "could not cast to ServiceConfigEntry")
+	require.Equal(t, "tcp", svcDefaultEntry.Protocol)
+}
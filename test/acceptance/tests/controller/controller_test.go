@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"strconv"
 	"testing"
-	"time"
 
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/metrics"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/stretchr/testify/require"
@@ -32,6 +34,17 @@ func TestController(t *testing.T) {
 	// the same as the kube name of the resource.
 	const IntentionName = "svc1"
 
+	// customResources are the kinds and names of
+	// the custom resources in fixtures.DefaultCustomResources.
+	customResources := map[string]string{
+		"servicedefaults":   "defaults",
+		"serviceresolver":   "resolver",
+		"proxydefaults":     "global",
+		"servicerouter":     "router",
+		"servicesplitter":   "splitter",
+		"serviceintentions": "intentions",
+	}
+
 	for _, c := range cases {
 		name := fmt.Sprintf("secure: %t; auto-encrypt: %t", c.secure, c.autoEncrypt)
 		t.Run(name, func(t *testing.T) {
@@ -54,24 +67,17 @@ func TestController(t *testing.T) {
 			// Test creation.
 			{
 				logger.Log(t, "creating custom resources")
-				retry.Run(t, func(r *retry.R) {
-					// Retry the kubectl apply because we've seen sporadic
-					// "connection refused" errors where the mutating webhook
-					// endpoint fails initially.
-					out, err := k8s.RunKubectlAndGetOutputE(t, ctx.KubectlOptions(t), "apply", "-f", "../fixtures/crds")
-					require.NoError(r, err, out)
-					helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
-						// Ignore errors here because if the test ran as expected
-						// the custom resources will have been deleted.
-						k8s.RunKubectlAndGetOutputE(t, ctx.KubectlOptions(t), "delete", "-f", "../fixtures/crds")
-					})
+				fixtures.Apply(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+				helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+					// If the test ran as expected the custom resources
+					// will have been deleted, which Delete ignores.
+					fixtures.Delete(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
 				})
 
 				// On startup, the controller can take upwards of 1m to perform
 				// leader election so we may need to wait a long time for
-				// the reconcile loop to run (hence the 1m timeout here).
-				counter := &retry.Counter{Count: 60, Wait: 1 * time.Second}
-				retry.RunWith(counter, t, func(r *retry.R) {
+				// the reconcile loop to run (hence the -timeout-controller-sync timeout here).
+				helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
 					// service-defaults
 					entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
 					require.NoError(r, err)
@@ -115,34 +121,44 @@ func TestController(t *testing.T) {
 					require.Equal(r, api.IntentionActionAllow, svcIntentionsEntry.Sources[0].Action)
 					require.Equal(r, api.IntentionActionAllow, svcIntentionsEntry.Sources[1].Permissions[0].Action)
 				})
+
+				logger.Log(t, "checking that the custom resources have the synced status")
+				recordFirstSync := metrics.Time(t, metrics.PhaseFirstSync)
+				helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+					for kind, name := range customResources {
+						k8s.RequireCRDCondition(r, t, ctx.KubectlOptions(t), kind, name, k8s.ConditionSynced, "True", "")
+					}
+				})
+				recordFirstSync()
 			}
 
 			// Test updates.
 			{
-				logger.Log(t, "patching service-defaults custom resource")
+				logger.Log(t, "updating service-defaults custom resource")
 				patchProtocol := "tcp"
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "servicedefaults", "defaults", "-p", fmt.Sprintf(`{"spec":{"protocol":"%s"}}`, patchProtocol), "--type=merge")
+				fixtures.NewServiceDefaults("defaults").WithProtocol(patchProtocol).Apply(t, ctx.KubectlOptions(t))
 
-				logger.Log(t, "patching service-resolver custom resource")
+				logger.Log(t, "updating service-resolver custom resource")
 				patchRedirectSvc := "baz"
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "serviceresolver", "resolver", "-p", fmt.Sprintf(`{"spec":{"redirect":{"service": "%s"}}}`, patchRedirectSvc), "--type=merge")
+				fixtures.NewServiceResolver("resolver").WithRedirect(patchRedirectSvc).Apply(t, ctx.KubectlOptions(t))
 
-				logger.Log(t, "patching proxy-defaults custom resource")
-				patchMeshGatewayMode := "remote"
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "proxydefaults", "global", "-p", fmt.Sprintf(`{"spec":{"meshGateway":{"mode": "%s"}}}`, patchMeshGatewayMode), "--type=merge")
+				logger.Log(t, "updating proxy-defaults custom resource")
+				fixtures.NewProxyDefaults().WithMeshGatewayMode("remote").Apply(t, ctx.KubectlOptions(t))
 
-				logger.Log(t, "patching service-router custom resource")
+				logger.Log(t, "updating service-router custom resource")
 				patchPathPrefix := "/baz"
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "servicerouter", "router", "-p", fmt.Sprintf(`{"spec":{"routes":[{"match":{"http":{"pathPrefix":"%s"}}}]}}`, patchPathPrefix), "--type=merge")
+				fixtures.NewServiceRouter("router").WithPathPrefixRoute(patchPathPrefix).Apply(t, ctx.KubectlOptions(t))
 
-				logger.Log(t, "patching service-splitter custom resource")
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "servicesplitter", "splitter", "-p", `{"spec": {"splits": [{"weight": 50}, {"weight": 50, "service": "other-splitter"}]}}`, "--type=merge")
+				logger.Log(t, "updating service-splitter custom resource")
+				fixtures.NewServiceSplitter("splitter").WithSplit(50, "").WithSplit(50, "other-splitter").Apply(t, ctx.KubectlOptions(t))
 
-				logger.Log(t, "patching service-intentions custom resource")
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "patch", "serviceintentions", "intentions", "-p", `{"spec": {"sources": [{"name": "svc2", "action": "deny"}, {"name": "svc3", "permissions": [{"action": "deny", "http": {"pathExact": "/foo", "methods": ["GET", "PUT"]}}]}]}}`, "--type=merge")
+				logger.Log(t, "updating service-intentions custom resource")
+				fixtures.NewServiceIntentions("intentions", IntentionName).
+					WithSource("svc2", "deny").
+					WithSourcePermissions("svc3", fixtures.HTTPPermission{Action: "deny", PathExact: "/foo", Methods: []string{"GET", "PUT"}}).
+					Apply(t, ctx.KubectlOptions(t))
 
-				counter := &retry.Counter{Count: 10, Wait: 500 * time.Millisecond}
-				retry.RunWith(counter, t, func(r *retry.R) {
+				helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
 					// service-defaults
 					entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
 					require.NoError(r, err)
@@ -210,8 +226,7 @@ func TestController(t *testing.T) {
 				logger.Log(t, "deleting service-intentions custom resource")
 				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "serviceintentions", "intentions")
 
-				counter := &retry.Counter{Count: 10, Wait: 500 * time.Millisecond}
-				retry.RunWith(counter, t, func(r *retry.R) {
+				helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
 					// service-defaults
 					_, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
 					require.Error(r, err)
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the controller's validating webhook rejects invalid custom resources
+// with messages pointing at the invalid fields, and that once the
+// custom resources are corrected, they are synced to Consul.
+func TestControllerValidation(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"controller.enabled":    "true",
+		"connectInject.enabled": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+	consulClient := consulCluster.SetupConsulClient(t, false)
+
+	cases := []struct {
+		name            string
+		invalidFixture  string
+		expectedErrors  []string
+		validFixtures   []fixtures.Fixture
+		configEntryKind string
+		configEntryName string
+	}{
+		{
+			name:            "service-defaults with an invalid expose path protocol",
+			invalidFixture:  "../fixtures/crds-invalid/servicedefaults.yaml",
+			expectedErrors:  []string{`servicedefaults.consul.hashicorp.com "defaults"`, "spec.expose.paths[0].protocol", `"tcp"`},
+			validFixtures:   []fixtures.Fixture{fixtures.NewServiceDefaults("defaults").WithProtocol("http")},
+			configEntryKind: api.ServiceDefaults,
+			configEntryName: "defaults",
+		},
+		{
+			name:           "service-splitter with weights that don't add up to 100",
+			invalidFixture: "../fixtures/crds-invalid/servicesplitter.yaml",
+			expectedErrors: []string{`servicesplitter.consul.hashicorp.com "splitter"`, "spec.splits"},
+			validFixtures: []fixtures.Fixture{
+				fixtures.NewServiceDefaults("splitter").WithProtocol("http"),
+				fixtures.NewServiceSplitter("splitter").WithSplit(100, ""),
+			},
+			configEntryKind: api.ServiceSplitter,
+			configEntryName: "splitter",
+		},
+		{
+			name:           "service-intentions with both an action and permissions",
+			invalidFixture: "../fixtures/crds-invalid/serviceintentions.yaml",
+			expectedErrors: []string{`serviceintentions.consul.hashicorp.com "intentions"`, "spec.sources[0]"},
+			validFixtures: []fixtures.Fixture{
+				fixtures.NewServiceDefaults("svc1").WithProtocol("http"),
+				fixtures.NewServiceIntentions("intentions", IntentionName).WithSource("svc2", "allow"),
+			},
+			configEntryKind: api.ServiceIntentions,
+			configEntryName: IntentionName,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			logger.Logf(t, "applying invalid custom resource %s", c.invalidFixture)
+			out := k8s.KubectlApplyExpectError(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, c.invalidFixture)
+			require.Contains(t, out, "denied the request")
+			for _, expectedErr := range c.expectedErrors {
+				require.Contains(t, out, expectedErr)
+			}
+
+			logger.Log(t, "applying corrected custom resources")
+			fixtures.Apply(t, ctx.KubectlOptions(t), c.validFixtures...)
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				fixtures.Delete(t, ctx.KubectlOptions(t), c.validFixtures...)
+			})
+
+			// On startup, the controller can take upwards of 1m to perform
+			// leader election so we may need to wait a long time for
+			// the reconcile loop to run (hence the -timeout-controller-sync timeout here).
+			helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+				_, _, err := consulClient.ConfigEntries().Get(c.configEntryKind, c.configEntryName, nil)
+				require.NoError(r, err)
+			})
+		})
+	}
+}
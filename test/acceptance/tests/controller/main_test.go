@@ -10,6 +10,6 @@ import (
 var suite testSuite.Suite
 
 func TestMain(m *testing.M) {
-	suite = testSuite.NewSuite(m)
+	suite = testSuite.NewSuite(m, testSuite.SmokeCases("TestController/secure: false; auto-encrypt: false"))
 	os.Exit(suite.Run())
 }
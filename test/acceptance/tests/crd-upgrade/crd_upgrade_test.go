@@ -0,0 +1,162 @@
+package crdupgrade
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	serviceDefaultsCRD = "servicedefaults.consul.hashicorp.com"
+
+	// crdAPI is the group version of the chart's CRDs.
+	// It was removed in Kubernetes 1.22.
+	crdAPI = "apiextensions.k8s.io/v1beta1"
+
+	// newSpecField is the field added to the service-defaults CRD
+	// to simulate a new version of the chart adding fields to CRDs.
+	newSpecField = "newField"
+)
+
+// Test that upgrading the CRDs to a new version that adds fields,
+// followed by a Helm upgrade, doesn't break existing custom resources:
+// they continue to be reconciled by the controller and can be updated
+// using the new fields.
+func TestCRDUpgrade(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	// The test edits the CRDs' schemas, which have a different structure
+	// in newer versions of the CRD API.
+	environment.SkipUnlessAPIResource(t, ctx, crdAPI, "CustomResourceDefinition")
+
+	// Install the CRDs separately from the Helm release
+	// so that they can be upgraded independently.
+	crdsDir := filepath.Join(t.TempDir(), "crds")
+	consul.WriteChartCRDs(t, crdsDir)
+	k8s.ApplyCRDs(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, crdsDir)
+
+	helmValues := map[string]string{
+		"controller.enabled": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName, consul.SkipCRDInstall())
+
+	consulCluster.Create(t)
+	consulClient := consulCluster.SetupConsulClient(t, false)
+
+	logger.Log(t, "creating custom resources")
+	fixtures.Apply(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+		fixtures.Delete(t, ctx.KubectlOptions(t), fixtures.DefaultCustomResources()...)
+	})
+
+	logger.Log(t, "waiting for config entries to be created")
+	requireServiceDefaultsProtocol(t, consulClient, "http")
+
+	// Simulate a new version of the chart that adds a field to a CRD.
+	logger.Log(t, "upgrading CRDs")
+	upgradedCRDsDir := filepath.Join(t.TempDir(), "crds-upgraded")
+	consul.WriteChartCRDs(t, upgradedCRDsDir)
+	addSpecField(t, filepath.Join(upgradedCRDsDir, "crd-servicedefaults.yaml"), newSpecField)
+	k8s.ApplyCRDs(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, upgradedCRDsDir)
+
+	fieldType, err := k8s.RunKubectlAndGetOutputE(t, ctx.KubectlOptions(t), "get", "crd", serviceDefaultsCRD, "-o",
+		"jsonpath={.spec.validation.openAPIV3Schema.properties.spec.properties."+newSpecField+".type}")
+	require.NoError(t, err)
+	require.Equal(t, "string", fieldType)
+
+	// Upgrade the release so that the controller is restarted,
+	// as it would be when upgrading to a new chart version.
+	consulCluster.Upgrade(t, map[string]string{
+		"controller.logLevel": "debug",
+	})
+
+	logger.Log(t, "checking that existing custom resources are still synced")
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		for _, kind := range []string{"servicedefaults", "serviceresolver", "proxydefaults", "servicerouter", "servicesplitter", "serviceintentions"} {
+			synced, err := k8s.RunKubectlAndGetOutputE(t, ctx.KubectlOptions(t), "get", kind, "-o", `jsonpath={.items[*].status.conditions[?(@.type=="Synced")].status}`)
+			require.NoError(r, err)
+			require.Equal(r, "True", synced, "%s is not synced", kind)
+		}
+	})
+
+	logger.Log(t, "updating service-defaults custom resource using the new field")
+	// Patch rather than apply fixtures because the fixtures
+	// can't set fields that the released CRDs don't have.
+	out, err := k8s.RunKubectlAndGetOutputE(t, ctx.KubectlOptions(t), "patch", "servicedefaults", "defaults", "--type=merge",
+		"-p", `{"spec":{"protocol":"tcp","`+newSpecField+`":"value"}}`)
+	require.NoError(t, err, out)
+	requireServiceDefaultsProtocol(t, consulClient, "tcp")
+}
+
+// requireServiceDefaultsProtocol waits for the service-defaults config entry
+// created from fixtures.DefaultCustomResources to have the expected protocol.
+func requireServiceDefaultsProtocol(t *testing.T, consulClient *api.Client, protocol string) {
+	t.Helper()
+
+	// On startup, the controller can take upwards of 1m to perform
+	// leader election so we may need to wait a long time for
+	// the reconcile loop to run (hence the -timeout-controller-sync timeout here).
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
+		require.NoError(r, err)
+		svcDefaultEntry, ok := entry.(*api.ServiceConfigEntry)
+		require.True(r, ok, "could not cast to ServiceConfigEntry")
+		require.Equal(r, protocol, svcDefaultEntry.Protocol)
+	})
+}
+
+// addSpecField adds a string field with the given name to the spec
+// of the custom resource definition in file, rewriting the file as JSON.
+func addSpecField(t *testing.T, file, field string) {
+	t.Helper()
+
+	contents, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+
+	// Rendered templates start with a document separator, so skip any
+	// empty documents before the custom resource definition.
+	var crd map[string]interface{}
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(contents), 4096)
+	for len(crd) == 0 {
+		require.NoError(t, decoder.Decode(&crd))
+	}
+
+	specProperties := nestedMap(t, crd, "spec", "validation", "openAPIV3Schema", "properties", "spec", "properties")
+	specProperties[field] = map[string]interface{}{
+		"description": "A field added by a new version of the CRD.",
+		"type":        "string",
+	}
+
+	jsonContents, err := json.MarshalIndent(crd, "", "  ")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(file, jsonContents, 0644))
+}
+
+// nestedMap returns the map at the path of keys in m.
+func nestedMap(t *testing.T, m map[string]interface{}, keys ...string) map[string]interface{} {
+	t.Helper()
+
+	for _, key := range keys {
+		next, ok := m[key].(map[string]interface{})
+		require.True(t, ok, "%s is not an object", key)
+		m = next
+	}
+	return m
+}
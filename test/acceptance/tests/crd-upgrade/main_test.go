@@ -0,0 +1,15 @@
+package crdupgrade
+
+import (
+	"os"
+	"testing"
+
+	testsuite "github.com/hashicorp/consul-helm/test/acceptance/framework/suite"
+)
+
+var suite testsuite.Suite
+
+func TestMain(m *testing.M) {
+	suite = testsuite.NewSuite(m, testsuite.SmokeCases("TestCRDUpgrade"))
+	os.Exit(suite.Run())
+}
@@ -2,7 +2,6 @@
 package example
 
 import (
-	"context"
 	"testing"
 
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
@@ -41,14 +40,14 @@ func TestExample(t *testing.T) {
 	k8s.KubectlApply(t, ctx.KubectlOptions(t), "path/to/config")
 
 	// Clean up any Kubernetes resources you have created
-	helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
 		k8s.KubectlDelete(t, ctx.KubectlOptions(t), "path/to/config")
 	})
 
 	// Similarly, you can obtain Kubernetes client from your test context.
 	// You can use it to, for example, read all services in a namespace:
 	k8sClient := ctx.KubernetesClient(t)
-	services, err := k8sClient.CoreV1().Services(ctx.KubectlOptions(t).Namespace).List(context.Background(), metav1.ListOptions{})
+	services, err := k8sClient.CoreV1().Services(ctx.KubectlOptions(t).Namespace).List(helpers.TestContext(t), metav1.ListOptions{})
 	require.NoError(t, err)
 	require.NotNil(t, services.Items)
 
@@ -11,8 +11,9 @@ var suite testsuite.Suite
 
 func TestMain(m *testing.M) {
 	// First, uncomment the line below to create a new suite so that all flags are parsed.
+	// Register a representative case of the suite that runs when -smoke-only is set.
 	/*
-		suite = framework.NewSuite(m)
+		suite = framework.NewSuite(m, framework.SmokeCases("TestExample/secure: false"))
 	*/
 
 	// If the test suite needs to run only when certain test flags are passed,
@@ -0,0 +1,130 @@
+package externalservers
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const staticClientName = "static-client"
+const staticServerName = "static-server"
+
+// Test that connect inject, the controller and catalog sync work
+// when the chart is installed with externalServers pointing at Consul
+// servers that aren't part of the release, with TLS and ACLs enabled.
+// The external servers are installed by a separate Helm release with
+// only servers enabled. Both releases use the same custom CA so that
+// clients can verify the servers' certificates.
+// Note that externalServers.useSystemRoots can only be tested with
+// servers whose certificates are signed by a public CA, so the
+// consul-k8s components use the CA from global.tls.caCert instead.
+func TestExternalServers(t *testing.T) {
+	cases := []struct {
+		autoEncrypt bool
+	}{
+		{false},
+		{true},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("auto-encrypt: %t", c.autoEncrypt)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+			ca := consul.GenerateCA(t, "Consul Agent CA")
+
+			serverHelmValues := map[string]string{
+				"global.tls.enabled":           "true",
+				"global.tls.httpsOnly":         "true",
+				"global.acls.manageSystemACLs": "true",
+
+				// Clients are installed by the release that uses
+				// the external servers. They can't be installed twice
+				// because they use host ports.
+				"client.enabled": "false",
+			}
+			serverReleaseName := helpers.RandomName()
+			serverCluster := consul.NewHelmCluster(t, serverHelmValues, ctx, cfg, serverReleaseName, consul.WithCustomCA(ca))
+			serverCluster.Create(t)
+
+			serverHost := fmt.Sprintf("%s-consul-server", serverReleaseName)
+			helmValues := map[string]string{
+				"server.enabled":            "false",
+				"externalServers.enabled":   "true",
+				"externalServers.hosts[0]":  serverHost,
+				"externalServers.httpsPort": "8501",
+				"client.join[0]":            serverHost,
+
+				"global.tls.enabled":           "true",
+				"global.tls.enableAutoEncrypt": strconv.FormatBool(c.autoEncrypt),
+				"global.tls.httpsOnly":         "true",
+
+				"global.acls.manageSystemACLs":          "true",
+				"global.acls.bootstrapToken.secretName": fmt.Sprintf("%s-consul-bootstrap-acl-token", serverReleaseName),
+				"global.acls.bootstrapToken.secretKey":  "token",
+
+				"connectInject.enabled": "true",
+				"controller.enabled":    "true",
+				"syncCatalog.enabled":   "true",
+			}
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName, consul.WithCustomCA(ca))
+			consulCluster.Create(t)
+
+			consulClient := serverCluster.SetupConsulClient(t, true)
+
+			logger.Log(t, "creating static-server and static-client deployments")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+			logger.Log(t, "checking that the connection is not successful because there's no intention")
+			k8s.CheckStaticServerConnectionFailing(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+			logger.Log(t, "creating intention")
+			_, _, err := consulClient.Connect().IntentionCreate(&api.Intention{
+				SourceName:      staticClientName,
+				DestinationName: staticServerName,
+				Action:          api.IntentionActionAllow,
+			}, nil)
+			require.NoError(t, err)
+
+			logger.Log(t, "checking that connection is successful")
+			k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+			logger.Log(t, "checking that the static-server service has been synced to Consul")
+			syncedServiceName := fmt.Sprintf("%s-%s", staticServerName, ctx.KubectlOptions(t).Namespace)
+			helpers.RetryEventually(t, 1*time.Minute, func(r *retry.R) {
+				services, _, err := consulClient.Catalog().Services(nil)
+				require.NoError(r, err)
+				require.Contains(r, services, syncedServiceName)
+			})
+
+			logger.Log(t, "creating service-defaults custom resource")
+			serviceDefaults := fixtures.NewServiceDefaults("defaults").WithProtocol("http")
+			serviceDefaults.Apply(t, ctx.KubectlOptions(t))
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				serviceDefaults.Delete(t, ctx.KubectlOptions(t))
+			})
+
+			logger.Log(t, "checking that the service-defaults config entry has been created in Consul")
+			helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+				entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
+				require.NoError(r, err)
+				svcDefaultEntry, ok := entry.(*api.ServiceConfigEntry)
+				require.True(r, ok, "could not cast to ServiceConfigEntry")
+				require.Equal(r, "http", svcDefaultEntry.Protocol)
+			})
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package hostnetwork
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const staticClientName = "static-client"
+
+// serfLANPort is the port the client agents gossip on.
+const serfLANPort = 8301
+
+// serfMemberAlive is the status of a serf member that is alive.
+const serfMemberAlive = 1
+
+// Test that client agents whose gossip ports are exposed on their node,
+// either through hostPorts or host networking, advertise their node's IP
+// and can be reached on it by the servers. This is what servers running
+// outside of the pod network rely on to join the clients.
+// Connect injection must continue to work in these configurations.
+func TestClientHostNetworking(t *testing.T) {
+	cases := []struct {
+		exposeGossipPorts bool
+		hostNetwork       bool
+	}{
+		{true, false},
+		{false, true},
+		{true, true},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("exposeGossipPorts: %t; hostNetwork: %t", c.exposeGossipPorts, c.hostNetwork)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"connectInject.enabled":    "true",
+				"client.exposeGossipPorts": strconv.FormatBool(c.exposeGossipPorts),
+				"client.hostNetwork":       strconv.FormatBool(c.hostNetwork),
+			}
+			if c.hostNetwork {
+				// Host networking needs this DNS policy so that
+				// the clients can still resolve cluster-local names.
+				helmValues["client.dnsPolicy"] = "ClusterFirstWithHostNet"
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+			consulClient := consulCluster.SetupConsulClient(t, false)
+
+			logger.Log(t, "checking that clients advertise and gossip on their node's IP")
+			hostIPs := clientHostIPs(t, consulCluster)
+			require.NotEmpty(t, hostIPs, "no client pods found")
+			helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+				members, err := consulClient.Agent().Members(false)
+				require.NoError(r, err)
+
+				// The servers only consider the clients alive if they
+				// can gossip with them on their advertised address.
+				membersByName := make(map[string]*api.AgentMember)
+				for _, member := range members {
+					membersByName[member.Name] = member
+				}
+				for nodeName, hostIP := range hostIPs {
+					member, ok := membersByName[nodeName]
+					require.True(r, ok, "client on node %s has not joined", nodeName)
+					require.Equal(r, hostIP, member.Addr, "client on node %s is not advertising its node's IP", nodeName)
+					require.Equal(r, uint16(serfLANPort), member.Port)
+					require.Equal(r, serfMemberAlive, member.Status, "client on node %s is not alive", nodeName)
+				}
+			})
+
+			logger.Log(t, "creating static-server and static-client deployments")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+			logger.Log(t, "checking that connection is successful")
+			k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+		})
+	}
+}
+
+// clientHostIPs returns the IPs of the nodes that the client pods
+// of consulCluster are running on, keyed by node name. The client agents
+// use the name of their node as their Consul node name.
+func clientHostIPs(t *testing.T, consulCluster consul.Cluster) map[string]string {
+	t.Helper()
+
+	hostIPs := make(map[string]string)
+	for _, pod := range consulCluster.ClientPods(t) {
+		require.NotEmpty(t, pod.Status.HostIP, "client pod %s has no host IP", pod.Name)
+		hostIPs[pod.Spec.NodeName] = pod.Status.HostIP
+	}
+	return hostIPs
+}
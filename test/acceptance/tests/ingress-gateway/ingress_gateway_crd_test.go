@@ -0,0 +1,128 @@
+package ingressgateway
+
+import (
+	"fmt"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/metrics"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const staticServerHost = "static-server.example.com"
+
+// Test that an ingress gateway routes requests by their Host header to
+// a service whose protocol is set by a ServiceDefaults custom resource,
+// both on a plain HTTP listener and with TLS enabled on the gateway.
+// The controller of this chart version doesn't support IngressGateway
+// custom resources, so the ingress-gateway config entry is written with
+// the Consul API. Consul only accepts it once the controller has synced
+// the ServiceDefaults, because HTTP listeners require HTTP services.
+func TestIngressGateway_HostRoutingWithCRDs(t *testing.T) {
+	ctx := suite.Environment().DefaultContext(t)
+	cfg := suite.Config()
+	helmValues := map[string]string{
+		"connectInject.enabled":                "true",
+		"controller.enabled":                   "true",
+		"ingressGateways.enabled":              "true",
+		"ingressGateways.gateways[0].name":     "ingress-gateway",
+		"ingressGateways.gateways[0].replicas": "1",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	logger.Log(t, "creating server")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+
+	// We use the static-client pod so that we can make calls to the ingress gateway
+	// via kubectl exec without needing a route into the cluster from the test machine.
+	logger.Log(t, "creating static-client pod")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/bases/static-client")
+
+	logger.Log(t, "creating service-defaults custom resource for static-server")
+	serviceDefaults := fixtures.NewServiceDefaults("static-server").WithProtocol("http")
+	serviceDefaults.Apply(t, ctx.KubectlOptions(t))
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+		serviceDefaults.Delete(t, ctx.KubectlOptions(t))
+	})
+	recordFirstSync := metrics.Time(t, metrics.PhaseFirstSync)
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		k8s.RequireCRDCondition(r, t, ctx.KubectlOptions(t), "servicedefaults", "static-server", k8s.ConditionSynced, "True", "")
+	})
+	recordFirstSync()
+
+	consulClient := consulCluster.SetupConsulClient(t, false)
+	gatewayAddress := fmt.Sprintf("%s-consul-ingress-gateway:8080", releaseName)
+
+	logger.Log(t, "creating ingress-gateway config entry with an HTTP listener")
+	setIngressGatewayHosts(t, consulClient, false)
+
+	logger.Log(t, "checking that requests are routed by their Host header")
+	requireHostRouting(t, ctx.KubectlOptions(t), "http://"+gatewayAddress, false)
+
+	logger.Log(t, "enabling TLS on the ingress gateway")
+	setIngressGatewayHosts(t, consulClient, true)
+
+	logger.Log(t, "checking that the listener only accepts TLS connections")
+	k8s.CheckHTTP(t, ctx.KubectlOptions(t), "static-client",
+		k8s.HTTPRequest{URL: "http://" + gatewayAddress, Headers: []string{"Host: " + staticServerHost}},
+		k8s.HTTPExpectation{FailureMessages: []string{"Empty reply from server", "Recv failure", "Connection reset"}})
+
+	logger.Log(t, "checking that TLS requests are routed by their Host header")
+	requireHostRouting(t, ctx.KubectlOptions(t), "https://"+gatewayAddress, true)
+}
+
+// setIngressGatewayHosts writes the ingress-gateway config entry with an
+// HTTP listener on port 8080 that routes staticServerHost to static-server.
+func setIngressGatewayHosts(t *testing.T, consulClient *api.Client, tls bool) {
+	t.Helper()
+
+	created, _, err := consulClient.ConfigEntries().Set(&api.IngressGatewayConfigEntry{
+		Kind: api.IngressGateway,
+		Name: "ingress-gateway",
+		TLS:  api.GatewayTLSConfig{Enabled: tls},
+		Listeners: []api.IngressListener{
+			{
+				Port:     8080,
+				Protocol: "http",
+				Services: []api.IngressService{
+					{
+						Name:  "static-server",
+						Hosts: []string{staticServerHost},
+					},
+				},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+	require.True(t, created, "config entry failed")
+}
+
+// requireHostRouting checks that the gateway at url routes requests for
+// staticServerHost to static-server and doesn't route requests for other
+// hosts, including the default host of static-server that the custom
+// hosts replace.
+func requireHostRouting(t *testing.T, options *terratestk8s.KubectlOptions, url string, insecure bool) {
+	t.Helper()
+
+	k8s.CheckHTTP(t, options, "static-client",
+		k8s.HTTPRequest{URL: url, Headers: []string{"Host: " + staticServerHost}, Insecure: insecure},
+		k8s.HTTPExpectation{Body: "hello world"})
+
+	for _, host := range []string{"static-server.ingress.consul", "other.example.com"} {
+		k8s.CheckHTTP(t, options, "static-client",
+			k8s.HTTPRequest{URL: url, Headers: []string{"Host: " + host}, Insecure: insecure},
+			k8s.HTTPExpectation{StatusCode: 404})
+	}
+}
@@ -80,7 +80,7 @@ func TestIngressGatewaySingleNamespace(t *testing.T) {
 
 			logger.Logf(t, "creating Kubernetes namespace %s", testNamespace)
 			k8s.RunKubectl(t, ctx.KubectlOptions(t), "create", "ns", testNamespace)
-			helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
 				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "ns", testNamespace)
 			})
 
@@ -91,12 +91,12 @@ func TestIngressGatewaySingleNamespace(t *testing.T) {
 			}
 
 			logger.Logf(t, "creating server in %s namespace", testNamespace)
-			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
 
 			// We use the static-client pod so that we can make calls to the ingress gateway
 			// via kubectl exec without needing a route into the cluster from the test machine.
 			logger.Logf(t, "creating static-client in %s namespace", testNamespace)
-			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/bases/static-client")
+			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/bases/static-client")
 
 			// With the cluster up, we can create our ingress-gateway config entry.
 			logger.Log(t, "creating config entry")
@@ -171,13 +171,10 @@ func TestIngressGatewayNamespaceMirroring(t *testing.T) {
 			true,
 		},
 	}
+	testSuite := suite.TestSuite(t)
 	for _, c := range cases {
 		name := fmt.Sprintf("secure: %t", c.secure)
 		t.Run(name, func(t *testing.T) {
-			ctx := suite.Environment().DefaultContext(t)
-
-			// Install the Helm chart without the ingress gateway first
-			// so that we can create the namespace for it.
 			helmValues := map[string]string{
 				"connectInject.enabled":                       "true",
 				"connectInject.consulNamespaces.mirroringK8S": "true",
@@ -191,30 +188,18 @@ func TestIngressGatewayNamespaceMirroring(t *testing.T) {
 				"ingressGateways.gateways[0].replicas": "1",
 			}
 
-			releaseName := helpers.RandomName()
-			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+			consulCluster, ctx := testSuite.HelmCluster(t, suite.Environment().DefaultContext(t), helmValues)
 
-			consulCluster.Create(t)
-
-			logger.Logf(t, "creating Kubernetes namespace %s", testNamespace)
-			k8s.RunKubectl(t, ctx.KubectlOptions(t), "create", "ns", testNamespace)
-			helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "ns", testNamespace)
-			})
-
-			nsK8SOptions := &terratestk8s.KubectlOptions{
-				ContextName: ctx.KubectlOptions(t).ContextName,
-				ConfigPath:  ctx.KubectlOptions(t).ConfigPath,
-				Namespace:   testNamespace,
-			}
+			nsK8SOptions := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+			testNamespace := nsK8SOptions.Namespace
 
 			logger.Logf(t, "creating server in %s namespace", testNamespace)
-			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
 
 			// We use the static-client pod so that we can make calls to the ingress gateway
 			// via kubectl exec without needing a route into the cluster from the test machine.
 			logger.Logf(t, "creating static-client in %s namespace", testNamespace)
-			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/bases/static-client")
+			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/bases/static-client")
 
 			consulClient := consulCluster.SetupConsulClient(t, c.secure)
 
@@ -240,7 +225,10 @@ func TestIngressGatewayNamespaceMirroring(t *testing.T) {
 			require.NoError(t, err)
 			require.Equal(t, true, created, "config entry failed")
 
-			ingressGatewayService := fmt.Sprintf("http://%s-consul-ingress-gateway.%s:8080/", releaseName, ctx.KubectlOptions(t).Namespace)
+			// The gateway runs in the namespace of the release,
+			// which isn't the namespace of ctx when clusters are reused.
+			gateway := consulCluster.IngressGatewayService(t, "ingress-gateway")
+			ingressGatewayService := fmt.Sprintf("http://%s.%s:8080/", gateway.Name, gateway.Namespace)
 
 			// If ACLs are enabled, test that intentions prevent connections.
 			if c.secure {
@@ -0,0 +1,159 @@
+package l7routing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const staticClientName = "static-client"
+const staticServerName = "static-server"
+
+// requestsPerCheck is the number of requests sent to check how traffic is distributed.
+// With a 50/50 split, the chance that fewer than 30 of them go to one version is tiny.
+const requestsPerCheck = 100
+
+// Test that traffic between connect-injected pods follows the service-resolver,
+// service-splitter and service-router config entries created as custom resources.
+// The static-server service has two versions, v1 and v2, that respond with their
+// version, so the test can check where each request was actually routed to
+// rather than only that the config entries were synced to Consul.
+func TestL7Routing(t *testing.T) {
+	cases := []struct {
+		secure      bool
+		autoEncrypt bool
+	}{
+		{false, false},
+		{true, false},
+		{true, true},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("secure: %t; auto-encrypt: %t", c.secure, c.autoEncrypt)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"connectInject.enabled":        "true",
+				"controller.enabled":           "true",
+				"global.tls.enabled":           strconv.FormatBool(c.secure),
+				"global.tls.enableAutoEncrypt": strconv.FormatBool(c.autoEncrypt),
+				"global.acls.manageSystemACLs": strconv.FormatBool(c.secure),
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+
+			logger.Log(t, "creating static-server v1 and v2 and static-client deployments")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-v1-inject")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-v2-inject")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+			// Splitters and routers require the http protocol. Requests that
+			// don't ask for a subset go to v1, the resolver's default subset.
+			serviceDefaults := fixtures.NewServiceDefaults(staticServerName).WithProtocol("http")
+			resolver := fixtures.NewServiceResolver(staticServerName).
+				WithSubset("v1", "Service.Meta.version == v1").
+				WithSubset("v2", "Service.Meta.version == v2").
+				WithDefaultSubset("v1")
+			configEntries := []fixtures.Fixture{serviceDefaults, resolver}
+			if c.secure {
+				configEntries = append(configEntries, fixtures.NewServiceIntentions(staticServerName, staticServerName).WithSource(staticClientName, "allow"))
+			}
+			splitter := fixtures.NewServiceSplitter(staticServerName).WithSubsetSplit(50, "v1").WithSubsetSplit(50, "v2")
+			router := fixtures.NewServiceRouter(staticServerName).
+				WithPathPrefixRouteToSubset("/v1", "v1").
+				WithPathPrefixRouteToSubset("/v2", "v2")
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				// Delete the router and splitter first because Consul doesn't
+				// allow deleting the resolver while they use its subsets.
+				fixtures.Delete(t, ctx.KubectlOptions(t), router, splitter)
+				fixtures.Delete(t, ctx.KubectlOptions(t), configEntries...)
+			})
+
+			logger.Log(t, "creating service-defaults and service-resolver custom resources")
+			fixtures.Apply(t, ctx.KubectlOptions(t), configEntries...)
+			waitForSync(t, ctx.KubectlOptions(t), "servicedefaults", "serviceresolver")
+
+			logger.Log(t, "checking that all requests go to the default subset")
+			checkVersions(t, ctx.KubectlOptions(t), "/", func(r *retry.R, counts map[string]int) {
+				require.Equal(r, map[string]int{"v1": requestsPerCheck}, counts)
+			})
+
+			logger.Log(t, "creating a service-splitter custom resource splitting traffic between v1 and v2")
+			fixtures.Apply(t, ctx.KubectlOptions(t), splitter)
+			waitForSync(t, ctx.KubectlOptions(t), "servicesplitter")
+
+			logger.Log(t, "checking that requests are split between v1 and v2")
+			checkVersions(t, ctx.KubectlOptions(t), "/", requireSplit)
+
+			logger.Log(t, "creating a service-router custom resource routing requests by path prefix")
+			fixtures.Apply(t, ctx.KubectlOptions(t), router)
+			waitForSync(t, ctx.KubectlOptions(t), "servicerouter")
+
+			for _, version := range []string{"v1", "v2"} {
+				logger.Logf(t, "checking that all requests to /%s/ go to %s", version, version)
+				checkVersions(t, ctx.KubectlOptions(t), "/"+version+"/foo", func(r *retry.R, counts map[string]int) {
+					require.Equal(r, map[string]int{version: requestsPerCheck}, counts)
+				})
+			}
+
+			logger.Log(t, "checking that requests that don't match a route are still split between v1 and v2")
+			checkVersions(t, ctx.KubectlOptions(t), "/foo", requireSplit)
+		})
+	}
+}
+
+// waitForSync waits until the static-server custom resources of kinds are synced to Consul.
+func waitForSync(t *testing.T, options *terratestk8s.KubectlOptions, kinds ...string) {
+	t.Helper()
+
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		for _, kind := range kinds {
+			k8s.RequireCRDCondition(r, t, options, kind, staticServerName, k8s.ConditionSynced, "True", "")
+		}
+	})
+}
+
+// checkVersions sends requestsPerCheck requests for path from static-client to static-server
+// and calls check with the number of responses from each version, retrying until check passes
+// because Envoy receives the changes to config entries asynchronously.
+func checkVersions(t *testing.T, options *terratestk8s.KubectlOptions, path string, check func(r *retry.R, counts map[string]int)) {
+	t.Helper()
+
+	helpers.RetryEventually(t, timeouts.TrafficCheck()+1*time.Minute, func(r *retry.R) {
+		resps, err := k8s.CurlRepeatedE(t, options, staticClientName, k8s.HTTPRequest{URL: "http://localhost:1234" + path}, requestsPerCheck)
+		require.NoError(r, err)
+
+		counts := make(map[string]int)
+		for _, resp := range resps {
+			require.Equal(r, 200, resp.StatusCode, "unexpected status code, body: %s", resp.Body)
+			counts[strings.TrimSpace(resp.Body)]++
+		}
+		logger.Logf(t, "responses by version for %s: %v", path, counts)
+		check(r, counts)
+	})
+}
+
+// requireSplit checks that responses came from both v1 and v2 in roughly equal numbers.
+func requireSplit(r *retry.R, counts map[string]int) {
+	require.Len(r, counts, 2, "expected responses from v1 and v2 only")
+	for _, version := range []string{"v1", "v2"} {
+		require.InDelta(r, requestsPerCheck/2, counts[version], requestsPerCheck/5, "unexpected number of responses from %s", version)
+	}
+}
@@ -0,0 +1,15 @@
+package l7routing
+
+import (
+	"os"
+	"testing"
+
+	testSuite "github.com/hashicorp/consul-helm/test/acceptance/framework/suite"
+)
+
+var suite testSuite.Suite
+
+func TestMain(m *testing.M) {
+	suite = testSuite.NewSuite(m, testSuite.SmokeCases("TestL7Routing/secure: false; auto-encrypt: false"))
+	os.Exit(suite.Run())
+}
@@ -11,7 +11,7 @@ import (
 var suite testsuite.Suite
 
 func TestMain(m *testing.M) {
-	suite = testsuite.NewSuite(m)
+	suite = testsuite.NewSuite(m, testsuite.SmokeCases("TestMeshGatewayDefault"))
 
 	if suite.Config().EnableMultiCluster {
 		os.Exit(suite.Run())
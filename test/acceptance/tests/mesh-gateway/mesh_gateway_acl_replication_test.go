@@ -0,0 +1,161 @@
+package meshgateway
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that ACLs are replicated from the primary datacenter to the secondary datacenter
+// when the secondary is configured with global.acls.replicationToken: tokens and intentions
+// created in the primary become usable in the secondary, and the secondary's components
+// have bootstrapped their own tokens.
+func TestMeshGatewaySecure_ACLReplication(t *testing.T) {
+	env := suite.Environment()
+	cfg := suite.Config()
+
+	primaryContext := env.DefaultContext(t)
+	secondaryContext := env.Context(t, environment.SecondaryContextName)
+
+	primaryHelmValues := map[string]string{
+		"global.datacenter":  "dc1",
+		"global.tls.enabled": "true",
+
+		"global.acls.manageSystemACLs":       "true",
+		"global.acls.createReplicationToken": "true",
+
+		"global.federation.enabled":                "true",
+		"global.federation.createFederationSecret": "true",
+
+		"connectInject.enabled": "true",
+
+		"meshGateway.enabled":  "true",
+		"meshGateway.replicas": "1",
+	}
+
+	if cfg.UseKind {
+		primaryHelmValues["meshGateway.service.type"] = "NodePort"
+		primaryHelmValues["meshGateway.service.nodePort"] = "30000"
+	}
+
+	releaseName := helpers.RandomName()
+
+	// Install the primary consul cluster in the default kubernetes context
+	primaryConsulCluster := consul.NewHelmCluster(t, primaryHelmValues, primaryContext, cfg, releaseName)
+	primaryConsulCluster.Create(t)
+
+	// Get the federation secret from the primary cluster and apply it to secondary cluster
+	federationSecret := consul.ExportFederationSecret(t, primaryContext, releaseName)
+	consul.ImportFederationSecret(t, secondaryContext, federationSecret)
+
+	// Create secondary cluster
+	secondaryHelmValues := map[string]string{
+		"global.datacenter": "dc2",
+
+		"global.tls.enabled":   "true",
+		"global.tls.httpsOnly": "false",
+
+		"global.acls.manageSystemACLs": "true",
+
+		// Enterprise license job will fail if it runs in the secondary DC,
+		// so we're explicitly setting these values to empty to avoid that.
+		"server.enterpriseLicense.secretName": "",
+		"server.enterpriseLicense.secretKey":  "",
+
+		"connectInject.enabled": "true",
+
+		"meshGateway.enabled":  "true",
+		"meshGateway.replicas": "1",
+	}
+
+	if cfg.UseKind {
+		secondaryHelmValues["meshGateway.service.type"] = "NodePort"
+		secondaryHelmValues["meshGateway.service.nodePort"] = "30000"
+	}
+
+	// Install the secondary consul cluster in the secondary kubernetes context
+	secondaryConsulCluster := consul.NewHelmCluster(t, secondaryHelmValues, secondaryContext, cfg, releaseName, consul.WithFederationSecret(federationSecret))
+	secondaryConsulCluster.Create(t)
+
+	primaryClient := primaryConsulCluster.SetupConsulClient(t, true)
+	secondaryClient := secondaryConsulCluster.SetupConsulClient(t, true)
+
+	logger.Log(t, "verifying federation was successful")
+	verifyFederation(t, primaryClient, secondaryClient, releaseName, true)
+
+	logger.Log(t, "checking that the secondary is replicating tokens from the primary")
+	retry.Run(t, func(r *retry.R) {
+		replicationStatus, _, err := secondaryClient.ACL().Replication(nil)
+		require.NoError(r, err)
+		require.Equal(r, "dc1", replicationStatus.SourceDatacenter)
+		require.Equal(r, "tokens", replicationStatus.ReplicationType)
+		require.NotZero(r, replicationStatus.ReplicatedTokenIndex)
+	})
+
+	// Create a global token in the primary that can only write to
+	// a KV prefix and check that it can be used in the secondary.
+	logger.Log(t, "creating a global token in the primary")
+	policy, _, err := primaryClient.ACL().PolicyCreate(&api.ACLPolicy{
+		Name:  "replication-test",
+		Rules: `key_prefix "replication-test/" { policy = "write" }`,
+	}, nil)
+	require.NoError(t, err)
+	token, _, err := primaryClient.ACL().TokenCreate(&api.ACLToken{
+		Description: "Replication test token",
+		Policies:    []*api.ACLTokenPolicyLink{{ID: policy.ID}},
+	}, nil)
+	require.NoError(t, err)
+
+	logger.Log(t, "checking that the global token is usable in the secondary")
+	helpers.RetryEventually(t, 1*time.Minute, func(r *retry.R) {
+		_, err := secondaryClient.KV().Put(&api.KVPair{Key: "replication-test/foo", Value: []byte("bar")}, &api.WriteOptions{Token: token.SecretID, Datacenter: "dc2"})
+		require.NoError(r, err)
+	})
+	_, err = secondaryClient.KV().Put(&api.KVPair{Key: "other/foo", Value: []byte("bar")}, &api.WriteOptions{Token: token.SecretID, Datacenter: "dc2"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Permission denied")
+
+	logger.Log(t, "creating intention in the primary")
+	_, _, err = primaryClient.Connect().IntentionCreate(&api.Intention{
+		SourceName:      staticClientName,
+		DestinationName: staticServerName,
+		Action:          api.IntentionActionDeny,
+	}, nil)
+	require.NoError(t, err)
+
+	logger.Log(t, "checking that the intention is enforced in the secondary")
+	helpers.RetryEventually(t, 1*time.Minute, func(r *retry.R) {
+		intention, _, err := secondaryClient.Connect().IntentionGetExact(staticClientName, staticServerName, &api.QueryOptions{Datacenter: "dc2"})
+		require.NoError(r, err)
+		require.NotNil(r, intention)
+		require.Equal(r, api.IntentionActionDeny, intention.Action)
+
+		allowed, _, err := secondaryClient.Connect().IntentionCheck(&api.IntentionCheck{
+			Source:      staticClientName,
+			Destination: staticServerName,
+		}, &api.QueryOptions{Datacenter: "dc2"})
+		require.NoError(r, err)
+		require.False(r, allowed)
+	})
+
+	// Components in the secondary can't use tokens created in the primary
+	// because they are bootstrapped by the secondary's server-acl-init job
+	// as local tokens. Check that they exist and are valid.
+	logger.Log(t, "checking that the secondary's components have bootstrapped their own tokens")
+	for _, component := range []string{"client", "mesh-gateway"} {
+		secretName := fmt.Sprintf("%s-consul-%s-acl-token", releaseName, component)
+		secret := k8s.WaitForSecret(t, secondaryContext.KubectlOptions(t), secretName, "token")
+		componentToken, _, err := secondaryClient.ACL().TokenReadSelf(&api.QueryOptions{Token: string(secret.Data["token"]), Datacenter: "dc2"})
+		require.NoError(t, err, "token from secret %s is not valid in dc2", secretName)
+		require.True(t, componentToken.Local, "expected token from secret %s to be local to dc2", secretName)
+	}
+}
@@ -0,0 +1,195 @@
+package meshgateway
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/envoy"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// gatewayRequests is the number of requests the static-client makes
+// to the static-server in dc2 each time the gateway stats are checked.
+const gatewayRequests = 5
+
+const (
+	// dc2ClusterStats matches the connections that a gateway in dc1 opens to
+	// the gateways in dc2 for requests to services in dc2. The connections
+	// that the servers make through the gateways use a separate cluster.
+	dc2ClusterStats = `^cluster\.dc2\.internal\..*\.upstream_cx_total$`
+	// staticServerClusterStats matches the connections that a gateway
+	// in dc2 opens to the static-server.
+	staticServerClusterStats = `^cluster\.static-server\.default\.dc2\.internal\..*\.upstream_cx_total$`
+)
+
+// Test that the mesh gateway mode of proxy-defaults decides which gateways
+// requests to another datacenter go through. In local mode, the static-client
+// in dc1 sends requests to the gateway in dc1, which forwards them to the
+// gateway in dc2. In remote mode, it sends them to the gateway in dc2 directly.
+// The paths are checked with the connection counters of the gateways' Envoy
+// clusters, so the test checks that traffic takes the path the mode promises
+// rather than that the config entry is written.
+func TestMeshGateway_Modes(t *testing.T) {
+	env := suite.Environment()
+	cfg := suite.Config()
+
+	primaryContext := env.DefaultContext(t)
+	secondaryContext := env.Context(t, environment.SecondaryContextName)
+
+	primaryHelmValues := map[string]string{
+		"global.datacenter":                        "dc1",
+		"global.tls.enabled":                       "true",
+		"global.tls.httpsOnly":                     "false",
+		"global.federation.enabled":                "true",
+		"global.federation.createFederationSecret": "true",
+
+		"connectInject.enabled": "true",
+
+		"meshGateway.enabled":  "true",
+		"meshGateway.replicas": "1",
+	}
+
+	if cfg.UseKind {
+		primaryHelmValues["meshGateway.service.type"] = "NodePort"
+		primaryHelmValues["meshGateway.service.nodePort"] = "30000"
+	}
+
+	releaseName := helpers.RandomName()
+
+	primaryConsulCluster := consul.NewHelmCluster(t, primaryHelmValues, primaryContext, cfg, releaseName)
+	primaryConsulCluster.Create(t)
+
+	federationSecret := consul.ExportFederationSecret(t, primaryContext, releaseName)
+	consul.ImportFederationSecret(t, secondaryContext, federationSecret)
+
+	secondaryHelmValues := map[string]string{
+		"global.datacenter": "dc2",
+
+		"global.tls.enabled":   "true",
+		"global.tls.httpsOnly": "false",
+
+		// Enterprise license job will fail if it runs in the secondary DC,
+		// so we're explicitly setting these values to empty to avoid that.
+		"server.enterpriseLicense.secretName": "",
+		"server.enterpriseLicense.secretKey":  "",
+
+		"connectInject.enabled": "true",
+
+		"meshGateway.enabled":  "true",
+		"meshGateway.replicas": "1",
+	}
+
+	if cfg.UseKind {
+		secondaryHelmValues["meshGateway.service.type"] = "NodePort"
+		secondaryHelmValues["meshGateway.service.nodePort"] = "30000"
+	}
+
+	secondaryConsulCluster := consul.NewHelmCluster(t, secondaryHelmValues, secondaryContext, cfg, releaseName, consul.WithFederationSecret(federationSecret))
+	secondaryConsulCluster.Create(t)
+
+	primaryClient := primaryConsulCluster.SetupConsulClient(t, false)
+	secondaryClient := secondaryConsulCluster.SetupConsulClient(t, false)
+
+	logger.Log(t, "verifying federation was successful")
+	verifyFederation(t, primaryClient, secondaryClient, releaseName, false)
+
+	logger.Log(t, "creating static-server in dc2")
+	k8s.DeployKustomize(t, secondaryContext.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+
+	logger.Log(t, "creating static-client in dc1")
+	k8s.DeployKustomize(t, primaryContext.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-multi-dc")
+
+	logger.Log(t, "verifying cross-datacenter service discovery")
+	verifyCrossDatacenterDiscovery(t, primaryClient, secondaryClient)
+
+	primaryGateway := meshGatewayAdmin(t, primaryContext, primaryConsulCluster)
+	secondaryGateway := meshGatewayAdmin(t, secondaryContext, secondaryConsulCluster)
+
+	// The modes are switched on the same installation. Upstreams follow
+	// the global proxy-defaults of the datacenter of the static-client.
+	cases := []struct {
+		mode                api.MeshGatewayMode
+		throughLocalGateway bool
+	}{
+		{api.MeshGatewayModeLocal, true},
+		{api.MeshGatewayModeRemote, false},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.mode), func(t *testing.T) {
+			logger.Logf(t, "setting the mesh gateway mode of the global proxy-defaults in dc1 to %s", c.mode)
+			setGlobalMeshGatewayMode(t, primaryClient, c.mode)
+
+			// The sidecar of the static-client may still use the previous
+			// mode for a moment after the config entry is written, so the
+			// requests and stats are checked again until they match the mode.
+			helpers.RetryEventually(t, timeouts.TrafficCheck(), func(r *retry.R) {
+				primaryBefore := sumStats(primaryGateway.Stats(r, dc2ClusterStats))
+				secondaryBefore := sumStats(secondaryGateway.Stats(r, staticServerClusterStats))
+
+				resps, err := k8s.CurlRepeatedE(t, primaryContext.KubectlOptions(t), staticClientName,
+					k8s.HTTPRequest{URL: "http://localhost:1234", MaxTime: 5 * time.Second}, gatewayRequests)
+				require.NoError(r, err)
+				for _, resp := range resps {
+					require.Equal(r, http.StatusOK, resp.StatusCode, "unexpected response from static-server: %s", resp.Body)
+				}
+
+				primaryConns := sumStats(primaryGateway.Stats(r, dc2ClusterStats)) - primaryBefore
+				secondaryConns := sumStats(secondaryGateway.Stats(r, staticServerClusterStats)) - secondaryBefore
+				require.GreaterOrEqual(r, secondaryConns, int64(gatewayRequests),
+					"the gateway in dc2 didn't forward the requests to static-server")
+				if c.throughLocalGateway {
+					require.GreaterOrEqual(r, primaryConns, int64(gatewayRequests),
+						"the requests didn't go through the gateway in dc1")
+				} else {
+					require.Zero(r, primaryConns, "the requests went through the gateway in dc1")
+				}
+			})
+		})
+	}
+}
+
+// meshGatewayAdmin returns a client for the Envoy admin API of the
+// mesh gateway of the release and fails unless there is exactly one.
+func meshGatewayAdmin(t *testing.T, ctx environment.TestContext, consulCluster consul.Cluster) *envoy.Admin {
+	t.Helper()
+
+	pods := consulCluster.ComponentPods(t, helpers.ComponentMeshGateway)
+	require.Len(t, pods, 1, "expected exactly one mesh gateway pod")
+	return envoy.NewAdmin(t, ctx.KubectlOptions(t), pods[0].Name)
+}
+
+// setGlobalMeshGatewayMode sets the mesh gateway mode of the global
+// proxy-defaults, keeping the rest of the entry that the chart wrote.
+func setGlobalMeshGatewayMode(t *testing.T, client *api.Client, mode api.MeshGatewayMode) {
+	t.Helper()
+
+	entry, _, err := client.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal, nil)
+	require.NoError(t, err)
+	proxyDefaults, ok := entry.(*api.ProxyConfigEntry)
+	require.True(t, ok, "unexpected config entry type %T", entry)
+
+	proxyDefaults.MeshGateway.Mode = mode
+	written, _, err := client.ConfigEntries().CAS(proxyDefaults, proxyDefaults.ModifyIndex, nil)
+	require.NoError(t, err)
+	require.True(t, written, "the global proxy-defaults were changed while they were being updated")
+}
+
+// sumStats returns the sum of the values of stats, e.g. of
+// the same counter of clusters with different trust domains.
+func sumStats(stats map[string]int64) int64 {
+	var sum int64
+	for _, value := range stats {
+		sum += value
+	}
+	return sum
+}
@@ -1,7 +1,6 @@
 package meshgateway
 
 import (
-	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -11,13 +10,14 @@ import (
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/stretchr/testify/require"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const staticClientName = "static-client"
+const staticServerName = "static-server"
 
 // Test that Connect and wan federation over mesh gateways work in a default installation
 // i.e. without ACLs because TLS is required for WAN federation over mesh gateways
@@ -53,32 +53,15 @@ func TestMeshGatewayDefault(t *testing.T) {
 	primaryConsulCluster.Create(t)
 
 	// Get the federation secret from the primary cluster and apply it to secondary cluster
-	federationSecretName := fmt.Sprintf("%s-consul-federation", releaseName)
-	logger.Logf(t, "retrieving federation secret %s from the primary cluster and applying to the secondary", federationSecretName)
-	federationSecret, err := primaryContext.KubernetesClient(t).CoreV1().Secrets(primaryContext.KubectlOptions(t).Namespace).Get(context.Background(), federationSecretName, metav1.GetOptions{})
-	federationSecret.ResourceVersion = ""
-	require.NoError(t, err)
-	_, err = secondaryContext.KubernetesClient(t).CoreV1().Secrets(secondaryContext.KubectlOptions(t).Namespace).Create(context.Background(), federationSecret, metav1.CreateOptions{})
-	require.NoError(t, err)
+	federationSecret := consul.ExportFederationSecret(t, primaryContext, releaseName)
+	consul.ImportFederationSecret(t, secondaryContext, federationSecret)
 
 	// Create secondary cluster
 	secondaryHelmValues := map[string]string{
 		"global.datacenter": "dc2",
 
-		"global.tls.enabled":           "true",
-		"global.tls.httpsOnly":         "false",
-		"global.tls.caCert.secretName": federationSecretName,
-		"global.tls.caCert.secretKey":  "caCert",
-		"global.tls.caKey.secretName":  federationSecretName,
-		"global.tls.caKey.secretKey":   "caKey",
-
-		"global.federation.enabled": "true",
-
-		"server.extraVolumes[0].type":          "secret",
-		"server.extraVolumes[0].name":          federationSecretName,
-		"server.extraVolumes[0].load":          "true",
-		"server.extraVolumes[0].items[0].key":  "serverConfigJSON",
-		"server.extraVolumes[0].items[0].path": "config.json",
+		"global.tls.enabled":   "true",
+		"global.tls.httpsOnly": "false",
 
 		// Enterprise license job will fail if it runs in the secondary DC,
 		// so we're explicitly setting these values to empty to avoid that.
@@ -97,7 +80,7 @@ func TestMeshGatewayDefault(t *testing.T) {
 	}
 
 	// Install the secondary consul cluster in the secondary kubernetes context
-	secondaryConsulCluster := consul.NewHelmCluster(t, secondaryHelmValues, secondaryContext, cfg, releaseName)
+	secondaryConsulCluster := consul.NewHelmCluster(t, secondaryHelmValues, secondaryContext, cfg, releaseName, consul.WithFederationSecret(federationSecret))
 	secondaryConsulCluster.Create(t)
 
 	primaryClient := primaryConsulCluster.SetupConsulClient(t, false)
@@ -109,10 +92,13 @@ func TestMeshGatewayDefault(t *testing.T) {
 
 	// Check that we can connect services over the mesh gateways
 	logger.Log(t, "creating static-server in dc2")
-	k8s.DeployKustomize(t, secondaryContext.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+	k8s.DeployKustomize(t, secondaryContext.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
 
 	logger.Log(t, "creating static-client in dc1")
-	k8s.DeployKustomize(t, primaryContext.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-client-multi-dc")
+	k8s.DeployKustomize(t, primaryContext.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-multi-dc")
+
+	logger.Log(t, "verifying cross-datacenter service discovery")
+	verifyCrossDatacenterDiscovery(t, primaryClient, secondaryClient)
 
 	logger.Log(t, "checking that connection is successful")
 	k8s.CheckStaticServerConnectionSuccessful(t, primaryContext.KubectlOptions(t), staticClientName, "http://localhost:1234")
@@ -172,13 +158,8 @@ func TestMeshGatewaySecure(t *testing.T) {
 			primaryConsulCluster.Create(t)
 
 			// Get the federation secret from the primary cluster and apply it to secondary cluster
-			federationSecretName := fmt.Sprintf("%s-consul-federation", releaseName)
-			logger.Logf(t, "retrieving federation secret %s from the primary cluster and applying to the secondary", federationSecretName)
-			federationSecret, err := primaryContext.KubernetesClient(t).CoreV1().Secrets(primaryContext.KubectlOptions(t).Namespace).Get(context.Background(), federationSecretName, metav1.GetOptions{})
-			require.NoError(t, err)
-			federationSecret.ResourceVersion = ""
-			_, err = secondaryContext.KubernetesClient(t).CoreV1().Secrets(secondaryContext.KubectlOptions(t).Namespace).Create(context.Background(), federationSecret, metav1.CreateOptions{})
-			require.NoError(t, err)
+			federationSecret := consul.ExportFederationSecret(t, primaryContext, releaseName)
+			consul.ImportFederationSecret(t, secondaryContext, federationSecret)
 
 			// Create secondary cluster
 			secondaryHelmValues := map[string]string{
@@ -187,22 +168,8 @@ func TestMeshGatewaySecure(t *testing.T) {
 				"global.tls.enabled":           "true",
 				"global.tls.httpsOnly":         "false",
 				"global.tls.enableAutoEncrypt": c.enableAutoEncrypt,
-				"global.tls.caCert.secretName": federationSecretName,
-				"global.tls.caCert.secretKey":  "caCert",
-				"global.tls.caKey.secretName":  federationSecretName,
-				"global.tls.caKey.secretKey":   "caKey",
-
-				"global.acls.manageSystemACLs":            "true",
-				"global.acls.replicationToken.secretName": federationSecretName,
-				"global.acls.replicationToken.secretKey":  "replicationToken",
 
-				"global.federation.enabled": "true",
-
-				"server.extraVolumes[0].type":          "secret",
-				"server.extraVolumes[0].name":          federationSecretName,
-				"server.extraVolumes[0].load":          "true",
-				"server.extraVolumes[0].items[0].key":  "serverConfigJSON",
-				"server.extraVolumes[0].items[0].path": "config.json",
+				"global.acls.manageSystemACLs": "true",
 
 				// Enterprise license job will fail if it runs in the secondary DC,
 				// so we're explicitly setting these values to empty to avoid that.
@@ -221,7 +188,7 @@ func TestMeshGatewaySecure(t *testing.T) {
 			}
 
 			// Install the secondary consul cluster in the secondary kubernetes context
-			secondaryConsulCluster := consul.NewHelmCluster(t, secondaryHelmValues, secondaryContext, cfg, releaseName)
+			secondaryConsulCluster := consul.NewHelmCluster(t, secondaryHelmValues, secondaryContext, cfg, releaseName, consul.WithFederationSecret(federationSecret))
 			secondaryConsulCluster.Create(t)
 
 			primaryClient := primaryConsulCluster.SetupConsulClient(t, true)
@@ -233,15 +200,18 @@ func TestMeshGatewaySecure(t *testing.T) {
 
 			// Check that we can connect services over the mesh gateways
 			logger.Log(t, "creating static-server in dc2")
-			k8s.DeployKustomize(t, secondaryContext.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			k8s.DeployKustomize(t, secondaryContext.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
 
 			logger.Log(t, "creating static-client in dc1")
-			k8s.DeployKustomize(t, primaryContext.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-client-multi-dc")
+			k8s.DeployKustomize(t, primaryContext.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-multi-dc")
+
+			logger.Log(t, "verifying cross-datacenter service discovery")
+			verifyCrossDatacenterDiscovery(t, primaryClient, secondaryClient)
 
 			logger.Log(t, "creating intention")
-			_, _, err = primaryClient.Connect().IntentionCreate(&api.Intention{
+			_, _, err := primaryClient.Connect().IntentionCreate(&api.Intention{
 				SourceName:      staticClientName,
-				DestinationName: "static-server",
+				DestinationName: staticServerName,
 				Action:          api.IntentionActionAllow,
 			}, nil)
 			require.NoError(t, err)
@@ -256,7 +226,6 @@ func TestMeshGatewaySecure(t *testing.T) {
 // by first checking members are alive from the perspective of both servers.
 // If secure is true, it will also check that the ACL replication is running on the secondary server.
 func verifyFederation(t *testing.T, primaryClient, secondaryClient *api.Client, releaseName string, secure bool) {
-	retrier := &retry.Timer{Timeout: 5 * time.Minute, Wait: 1 * time.Second}
 	start := time.Now()
 
 	// Check that server in dc1 is healthy from the perspective of the server in dc2, and vice versa.
@@ -267,7 +236,7 @@ func verifyFederation(t *testing.T, primaryClient, secondaryClient *api.Client,
 	// and then switch to "failed". This would require us to check that the status is "alive" is showing consistently for
 	// some amount of time, which could be quite flakey. Calling the API in another datacenter allows us to check that
 	// each server can forward calls to another, which is what we need for connect.
-	retry.RunWith(retrier, t, func(r *retry.R) {
+	helpers.RetryEventually(t, 5*time.Minute, func(r *retry.R) {
 		secondaryServerHealth, _, err := primaryClient.Health().Node(fmt.Sprintf("%s-consul-server-0", releaseName), &api.QueryOptions{Datacenter: "dc2"})
 		require.NoError(r, err)
 		require.Equal(r, secondaryServerHealth.AggregatedStatus(), api.HealthPassing)
@@ -286,3 +255,21 @@ func verifyFederation(t *testing.T, primaryClient, secondaryClient *api.Client,
 
 	logger.Logf(t, "Took %s to verify federation", time.Since(start))
 }
+
+// verifyCrossDatacenterDiscovery checks that the static-server registered in dc2
+// can be discovered from dc1 and that the static-client registered in dc1
+// can be discovered from dc2, i.e. that catalog queries are forwarded
+// between datacenters in both directions.
+func verifyCrossDatacenterDiscovery(t *testing.T, primaryClient, secondaryClient *api.Client) {
+	t.Helper()
+
+	helpers.RetryEventually(t, timeouts.TrafficCheck(), func(r *retry.R) {
+		services, _, err := primaryClient.Catalog().Service(staticServerName, "", &api.QueryOptions{Datacenter: "dc2"})
+		require.NoError(r, err)
+		require.Len(r, services, 1)
+
+		services, _, err = secondaryClient.Catalog().Service(staticClientName, "", &api.QueryOptions{Datacenter: "dc1"})
+		require.NoError(r, err)
+		require.Len(r, services, 1)
+	})
+}
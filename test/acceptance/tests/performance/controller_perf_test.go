@@ -0,0 +1,144 @@
+package performance
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// pollInterval is how often Consul is queried for synced config entries.
+// It bounds the precision of the measured latencies.
+const pollInterval = 500 * time.Millisecond
+
+// templatedFixtures maps the config entry kinds created by the test
+// to the templated custom resource fixtures for them.
+var templatedFixtures = map[string]string{
+	api.ServiceDefaults:   "../fixtures/performance/servicedefaults.yaml",
+	api.ServiceIntentions: "../fixtures/performance/serviceintentions.yaml",
+}
+
+// Test how long it takes the controller to sync a large number
+// of custom resources to Consul. The test creates -perf-resources
+// ServiceDefaults and ServiceIntentions resources at once and reports
+// the total sync time and the p50 and p95 latencies from when the
+// resources were applied to when their config entries appeared in Consul.
+func TestControllerPerformance(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"controller.enabled": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+	consulClient := consulCluster.SetupConsulClient(t, false)
+
+	manifest := renderFixtures(t, cfg.PerfResources)
+
+	// Create a single resource and wait for it to be synced first so that
+	// the measurements don't include the time it takes the controller
+	// to perform leader election.
+	logger.Log(t, "waiting for the controller to be ready")
+	proxyDefaults := fixtures.NewProxyDefaults().WithMeshGatewayMode("local")
+	proxyDefaults.Apply(t, ctx.KubectlOptions(t))
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+		proxyDefaults.Delete(t, ctx.KubectlOptions(t))
+	})
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		_, _, err := consulClient.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal, nil)
+		require.NoError(r, err)
+	})
+
+	logger.Logf(t, "creating %d custom resources of each kind", cfg.PerfResources)
+	applyStart := time.Now()
+	k8s.KubectlApply(t, ctx.KubectlOptions(t), manifest)
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+		k8s.RunKubectlAndGetOutputE(t, ctx.KubectlOptions(t), "delete", "-f", manifest)
+	})
+	logger.Logf(t, "took %s to apply the custom resources", time.Since(applyStart))
+
+	logger.Log(t, "waiting for all config entries to be synced")
+	latencies := make(map[string]time.Duration)
+	expected := len(templatedFixtures) * cfg.PerfResources
+	deadline := time.Now().Add(cfg.PerfSyncTimeout)
+	for len(latencies) < expected && time.Now().Before(deadline) {
+		for kind := range templatedFixtures {
+			entries, _, err := consulClient.ConfigEntries().List(kind, nil)
+			if err != nil {
+				logger.Logf(t, "error listing %s config entries: %s", kind, err)
+				continue
+			}
+			now := time.Now()
+			for _, entry := range entries {
+				key := kind + "/" + entry.GetName()
+				if _, ok := latencies[key]; !ok {
+					latencies[key] = now.Sub(applyStart)
+				}
+			}
+		}
+		time.Sleep(pollInterval)
+	}
+	require.Len(t, latencies, expected, "not all config entries were synced within -perf-sync-timeout (%s)", cfg.PerfSyncTimeout)
+
+	var durations []time.Duration
+	for _, latency := range latencies {
+		durations = append(durations, latency)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	logger.Logf(t, "synced %d config entries: total %s, p50 %s, p95 %s",
+		len(durations),
+		durations[len(durations)-1],
+		helpers.Percentile(durations, 50),
+		helpers.Percentile(durations, 95))
+}
+
+// renderFixtures renders numResources custom resources of each kind
+// from the templated fixtures into a single manifest and returns its path.
+// Each ServiceIntentions resource allows traffic to the service with the
+// same index from the service with the next index.
+func renderFixtures(t *testing.T, numResources int) string {
+	t.Helper()
+
+	var manifest bytes.Buffer
+	for _, fixture := range templatedFixtures {
+		tmpl, err := template.ParseFiles(fixture)
+		require.NoError(t, err)
+
+		for i := 0; i < numResources; i++ {
+			manifest.WriteString("---\n")
+			require.NoError(t, tmpl.Execute(&manifest, struct {
+				Name   string
+				Source string
+			}{
+				Name:   serviceName(i),
+				Source: serviceName(i + 1),
+			}))
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "resources.yaml")
+	require.NoError(t, ioutil.WriteFile(file, manifest.Bytes(), 0644))
+	return file
+}
+
+func serviceName(i int) string {
+	return fmt.Sprintf("perf-svc-%d", i)
+}
@@ -0,0 +1,22 @@
+package performance
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	testsuite "github.com/hashicorp/consul-helm/test/acceptance/framework/suite"
+)
+
+var suite testsuite.Suite
+
+func TestMain(m *testing.M) {
+	suite = testsuite.NewSuite(m)
+
+	if suite.Config().EnablePerf {
+		os.Exit(suite.Run())
+	} else {
+		fmt.Println("Skipping performance tests because -enable-perf is not set")
+		os.Exit(0)
+	}
+}
@@ -0,0 +1,223 @@
+package podsecurity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	staticClientName = "static-client"
+	staticServerName = "static-server"
+
+	// pspAnnotation is set on pods by the PodSecurityPolicy
+	// admission plugin to the name of the policy that admitted them.
+	pspAnnotation = "kubernetes.io/psp"
+
+	// enforceRestrictedLabel makes Pod Security Admission reject pods in
+	// a namespace that don't meet the restricted Pod Security Standard.
+	enforceRestrictedLabel = "pod-security.kubernetes.io/enforce"
+
+	// minPodSecurityAdmissionVersion is the minor version of Kubernetes
+	// from which Pod Security Admission is enabled by default.
+	minPodSecurityAdmissionVersion = 23
+
+	// podSecurityPolicyAPI is the group version of pod security policies,
+	// which were removed in Kubernetes 1.25.
+	podSecurityPolicyAPI = "policy/v1beta1"
+)
+
+// Test that all components schedule and run with global.enablePodSecurityPolicies,
+// that the chart creates a pod security policy for each of them, and that pods
+// admitted by the PodSecurityPolicy admission plugin, if the cluster has it enabled,
+// were admitted by the release's policies. This is skipped on clusters
+// that no longer serve pod security policies.
+func TestPodSecurityPolicies(t *testing.T) {
+	environment.SkipUnlessAPIResource(t, suite.Environment().DefaultContext(t), podSecurityPolicyAPI, "PodSecurityPolicy")
+
+	cases := []struct {
+		secure      bool
+		autoEncrypt bool
+	}{
+		{false, false},
+		{true, false},
+		{true, true},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("secure: %t; auto-encrypt: %t", c.secure, c.autoEncrypt)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"global.enablePodSecurityPolicies": "true",
+
+				"connectInject.enabled":       "true",
+				"controller.enabled":          "true",
+				"syncCatalog.enabled":         "true",
+				"ingressGateways.enabled":     "true",
+				"terminatingGateways.enabled": "true",
+
+				"global.tls.enabled":           strconv.FormatBool(c.secure),
+				"global.tls.enableAutoEncrypt": strconv.FormatBool(c.autoEncrypt),
+				"global.acls.manageSystemACLs": strconv.FormatBool(c.secure),
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			// Create waits for all pods of the release to be ready,
+			// so every component has been admitted and is running.
+			consulCluster.Create(t)
+
+			prefix := releaseName + "-consul-"
+			expectedPolicies := []string{
+				"server", "client", "connect-injector-webhook", "controller",
+				"sync-catalog", "webhook-cert-manager", "ingress-gateway", "terminating-gateway",
+			}
+			if c.secure {
+				expectedPolicies = append(expectedPolicies, "server-acl-init", "server-acl-init-cleanup", "tls-init", "tls-init-cleanup")
+			}
+
+			logger.Log(t, "checking that the pod security policies were created")
+			policies, err := ctx.KubernetesClient(t).PolicyV1beta1().PodSecurityPolicies().List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "release=" + releaseName})
+			require.NoError(t, err)
+			var policyNames []string
+			for _, policy := range policies.Items {
+				policyNames = append(policyNames, strings.TrimPrefix(policy.Name, prefix))
+			}
+			require.Subset(t, policyNames, expectedPolicies)
+
+			logger.Log(t, "checking that pods were admitted by the release's pod security policies")
+			pods, err := ctx.KubernetesClient(t).CoreV1().Pods(ctx.KubectlOptions(t).Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "release=" + releaseName})
+			require.NoError(t, err)
+			for _, pod := range pods.Items {
+				if policy, ok := pod.Annotations[pspAnnotation]; ok {
+					require.True(t, strings.HasPrefix(policy, prefix), "pod %s was admitted by pod security policy %s", pod.Name, policy)
+				}
+			}
+
+			logger.Log(t, "creating static-server and static-client deployments")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+			if c.secure {
+				consulClient := consulCluster.SetupConsulClient(t, true)
+
+				logger.Log(t, "creating intention")
+				_, _, err := consulClient.Connect().IntentionCreate(&api.Intention{
+					SourceName:      staticClientName,
+					DestinationName: staticServerName,
+					Action:          api.IntentionActionAllow,
+				}, nil)
+				require.NoError(t, err)
+			}
+
+			logger.Log(t, "checking that connection is successful")
+			k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+		})
+	}
+}
+
+// Test that a pod that meets the restricted Pod Security Standard can run
+// in a namespace that enforces it after it's injected, which requires the
+// injected init containers and sidecars to meet the standard too.
+// Pod Security Admission replaces pod security policies, which were removed
+// in Kubernetes 1.25, so this is skipped on clusters that don't enable it by default.
+func TestPodSecurityAdmission_Restricted(t *testing.T) {
+	// Remove this skip once the chart pins a consul-k8s version whose
+	// injector sets restricted security contexts on the containers it adds.
+	t.Skipf("skipping this test because the connect injector of consul-k8s 0.21.0 " +
+		"adds init and sidecar containers without security contexts, which Pod Security Admission rejects")
+
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	environment.SkipUnlessKubernetesVersion(t, ctx, 1, minPodSecurityAdmissionVersion)
+
+	helmValues := map[string]string{
+		"connectInject.enabled": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	// Only the static-server runs in the restricted namespace because the
+	// Consul clients and the static-client don't meet the restricted standard.
+	restrictedOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+	logger.Logf(t, "enforcing the restricted Pod Security Standard in namespace %s", restrictedOpts.Namespace)
+	namespace, err := ctx.KubernetesClient(t).CoreV1().Namespaces().Get(helpers.TestContext(t), restrictedOpts.Namespace, metav1.GetOptions{})
+	require.NoError(t, err)
+	if namespace.Labels == nil {
+		namespace.Labels = map[string]string{}
+	}
+	namespace.Labels[enforceRestrictedLabel] = "restricted"
+	_, err = ctx.KubernetesClient(t).CoreV1().Namespaces().Update(helpers.TestContext(t), namespace, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	logger.Log(t, "creating static-server deployment in the restricted namespace and static-client deployment")
+	k8s.DeployKustomize(t, restrictedOpts, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-restricted")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+	pods, err := ctx.KubernetesClient(t).CoreV1().Pods(restrictedOpts.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: "app=" + staticServerName})
+	require.NoError(t, err)
+	require.Len(t, pods.Items, 1)
+	requireRestrictedSecurityContext(t, pods.Items[0])
+
+	logger.Log(t, "checking that connection is successful")
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+}
+
+// requireRestrictedSecurityContext checks that all containers of pod, including
+// injected init containers and sidecars, meet the security context requirements
+// of the restricted Pod Security Standard. Pod Security Admission rejects pods
+// that don't, but checking them here reports which container is at fault.
+func requireRestrictedSecurityContext(t *testing.T, pod corev1.Pod) {
+	t.Helper()
+
+	podContext := pod.Spec.SecurityContext
+	if podContext == nil {
+		podContext = &corev1.PodSecurityContext{}
+	}
+
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	require.Greater(t, len(containers), 1, "pod %s was not injected", pod.Name)
+	for _, container := range containers {
+		sc := container.SecurityContext
+		require.NotNil(t, sc, "container %s has no security context", container.Name)
+
+		require.NotNil(t, sc.AllowPrivilegeEscalation, "container %s must set allowPrivilegeEscalation", container.Name)
+		require.False(t, *sc.AllowPrivilegeEscalation, "container %s allows privilege escalation", container.Name)
+
+		runAsNonRoot := podContext.RunAsNonRoot
+		if sc.RunAsNonRoot != nil {
+			runAsNonRoot = sc.RunAsNonRoot
+		}
+		require.True(t, runAsNonRoot != nil && *runAsNonRoot, "container %s must run as non-root", container.Name)
+
+		require.NotNil(t, sc.Capabilities, "container %s must drop all capabilities", container.Name)
+		require.Contains(t, sc.Capabilities.Drop, corev1.Capability("ALL"), "container %s must drop all capabilities", container.Name)
+
+		seccomp := podContext.SeccompProfile
+		if sc.SeccompProfile != nil {
+			seccomp = sc.SeccompProfile
+		}
+		require.NotNil(t, seccomp, "container %s has no seccomp profile", container.Name)
+		require.Contains(t, []corev1.SeccompProfileType{corev1.SeccompProfileTypeRuntimeDefault, corev1.SeccompProfileTypeLocalhost}, seccomp.Type,
+			"container %s has seccomp profile %s", container.Name, seccomp.Type)
+	}
+}
@@ -0,0 +1,141 @@
+package podsecurity
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// consulUID and consulGID are the user and group
+	// of the consul user in the Consul image.
+	consulUID = 100
+	consulGID = 1000
+)
+
+// securityContextComponents are the components whose pods are checked below.
+var securityContextComponents = []string{
+	helpers.ComponentServer,
+	helpers.ComponentClient,
+	helpers.ComponentConnectInjector,
+	helpers.ComponentController,
+	helpers.ComponentIngressGateway,
+	helpers.ComponentTerminatingGateway,
+}
+
+// Test the security contexts that the pods of the chart's components run with.
+// The security context of the servers is set with server.securityContext,
+// which defaults to an fsGroup that makes their data volume writable by the
+// consul user and can run them as that user instead of root. The other
+// components don't set a user, so they run as the user of their images,
+// which the test logs. No container of any component may run privileged,
+// and containers whose security context sets a user must run as that user.
+func TestSecurityContext(t *testing.T) {
+	cases := []struct {
+		name string
+		// serverSecurityContext is set as server.securityContext
+		// if it isn't nil, overriding the chart's default.
+		serverSecurityContext map[string]interface{}
+		serverNonRoot         bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "non-root servers",
+			serverSecurityContext: map[string]interface{}{
+				"runAsNonRoot": true,
+				"runAsUser":    consulUID,
+				"runAsGroup":   consulGID,
+				"fsGroup":      consulGID,
+			},
+			serverNonRoot: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"connectInject.enabled":       "true",
+				"controller.enabled":          "true",
+				"ingressGateways.enabled":     "true",
+				"terminatingGateways.enabled": "true",
+			}
+
+			var options []consul.HelmClusterOption
+			if c.serverSecurityContext != nil {
+				options = append(options, consul.WithValues(map[string]interface{}{
+					"server": map[string]interface{}{"securityContext": c.serverSecurityContext},
+				}))
+			}
+
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, helpers.RandomName(), options...)
+
+			// Create waits for all pods of the release to be ready,
+			// so the servers have elected a leader with this security context.
+			consulCluster.Create(t)
+
+			for _, component := range securityContextComponents {
+				pods := consulCluster.ComponentPods(t, component)
+				require.NotEmpty(t, pods, "no %s pods found", component)
+
+				for _, pod := range pods {
+					for _, container := range pod.Spec.Containers {
+						sc, err := k8s.EffectiveSecurityContext(pod, container.Name)
+						require.NoError(t, err)
+						require.False(t, sc.Privileged != nil && *sc.Privileged, "container %s of pod %s runs privileged", container.Name, pod.Name)
+
+						if sc.RunAsUser != nil {
+							uid := k8s.ContainerProcessUID(t, ctx.KubectlOptions(t), pod.Name, container.Name)
+							require.Equal(t, *sc.RunAsUser, uid, "container %s of pod %s doesn't run as the user of its security context", container.Name, pod.Name)
+							continue
+						}
+
+						// The user of the other containers depends on their images,
+						// so it's only logged. Images without cat are skipped.
+						uid, err := k8s.ContainerProcessUIDE(t, ctx.KubectlOptions(t), pod.Name, container.Name)
+						if err != nil {
+							logger.Logf(t, "could not read the user of container %s of pod %s: %s", container.Name, pod.Name, err)
+							continue
+						}
+						logger.Logf(t, "container %s of %s pod %s runs as user %d", container.Name, component, pod.Name, uid)
+					}
+				}
+			}
+
+			logger.Log(t, "checking the security context of the servers")
+			for _, pod := range consulCluster.ServerPods(t) {
+				sc, err := k8s.EffectiveSecurityContext(pod, "consul")
+				require.NoError(t, err)
+				require.NotNil(t, sc.FSGroup, "pod %s has no fsGroup", pod.Name)
+				require.Equal(t, int64(consulGID), *sc.FSGroup)
+
+				if c.serverNonRoot {
+					require.True(t, sc.RunAsNonRoot != nil && *sc.RunAsNonRoot, "pod %s doesn't require running as non-root", pod.Name)
+					require.Equal(t, int64(consulUID), k8s.ContainerProcessUID(t, ctx.KubectlOptions(t), pod.Name, "consul"))
+				} else {
+					require.Nil(t, sc.RunAsUser, "pod %s sets a user by default", pod.Name)
+				}
+			}
+
+			// Writing to the KV store checks that the servers can write to
+			// their data directory with the user they run as.
+			logger.Log(t, "checking that the servers can write to their data directory")
+			consulClient := consulCluster.SetupConsulClient(t, false)
+			_, err := consulClient.KV().Put(&api.KVPair{Key: "security-context", Value: []byte(c.name)}, nil)
+			require.NoError(t, err)
+			pair, _, err := consulClient.KV().Get("security-context", nil)
+			require.NoError(t, err)
+			require.NotNil(t, pair)
+			require.Equal(t, c.name, string(pair.Value))
+		})
+	}
+}
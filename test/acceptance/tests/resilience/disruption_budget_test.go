@@ -0,0 +1,110 @@
+package resilience
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Test that the server PodDisruptionBudget keeps node maintenance from
+// costing the servers their quorum. The test drains the node of the server
+// leader while the evicted server can't be rescheduled and checks that
+// a new leader is elected, that the budget refuses to evict a second server,
+// that the controller keeps syncing custom resources, and that all servers
+// rejoin once the node is schedulable again.
+// It needs at least three nodes because the servers' default affinity
+// only schedules one server on each node.
+func TestResilience_ServerDisruptionBudget(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	nodes := k8s.SchedulableNodes(t, ctx.KubectlOptions(t))
+	if len(nodes) < 3 {
+		t.Skipf("skipping this test because it needs at least 3 schedulable nodes, found %d", len(nodes))
+	}
+
+	helmValues := map[string]string{
+		"server.replicas":                 "3",
+		"server.bootstrapExpect":          "3",
+		"server.disruptionBudget.enabled": "true",
+		"controller.enabled":              "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	consulClient := consulCluster.SetupConsulClient(t, false)
+
+	logger.Log(t, "creating service-defaults custom resource")
+	serviceDefaults := fixtures.NewServiceDefaults("defaults").WithProtocol("http")
+	serviceDefaults.Apply(t, ctx.KubectlOptions(t))
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+		serviceDefaults.Delete(t, ctx.KubectlOptions(t))
+	})
+	requireServiceDefaultsProtocol(t, consulClient, "http")
+
+	serverNodes := map[string]bool{}
+	for _, pod := range consulCluster.ServerPods(t) {
+		serverNodes[pod.Spec.NodeName] = true
+	}
+	require.Len(t, serverNodes, 3, "expected the servers to run on different nodes")
+
+	// Cordon the nodes without servers so that the server evicted by the
+	// drain can't be rescheduled until the drained node is uncordoned.
+	for _, node := range nodes {
+		if !serverNodes[node.Name] {
+			k8s.CordonNode(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, node.Name)
+		}
+	}
+
+	leader, err := consulClient.Status().Leader()
+	require.NoError(t, err)
+	leaderPod := k8s.GetPod(t, ctx.KubectlOptions(t), serverPodWithAddress(t, consulCluster, leader))
+	drainedNode := leaderPod.Spec.NodeName
+	k8s.DrainNode(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, drainedNode)
+
+	logger.Log(t, "checking that a new leader is elected")
+	helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+		newLeader, err := consulClient.Status().Leader()
+		require.NoError(r, err)
+		require.NotEmpty(r, newLeader)
+		require.NotEqual(r, leader, newLeader)
+
+		// Writes need a quorum of the servers.
+		_, err = consulClient.KV().Put(&api.KVPair{Key: "disruption-budget", Value: []byte("quorum")}, nil)
+		require.NoError(r, err)
+	})
+
+	logger.Log(t, "checking that the disruption budget refuses to evict another server")
+	for _, pod := range consulCluster.ServerPods(t) {
+		if pod.Name == leaderPod.Name || pod.Spec.NodeName == drainedNode {
+			continue
+		}
+		err := k8s.EvictPodE(t, ctx.KubectlOptions(t), pod.Name)
+		require.Error(t, err, "evicting server pod %s should violate the disruption budget", pod.Name)
+		require.True(t, errors.IsTooManyRequests(err), "unexpected error evicting server pod %s: %s", pod.Name, err)
+	}
+
+	logger.Log(t, "checking that the controller keeps syncing custom resources")
+	serviceDefaults.WithProtocol("tcp").Apply(t, ctx.KubectlOptions(t))
+	requireServiceDefaultsProtocol(t, consulClient, "tcp")
+
+	k8s.UncordonNode(t, ctx.KubectlOptions(t), drainedNode)
+	helpers.WaitForAllPodsRunning(t, ctx.KubernetesClient(t), ctx.KubectlOptions(t).Namespace, releaseName, helpers.ComponentServer)
+
+	logger.Log(t, "checking that all servers rejoin the cluster")
+	helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+		consul.RequireRaftPeers(r, consulClient, 3)
+	})
+}
@@ -0,0 +1,137 @@
+package resilience
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/fixtures"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	staticClientName = "static-client"
+	staticServerName = "static-server"
+)
+
+// Test that killing the Consul server leader, the connect injector
+// and the controller in the middle of a test doesn't break the cluster:
+// a new leader is elected, the controller re-syncs config entries,
+// and mesh traffic recovers.
+func TestResilience_KillComponents(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"server.replicas":        "3",
+		"server.bootstrapExpect": "3",
+		// Allow scheduling servers on the same node so that
+		// the test can run against single-node clusters.
+		"server.affinity": "null",
+
+		"connectInject.enabled": "true",
+		"controller.enabled":    "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	logger.Log(t, "creating static-server and static-client deployments")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+	logger.Log(t, "creating service-defaults custom resource")
+	serviceDefaults := fixtures.NewServiceDefaults("defaults").WithProtocol("http")
+	serviceDefaults.Apply(t, ctx.KubectlOptions(t))
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+		serviceDefaults.Delete(t, ctx.KubectlOptions(t))
+	})
+
+	consulClient := consulCluster.SetupConsulClient(t, false)
+
+	// On startup, the controller can take upwards of 1m to perform
+	// leader election so we may need to wait a long time for
+	// the reconcile loop to run (hence the -timeout-controller-sync timeout here).
+	requireServiceDefaultsProtocol(t, consulClient, "http")
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+	// Kill the server leader and wait for a new one to be elected.
+	leader, err := consulClient.Status().Leader()
+	require.NoError(t, err)
+	leaderPod := serverPodWithAddress(t, consulCluster, leader)
+	k8s.KillPod(t, ctx.KubectlOptions(t), leaderPod)
+
+	logger.Log(t, "waiting for a new leader to be elected")
+	helpers.RetryEventually(t, timeouts.PodsReady(), func(r *retry.R) {
+		newLeader, err := consulClient.Status().Leader()
+		require.NoError(r, err)
+		require.NotEmpty(r, newLeader)
+		require.NotEqual(r, leader, newLeader, "the killed server %s is still the leader", leaderPod)
+	})
+
+	// Kill the connect injector and the controller.
+	for _, component := range []string{helpers.ComponentConnectInjector, helpers.ComponentController} {
+		pods := consulCluster.ComponentPods(t, component)
+		require.NotEmpty(t, pods, "no %s pods found", component)
+		for _, pod := range pods {
+			k8s.KillPod(t, ctx.KubectlOptions(t), pod.Name)
+		}
+	}
+	helpers.WaitForAllPodsRunning(t, ctx.KubernetesClient(t), ctx.KubectlOptions(t).Namespace, releaseName, helpers.ComponentConnectInjector, helpers.ComponentController)
+
+	// Check that the controller picks up changes to custom resources.
+	logger.Log(t, "updating service-defaults custom resource")
+	k8s.WaitForWebhook(t, ctx.KubectlOptions(t), releaseName+"-consul-controller-mutating-webhook-configuration")
+	serviceDefaults.WithProtocol("tcp").Apply(t, ctx.KubectlOptions(t))
+	requireServiceDefaultsProtocol(t, consulClient, "tcp")
+
+	// Restart the services so that they need to be injected by the new connect injector
+	// and registered with the new server leader.
+	k8s.RestartDeployment(t, ctx.KubectlOptions(t), staticServerName)
+	k8s.RestartDeployment(t, ctx.KubectlOptions(t), staticClientName)
+
+	logger.Log(t, "checking that mesh traffic has recovered")
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+}
+
+// requireServiceDefaultsProtocol waits for the service-defaults config entry
+// created by the test to have the expected protocol.
+func requireServiceDefaultsProtocol(t *testing.T, consulClient *api.Client, protocol string) {
+	t.Helper()
+
+	helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+		entry, _, err := consulClient.ConfigEntries().Get(api.ServiceDefaults, "defaults", nil)
+		require.NoError(r, err)
+		svcDefaultEntry, ok := entry.(*api.ServiceConfigEntry)
+		require.True(r, ok, "could not cast to ServiceConfigEntry")
+		require.Equal(r, protocol, svcDefaultEntry.Protocol)
+	})
+}
+
+// serverPodWithAddress returns the name of the server pod of consulCluster
+// whose IP matches the host of the given server address, e.g. the leader address.
+func serverPodWithAddress(t *testing.T, consulCluster consul.Cluster, address string) string {
+	t.Helper()
+
+	host, _, err := net.SplitHostPort(address)
+	require.NoError(t, err)
+
+	pods := consulCluster.ServerPods(t)
+	var pod *corev1.Pod
+	for i := range pods {
+		if pods[i].Status.PodIP == host {
+			pod = &pods[i]
+		}
+	}
+	require.NotNil(t, pod, "no server pod with address %s", address)
+	return pod.Name
+}
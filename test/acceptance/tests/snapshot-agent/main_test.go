@@ -0,0 +1,22 @@
+package snapshotagent
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	testsuite "github.com/hashicorp/consul-helm/test/acceptance/framework/suite"
+)
+
+var suite testsuite.Suite
+
+func TestMain(m *testing.M) {
+	suite = testsuite.NewSuite(m, testsuite.SmokeCases("TestSnapshotAgent/secure: false; auto-encrypt: false"))
+
+	if suite.Config().EnableEnterprise {
+		os.Exit(suite.Run())
+	} else {
+		fmt.Println("Skipping snapshot agent tests because -enable-enterprise is not set")
+		os.Exit(0)
+	}
+}
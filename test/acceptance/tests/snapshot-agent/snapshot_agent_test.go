@@ -0,0 +1,86 @@
+package snapshotagent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	snapshotConfigSecretKey = "config"
+
+	// snapshotConfig configures the snapshot agent to take a snapshot
+	// every 5 seconds and store it in the local file system of the pod.
+	snapshotConfig = `{
+  "snapshot_agent": {
+    "snapshot": {
+      "interval": "5s"
+    },
+    "local_storage": {
+      "path": "/tmp"
+    }
+  }
+}`
+)
+
+// Test that the snapshot agent takes snapshots of the Consul servers
+// in both the default and the secure installations.
+func TestSnapshotAgent(t *testing.T) {
+	cases := []struct {
+		secure      bool
+		autoEncrypt bool
+	}{
+		{false, false},
+		{true, false},
+		{true, true},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("secure: %t; auto-encrypt: %t", c.secure, c.autoEncrypt)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			releaseName := helpers.RandomName()
+			configSecretName := fmt.Sprintf("%s-snapshot-agent-config", releaseName)
+
+			// The snapshot agent needs its config secret before it starts.
+			createConfigSecret := func(t *testing.T, cluster *consul.HelmCluster) {
+				k8s.CreateSecret(t, cluster.KubectlOptions(), cfg.NoCleanupOnFailure, cfg.NoCleanup, configSecretName, map[string]string{snapshotConfigSecretKey: snapshotConfig})
+			}
+
+			helmValues := map[string]string{
+				"client.snapshotAgent.enabled":                 "true",
+				"client.snapshotAgent.replicas":                "1",
+				"client.snapshotAgent.configSecret.secretName": configSecretName,
+				"client.snapshotAgent.configSecret.secretKey":  snapshotConfigSecretKey,
+
+				"global.tls.enabled":           strconv.FormatBool(c.secure),
+				"global.tls.enableAutoEncrypt": strconv.FormatBool(c.autoEncrypt),
+				"global.acls.manageSystemACLs": strconv.FormatBool(c.secure),
+			}
+
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName, consul.WithPreInstall(createConfigSecret))
+			consulCluster.Create(t)
+
+			// Create will wait for all pods in the release, including the snapshot agent,
+			// to become ready, so we only need to wait for the first snapshot to be saved.
+			snapshotAgentDeployment := fmt.Sprintf("deploy/%s-consul-snapshot-agent", releaseName)
+			logger.Log(t, "checking that the snapshot agent has saved a snapshot")
+			helpers.RetryEventually(t, 2*time.Minute, func(r *retry.R) {
+				files, err := k8s.RunKubectlAndGetOutputE(t, ctx.KubectlOptions(t), "exec", snapshotAgentDeployment, "-c", "consul-snapshot-agent", "--", "ls", "/tmp")
+				require.NoError(r, err)
+				require.True(r, strings.Contains(files, ".snap"), "no snapshots found in /tmp: %s", files)
+			})
+		})
+	}
+}
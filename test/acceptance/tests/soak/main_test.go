@@ -0,0 +1,22 @@
+package soak
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	testsuite "github.com/hashicorp/consul-helm/test/acceptance/framework/suite"
+)
+
+var suite testsuite.Suite
+
+func TestMain(m *testing.M) {
+	suite = testsuite.NewSuite(m)
+
+	if suite.Config().SoakDuration > 0 {
+		os.Exit(suite.Run())
+	} else {
+		fmt.Println("Skipping soak tests because -soak-duration is not set")
+		os.Exit(0)
+	}
+}
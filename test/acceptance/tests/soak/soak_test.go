@@ -0,0 +1,156 @@
+package soak
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	staticClientName = "static-client"
+
+	// loadWindow is the length of the windows of time
+	// that the error rate of the load is reported for.
+	loadWindow = 1 * time.Minute
+
+	// settlePeriod is how long the test waits after each restart
+	// before restarting the next component, so that the load
+	// also runs against a cluster that isn't being restarted.
+	settlePeriod = 1 * time.Minute
+)
+
+// Test that a constant load of requests between services in the mesh,
+// both directly between sidecars and through an ingress gateway, keeps
+// succeeding for -soak-duration while the servers, clients and gateways
+// are restarted one after another, over and over. The error rate of each
+// window of time is logged so that a run that fails can be matched up
+// with the restarts, and the test fails if the error rate over the whole
+// run is higher than -soak-max-error-rate.
+func TestSoak(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	if deadline, ok := t.Deadline(); ok && time.Until(deadline) < cfg.SoakDuration {
+		t.Fatalf("the test timeout ends in %s, which is shorter than -soak-duration %s; set a longer -timeout",
+			time.Until(deadline).Round(time.Second), cfg.SoakDuration)
+	}
+
+	helmValues := map[string]string{
+		"server.replicas":        "3",
+		"server.bootstrapExpect": "3",
+		// Allow the servers to be scheduled on the same
+		// node so that the test can run on a single node.
+		"server.affinity": "null",
+
+		"connectInject.enabled": "true",
+
+		"ingressGateways.enabled":              "true",
+		"ingressGateways.gateways[0].name":     "ingress-gateway",
+		"ingressGateways.gateways[0].replicas": "1",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	logger.Log(t, "creating static-server and static-client deployments")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+	consulClient := consulCluster.SetupConsulClient(t, false)
+
+	logger.Log(t, "creating ingress-gateway config entry with a TCP listener for static-server")
+	created, _, err := consulClient.ConfigEntries().Set(&api.IngressGatewayConfigEntry{
+		Kind: api.IngressGateway,
+		Name: "ingress-gateway",
+		Listeners: []api.IngressListener{
+			{
+				Port:     8080,
+				Protocol: "tcp",
+				Services: []api.IngressService{{Name: "static-server"}},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+	require.True(t, created, "config entry failed")
+
+	// The client's sidecar only handles traffic to its upstreams, so
+	// requests to the gateway's Kubernetes service go to the gateway directly.
+	loads := map[string]k8s.HTTPRequest{
+		"sidecar":         {URL: "http://localhost:1234"},
+		"ingress gateway": {URL: fmt.Sprintf("http://%s-consul-ingress-gateway:8080", releaseName)},
+	}
+	for name, req := range loads {
+		logger.Logf(t, "checking that requests through the %s succeed", name)
+		k8s.CheckHTTP(t, ctx.KubectlOptions(t), staticClientName, req, k8s.HTTPExpectation{})
+	}
+
+	// If the test fails while restarting a component, the load
+	// has to be stopped before the test finishes as well.
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var wg sync.WaitGroup
+	stopLoad := func() {
+		stopOnce.Do(func() { close(stop) })
+		wg.Wait()
+	}
+	t.Cleanup(stopLoad)
+
+	reports := make(map[string]chan k8s.LoadReport)
+	for name, req := range loads {
+		req.MaxTime = 2 * time.Second
+		reports[name] = make(chan k8s.LoadReport, 1)
+		wg.Add(1)
+		go func(report chan<- k8s.LoadReport, req k8s.HTTPRequest) {
+			defer wg.Done()
+			report <- k8s.GenerateLoad(t, ctx.KubectlOptions(t), staticClientName, req, loadWindow, stop)
+		}(reports[name], req)
+	}
+
+	restarts := []struct {
+		name    string
+		restart func()
+	}{
+		{"servers", func() { k8s.RestartStatefulSet(t, ctx.KubectlOptions(t), releaseName+"-consul-server") }},
+		{"clients", func() { k8s.RestartDaemonSet(t, ctx.KubectlOptions(t), releaseName+"-consul") }},
+		{"ingress gateway", func() { k8s.RestartDeployment(t, ctx.KubectlOptions(t), releaseName+"-consul-ingress-gateway") }},
+	}
+
+	logger.Logf(t, "restarting Consul's components under load for %s", cfg.SoakDuration)
+	end := time.Now().Add(cfg.SoakDuration)
+	for round := 1; time.Now().Before(end); round++ {
+		for _, r := range restarts {
+			if !time.Now().Before(end) {
+				break
+			}
+			logger.Logf(t, "round %d: restarting the %s", round, r.name)
+			r.restart()
+			time.Sleep(settlePeriod)
+		}
+	}
+	stopLoad()
+
+	for name := range loads {
+		report := <-reports[name]
+		for _, w := range report.Windows {
+			logger.Logf(t, "%s load from %s: %d requests, %d failed, error rate %.2f%%",
+				name, w.Start.Format(time.RFC3339), w.Requests, w.Failures, 100*w.ErrorRate())
+		}
+		total := report.Total()
+		logger.Logf(t, "%s load in total: %d requests, %d failed, error rate %.2f%%",
+			name, total.Requests, total.Failures, 100*total.ErrorRate())
+
+		require.NotZero(t, total.Requests, "no requests were made through the %s", name)
+		require.LessOrEqual(t, total.ErrorRate(), cfg.SoakMaxErrorRate,
+			"the error rate of requests through the %s is higher than -soak-max-error-rate", name)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"testing"
 	"time"
 
-	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
 	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
@@ -15,7 +14,9 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-const staticServerNamespace = "sync"
+// destinationNamespace is the Consul namespace that services are synced to
+// when Kubernetes namespaces aren't mirrored.
+const destinationNamespace = "sync"
 const staticServerService = "static-server"
 
 // Test that sync catalog can sync services to consul namespaces,
@@ -37,76 +38,65 @@ func TestSyncCatalogNamespaces(t *testing.T) {
 	}{
 		{
 			"single destination namespace (non-default)",
-			staticServerNamespace,
+			destinationNamespace,
 			false,
 			false,
 		},
 		{
 			"single destination namespace (non-default); secure",
-			staticServerNamespace,
+			destinationNamespace,
 			false,
 			true,
 		},
 		{
 			"mirror k8s namespaces",
-			staticServerNamespace,
+			destinationNamespace,
 			true,
 			false,
 		},
 		{
 			"mirror k8s namespaces; secure",
-			staticServerNamespace,
+			destinationNamespace,
 			true,
 			true,
 		},
 	}
 
+	testSuite := suite.TestSuite(t)
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-
-			ctx := suite.Environment().DefaultContext(t)
-
 			helmValues := map[string]string{
 				"global.enableConsulNamespaces": "true",
 				"syncCatalog.enabled":           "true",
 				// When mirroringK8S is set, this setting is ignored.
-				"syncCatalog.consulNamespaces.consulDestinationNamespace": c.destinationNamespace,
+				"syncCatalog.consulNamespaces.consulDestinationNamespace": cfg.ConsulNamespace(c.destinationNamespace),
 				"syncCatalog.consulNamespaces.mirroringK8S":               strconv.FormatBool(c.mirrorK8S),
+				"syncCatalog.consulNamespaces.mirroringK8SPrefix":         cfg.ConsulNamespacePrefix,
 				"syncCatalog.addK8SNamespaceSuffix":                       "false",
 
 				"global.acls.manageSystemACLs": strconv.FormatBool(c.secure),
 				"global.tls.enabled":           strconv.FormatBool(c.secure),
 			}
 
-			releaseName := helpers.RandomName()
-			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+			consulCluster, ctx := testSuite.HelmCluster(t, suite.Environment().DefaultContext(t), helmValues)
 
-			consulCluster.Create(t)
-
-			staticServerOpts := &terratestk8s.KubectlOptions{
-				ContextName: ctx.KubectlOptions(t).ContextName,
-				ConfigPath:  ctx.KubectlOptions(t).ConfigPath,
-				Namespace:   staticServerNamespace,
-			}
-
-			logger.Logf(t, "creating namespace %s", staticServerNamespace)
-			k8s.RunKubectl(t, ctx.KubectlOptions(t), "create", "ns", staticServerNamespace)
-			helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "ns", staticServerNamespace)
-			})
+			staticServerOpts := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
 
 			logger.Log(t, "creating a static-server with a service")
-			k8s.DeployKustomize(t, staticServerOpts, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/bases/static-server")
+			k8s.DeployKustomize(t, staticServerOpts, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/bases/static-server")
 
 			consulClient := consulCluster.SetupConsulClient(t, c.secure)
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				consul.CleanupNamespaces(t, consulClient, cfg.ConsulNamespacePrefix)
+			})
 
 			logger.Log(t, "checking that the service has been synced to Consul")
 			var services map[string][]string
 			counter := &retry.Counter{Count: 10, Wait: 5 * time.Second}
 
-			consulNamespace := c.destinationNamespace
+			consulNamespace := cfg.ConsulNamespace(c.destinationNamespace)
 			if c.mirrorK8S {
-				consulNamespace = staticServerNamespace
+				consulNamespace = cfg.ConsulNamespace(staticServerOpts.Namespace)
 			}
 
 			retry.RunWith(counter, t, func(r *retry.R) {
@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// syncQuietPeriod is how long a disabled sync direction is watched
+// after the enabled direction has synced, to check that it does nothing.
+const syncQuietPeriod = 30 * time.Second
+
+// Test that sync catalog only syncs in the enabled direction when
+// one of syncCatalog.toConsul and syncCatalog.toK8S is false.
+// Each case waits for a service to be synced in the enabled direction,
+// which shows that the sync is running and has caught up, and then checks
+// that a service on the other side is never synced in the disabled direction.
+func TestSyncCatalog_OneWay(t *testing.T) {
+	cases := []struct {
+		name     string
+		toConsul bool
+		toK8S    bool
+	}{
+		{"to Consul only", true, false},
+		{"to Kubernetes only", false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"syncCatalog.enabled":   "true",
+				"syncCatalog.toConsul":  strconv.FormatBool(c.toConsul),
+				"syncCatalog.toK8S":     strconv.FormatBool(c.toK8S),
+				"syncCatalog.k8sPrefix": k8sPrefix,
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+
+			namespace := ctx.KubectlOptions(t).Namespace
+			consulClient := consulCluster.SetupConsulClient(t, false)
+
+			logger.Log(t, "creating a static-server with a service")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/bases/static-server")
+
+			logger.Log(t, "registering service web in Consul")
+			_, err := consulClient.Catalog().Register(&api.CatalogRegistration{
+				Node:     consulOnlyNode,
+				Address:  "127.0.0.1",
+				NodeMeta: map[string]string{"external-node": "true"},
+				Service:  &api.AgentService{ID: "web", Service: "web", Port: 8080},
+			}, nil)
+			require.NoError(t, err)
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				consulClient.Catalog().Deregister(&api.CatalogDeregistration{Node: consulOnlyNode}, nil)
+				k8s.RunKubectlAndGetOutputE(t, ctx.KubectlOptions(t), "delete", "service", k8sPrefix+"web", "--ignore-not-found")
+			})
+
+			// Each of these returns true if the service on the
+			// other side has been synced in its direction.
+			syncedToConsul := func() (bool, error) {
+				syncedServiceName := fmt.Sprintf("%s-%s", staticServerService, namespace)
+				instances, _, err := consulClient.Catalog().Service(syncedServiceName, "", nil)
+				return len(instances) > 0, err
+			}
+			syncedToK8s := func() (bool, error) {
+				svc, err := k8s.GetServiceE(t, ctx.KubectlOptions(t), k8sPrefix+"web")
+				if errors.IsNotFound(err) {
+					return false, nil
+				}
+				if err != nil {
+					return false, err
+				}
+				return svc.Spec.Type == corev1.ServiceTypeExternalName, nil
+			}
+
+			enabled, disabled := syncedToConsul, syncedToK8s
+			enabledDirection, disabledDirection := "Consul", "Kubernetes"
+			if c.toK8S {
+				enabled, disabled = syncedToK8s, syncedToConsul
+				enabledDirection, disabledDirection = "Kubernetes", "Consul"
+			}
+
+			logger.Logf(t, "checking that a service is synced to %s", enabledDirection)
+			helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+				synced, err := enabled()
+				require.NoError(r, err)
+				require.True(r, synced, "the service has not been synced to %s", enabledDirection)
+			})
+
+			logger.Logf(t, "checking that no service is synced to %s for %s", disabledDirection, syncQuietPeriod)
+			for deadline := time.Now().Add(syncQuietPeriod); time.Now().Before(deadline); time.Sleep(5 * time.Second) {
+				synced, err := disabled()
+				require.NoError(t, err)
+				require.False(t, synced, "a service has been synced to %s even though the sync to it is disabled", disabledDirection)
+			}
+		})
+	}
+}
@@ -61,15 +61,14 @@ func TestSyncCatalog(t *testing.T) {
 			consulCluster.Create(t)
 
 			logger.Log(t, "creating a static-server with a service")
-			k8s.DeployKustomize(t, ctx.KubectlOptions(t), suite.Config().NoCleanupOnFailure, suite.Config().DebugDirectory, "../fixtures/bases/static-server")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), suite.Config().NoCleanupOnFailure, suite.Config().NoCleanup, suite.Config().DebugDirectory, "../fixtures/bases/static-server")
 
 			consulClient := consulCluster.SetupConsulClient(t, c.secure)
 
 			logger.Log(t, "checking that the service has been synced to Consul")
 			var services map[string][]string
 			syncedServiceName := fmt.Sprintf("static-server-%s", ctx.KubectlOptions(t).Namespace)
-			counter := &retry.Counter{Count: 10, Wait: 5 * time.Second}
-			retry.RunWith(counter, t, func(r *retry.R) {
+			helpers.RetryEventually(t, 1*time.Minute, func(r *retry.R) {
 				var err error
 				services, _, err = consulClient.Catalog().Services(nil)
 				require.NoError(r, err)
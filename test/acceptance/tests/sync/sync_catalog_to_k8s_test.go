@@ -0,0 +1,190 @@
+package sync
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/timeouts"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// k8sPrefix is prepended to the names of the Kubernetes services
+// that the sync creates for Consul services.
+const k8sPrefix = "consul-"
+
+// consulOnlyNode is the node that services only in Consul are registered on.
+const consulOnlyNode = "consul-only-node"
+
+// Test that sync catalog syncs services registered in Consul to
+// Kubernetes ExternalName services in the release namespace and deletes
+// them when the services are deregistered. Because it also syncs a NodePort
+// service to Consul, this checks that the sync doesn't sync services back
+// to the side they came from when it runs in both directions.
+func TestSyncCatalog_ConsulToK8s(t *testing.T) {
+	cases := []struct {
+		secure      bool
+		autoEncrypt bool
+	}{
+		{false, false},
+		{true, false},
+		{true, true},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("secure: %t; auto-encrypt: %t", c.secure, c.autoEncrypt)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"syncCatalog.enabled":          "true",
+				"syncCatalog.toConsul":         "true",
+				"syncCatalog.toK8S":            "true",
+				"syncCatalog.k8sPrefix":        k8sPrefix,
+				"syncCatalog.nodePortSyncType": "InternalOnly",
+
+				"global.tls.enabled":           strconv.FormatBool(c.secure),
+				"global.tls.enableAutoEncrypt": strconv.FormatBool(c.autoEncrypt),
+				"global.acls.manageSystemACLs": strconv.FormatBool(c.secure),
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+
+			namespace := ctx.KubectlOptions(t).Namespace
+			k8sClient := ctx.KubernetesClient(t)
+			consulClient := consulCluster.SetupConsulClient(t, c.secure)
+
+			logger.Log(t, "creating a static-server with a NodePort service")
+			k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-nodeport")
+
+			logger.Log(t, "checking that the NodePort service has been synced to Consul with node addresses")
+			staticServer, err := k8sClient.CoreV1().Services(namespace).Get(helpers.TestContext(t), staticServerService, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Len(t, staticServer.Spec.Ports, 1)
+			nodePort := int(staticServer.Spec.Ports[0].NodePort)
+			internalIPs := nodeInternalIPs(t, k8sClient)
+			syncedServiceName := fmt.Sprintf("%s-%s", staticServerService, namespace)
+			helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+				instances, _, err := consulClient.Catalog().Service(syncedServiceName, "", nil)
+				require.NoError(r, err)
+				require.NotEmpty(r, instances, "service %s has not been synced to Consul", syncedServiceName)
+				for _, instance := range instances {
+					require.Contains(r, internalIPs, instance.ServiceAddress)
+					require.Equal(r, nodePort, instance.ServicePort)
+					require.Contains(r, instance.ServiceTags, "k8s")
+				}
+			})
+
+			// Services are registered on a node that has no agent
+			// so that anti-entropy doesn't deregister them.
+			services := []*api.AgentService{
+				{
+					ID:      "web",
+					Service: "web",
+					Tags:    []string{"v1", "primary"},
+					Meta:    map[string]string{"version": "1"},
+					Port:    8080,
+				},
+				{
+					ID:      "api",
+					Service: "api",
+					Tags:    []string{"v2"},
+					Meta:    map[string]string{"team": "payments"},
+					Port:    9090,
+				},
+				// Services with the k8s tag are treated as synced from Kubernetes,
+				// so the sync must not create Kubernetes services for them.
+				{
+					ID:      "legacy",
+					Service: "legacy",
+					Tags:    []string{"k8s"},
+					Port:    7070,
+				},
+			}
+			// Register the cleanup first so that it also runs if a registration fails.
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+				// Deregister the services so that the sync doesn't recreate them, and
+				// delete the ExternalName services because the sync doesn't delete
+				// the ones it created when it's uninstalled.
+				consulClient.Catalog().Deregister(&api.CatalogDeregistration{Node: consulOnlyNode}, nil)
+				for _, service := range services {
+					err := k8sClient.CoreV1().Services(namespace).Delete(helpers.TestContext(t), k8sPrefix+service.Service, metav1.DeleteOptions{})
+					if err != nil && !errors.IsNotFound(err) {
+						logger.Logf(t, "failed to delete service %s: %s", k8sPrefix+service.Service, err)
+					}
+				}
+			})
+			for _, service := range services {
+				logger.Logf(t, "registering service %s in Consul", service.Service)
+				_, err := consulClient.Catalog().Register(&api.CatalogRegistration{
+					Node:     consulOnlyNode,
+					Address:  "127.0.0.1",
+					NodeMeta: map[string]string{"external-node": "true"},
+					Service:  service,
+				}, nil)
+				require.NoError(t, err)
+			}
+
+			logger.Log(t, "checking that the Consul services have been synced to Kubernetes")
+			helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+				for _, name := range []string{"web", "api"} {
+					svc, err := k8sClient.CoreV1().Services(namespace).Get(helpers.TestContext(t), k8sPrefix+name, metav1.GetOptions{})
+					require.NoError(r, err)
+					require.Equal(r, corev1.ServiceTypeExternalName, svc.Spec.Type)
+					require.Equal(r, fmt.Sprintf("%s.service.consul", name), svc.Spec.ExternalName)
+				}
+			})
+
+			logger.Log(t, "checking that services synced from Kubernetes are not synced back")
+			for _, name := range []string{"legacy", syncedServiceName} {
+				_, err := k8sClient.CoreV1().Services(namespace).Get(helpers.TestContext(t), k8sPrefix+name, metav1.GetOptions{})
+				require.True(t, errors.IsNotFound(err), "service %s should not have been synced to Kubernetes", name)
+			}
+
+			logger.Log(t, "deregistering service web from Consul")
+			_, err = consulClient.Catalog().Deregister(&api.CatalogDeregistration{Node: consulOnlyNode, ServiceID: "web"}, nil)
+			require.NoError(t, err)
+
+			logger.Log(t, "checking that the Kubernetes service for web has been deleted")
+			helpers.RetryEventually(t, timeouts.ControllerSync(), func(r *retry.R) {
+				_, err := k8sClient.CoreV1().Services(namespace).Get(helpers.TestContext(t), k8sPrefix+"web", metav1.GetOptions{})
+				require.True(r, errors.IsNotFound(err), "service %s has not been deleted", k8sPrefix+"web")
+			})
+			_, err = k8sClient.CoreV1().Services(namespace).Get(helpers.TestContext(t), k8sPrefix+"api", metav1.GetOptions{})
+			require.NoError(t, err, "service %s should not have been deleted", k8sPrefix+"api")
+		})
+	}
+}
+
+// nodeInternalIPs returns the internal IP addresses of the cluster's nodes,
+// which the sync registers NodePort services with when nodePortSyncType is InternalOnly.
+func nodeInternalIPs(t *testing.T, client kubernetes.Interface) []string {
+	t.Helper()
+
+	nodes, err := client.CoreV1().Nodes().List(helpers.TestContext(t), metav1.ListOptions{})
+	require.NoError(t, err)
+	var ips []string
+	for _, node := range nodes.Items {
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP {
+				ips = append(ips, address.Address)
+			}
+		}
+	}
+	require.NotEmpty(t, ips, "no nodes have internal IPs")
+	return ips
+}
@@ -80,7 +80,7 @@ func TestTerminatingGatewaySingleNamespace(t *testing.T) {
 
 			logger.Logf(t, "creating Kubernetes namespace %s", testNamespace)
 			k8s.RunKubectl(t, ctx.KubectlOptions(t), "create", "ns", testNamespace)
-			helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
 				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "ns", testNamespace)
 			})
 
@@ -92,7 +92,7 @@ func TestTerminatingGatewaySingleNamespace(t *testing.T) {
 
 			// Deploy a static-server that will play the role of an external service.
 			logger.Log(t, "creating static-server deployment")
-			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/bases/static-server")
+			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/bases/static-server")
 
 			// Register the external service.
 			registerExternalService(t, consulClient, testNamespace)
@@ -109,7 +109,7 @@ func TestTerminatingGatewaySingleNamespace(t *testing.T) {
 
 			// Deploy the static client.
 			logger.Log(t, "deploying static client")
-			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-client-namespaces")
+			k8s.DeployKustomize(t, nsK8SOptions, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-namespaces")
 
 			// If ACLs are enabled, test that intentions prevent connections.
 			if c.secure {
@@ -147,13 +147,10 @@ func TestTerminatingGatewayNamespaceMirroring(t *testing.T) {
 			true,
 		},
 	}
+	testSuite := suite.TestSuite(t)
 	for _, c := range cases {
 		name := fmt.Sprintf("secure: %t", c.secure)
 		t.Run(name, func(t *testing.T) {
-			ctx := suite.Environment().DefaultContext(t)
-
-			// Install the Helm chart without the terminating gateway first
-			// so that we can create the namespace for it.
 			helmValues := map[string]string{
 				"connectInject.enabled":                       "true",
 				"connectInject.consulNamespaces.mirroringK8S": "true",
@@ -167,40 +164,20 @@ func TestTerminatingGatewayNamespaceMirroring(t *testing.T) {
 				"terminatingGateways.gateways[0].replicas": "1",
 			}
 
-			releaseName := helpers.RandomName()
-			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
-
-			consulCluster.Create(t)
+			consulCluster, ctx := testSuite.HelmCluster(t, suite.Environment().DefaultContext(t), helmValues)
 
 			consulClient := consulCluster.SetupConsulClient(t, c.secure)
 
-			logger.Logf(t, "creating Kubernetes namespace %s", testNamespace)
-			k8s.RunKubectl(t, ctx.KubectlOptions(t), "create", "ns", testNamespace)
-			helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "ns", testNamespace)
-			})
-
-			staticClientNamespace := "ns2"
-			logger.Logf(t, "creating Kubernetes namespace %s", staticClientNamespace)
-			k8s.RunKubectl(t, ctx.KubectlOptions(t), "create", "ns", staticClientNamespace)
-			helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
-				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "ns", staticClientNamespace)
-			})
-
-			ns1K8SOptions := &terratestk8s.KubectlOptions{
-				ContextName: ctx.KubectlOptions(t).ContextName,
-				ConfigPath:  ctx.KubectlOptions(t).ConfigPath,
-				Namespace:   testNamespace,
-			}
-			ns2K8SOptions := &terratestk8s.KubectlOptions{
-				ContextName: ctx.KubectlOptions(t).ContextName,
-				ConfigPath:  ctx.KubectlOptions(t).ConfigPath,
-				Namespace:   staticClientNamespace,
-			}
+			// The external service is registered in the Consul namespace
+			// with the same name as the static-server's Kubernetes namespace.
+			ns1K8SOptions := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+			ns2K8SOptions := helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+			testNamespace := ns1K8SOptions.Namespace
+			staticClientNamespace := ns2K8SOptions.Namespace
 
 			// Deploy a static-server that will play the role of an external service.
 			logger.Log(t, "creating static-server deployment")
-			k8s.DeployKustomize(t, ns1K8SOptions, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/bases/static-server")
+			k8s.DeployKustomize(t, ns1K8SOptions, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/bases/static-server")
 
 			// Register the external service
 			registerExternalService(t, consulClient, testNamespace)
@@ -217,7 +194,9 @@ func TestTerminatingGatewayNamespaceMirroring(t *testing.T) {
 
 			// Deploy the static client
 			logger.Log(t, "deploying static client")
-			k8s.DeployKustomize(t, ns2K8SOptions, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-client-namespaces")
+			k8s.DeployTemplate(t, ns2K8SOptions, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/templates/static-client-upstream-namespace.yaml", struct {
+				ServerNamespace string
+			}{testNamespace})
 
 			// If ACLs are enabled, test that intentions prevent connections.
 			if c.secure {
@@ -0,0 +1,201 @@
+package ui
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Test that the UI service is created with the configured type
+// and that the UI is served through it.
+func TestUI(t *testing.T) {
+	cases := []struct {
+		serviceType  string
+		expectedType corev1.ServiceType
+	}{
+		{"", corev1.ServiceTypeClusterIP},
+		{"NodePort", corev1.ServiceTypeNodePort},
+		{"LoadBalancer", corev1.ServiceTypeLoadBalancer},
+	}
+
+	for _, c := range cases {
+		name := fmt.Sprintf("service type: %q", c.serviceType)
+		t.Run(name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"ui.enabled": "true",
+			}
+			if c.serviceType != "" {
+				helmValues["ui.service.type"] = c.serviceType
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+			consulCluster.Create(t)
+
+			service := uiService(t, ctx, releaseName)
+			require.Equal(t, c.expectedType, service.Spec.Type)
+
+			endpoint := k8s.PortForward(t, ctx.KubectlOptions(t), terratestk8s.ResourceTypeService, service.Name, 80)
+			requireUIServed(t, &http.Client{Timeout: 10 * time.Second}, "http://"+endpoint)
+		})
+	}
+}
+
+// Test that when TLS is enabled, the UI is only served over HTTPS
+// with a certificate signed by the CA generated by the chart.
+func TestUI_TLS(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"ui.enabled":         "true",
+		"global.tls.enabled": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	service := uiService(t, ctx, releaseName)
+	require.Len(t, service.Spec.Ports, 1, "expected only the HTTPS port because global.tls.httpsOnly defaults to true")
+	require.Equal(t, "https", service.Spec.Ports[0].Name)
+
+	caSecret, err := ctx.KubernetesClient(t).CoreV1().Secrets(ctx.KubectlOptions(t).Namespace).Get(helpers.TestContext(t), releaseName+"-consul-ca-cert", metav1.GetOptions{})
+	require.NoError(t, err)
+	rootCAs := x509.NewCertPool()
+	require.True(t, rootCAs.AppendCertsFromPEM(caSecret.Data[corev1.TLSCertKey]), "could not parse the CA certificate")
+
+	endpoint := k8s.PortForward(t, ctx.KubectlOptions(t), terratestk8s.ResourceTypeService, service.Name, 443)
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs: rootCAs,
+				// Server certificates generated by the chart
+				// always include this name as a DNS SAN.
+				ServerName: "server.dc1.consul",
+			},
+		},
+	}
+	requireUIServed(t, httpClient, "https://"+endpoint)
+}
+
+// Test that the UI service can be exposed through an ingress,
+// i.e. that an ingress backend referencing the service's "http" port
+// by name reaches the UI. The test clusters don't run an ingress
+// controller, so the backend is resolved from the ingress and reached
+// through a port-forward instead of through the controller.
+func TestUI_Ingress(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"ui.enabled": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+
+	consulCluster.Create(t)
+
+	ingresses := ctx.KubernetesClient(t).NetworkingV1beta1().Ingresses(ctx.KubectlOptions(t).Namespace)
+	ingress := &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: releaseName + "-consul-ui",
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host: "consul-ui.example.com",
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+							Paths: []networkingv1beta1.HTTPIngressPath{
+								{
+									Path: "/",
+									Backend: networkingv1beta1.IngressBackend{
+										ServiceName: releaseName + "-consul-ui",
+										ServicePort: intstr.FromString("http"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	_, err := ingresses.Create(helpers.TestContext(t), ingress, metav1.CreateOptions{})
+	require.NoError(t, err)
+	helpers.Cleanup(t, cfg.NoCleanupOnFailure, cfg.NoCleanup, func() {
+		err := ingresses.Delete(helpers.TestContext(t), ingress.Name, metav1.DeleteOptions{})
+		if !errors.IsNotFound(err) {
+			require.NoError(t, err)
+		}
+	})
+
+	ingress, err = ingresses.Get(helpers.TestContext(t), ingress.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	backend := ingress.Spec.Rules[0].HTTP.Paths[0].Backend
+
+	service := uiService(t, ctx, releaseName)
+	require.Equal(t, service.Name, backend.ServiceName)
+	var port int
+	for _, p := range service.Spec.Ports {
+		if p.Name == backend.ServicePort.StrVal {
+			port = int(p.Port)
+		}
+	}
+	require.NotZero(t, port, "UI service has no port named %q", backend.ServicePort.StrVal)
+
+	endpoint := k8s.PortForward(t, ctx.KubectlOptions(t), terratestk8s.ResourceTypeService, backend.ServiceName, port)
+	requireUIServed(t, &http.Client{Timeout: 10 * time.Second}, "http://"+endpoint)
+}
+
+// uiService returns the UI service of the release.
+func uiService(t *testing.T, ctx environment.TestContext, releaseName string) *corev1.Service {
+	t.Helper()
+
+	service, err := ctx.KubernetesClient(t).CoreV1().Services(ctx.KubectlOptions(t).Namespace).Get(helpers.TestContext(t), releaseName+"-consul-ui", metav1.GetOptions{})
+	require.NoError(t, err)
+	return service
+}
+
+// requireUIServed checks that the Consul UI is served at baseURL.
+func requireUIServed(t *testing.T, httpClient *http.Client, baseURL string) {
+	t.Helper()
+
+	logger.Logf(t, "checking that the UI is served at %s", baseURL)
+	retry.Run(t, func(r *retry.R) {
+		resp, err := httpClient.Get(baseURL + "/ui/")
+		require.NoError(r, err)
+		defer resp.Body.Close()
+
+		require.Equal(r, http.StatusOK, resp.StatusCode)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(r, err)
+		require.Contains(r, string(body), "consul-ui")
+	})
+}
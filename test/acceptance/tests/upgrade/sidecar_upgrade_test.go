@@ -0,0 +1,85 @@
+package upgrade
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/images"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/resources"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// previousEnvoyImage is an older Envoy image that is
+	// still supported by the Consul version of the chart.
+	previousEnvoyImage = "envoyproxy/envoy-alpine:v1.15.2"
+	currentEnvoyImage  = "envoyproxy/envoy-alpine:v1.16.0"
+)
+
+// Test that upgrading the release to a new Envoy image only changes
+// the sidecars of pods that are created after the upgrade, and that
+// connections keep working between pods with the old and new sidecars
+// while the applications are restarted one at a time.
+func TestUpgrade_SidecarImage(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	helmValues := map[string]string{
+		"connectInject.enabled": "true",
+		"global.imageEnvoy":     previousEnvoyImage,
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+	consulCluster.Create(t)
+
+	logger.Log(t, "creating static-server and static-client deployments")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+	requireSidecarImage(t, ctx, cfg.TestImageRegistry, staticServerName, previousEnvoyImage)
+	requireSidecarImage(t, ctx, cfg.TestImageRegistry, staticClientName, previousEnvoyImage)
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+	logger.Logf(t, "upgrading the release to Envoy image %s", currentEnvoyImage)
+	consulCluster.Upgrade(t, map[string]string{"global.imageEnvoy": currentEnvoyImage})
+
+	logger.Log(t, "checking that pods that weren't restarted keep their sidecars and connections")
+	requireSidecarImage(t, ctx, cfg.TestImageRegistry, staticServerName, previousEnvoyImage)
+	requireSidecarImage(t, ctx, cfg.TestImageRegistry, staticClientName, previousEnvoyImage)
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+	k8s.RestartDeployment(t, ctx.KubectlOptions(t), staticClientName)
+
+	logger.Log(t, "checking that the restarted client can connect to the server that wasn't restarted")
+	requireSidecarImage(t, ctx, cfg.TestImageRegistry, staticClientName, currentEnvoyImage)
+	requireSidecarImage(t, ctx, cfg.TestImageRegistry, staticServerName, previousEnvoyImage)
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+	k8s.RestartDeployment(t, ctx.KubectlOptions(t), staticServerName)
+
+	logger.Log(t, "checking that the restarted client can connect to the restarted server")
+	requireSidecarImage(t, ctx, cfg.TestImageRegistry, staticServerName, currentEnvoyImage)
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+}
+
+// requireSidecarImage checks that all running pods of the deployment
+// have an Envoy sidecar with image, as pulled from registry, which is set by -test-image-registry.
+func requireSidecarImage(t *testing.T, ctx environment.TestContext, registry, deploymentName, image string) {
+	t.Helper()
+
+	pods := k8s.GetPods(t, ctx.KubectlOptions(t), fmt.Sprintf("app=%s", deploymentName))
+	require.NotEmpty(t, pods, "deployment %s has no pods", deploymentName)
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		require.Equal(t, images.Rewrite(registry, image), k8s.ContainerImage(pod, resources.SidecarContainer),
+			"unexpected sidecar image of pod %s", pod.Name)
+	}
+}
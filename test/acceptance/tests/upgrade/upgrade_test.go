@@ -0,0 +1,171 @@
+package upgrade
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helm"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// previousChartVersion is the release of the chart before the current one.
+	// It needs to be updated every time a new version of the chart is released.
+	previousChartVersion = "0.26.0"
+
+	hashicorpHelmRepoURL = "https://helm.releases.hashicorp.com"
+
+	serverReplicas = 3
+
+	// maxConnectDowntime is the longest period of time connections
+	// between services in the mesh are allowed to fail during the upgrade.
+	maxConnectDowntime = 30 * time.Second
+
+	staticClientName = "static-client"
+	staticServerName = "static-server"
+)
+
+// Test that upgrading a secure installation of the previous release
+// of the chart to the current chart doesn't lose any data, doesn't
+// interrupt Connect traffic for too long, and rolls servers one at a time.
+func TestUpgrade(t *testing.T) {
+	cfg := suite.Config()
+	ctx := suite.Environment().DefaultContext(t)
+
+	repoName := helpers.RandomName()
+	repoOptions := &helm.Options{KubectlOptions: ctx.KubectlOptions(t)}
+	logger.Logf(t, "adding Helm repository %s", hashicorpHelmRepoURL)
+	helm.AddRepo(t, repoOptions, repoName, hashicorpHelmRepoURL)
+	t.Cleanup(func() {
+		helm.RemoveRepoE(t, repoOptions, repoName)
+	})
+
+	helmValues := map[string]string{
+		"server.replicas":        strconv.Itoa(serverReplicas),
+		"server.bootstrapExpect": strconv.Itoa(serverReplicas),
+		// Allow scheduling servers on the same node so that
+		// the test can run against single-node clusters.
+		"server.affinity": "null",
+
+		"connectInject.enabled":        "true",
+		"global.tls.enabled":           "true",
+		"global.acls.manageSystemACLs": "true",
+	}
+
+	releaseName := helpers.RandomName()
+	consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName, consul.WithChart(repoName+"/consul", previousChartVersion))
+	consulCluster.Create(t)
+
+	logger.Log(t, "creating static-server and static-client deployments")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, "../fixtures/cases/static-client-inject")
+
+	consulClient := consulCluster.SetupConsulClient(t, true)
+
+	randomKey := helpers.RandomName()
+	randomValue := []byte(helpers.RandomName())
+	logger.Logf(t, "creating KV entry with key %s", randomKey)
+	_, err := consulClient.KV().Put(&api.KVPair{Key: randomKey, Value: randomValue}, nil)
+	require.NoError(t, err)
+
+	logger.Log(t, "creating intention")
+	_, _, err = consulClient.Connect().IntentionCreate(&api.Intention{
+		SourceName:      staticClientName,
+		DestinationName: staticServerName,
+		Action:          api.IntentionActionAllow,
+	}, nil)
+	require.NoError(t, err)
+
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+
+	// Watch the servers and the connection between services while the upgrade is running.
+	// If the upgrade fails, the watchers have to be stopped before the test finishes as well.
+	watchCtx := helpers.TestContext(t)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var wg sync.WaitGroup
+	stopWatching := func() {
+		stopOnce.Do(func() { close(stop) })
+		wg.Wait()
+	}
+	t.Cleanup(stopWatching)
+
+	var maxUnavailableServers int
+	var maxDowntime time.Duration
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		maxUnavailableServers = watchUnavailableServers(watchCtx, t, ctx.KubernetesClient(t), ctx.KubectlOptions(t).Namespace, consulCluster.ComponentSelector(helpers.ComponentServer), stop)
+	}()
+	go func() {
+		defer wg.Done()
+		maxDowntime = k8s.WatchStaticServerDowntime(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234", stop)
+	}()
+
+	logger.Logf(t, "upgrading from chart version %s to the current chart", previousChartVersion)
+	consulCluster.Upgrade(t, map[string]string{})
+	stopWatching()
+
+	logger.Logf(t, "at most %d servers were unavailable and connections failed for at most %s during the upgrade", maxUnavailableServers, maxDowntime)
+	require.LessOrEqual(t, maxUnavailableServers, 1, "servers were not rolled one at a time")
+	require.LessOrEqual(t, int64(maxDowntime), int64(maxConnectDowntime), "connections failed for longer than %s", maxConnectDowntime)
+
+	logger.Logf(t, "reading value for key %s", randomKey)
+	kv, _, err := consulClient.KV().Get(randomKey, nil)
+	require.NoError(t, err)
+	require.NotNil(t, kv, "key %s not found after upgrade", randomKey)
+	require.Equal(t, randomValue, kv.Value)
+
+	logger.Log(t, "checking that the intention still exists")
+	intentions, _, err := consulClient.Connect().Intentions(nil)
+	require.NoError(t, err)
+	found := false
+	for _, intention := range intentions {
+		if intention.SourceName == staticClientName && intention.DestinationName == staticServerName {
+			found = true
+			require.Equal(t, api.IntentionActionAllow, intention.Action)
+		}
+	}
+	require.True(t, found, "intention %s => %s not found after upgrade", staticClientName, staticServerName)
+
+	k8s.CheckStaticServerConnectionSuccessful(t, ctx.KubectlOptions(t), staticClientName, "http://localhost:1234")
+}
+
+// watchUnavailableServers checks the server pods matching serverSelector every second
+// until stop is closed and returns the largest number of servers that were
+// not ready at the same time.
+func watchUnavailableServers(ctx context.Context, t *testing.T, client kubernetes.Interface, namespace, serverSelector string, stop <-chan struct{}) int {
+	maxUnavailable := 0
+	for {
+		select {
+		case <-stop:
+			return maxUnavailable
+		case <-time.After(1 * time.Second):
+		}
+
+		pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: serverSelector})
+		if err != nil {
+			logger.Logf(t, "failed to list server pods: %s", err)
+			continue
+		}
+		ready := 0
+		for _, pod := range pods.Items {
+			if helpers.IsReady(pod) {
+				ready++
+			}
+		}
+		if unavailable := serverReplicas - ready; unavailable > maxUnavailable {
+			maxUnavailable = unavailable
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package windows
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	testsuite "github.com/hashicorp/consul-helm/test/acceptance/framework/suite"
+)
+
+var suite testsuite.Suite
+
+func TestMain(m *testing.M) {
+	suite = testsuite.NewSuite(m)
+
+	if suite.Config().EnableWindows {
+		os.Exit(suite.Run())
+	} else {
+		fmt.Println("Skipping Windows tests because -enable-windows is not set")
+		os.Exit(0)
+	}
+}
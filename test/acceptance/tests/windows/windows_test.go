@@ -0,0 +1,117 @@
+package windows
+
+import (
+	"fmt"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/consul"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/environment"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-helm/test/acceptance/framework/logger"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const staticServerName = "static-server-windows"
+const staticClientName = "static-client-windows"
+
+// injectStatusAnnotation is the annotation the connect injector
+// sets on the pods that it injects.
+const injectStatusAnnotation = "consul.hashicorp.com/connect-inject-status"
+
+// Test that in a cluster with Windows nodes, Consul runs only on Linux nodes
+// and the connect injector doesn't inject Windows pods when they opt out
+// of injection or are in a namespace where injection is denied,
+// even when connectInject.default injects all other pods.
+// The Windows pods should run and be able to talk to each other.
+func TestWindows_ConnectInjectSkipsWindowsPods(t *testing.T) {
+	cases := []struct {
+		name string
+		// deniedNamespace is true if the Windows pods are deployed
+		// into a namespace in connectInject.k8sDenyNamespaces.
+		deniedNamespace bool
+		serverFixture   string
+		clientFixture   string
+	}{
+		{
+			name:          "opt-out annotation",
+			serverFixture: "../fixtures/cases/static-server-windows-no-inject",
+			clientFixture: "../fixtures/cases/static-client-windows-no-inject",
+		},
+		{
+			// The pods don't opt out, so only the deny list prevents injection.
+			name:            "denied namespace",
+			deniedNamespace: true,
+			serverFixture:   "../fixtures/bases/static-server-windows",
+			clientFixture:   "../fixtures/bases/static-client-windows",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := suite.Config()
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"connectInject.enabled": "true",
+				"connectInject.default": "true",
+			}
+			options := ctx.KubectlOptions(t)
+			if c.deniedNamespace {
+				options = helpers.RandomNamespace(t, ctx, cfg.NoCleanupOnFailure, cfg.NoCleanup)
+				helmValues["connectInject.k8sAllowNamespaces"] = "{*}"
+				helmValues["connectInject.k8sDenyNamespaces"] = fmt.Sprintf("{%s}", options.Namespace)
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+			consulCluster.Create(t)
+
+			logger.Log(t, "checking that Consul clients only run on Linux nodes")
+			requirePodsOnLinuxNodes(t, ctx, consulCluster.ClientPods(t))
+
+			logger.Log(t, "creating Windows static-server and static-client deployments")
+			k8s.DeployKustomize(t, options, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, c.serverFixture)
+			k8s.DeployKustomize(t, options, cfg.NoCleanupOnFailure, cfg.NoCleanup, cfg.DebugDirectory, c.clientFixture)
+
+			logger.Log(t, "checking that the Windows pods are not injected")
+			requireNotInjected(t, ctx, options, "app="+staticServerName)
+			requireNotInjected(t, ctx, options, "app="+staticClientName)
+
+			logger.Log(t, "checking that the Windows static-client can reach the static-server")
+			k8s.CheckHTTP(t, options, staticClientName,
+				k8s.HTTPRequest{URL: "http://" + staticServerName},
+				k8s.HTTPExpectation{StatusCode: 200})
+		})
+	}
+}
+
+// requireNotInjected checks that the pods matching labelSelector
+// in the namespace of options were not injected.
+func requireNotInjected(t *testing.T, ctx environment.TestContext, options *terratestk8s.KubectlOptions, labelSelector string) {
+	t.Helper()
+
+	pods, err := ctx.KubernetesClient(t).CoreV1().Pods(options.Namespace).List(helpers.TestContext(t), metav1.ListOptions{LabelSelector: labelSelector})
+	require.NoError(t, err)
+	require.NotEmpty(t, pods.Items)
+	for _, pod := range pods.Items {
+		require.NotContains(t, pod.Annotations, injectStatusAnnotation, "pod %s/%s was injected", pod.Namespace, pod.Name)
+		require.Len(t, pod.Spec.Containers, 1, "pod %s/%s has sidecars", pod.Namespace, pod.Name)
+		require.Empty(t, pod.Spec.InitContainers, "pod %s/%s has init containers", pod.Namespace, pod.Name)
+	}
+}
+
+// requirePodsOnLinuxNodes checks that pods are scheduled on Linux nodes.
+func requirePodsOnLinuxNodes(t *testing.T, ctx environment.TestContext, pods []corev1.Pod) {
+	t.Helper()
+
+	require.NotEmpty(t, pods)
+	for _, pod := range pods {
+		node, err := ctx.KubernetesClient(t).CoreV1().Nodes().Get(helpers.TestContext(t), pod.Spec.NodeName, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, "linux", node.Labels["kubernetes.io/os"], "pod %s is on node %s", pod.Name, node.Name)
+	}
+}
@@ -498,6 +498,39 @@ load _helpers
   [ "${actual}" = "1000" ]
 }
 
+@test "server/StatefulSet: can set a custom security context with server.securityContext" {
+  cd `chart_dir`
+  local security_context=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'server.securityContext.runAsNonRoot=true' \
+      --set 'server.securityContext.runAsUser=100' \
+      --set 'server.securityContext.runAsGroup=1000' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.securityContext' | tee /dev/stderr)
+
+  local actual=$(echo $security_context | yq -r '.runAsNonRoot' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  actual=$(echo $security_context | yq -r '.runAsUser' | tee /dev/stderr)
+  [ "${actual}" = "100" ]
+
+  actual=$(echo $security_context | yq -r '.runAsGroup' | tee /dev/stderr)
+  [ "${actual}" = "1000" ]
+
+  actual=$(echo $security_context | yq -r '.fsGroup' | tee /dev/stderr)
+  [ "${actual}" = "1000" ]
+}
+
+@test "server/StatefulSet: security context is not set when server.securityContext is null" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'server.securityContext=null' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.securityContext' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
 #--------------------------------------------------------------------
 # gossip encryption
 